@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -55,6 +56,10 @@ func (p *PostgresDB) Close() error {
 // Migrate runs database migrations
 func (p *PostgresDB) Migrate() error {
 	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			name VARCHAR(100) PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL
+		)`,
 		`CREATE TABLE IF NOT EXISTS assets (
 			id UUID PRIMARY KEY,
 			type VARCHAR(50) NOT NULL,
@@ -94,11 +99,194 @@ func (p *PostgresDB) Migrate() error {
 			last_updated TIMESTAMP NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS networth_snapshots (
+			id UUID PRIMARY KEY,
+			date DATE NOT NULL UNIQUE,
+			total_assets DECIMAL(15, 2) NOT NULL,
+			total_debts DECIMAL(15, 2) NOT NULL,
+			net_worth DECIMAL(15, 2) NOT NULL,
+			currency VARCHAR(10) DEFAULT 'USD',
+			assets_by_type JSONB NOT NULL DEFAULT '{}',
+			debts_by_type JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS exchange_rates (
+			base VARCHAR(10) NOT NULL,
+			quote VARCHAR(10) NOT NULL,
+			rate DECIMAL(20, 10) NOT NULL,
+			provider VARCHAR(50) DEFAULT '',
+			last_updated TIMESTAMP NOT NULL,
+			PRIMARY KEY (base, quote)
+		)`,
+		`CREATE TABLE IF NOT EXISTS fx_rates (
+			base VARCHAR(10) NOT NULL,
+			quote VARCHAR(10) NOT NULL,
+			date DATE NOT NULL,
+			rate DECIMAL(20, 10) NOT NULL,
+			provider VARCHAR(50) DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (base, quote, date)
+		)`,
+		`CREATE TABLE IF NOT EXISTS transactions (
+			id UUID PRIMARY KEY,
+			asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+			type VARCHAR(20) NOT NULL,
+			date DATE NOT NULL,
+			quantity NUMERIC NOT NULL DEFAULT 0,
+			price NUMERIC NOT NULL DEFAULT 0,
+			amount NUMERIC NOT NULL DEFAULT 0,
+			fee NUMERIC NOT NULL DEFAULT 0,
+			ratio NUMERIC NOT NULL DEFAULT 0,
+			notes TEXT DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS provider VARCHAR(50) DEFAULT ''`,
+		`ALTER TABLE stock_prices ADD COLUMN IF NOT EXISTS provider VARCHAR(50) DEFAULT ''`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS cost_basis DECIMAL(15, 2)`,
+		`UPDATE assets SET cost_basis = ROUND(buy_price * quantity, 2) WHERE cost_basis IS NULL`,
+		// Assets created before the ledger get an opening buy so their position is derived from it
+		`INSERT INTO transactions (id, asset_id, type, date, quantity, price, notes, created_at, updated_at)
+		SELECT md5(a.id::text || ':opening')::uuid, a.id, 'buy', a.purchase_date, a.quantity, a.buy_price,
+			'Opening position', a.created_at, a.created_at
+		FROM assets a
+		WHERE a.quantity > 0 AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.asset_id = a.id)`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS cost_basis_method VARCHAR(20) DEFAULT 'fifo'`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS realized_gain DECIMAL(15, 2) DEFAULT 0`,
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS lot_id UUID REFERENCES transactions(id) ON DELETE SET NULL`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS income DECIMAL(15, 2)`,
+		`UPDATE assets a SET income = COALESCE((
+			SELECT SUM(t.amount) FROM transactions t
+			WHERE t.asset_id = a.id AND t.type IN ('dividend', 'interest', 'rent')
+		), 0) WHERE a.income IS NULL`,
+		`CREATE TABLE IF NOT EXISTS corporate_actions (
+			id UUID PRIMARY KEY,
+			asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+			type VARCHAR(20) NOT NULL,
+			date DATE NOT NULL,
+			numerator NUMERIC NOT NULL DEFAULT 1,
+			denominator NUMERIC NOT NULL DEFAULT 1,
+			old_symbol VARCHAR(255) DEFAULT '',
+			new_symbol VARCHAR(255) DEFAULT '',
+			source VARCHAR(50) DEFAULT 'manual',
+			transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+			notes TEXT DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(asset_id, type, date)
+		)`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS exchange VARCHAR(50) DEFAULT ''`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS display_name VARCHAR(255) DEFAULT ''`,
+		// Room for symbols such as EURUSD=X and listing suffixes
+		`ALTER TABLE stock_prices ALTER COLUMN symbol TYPE VARCHAR(20)`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS symbol VARCHAR(20) DEFAULT ''`,
+		// Crypto holdings need more than four decimal places (0.00012345 BTC);
+		// like transactions.quantity the column keeps whatever precision is stored
+		`ALTER TABLE assets ALTER COLUMN quantity TYPE NUMERIC`,
+		// Prices and values keep the precision they were computed with: crypto
+		// and fund prices have more than two decimals, and the average cost per
+		// unit is not a whole number of cents
+		`ALTER TABLE assets ALTER COLUMN buy_price TYPE NUMERIC`,
+		`ALTER TABLE assets ALTER COLUMN current_value TYPE NUMERIC`,
+		`ALTER TABLE asset_history ALTER COLUMN value TYPE NUMERIC`,
+		`ALTER TABLE stock_prices ALTER COLUMN price TYPE NUMERIC`,
+		// Prices are cached per provider, so an asset priced by its own
+		// provider is not served another provider's price
+		`UPDATE stock_prices SET provider = '' WHERE provider IS NULL`,
+		`ALTER TABLE stock_prices ALTER COLUMN provider SET NOT NULL`,
+		`ALTER TABLE stock_prices DROP CONSTRAINT IF EXISTS stock_prices_pkey`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_prices_symbol_provider ON stock_prices(symbol, provider)`,
+		// Investment sub-kinds and the terms bonds and CDs are valued from
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS kind VARCHAR(20) DEFAULT ''`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS face_value NUMERIC`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS clean_price NUMERIC`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS interest_rate DECIMAL(7, 4)`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS coupon_frequency INTEGER`,
+		`ALTER TABLE assets ADD COLUMN IF NOT EXISTS maturity_date DATE`,
+		// Loan terms debts are amortized by
+		`ALTER TABLE debts ADD COLUMN IF NOT EXISTS term_months INTEGER`,
+		`ALTER TABLE debts ADD COLUMN IF NOT EXISTS payment_frequency VARCHAR(20) DEFAULT ''`,
+		`ALTER TABLE debts ADD COLUMN IF NOT EXISTS payment_amount DECIMAL(15, 2)`,
+		// Interest accrual: existing balances are taken as owed today, so
+		// interest accrues on them from the upgrade on
+		`ALTER TABLE debts ADD COLUMN IF NOT EXISTS compounding VARCHAR(20) DEFAULT ''`,
+		`ALTER TABLE debts ADD COLUMN IF NOT EXISTS rate_type VARCHAR(10) DEFAULT ''`,
+		`ALTER TABLE debts ADD COLUMN IF NOT EXISTS interest_since DATE`,
+		`ALTER TABLE debts ADD COLUMN IF NOT EXISTS accrued_interest DECIMAL(15, 2)`,
+		`UPDATE debts SET interest_since = GREATEST(start_date, CURRENT_DATE) WHERE interest_since IS NULL`,
+		`CREATE TABLE IF NOT EXISTS debt_payments (
+			id UUID PRIMARY KEY,
+			debt_id UUID NOT NULL REFERENCES debts(id) ON DELETE CASCADE,
+			date DATE NOT NULL,
+			amount DECIMAL(15, 2) NOT NULL,
+			interest DECIMAL(15, 2) NOT NULL DEFAULT 0,
+			principal DECIMAL(15, 2) NOT NULL DEFAULT 0,
+			notes TEXT DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Interest a payment left unpaid, added to the balance it accrued on
+		`ALTER TABLE debt_payments ADD COLUMN IF NOT EXISTS capitalized_interest DECIMAL(15, 2) NOT NULL DEFAULT 0`,
+		// Payments whose interest or principal was given keep that split when
+		// an earlier payment changes; the others are split again
+		`ALTER TABLE debt_payments ADD COLUMN IF NOT EXISTS manual_split BOOLEAN NOT NULL DEFAULT false`,
+		`CREATE TABLE IF NOT EXISTS debt_history (
+			id UUID PRIMARY KEY,
+			debt_id UUID NOT NULL REFERENCES debts(id) ON DELETE CASCADE,
+			balance DECIMAL(15, 2) NOT NULL,
+			date DATE NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(debt_id, date)
+		)`,
+		// Amounts are rounded to their currency's minor unit, which is not
+		// always two decimals (dinars have three), so money columns keep the
+		// scale they are written with
+		`ALTER TABLE assets ALTER COLUMN cost_basis TYPE NUMERIC`,
+		`ALTER TABLE assets ALTER COLUMN realized_gain TYPE NUMERIC`,
+		`ALTER TABLE assets ALTER COLUMN income TYPE NUMERIC`,
+		`ALTER TABLE debts ALTER COLUMN principal TYPE NUMERIC`,
+		`ALTER TABLE debts ALTER COLUMN current_value TYPE NUMERIC`,
+		`ALTER TABLE debts ALTER COLUMN payment_amount TYPE NUMERIC`,
+		`ALTER TABLE debts ALTER COLUMN accrued_interest TYPE NUMERIC`,
+		`ALTER TABLE debt_payments ALTER COLUMN amount TYPE NUMERIC`,
+		`ALTER TABLE debt_payments ALTER COLUMN interest TYPE NUMERIC`,
+		`ALTER TABLE debt_payments ALTER COLUMN principal TYPE NUMERIC`,
+		`ALTER TABLE debt_payments ALTER COLUMN capitalized_interest TYPE NUMERIC`,
+		`ALTER TABLE debt_history ALTER COLUMN balance TYPE NUMERIC`,
+		`ALTER TABLE networth_snapshots ALTER COLUMN total_assets TYPE NUMERIC`,
+		`ALTER TABLE networth_snapshots ALTER COLUMN total_debts TYPE NUMERIC`,
+		`ALTER TABLE networth_snapshots ALTER COLUMN net_worth TYPE NUMERIC`,
+		// Rate schedules: effective-dated changes to a debt's rate, fixed or
+		// following a rate index, and promotional rates with an end date
+		`CREATE TABLE IF NOT EXISTS debt_rates (
+			id UUID PRIMARY KEY,
+			debt_id UUID NOT NULL REFERENCES debts(id) ON DELETE CASCADE,
+			effective_date DATE NOT NULL,
+			end_date DATE,
+			rate DECIMAL(7, 4),
+			rate_index VARCHAR(50) DEFAULT '',
+			margin DECIMAL(7, 4) DEFAULT 0,
+			notes TEXT DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS rate_index_values (
+			name VARCHAR(50) NOT NULL,
+			date DATE NOT NULL,
+			rate DECIMAL(7, 4) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (name, date)
+		)`,
 		`CREATE INDEX IF NOT EXISTS idx_assets_type ON assets(type)`,
+		`CREATE INDEX IF NOT EXISTS idx_assets_symbol ON assets(symbol)`,
 		`CREATE INDEX IF NOT EXISTS idx_asset_history_asset_id ON asset_history(asset_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_asset_history_date ON asset_history(date)`,
 		`CREATE INDEX IF NOT EXISTS idx_debts_type ON debts(type)`,
+		`CREATE INDEX IF NOT EXISTS idx_debt_payments_debt_id ON debt_payments(debt_id, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_debt_history_debt_id ON debt_history(debt_id, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_debt_rates_debt_id ON debt_rates(debt_id, effective_date)`,
 		`CREATE INDEX IF NOT EXISTS idx_stock_prices_symbol ON stock_prices(symbol)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_asset_id ON transactions(asset_id, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_type_date ON transactions(type, date)`,
 	}
 
 	for _, migration := range migrations {
@@ -107,5 +295,56 @@ func (p *PostgresDB) Migrate() error {
 		}
 	}
 
+	// Data migrations rewrite existing rows, so each runs only once
+	dataMigrations := []struct{ name, query string }{
+		// market_api stocks used to be named by their ticker: move it to symbol
+		// and name them after the listing where the lookup stored one
+		{"assets_symbol_from_name", `UPDATE assets
+		SET symbol = TRIM(name), name = COALESCE(NULLIF(display_name, ''), name)
+		WHERE COALESCE(symbol, '') = '' AND type = 'stock' AND source = 'market_api'
+			AND TRIM(name) ~ '^\^?[A-Z0-9]+([.=-][A-Z0-9]+)*$' AND name ~ '[A-Z]' AND LENGTH(TRIM(name)) <= 20`},
+		// Debts created before the history start it with their principal on the
+		// start date and their balance on the day of the upgrade
+		{"debt_history_start", `INSERT INTO debt_history (id, debt_id, balance, date, created_at)
+		SELECT md5(d.id::text || ':start')::uuid, d.id, d.principal, d.start_date, d.created_at
+		FROM debts d
+		WHERE NOT EXISTS (SELECT 1 FROM debt_history h WHERE h.debt_id = d.id)`},
+		{"debt_history_current", `INSERT INTO debt_history (id, debt_id, balance, date, created_at)
+		SELECT md5(d.id::text || ':current')::uuid, d.id, d.current_value, CURRENT_DATE, d.updated_at
+		FROM debts d
+		WHERE d.start_date < CURRENT_DATE
+			AND NOT EXISTS (SELECT 1 FROM debt_history h WHERE h.debt_id = d.id AND h.date > d.start_date)
+		ON CONFLICT (debt_id, date) DO NOTHING`},
+	}
+
+	for _, migration := range dataMigrations {
+		if err := p.runOnce(migration.name, migration.query); err != nil {
+			return fmt.Errorf("failed to execute migration %s: %w", migration.name, err)
+		}
+	}
+
 	return nil
 }
+
+// runOnce executes a data migration unless schema_migrations records that
+// it already ran, and records it in the same transaction
+func (p *PostgresDB) runOnce(name, query string) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO schema_migrations (name, applied_at) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`, name, time.Now())
+	if err != nil {
+		return err
+	}
+	if inserted, err := res.RowsAffected(); err != nil || inserted == 0 {
+		return err
+	}
+
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"personal-finance/api/v1/services"
+)
+
+// AdminHandler handles administrative requests for background jobs
+type AdminHandler struct {
+	scheduler *services.Scheduler
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(scheduler *services.Scheduler) *AdminHandler {
+	return &AdminHandler{scheduler: scheduler}
+}
+
+// ListJobs handles GET /api/v1/admin/jobs
+func (h *AdminHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, h.scheduler.Statuses())
+}
+
+// RunJob handles POST /api/v1/admin/jobs/{name}/run
+func (h *AdminHandler) RunJob(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	err := h.scheduler.RunNow(name)
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		respondWithError(w, http.StatusNotFound, "Unknown job "+name)
+		return
+	case errors.Is(err, services.ErrJobRunning):
+		respondWithError(w, http.StatusConflict, "Job "+name+" is already running")
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Job " + name + " started"})
+}
@@ -3,7 +3,10 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -11,20 +14,71 @@ import (
 
 	"personal-finance/api/v1/db"
 	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
 	"personal-finance/api/v1/services"
 )
 
+// assetColumns lists the asset columns in the order scanAsset expects
+const assetColumns = `id, type, name, buy_price, current_value, currency, quantity, COALESCE(cost_basis, 0), purchase_date, source, provider, created_at, updated_at,
+	COALESCE(cost_basis_method, 'fifo'), COALESCE(realized_gain, 0), COALESCE(income, 0), COALESCE(exchange, ''), COALESCE(display_name, ''),
+	COALESCE(symbol, ''), COALESCE(kind, ''), face_value, clean_price, COALESCE(interest_rate, 0), COALESCE(coupon_frequency, 0), maturity_date`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAsset scans a row selected with assetColumns into an asset
+func scanAsset(row rowScanner, asset *models.Asset) error {
+	return row.Scan(
+		&asset.ID, &asset.Type, &asset.Name, &asset.BuyPrice, &asset.CurrentValue,
+		&asset.Currency, &asset.Quantity, &asset.CostBasis, &asset.PurchaseDate, &asset.Source, &asset.Provider,
+		&asset.CreatedAt, &asset.UpdatedAt, &asset.CostBasisMethod, &asset.RealizedGain, &asset.Income,
+		&asset.Exchange, &asset.DisplayName, &asset.Symbol,
+		&asset.Kind, &asset.FaceValue, &asset.CleanPrice, &asset.InterestRate, &asset.CouponFrequency, &asset.MaturityDate,
+	)
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertAsset writes a new asset row
+func insertAsset(db execer, asset *models.Asset) error {
+	query := `
+		INSERT INTO assets (id, type, name, buy_price, current_value, currency, quantity, cost_basis, purchase_date, source, provider, created_at, updated_at, cost_basis_method, exchange, display_name, symbol,
+			kind, face_value, clean_price, interest_rate, coupon_frequency, maturity_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+	`
+
+	if !asset.CostBasisMethod.Valid() {
+		asset.CostBasisMethod = models.CostBasisFIFO
+	}
+	_, err := db.Exec(query,
+		asset.ID, asset.Type, asset.Name, asset.BuyPrice, asset.CurrentValue,
+		asset.Currency, asset.Quantity, asset.CostBasis, asset.PurchaseDate, asset.Source, asset.Provider,
+		asset.CreatedAt, asset.UpdatedAt, asset.CostBasisMethod, asset.Exchange, asset.DisplayName, asset.Symbol,
+		asset.Kind, asset.FaceValue, asset.CleanPrice, asset.InterestRate, asset.CouponFrequency, asset.MaturityDate,
+	)
+	return err
+}
+
 // AssetHandler handles asset-related requests
 type AssetHandler struct {
 	db         *db.PostgresDB
 	marketData *services.MarketDataService
+	fx         *services.FXService
+	ledger     *services.LedgerService
 }
 
 // NewAssetHandler creates a new asset handler
-func NewAssetHandler(database *db.PostgresDB, marketDataService *services.MarketDataService) *AssetHandler {
+func NewAssetHandler(database *db.PostgresDB, marketDataService *services.MarketDataService, fxService *services.FXService, ledgerService *services.LedgerService) *AssetHandler {
 	return &AssetHandler{
 		db:         database,
 		marketData: marketDataService,
+		fx:         fxService,
+		ledger:     ledgerService,
 	}
 }
 
@@ -36,8 +90,8 @@ func (h *AssetHandler) CreateAsset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if req.Name == "" || req.Type == "" || req.BuyPrice <= 0 || req.Quantity <= 0 {
+	// Validate required fields; a market_api stock may take its name from the listing
+	if (req.Name == "" && req.Symbol == "") || req.Type == "" || !req.BuyPrice.IsPositive() || !req.Quantity.IsPositive() {
 		respondWithError(w, http.StatusBadRequest, "Missing or invalid required fields")
 		return
 	}
@@ -54,55 +108,186 @@ func (h *AssetHandler) CreateAsset(w http.ResponseWriter, r *http.Request) {
 		currentValue = *req.CurrentValue
 	}
 
+	if req.Provider != "" && !services.IsRegisteredProvider(req.Provider) {
+		respondWithError(w, http.StatusBadRequest, "Unknown market data provider")
+		return
+	}
+
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+
+	// The only investments priced from market data are funds
+	if req.Type == models.AssetTypeInvestment && req.Source == models.AssetSourceMarketAPI && req.Kind == "" {
+		req.Kind = models.InvestmentKindFund
+	}
+
+	// Market-priced stocks, funds and crypto must have a symbol the providers
+	// know, so they never silently go without prices
+	var listing *services.SymbolInfo
+	if marketPricedType(req.Type, req.Kind) && req.Source == models.AssetSourceMarketAPI {
+		if req.Symbol == "" {
+			// Older clients name market_api stocks by their ticker
+			req.Symbol = strings.ToUpper(strings.TrimSpace(req.Name))
+		}
+
+		var ok bool
+		listing, ok = h.verifySymbol(w, req.Type, req.Provider, req.Symbol)
+		if !ok {
+			return
+		}
+
+		// Listings quoted in minor units (GBp on the LSE) keep the given currency
+		if req.Currency == "" && listing.Currency == strings.ToUpper(listing.Currency) {
+			req.Currency = services.NormalizeCurrency(listing.Currency)
+		}
+		// A crypto pair is priced in its quote currency (BTC-EUR in EUR)
+		if req.Currency == "" && req.Type == models.AssetTypeCrypto {
+			_, quote, _ := services.CryptoPair(req.Symbol)
+			req.Currency = services.NormalizeCurrency(quote)
+		}
+		if req.Name == "" {
+			req.Name = listing.Name
+		}
+	} else if req.Symbol != "" && !services.IsStockSymbol(req.Symbol) {
+		respondWithError(w, http.StatusBadRequest, "Invalid symbol "+req.Symbol)
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.Symbol
+	}
+
 	if req.Currency == "" {
 		req.Currency = "USD"
 	}
 
+	if req.CostBasisMethod == "" {
+		req.CostBasisMethod = models.CostBasisFIFO
+	}
+	if !req.CostBasisMethod.Valid() {
+		respondWithError(w, http.StatusBadRequest, "Invalid cost_basis_method (use fifo, lifo, average or specific)")
+		return
+	}
+
 	asset := models.Asset{
 		ID:           uuid.New().String(),
 		Type:         req.Type,
 		Name:         req.Name,
+		Symbol:       req.Symbol,
 		BuyPrice:     req.BuyPrice,
 		CurrentValue: currentValue,
 		Currency:     req.Currency,
 		Quantity:     req.Quantity,
 		PurchaseDate: purchaseDate,
 		Source:       req.Source,
+		Provider:     req.Provider,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
+
+		CostBasisMethod: req.CostBasisMethod,
+
+		Kind:            req.Kind,
+		FaceValue:       req.FaceValue,
+		CleanPrice:      req.CleanPrice,
+		InterestRate:    req.InterestRate,
+		CouponFrequency: req.CouponFrequency,
+	}
+	if listing != nil {
+		asset.Exchange = listing.Exchange
+		asset.DisplayName = listing.Name
 	}
 
-	query := `
-		INSERT INTO assets (id, type, name, buy_price, current_value, currency, quantity, purchase_date, source, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`
+	if req.MaturityDate != "" {
+		maturityDate, err := time.Parse("2006-01-02", req.MaturityDate)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid maturity_date format (use YYYY-MM-DD)")
+			return
+		}
+		asset.MaturityDate = &maturityDate
+	}
+	if err := services.ValidateInvestmentTerms(&asset); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Bonds and CDs are valued from their terms, not a given current value
+	if value, _, ok := services.FixedIncomeUnitValue(&asset, time.Now()); ok {
+		asset.CurrentValue = money.RoundTo(value, asset.Currency)
+	}
 
-	_, err = h.db.DB.Exec(query,
-		asset.ID, asset.Type, asset.Name, asset.BuyPrice, asset.CurrentValue,
-		asset.Currency, asset.Quantity, asset.PurchaseDate, asset.Source,
-		asset.CreatedAt, asset.UpdatedAt,
-	)
+	asset.CostBasis = asset.PurchaseCost()
 
+	// The asset and its opening buy are written together so the position is
+	// derived from the ledger from the start
+	tx, err := h.db.DB.Begin()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create asset")
 		return
 	}
+	defer tx.Rollback()
+
+	if err := insertAsset(tx, &asset); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create asset")
+		return
+	}
+	if err := services.InsertOpeningTransaction(tx, &asset); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create asset")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create asset")
+		return
+	}
 
 	// Create initial history entry
 	h.addHistoryEntry(asset.ID, asset.CurrentValue, time.Now())
 
 	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
-		"id":            asset.ID,
-		"name":          asset.Name,
-		"current_value": asset.CurrentValue,
-		"profit_loss":   asset.ProfitLoss(),
+		"id":              asset.ID,
+		"name":            asset.Name,
+		"symbol":          asset.Symbol,
+		"kind":            asset.Kind,
+		"display_name":    asset.DisplayName,
+		"exchange":        asset.Exchange,
+		"currency":        asset.Currency,
+		"current_value":   asset.CurrentValue,
+		"unrealized_gain": asset.UnrealizedGain(),
 	})
 }
 
+// marketPricedType reports whether assets of a type and kind can be priced
+// from market data: stocks, crypto and funds
+func marketPricedType(assetType models.AssetType, kind models.InvestmentKind) bool {
+	return assetType == models.AssetTypeStock || assetType == models.AssetTypeCrypto ||
+		(assetType == models.AssetTypeInvestment && kind == models.InvestmentKindFund)
+}
+
+// verifySymbol checks a market_api stock's or crypto asset's symbol with the
+// providers and returns its listing. It writes the error response and
+// reports false if the symbol is malformed, unknown or cannot be checked.
+func (h *AssetHandler) verifySymbol(w http.ResponseWriter, assetType models.AssetType, provider, symbol string) (*services.SymbolInfo, bool) {
+	if !services.IsMarketPriced(string(assetType), string(models.AssetSourceMarketAPI), symbol) {
+		if assetType == models.AssetTypeCrypto {
+			respondWithError(w, http.StatusBadRequest, "market_api crypto needs a BASE-QUOTE pair symbol (e.g. BTC-USD, ETH-EUR)")
+		} else {
+			respondWithError(w, http.StatusBadRequest, "market_api stocks and funds need a ticker symbol (e.g. AAPL, BRK.B, VWRL.L)")
+		}
+		return nil, false
+	}
+
+	listing, err := h.marketData.LookupSymbol(h.marketData.PreferredProvider(string(assetType), provider), symbol)
+	if errors.Is(err, services.ErrSymbolNotFound) {
+		respondWithError(w, http.StatusBadRequest, "Unknown symbol "+symbol+" (find it with /api/v1/symbols/search)")
+		return nil, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Failed to verify symbol: "+err.Error())
+		return nil, false
+	}
+	return listing, true
+}
+
 // ListAssets handles GET /api/v1/assets
 func (h *AssetHandler) ListAssets(w http.ResponseWriter, r *http.Request) {
 	query := `
-		SELECT id, type, name, buy_price, current_value, currency, quantity, purchase_date, source, created_at, updated_at
+		SELECT ` + assetColumns + `
 		FROM assets
 		ORDER BY created_at DESC
 	`
@@ -117,35 +302,18 @@ func (h *AssetHandler) ListAssets(w http.ResponseWriter, r *http.Request) {
 	assets := []models.Asset{}
 	for rows.Next() {
 		var asset models.Asset
-		err := rows.Scan(
-			&asset.ID, &asset.Type, &asset.Name, &asset.BuyPrice, &asset.CurrentValue,
-			&asset.Currency, &asset.Quantity, &asset.PurchaseDate, &asset.Source,
-			&asset.CreatedAt, &asset.UpdatedAt,
-		)
+		err := scanAsset(rows, &asset)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Failed to parse assets")
 			return
 		}
-
-		// Fetch real-time price for stocks
-		if string(asset.Type) == "stock" && string(asset.Source) == "market_api" {
-			currentPrice, err := h.marketData.GetCurrentValue(
-				string(asset.Type),
-				asset.Name,
-				asset.CurrentValue,
-				string(asset.Source),
-			)
-			if err == nil {
-				asset.CurrentValue = currentPrice
-			}
-			// Small delay to avoid rate limiting (100ms between requests)
-			time.Sleep(100 * time.Millisecond)
-			// If error, keep the stored value
-		}
-
 		assets = append(assets, asset)
 	}
 
+	// Fetch real-time prices for stocks in one batch
+	h.marketData.ApplyMarketPrices(assets)
+	services.ApplyFixedIncomeValues(assets, time.Now())
+
 	respondWithJSON(w, http.StatusOK, assets)
 }
 
@@ -154,17 +322,13 @@ func (h *AssetHandler) GetAsset(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	query := `
-		SELECT id, type, name, buy_price, current_value, currency, quantity, purchase_date, source, created_at, updated_at
+		SELECT ` + assetColumns + `
 		FROM assets
 		WHERE id = $1
 	`
 
 	var asset models.Asset
-	err := h.db.DB.QueryRow(query, id).Scan(
-		&asset.ID, &asset.Type, &asset.Name, &asset.BuyPrice, &asset.CurrentValue,
-		&asset.Currency, &asset.Quantity, &asset.PurchaseDate, &asset.Source,
-		&asset.CreatedAt, &asset.UpdatedAt,
-	)
+	err := scanAsset(h.db.DB.QueryRow(query, id), &asset)
 
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Asset not found")
@@ -176,19 +340,23 @@ func (h *AssetHandler) GetAsset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch real-time price for stocks
-	if string(asset.Type) == "stock" && string(asset.Source) == "market_api" {
+	// Fetch real-time price for stocks and crypto
+	if services.IsMarketPriced(string(asset.Type), string(asset.Source), asset.Symbol) {
 		currentPrice, err := h.marketData.GetCurrentValue(
 			string(asset.Type),
-			asset.Name,
+			asset.Symbol,
 			asset.CurrentValue,
 			string(asset.Source),
+			asset.Provider,
 		)
 		if err == nil {
-			asset.CurrentValue = currentPrice
+			asset.CurrentValue = currentPrice.Price
+			asset.PriceProvider = currentPrice.Provider
+			asset.PriceStale = currentPrice.Stale
 		}
 		// If error, keep the stored value
 	}
+	services.ApplyFixedIncomeValue(&asset, time.Now())
 
 	respondWithJSON(w, http.StatusOK, asset)
 }
@@ -208,16 +376,188 @@ func (h *AssetHandler) UpdateAsset(w http.ResponseWriter, r *http.Request) {
 	if req.Name != nil {
 		updates["name"] = *req.Name
 	}
+	if req.Provider != nil && *req.Provider != "" && !services.IsRegisteredProvider(*req.Provider) {
+		respondWithError(w, http.StatusBadRequest, "Unknown market data provider")
+		return
+	}
+
+	// A market-priced asset whose symbol, provider or source changes is
+	// checked with the providers as on creation
+	if req.Symbol != nil || req.Source != nil || req.Provider != nil {
+		var asset models.Asset
+		err := scanAsset(h.db.DB.QueryRow(`SELECT `+assetColumns+` FROM assets WHERE id = $1`, id), &asset)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Asset not found")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch asset")
+			return
+		}
+
+		symbol, source, provider := asset.Symbol, asset.Source, asset.Provider
+		if req.Symbol != nil {
+			symbol = strings.ToUpper(strings.TrimSpace(*req.Symbol))
+			updates["symbol"] = symbol
+		}
+		if req.Source != nil {
+			source = *req.Source
+		}
+		if req.Provider != nil {
+			provider = *req.Provider
+		}
+
+		kind := asset.Kind
+		if asset.Type == models.AssetTypeInvestment && source == models.AssetSourceMarketAPI && kind == "" {
+			kind = models.InvestmentKindFund
+			updates["kind"] = kind
+		}
+
+		if marketPricedType(asset.Type, kind) && source == models.AssetSourceMarketAPI {
+			if symbol != asset.Symbol || source != asset.Source || provider != asset.Provider {
+				listing, ok := h.verifySymbol(w, asset.Type, provider, symbol)
+				if !ok {
+					return
+				}
+				updates["exchange"] = listing.Exchange
+				updates["display_name"] = listing.Name
+			}
+		} else if req.Symbol != nil && symbol != "" && !services.IsStockSymbol(symbol) {
+			respondWithError(w, http.StatusBadRequest, "Invalid symbol "+symbol)
+			return
+		}
+	}
 	if req.CurrentValue != nil {
 		updates["current_value"] = *req.CurrentValue
 	}
 	if req.Quantity != nil {
-		updates["quantity"] = *req.Quantity
+		var quantity, buyPrice money.Decimal
+		var currency string
+		err := h.db.DB.QueryRow(
+			`SELECT quantity, buy_price, COALESCE(currency, '') FROM assets WHERE id = $1`, id,
+		).Scan(&quantity, &buyPrice, &currency)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Asset not found")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch asset")
+			return
+		}
+
+		hasLedger, err := h.ledger.HasTransactions(id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch transactions")
+			return
+		}
+
+		// Quantity is derived from the ledger; resending the current value is accepted
+		switch {
+		case hasLedger && !req.Quantity.Equal(quantity):
+			respondWithError(w, http.StatusBadRequest, "Quantity is derived from transactions; record a buy, sell or transfer instead")
+			return
+		case !hasLedger:
+			updates["quantity"] = *req.Quantity
+			updates["cost_basis"] = money.RoundTo(buyPrice.Mul(*req.Quantity), currency)
+		}
 	}
 	if req.Source != nil {
 		updates["source"] = *req.Source
 	}
 
+	// Bonds and CDs are valued from their terms, so new terms revalue them
+	// and a value cannot be set directly
+	var revalued *money.Decimal
+	termsChanged := req.FaceValue != nil || req.CleanPrice != nil || req.InterestRate != nil ||
+		req.CouponFrequency != nil || req.MaturityDate != nil
+	if termsChanged || req.CurrentValue != nil || req.Source != nil {
+		var asset models.Asset
+		err := scanAsset(h.db.DB.QueryRow(`SELECT `+assetColumns+` FROM assets WHERE id = $1`, id), &asset)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Asset not found")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch asset")
+			return
+		}
+
+		switch {
+		case termsChanged && !asset.IsFixedIncome():
+			respondWithError(w, http.StatusBadRequest, "face_value, clean_price, interest_rate, coupon_frequency and maturity_date only apply to bonds and CDs")
+			return
+		case req.CurrentValue != nil && asset.IsFixedIncome():
+			respondWithError(w, http.StatusBadRequest, "The value of bonds and CDs is derived from their terms; update clean_price instead")
+			return
+		}
+
+		if asset.IsFixedIncome() {
+			if req.FaceValue != nil {
+				asset.FaceValue = req.FaceValue
+			}
+			if req.CleanPrice != nil {
+				asset.CleanPrice = req.CleanPrice
+			}
+			if req.InterestRate != nil {
+				asset.InterestRate = *req.InterestRate
+			}
+			if req.CouponFrequency != nil {
+				asset.CouponFrequency = *req.CouponFrequency
+			}
+			if req.MaturityDate != nil {
+				maturityDate, err := time.Parse("2006-01-02", *req.MaturityDate)
+				if err != nil {
+					respondWithError(w, http.StatusBadRequest, "Invalid maturity_date format (use YYYY-MM-DD)")
+					return
+				}
+				asset.MaturityDate = &maturityDate
+			}
+			if req.Source != nil {
+				asset.Source = *req.Source
+			}
+			if err := services.ValidateInvestmentTerms(&asset); err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			if termsChanged {
+				value, _, _ := services.FixedIncomeUnitValue(&asset, time.Now())
+				value = money.RoundTo(value, asset.Currency)
+				revalued = &value
+				updates["face_value"] = asset.FaceValue
+				updates["clean_price"] = asset.CleanPrice
+				updates["interest_rate"] = asset.InterestRate
+				updates["coupon_frequency"] = asset.CouponFrequency
+				updates["maturity_date"] = asset.MaturityDate
+				updates["current_value"] = value
+			}
+		}
+	}
+
+	if req.Provider != nil {
+		updates["provider"] = *req.Provider
+	}
+
+	// Changing the method replays the ledger, so it is applied on its own
+	if req.CostBasisMethod != nil {
+		err := h.ledger.SetCostBasisMethod(id, *req.CostBasisMethod)
+		switch {
+		case errors.Is(err, services.ErrAssetNotFound):
+			respondWithError(w, http.StatusNotFound, "Asset not found")
+			return
+		case errors.Is(err, services.ErrInvalidTransaction):
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		case err != nil:
+			respondWithError(w, http.StatusInternalServerError, "Failed to update cost basis method")
+			return
+		}
+		if len(updates) == 0 {
+			respondWithJSON(w, http.StatusOK, map[string]string{"message": "Asset updated successfully"})
+			return
+		}
+	}
+
 	if len(updates) == 0 {
 		respondWithError(w, http.StatusBadRequest, "No fields to update")
 		return
@@ -233,11 +573,11 @@ func (h *AssetHandler) UpdateAsset(w http.ResponseWriter, r *http.Request) {
 		if i > 1 {
 			query += ", "
 		}
-		query += key + " = $" + string(rune(i+48))
+		query += key + " = $" + strconv.Itoa(i)
 		args = append(args, val)
 		i++
 	}
-	query += " WHERE id = $" + string(rune(i+48))
+	query += " WHERE id = $" + strconv.Itoa(i)
 	args = append(args, id)
 
 	result, err := h.db.DB.Exec(query, args...)
@@ -256,6 +596,9 @@ func (h *AssetHandler) UpdateAsset(w http.ResponseWriter, r *http.Request) {
 	if req.CurrentValue != nil {
 		h.addHistoryEntry(id, *req.CurrentValue, time.Now())
 	}
+	if revalued != nil {
+		h.addHistoryEntry(id, *revalued, time.Now())
+	}
 
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Asset updated successfully"})
 }
@@ -281,9 +624,29 @@ func (h *AssetHandler) DeleteAsset(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetAssetHistory handles GET /api/v1/assets/{id}/history
+// With ?currency= each value is converted at the rate of its own date.
 func (h *AssetHandler) GetAssetHistory(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
+	var converter *services.Converter
+	var assetCurrency string
+	if r.URL.Query().Get("currency") != "" {
+		var ok bool
+		if converter, ok = requestConverter(w, r, h.fx); !ok {
+			return
+		}
+
+		err := h.db.DB.QueryRow(`SELECT COALESCE(currency, '') FROM assets WHERE id = $1`, id).Scan(&assetCurrency)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Asset not found")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch asset")
+			return
+		}
+	}
+
 	query := `
 		SELECT id, asset_id, value, date, created_at
 		FROM asset_history
@@ -306,14 +669,48 @@ func (h *AssetHandler) GetAssetHistory(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusInternalServerError, "Failed to parse history")
 			return
 		}
+		if converter != nil {
+			rate, err := converter.RateOn(assetCurrency, h.Date)
+			if err != nil {
+				respondWithError(w, http.StatusBadGateway, "Failed to convert asset history: "+err.Error())
+				return
+			}
+			if h.Value, err = converter.ConvertOn(h.Value, assetCurrency, h.Date); err != nil {
+				respondWithError(w, http.StatusBadGateway, "Failed to convert asset history: "+err.Error())
+				return
+			}
+			h.Currency = converter.Target()
+			h.FXRate = &rate
+		}
 		history = append(history, h)
 	}
 
 	respondWithJSON(w, http.StatusOK, history)
 }
 
+// BackfillAssetHistory handles POST /api/v1/assets/{id}/history/backfill
+func (h *AssetHandler) BackfillAssetHistory(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	full := r.URL.Query().Get("full") == "true"
+
+	result, err := h.marketData.BackfillAssetHistory(id, full)
+	switch {
+	case errors.Is(err, services.ErrAssetNotFound):
+		respondWithError(w, http.StatusNotFound, "Asset not found")
+		return
+	case errors.Is(err, services.ErrNotMarketPriced):
+		respondWithError(w, http.StatusBadRequest, "Only market_api stock assets can be backfilled")
+		return
+	case err != nil:
+		respondWithError(w, http.StatusBadGateway, "Failed to backfill asset history: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
 // addHistoryEntry adds a history entry for an asset
-func (h *AssetHandler) addHistoryEntry(assetID string, value float64, date time.Time) error {
+func (h *AssetHandler) addHistoryEntry(assetID string, value money.Decimal, date time.Time) error {
 	query := `
 		INSERT INTO asset_history (id, asset_id, value, date, created_at)
 		VALUES ($1, $2, $3, $4, $5)
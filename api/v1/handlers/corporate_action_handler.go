@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/services"
+)
+
+// CorporateActionHandler handles split, ticker change and merger requests
+type CorporateActionHandler struct {
+	actions *services.CorporateActionService
+}
+
+// NewCorporateActionHandler creates a new corporate action handler
+func NewCorporateActionHandler(corporateActionService *services.CorporateActionService) *CorporateActionHandler {
+	return &CorporateActionHandler{actions: corporateActionService}
+}
+
+// ListCorporateActions handles GET /api/v1/assets/{id}/corporate-actions
+func (h *CorporateActionHandler) ListCorporateActions(w http.ResponseWriter, r *http.Request) {
+	actions, err := h.actions.ListCorporateActions(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithCorporateActionError(w, err, "Failed to fetch corporate actions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, actions)
+}
+
+// CreateCorporateAction handles POST /api/v1/assets/{id}/corporate-actions
+func (h *CorporateActionHandler) CreateCorporateAction(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCorporateActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)")
+		return
+	}
+
+	action := models.CorporateAction{
+		Type:        req.Type,
+		Date:        date,
+		Numerator:   req.Numerator,
+		Denominator: req.Denominator,
+		NewSymbol:   req.NewSymbol,
+		Notes:       req.Notes,
+	}
+	if err := h.actions.ApplyCorporateAction(chi.URLParam(r, "id"), &action); err != nil {
+		respondWithCorporateActionError(w, err, "Failed to apply corporate action")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, action)
+}
+
+// DeleteCorporateAction handles DELETE /api/v1/assets/{id}/corporate-actions/{actionID}
+func (h *CorporateActionHandler) DeleteCorporateAction(w http.ResponseWriter, r *http.Request) {
+	if err := h.actions.DeleteCorporateAction(chi.URLParam(r, "id"), chi.URLParam(r, "actionID")); err != nil {
+		respondWithCorporateActionError(w, err, "Failed to revert corporate action")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Corporate action reverted successfully"})
+}
+
+// SyncSplits handles POST /api/v1/assets/{id}/corporate-actions/sync
+func (h *CorporateActionHandler) SyncSplits(w http.ResponseWriter, r *http.Request) {
+	result, err := h.actions.SyncSplits(chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, services.ErrAssetNotFound):
+		respondWithError(w, http.StatusNotFound, "Asset not found")
+		return
+	case errors.Is(err, services.ErrNotMarketPriced):
+		respondWithError(w, http.StatusBadRequest, "Only market_api stock assets have provider splits")
+		return
+	case err != nil:
+		respondWithError(w, http.StatusBadGateway, "Failed to sync splits: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// respondWithCorporateActionError maps corporate action errors to status codes
+func respondWithCorporateActionError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrCorporateActionNotFound):
+		respondWithError(w, http.StatusNotFound, "Corporate action not found")
+	case errors.Is(err, services.ErrInvalidCorporateAction):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondWithLedgerError(w, err, message)
+	}
+}
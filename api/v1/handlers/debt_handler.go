@@ -3,7 +3,10 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -11,16 +14,87 @@ import (
 
 	"personal-finance/api/v1/db"
 	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+	"personal-finance/api/v1/services"
 )
 
+// debtColumns lists the debt columns in the order scanDebt expects
+const debtColumns = `id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at,
+	COALESCE(term_months, 0), COALESCE(payment_frequency, ''), payment_amount,
+	COALESCE(compounding, ''), COALESCE(rate_type, ''), interest_since`
+
+// scanDebt scans a row selected with debtColumns into a debt
+func scanDebt(row rowScanner, debt *models.Debt) error {
+	return row.Scan(
+		&debt.ID, &debt.Type, &debt.Name, &debt.Principal, &debt.CurrentValue,
+		&debt.Currency, &debt.InterestRate, &debt.StartDate,
+		&debt.CreatedAt, &debt.UpdatedAt,
+		&debt.TermMonths, &debt.PaymentFrequency, &debt.PaymentAmount,
+		&debt.Compounding, &debt.RateType, &debt.InterestSince,
+	)
+}
+
+// insertDebt writes a new debt row and starts its balance history. Interest
+// accrues from the start date unless the debt's InterestSince says otherwise.
+func insertDebt(db execer, debt *models.Debt) error {
+	if debt.InterestSince == nil {
+		since := debt.StartDate
+		debt.InterestSince = &since
+	}
+
+	query := `
+		INSERT INTO debts (id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at,
+			term_months, payment_frequency, payment_amount, compounding, rate_type, interest_since)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+
+	_, err := db.Exec(query,
+		debt.ID, debt.Type, debt.Name, debt.Principal, debt.CurrentValue,
+		debt.Currency, debt.InterestRate, debt.StartDate,
+		debt.CreatedAt, debt.UpdatedAt,
+		debt.TermMonths, debt.PaymentFrequency, debt.PaymentAmount,
+		debt.Compounding, debt.RateType, debt.InterestSince,
+	)
+	if err != nil {
+		return err
+	}
+	return services.StartDebtHistory(db, debt)
+}
+
+// accrueFromToday treats the balance given for a debt that started earlier
+// as owed today, so interest accrues on it from today rather than from the
+// start date
+func accrueFromToday(debt *models.Debt) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if debt.InterestSince == nil && debt.StartDate.Before(today) {
+		debt.InterestSince = &today
+	}
+}
+
+// createDebt inserts a debt and its history in one transaction
+func createDebt(database *sql.DB, debt *models.Debt) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertDebt(tx, debt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // DebtHandler handles debt-related requests
 type DebtHandler struct {
 	db *db.PostgresDB
+	fx *services.FXService
 }
 
 // NewDebtHandler creates a new debt handler
-func NewDebtHandler(database *db.PostgresDB) *DebtHandler {
-	return &DebtHandler{db: database}
+func NewDebtHandler(database *db.PostgresDB, fxService *services.FXService) *DebtHandler {
+	return &DebtHandler{db: database, fx: fxService}
 }
 
 // CreateDebt handles POST /api/v1/debts
@@ -32,7 +106,7 @@ func (h *DebtHandler) CreateDebt(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate required fields
-	if req.Name == "" || req.Type == "" || req.Principal <= 0 {
+	if req.Name == "" || req.Type == "" || !req.Principal.IsPositive() {
 		respondWithError(w, http.StatusBadRequest, "Missing or invalid required fields")
 		return
 	}
@@ -54,30 +128,57 @@ func (h *DebtHandler) CreateDebt(w http.ResponseWriter, r *http.Request) {
 	}
 
 	debt := models.Debt{
-		ID:           uuid.New().String(),
-		Type:         req.Type,
-		Name:         req.Name,
-		Principal:    req.Principal,
-		CurrentValue: currentValue,
-		Currency:     req.Currency,
-		InterestRate: req.InterestRate,
-		StartDate:    startDate,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:               uuid.New().String(),
+		Type:             req.Type,
+		Name:             req.Name,
+		Principal:        req.Principal,
+		CurrentValue:     currentValue,
+		Currency:         req.Currency,
+		InterestRate:     req.InterestRate,
+		StartDate:        startDate,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+		TermMonths:       req.TermMonths,
+		PaymentFrequency: req.PaymentFrequency,
+		PaymentAmount:    req.PaymentAmount,
+		Compounding:      req.Compounding,
+		RateType:         req.RateType,
 	}
 
-	query := `
-		INSERT INTO debts (id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`
+	if err := services.ValidateLoanTerms(&debt); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := services.ValidateInterestTerms(&debt); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	_, err = h.db.DB.Exec(query,
-		debt.ID, debt.Type, debt.Name, debt.Principal, debt.CurrentValue,
-		debt.Currency, debt.InterestRate, debt.StartDate,
-		debt.CreatedAt, debt.UpdatedAt,
-	)
+	if req.CurrentValue != nil {
+		accrueFromToday(&debt)
+	}
 
-	if err != nil {
+	// A loan with terms is worth its scheduled balance unless a value is
+	// given, and accrues interest from the last payment that left it there
+	if debt.HasLoanTerms() {
+		schedule, err := services.BuildAmortizationSchedule(&debt)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.CurrentValue == nil {
+			debt.CurrentValue = schedule.BalanceToday
+			for _, payment := range schedule.Payments {
+				if payment.Date.After(time.Now()) {
+					break
+				}
+				date := payment.Date
+				debt.InterestSince = &date
+			}
+		}
+	}
+
+	if err := createDebt(h.db.DB, &debt); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create debt")
 		return
 	}
@@ -86,9 +187,17 @@ func (h *DebtHandler) CreateDebt(w http.ResponseWriter, r *http.Request) {
 }
 
 // ListDebts handles GET /api/v1/debts
+// Balances include the interest accrued up to today, or up to ?as_of= for a
+// projection that assumes no further payments. Debts with a rate schedule
+// show their rate on that date and the changes after it.
 func (h *DebtHandler) ListDebts(w http.ResponseWriter, r *http.Request) {
+	asOf, ok := accrualDate(w, r)
+	if !ok {
+		return
+	}
+
 	query := `
-		SELECT id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at
+		SELECT ` + debtColumns + `
 		FROM debts
 		ORDER BY created_at DESC
 	`
@@ -103,49 +212,304 @@ func (h *DebtHandler) ListDebts(w http.ResponseWriter, r *http.Request) {
 	debts := []models.Debt{}
 	for rows.Next() {
 		var debt models.Debt
-		err := rows.Scan(
-			&debt.ID, &debt.Type, &debt.Name, &debt.Principal, &debt.CurrentValue,
-			&debt.Currency, &debt.InterestRate, &debt.StartDate,
-			&debt.CreatedAt, &debt.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanDebt(rows, &debt); err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Failed to parse debts")
 			return
 		}
 		debts = append(debts, debt)
 	}
 
+	pointers := make([]*models.Debt, len(debts))
+	for i := range debts {
+		pointers[i] = &debts[i]
+	}
+	if err := services.LoadRateSchedules(h.db.DB, pointers...); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch rate schedules")
+		return
+	}
+	if err := services.ApplyAccruals(h.db.DB, debts, asOf); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to accrue interest")
+		return
+	}
+	for i := range debts {
+		services.ApplyRateSchedule(&debts[i], asOf)
+	}
+
 	respondWithJSON(w, http.StatusOK, debts)
 }
 
 // GetDebt handles GET /api/v1/debts/{id}
+// Like ListDebts it accrues interest up to today or ?as_of=.
 func (h *DebtHandler) GetDebt(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	query := `
-		SELECT id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at
-		FROM debts
-		WHERE id = $1
-	`
+	asOf, ok := accrualDate(w, r)
+	if !ok {
+		return
+	}
+
+	debt, ok := h.fetchDebt(w, id)
+	if !ok {
+		return
+	}
+
+	debts := []models.Debt{*debt}
+	if err := services.ApplyAccruals(h.db.DB, debts, asOf); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to accrue interest")
+		return
+	}
+	services.ApplyRateSchedule(&debts[0], asOf)
+
+	respondWithJSON(w, http.StatusOK, debts[0])
+}
 
+// accrualDate parses ?as_of= (YYYY-MM-DD), defaulting to today. Past
+// balances come from the debt's history, so earlier dates are rejected.
+func accrualDate(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	value := r.URL.Query().Get("as_of")
+	if value == "" {
+		return today, true
+	}
+
+	asOf, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid as_of format (use YYYY-MM-DD)")
+		return time.Time{}, false
+	}
+	if asOf.Before(today) {
+		respondWithError(w, http.StatusBadRequest, "as_of cannot be in the past; use /history for past balances")
+		return time.Time{}, false
+	}
+	return asOf, true
+}
+
+// fetchDebt loads a debt with its rate schedule, writing the error response
+// when it cannot
+func (h *DebtHandler) fetchDebt(w http.ResponseWriter, id string) (*models.Debt, bool) {
 	var debt models.Debt
-	err := h.db.DB.QueryRow(query, id).Scan(
-		&debt.ID, &debt.Type, &debt.Name, &debt.Principal, &debt.CurrentValue,
-		&debt.Currency, &debt.InterestRate, &debt.StartDate,
-		&debt.CreatedAt, &debt.UpdatedAt,
-	)
+	err := scanDebt(h.db.DB.QueryRow(`SELECT `+debtColumns+` FROM debts WHERE id = $1`, id), &debt)
+	if err == nil {
+		err = services.LoadRateSchedules(h.db.DB, &debt)
+	}
 
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusNotFound, "Debt not found")
+		return nil, false
+	}
+
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch debt")
+		return nil, false
+	}
+
+	return &debt, true
+}
+
+// GetSchedule handles GET /api/v1/debts/{id}/schedule
+// It returns every payment of the loan with its interest and principal split
+// and the balance left after it, plus the balance scheduled for today.
+func (h *DebtHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	debt, ok := h.fetchDebt(w, id)
+	if !ok {
+		return
+	}
+	if !debt.HasLoanTerms() {
+		respondWithError(w, http.StatusBadRequest, "Debt has no loan terms; set term_months or payment_amount")
 		return
 	}
 
+	schedule, err := services.BuildAmortizationSchedule(debt)
+	if errors.Is(err, services.ErrInvalidLoanTerms) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build amortization schedule")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, schedule)
+}
+
+// GetDebtHistory handles GET /api/v1/debts/{id}/history
+// With ?currency= each balance is converted at the rate of its own date.
+func (h *DebtHandler) GetDebtHistory(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var debtCurrency string
+	err := h.db.DB.QueryRow(`SELECT COALESCE(currency, '') FROM debts WHERE id = $1`, id).Scan(&debtCurrency)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Debt not found")
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to fetch debt")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, debt)
+	var converter *services.Converter
+	if r.URL.Query().Get("currency") != "" {
+		var ok bool
+		if converter, ok = requestConverter(w, r, h.fx); !ok {
+			return
+		}
+	}
+
+	query := `
+		SELECT id, debt_id, balance, date, created_at
+		FROM debt_history
+		WHERE debt_id = $1
+		ORDER BY date DESC
+	`
+
+	rows, err := h.db.DB.Query(query, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch debt history")
+		return
+	}
+	defer rows.Close()
+
+	history := []models.DebtHistory{}
+	for rows.Next() {
+		var entry models.DebtHistory
+		if err := rows.Scan(&entry.ID, &entry.DebtID, &entry.Balance, &entry.Date, &entry.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to parse history")
+			return
+		}
+		if converter != nil {
+			rate, err := converter.RateOn(debtCurrency, entry.Date)
+			if err != nil {
+				respondWithError(w, http.StatusBadGateway, "Failed to convert debt history: "+err.Error())
+				return
+			}
+			if entry.Balance, err = converter.ConvertOn(entry.Balance, debtCurrency, entry.Date); err != nil {
+				respondWithError(w, http.StatusBadGateway, "Failed to convert debt history: "+err.Error())
+				return
+			}
+			entry.Currency = converter.Target()
+			entry.FXRate = &rate
+		}
+		history = append(history, entry)
+	}
+
+	respondWithJSON(w, http.StatusOK, history)
+}
+
+// PlanPayoff handles POST /api/v1/debts/payoff-plan
+// It simulates repaying every debt with a monthly budget using the snowball,
+// avalanche or a custom order, in the request's currency (the base currency
+// by default) at today's exchange rates.
+func (h *DebtHandler) PlanPayoff(w http.ResponseWriter, r *http.Request) {
+	var req models.PayoffPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Currency != "" && services.NormalizeCurrency(req.Currency) == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid currency (use a 3-letter code such as EUR)")
+		return
+	}
+	converter := h.fx.NewConverter(req.Currency)
+
+	rows, err := h.db.DB.Query(`SELECT ` + debtColumns + ` FROM debts ORDER BY created_at`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch debts")
+		return
+	}
+	defer rows.Close()
+
+	var debts []models.Debt
+	for rows.Next() {
+		var debt models.Debt
+		if err := scanDebt(rows, &debt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to parse debts")
+			return
+		}
+		debts = append(debts, debt)
+	}
+
+	// Plans start from what is owed today, interest included
+	pointers := make([]*models.Debt, len(debts))
+	for i := range debts {
+		pointers[i] = &debts[i]
+	}
+	if err := services.LoadRateSchedules(h.db.DB, pointers...); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch rate schedules")
+		return
+	}
+	if err := services.ApplyAccruals(h.db.DB, debts, time.Now()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to accrue interest")
+		return
+	}
+
+	known := make(map[string]bool, len(debts))
+	payoffDebts := make([]services.PayoffDebt, 0, len(debts))
+	for i := range debts {
+		debt := &debts[i]
+		known[debt.ID] = true
+
+		balance, err := converter.Convert(debt.CurrentValue, debt.Currency)
+		if err != nil {
+			respondWithError(w, http.StatusBadGateway, "Failed to convert debt balances: "+err.Error())
+			return
+		}
+
+		// A set minimum wins over a loan's scheduled payment; other debts
+		// pay a card-style minimum
+		minimum, hasMinimum := req.MinimumPayments[debt.ID]
+		if !hasMinimum {
+			minimum, hasMinimum, err = services.MonthlyPayment(debt)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, debt.Name+": "+err.Error())
+				return
+			}
+		}
+
+		payoffDebt := services.PayoffDebt{
+			ID:           debt.ID,
+			Name:         debt.Name,
+			Balance:      balance,
+			InterestRate: services.RateOn(debt, time.Now()),
+			RateChanges:  services.UpcomingRateChanges(debt, time.Now()),
+		}
+		if hasMinimum {
+			converted, err := converter.Convert(minimum, debt.Currency)
+			if err != nil {
+				respondWithError(w, http.StatusBadGateway, "Failed to convert minimum payments: "+err.Error())
+				return
+			}
+			payoffDebt.Minimum = &converted
+		}
+		payoffDebts = append(payoffDebts, payoffDebt)
+	}
+	for id := range req.MinimumPayments {
+		if !known[id] {
+			respondWithError(w, http.StatusBadRequest, "minimum_payments lists unknown debt "+id)
+			return
+		}
+	}
+
+	cardFloor, err := services.CardMinimumFloor(converter)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Failed to convert the card minimum: "+err.Error())
+		return
+	}
+
+	plan, err := services.PlanPayoff(payoffDebts, req.MonthlyBudget, req.Strategy, req.Order, converter.Target(), cardFloor, time.Now())
+	if errors.Is(err, services.ErrInvalidPayoffPlan) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to plan debt payoff")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, plan)
 }
 
 // UpdateDebt handles PUT /api/v1/debts/{id}
@@ -169,12 +533,83 @@ func (h *DebtHandler) UpdateDebt(w http.ResponseWriter, r *http.Request) {
 	if req.InterestRate != nil {
 		updates["interest_rate"] = *req.InterestRate
 	}
+	if req.Compounding != nil || req.RateType != nil || req.InterestRate != nil {
+		terms := models.Debt{}
+		if req.InterestRate != nil {
+			terms.InterestRate = *req.InterestRate
+		}
+		if req.Compounding != nil {
+			terms.Compounding = *req.Compounding
+			updates["compounding"] = *req.Compounding
+		}
+		if req.RateType != nil {
+			terms.RateType = *req.RateType
+			updates["rate_type"] = *req.RateType
+		}
+		if err := services.ValidateInterestTerms(&terms); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// New terms rebuild the schedule, and the debt takes the balance it
+	// schedules for today unless a value is given
+	if req.TermMonths != nil || req.PaymentFrequency != nil || req.PaymentAmount != nil || req.InterestRate != nil {
+		debt, ok := h.fetchDebt(w, id)
+		if !ok {
+			return
+		}
+		if req.InterestRate != nil {
+			debt.InterestRate = *req.InterestRate
+		}
+		if req.TermMonths != nil {
+			debt.TermMonths = *req.TermMonths
+		}
+		if req.PaymentFrequency != nil {
+			debt.PaymentFrequency = *req.PaymentFrequency
+		}
+		if req.PaymentAmount != nil {
+			// A zero payment removes the fixed payment
+			debt.PaymentAmount = req.PaymentAmount
+			if req.PaymentAmount.IsZero() {
+				debt.PaymentAmount = nil
+			}
+		}
+		if !debt.HasLoanTerms() {
+			debt.PaymentFrequency = ""
+		}
+
+		if err := services.ValidateLoanTerms(debt); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if debt.HasLoanTerms() {
+			schedule, err := services.BuildAmortizationSchedule(debt)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if req.CurrentValue == nil && (req.TermMonths != nil || req.PaymentFrequency != nil || req.PaymentAmount != nil) {
+				updates["current_value"] = schedule.BalanceToday
+			}
+		}
+		updates["term_months"] = debt.TermMonths
+		updates["payment_frequency"] = debt.PaymentFrequency
+		updates["payment_amount"] = debt.PaymentAmount
+	}
 
 	if len(updates) == 0 {
 		respondWithError(w, http.StatusBadRequest, "No fields to update")
 		return
 	}
 
+	// A balance set by hand includes any interest owed, which accrues
+	// afresh from today
+	if _, ok := updates["current_value"]; ok {
+		updates["interest_since"] = time.Now().Format("2006-01-02")
+		updates["accrued_interest"] = money.Zero
+	}
+
 	updates["updated_at"] = time.Now()
 
 	// Execute update
@@ -185,11 +620,11 @@ func (h *DebtHandler) UpdateDebt(w http.ResponseWriter, r *http.Request) {
 		if i > 1 {
 			query += ", "
 		}
-		query += key + " = $" + string(rune(i+48))
+		query += key + " = $" + strconv.Itoa(i)
 		args = append(args, val)
 		i++
 	}
-	query += " WHERE id = $" + string(rune(i+48))
+	query += " WHERE id = $" + strconv.Itoa(i)
 	args = append(args, id)
 
 	result, err := h.db.DB.Exec(query, args...)
@@ -204,6 +639,13 @@ func (h *DebtHandler) UpdateDebt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A balance set by hand is kept in the history like any other change
+	if value, ok := updates["current_value"].(money.Decimal); ok {
+		if err := services.RecordDebtBalance(h.db.DB, id, value, time.Now()); err != nil {
+			log.Printf("[Debts] Failed to record balance history for debt %s: %v", id, err)
+		}
+	}
+
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Debt updated successfully"})
 }
 
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/services"
+)
+
+// DebtPaymentHandler handles debt payment requests
+type DebtPaymentHandler struct {
+	ledger *services.DebtLedgerService
+}
+
+// NewDebtPaymentHandler creates a new debt payment handler
+func NewDebtPaymentHandler(debtLedgerService *services.DebtLedgerService) *DebtPaymentHandler {
+	return &DebtPaymentHandler{ledger: debtLedgerService}
+}
+
+// ListPayments handles GET /api/v1/debts/{id}/payments
+func (h *DebtPaymentHandler) ListPayments(w http.ResponseWriter, r *http.Request) {
+	payments, err := h.ledger.ListPayments(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithDebtLedgerError(w, err, "Failed to fetch payments")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, payments)
+}
+
+// GetPayment handles GET /api/v1/debts/{id}/payments/{paymentID}
+func (h *DebtPaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	payment, err := h.ledger.GetPayment(chi.URLParam(r, "id"), chi.URLParam(r, "paymentID"))
+	if err != nil {
+		respondWithDebtLedgerError(w, err, "Failed to fetch payment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, payment)
+}
+
+// CreatePayment handles POST /api/v1/debts/{id}/payments
+func (h *DebtPaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateDebtPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)")
+		return
+	}
+
+	payment := models.DebtPayment{
+		Date:   date,
+		Amount: req.Amount,
+		Notes:  req.Notes,
+	}
+	if err := h.ledger.CreatePayment(chi.URLParam(r, "id"), &payment, req.Interest, req.Principal); err != nil {
+		respondWithDebtLedgerError(w, err, "Failed to record payment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, payment)
+}
+
+// UpdatePayment handles PUT /api/v1/debts/{id}/payments/{paymentID}
+// A new date or amount without an interest or principal splits the payment
+// again; otherwise a split that was given is kept.
+func (h *DebtPaymentHandler) UpdatePayment(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateDebtPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	payment, err := h.ledger.GetPayment(chi.URLParam(r, "id"), chi.URLParam(r, "paymentID"))
+	if err != nil {
+		respondWithDebtLedgerError(w, err, "Failed to fetch payment")
+		return
+	}
+
+	if req.Date != nil {
+		date, err := time.Parse("2006-01-02", *req.Date)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)")
+			return
+		}
+		payment.Date = date
+	}
+	if req.Amount != nil {
+		payment.Amount = *req.Amount
+	}
+	if req.Notes != nil {
+		payment.Notes = *req.Notes
+	}
+
+	interest, principal := req.Interest, req.Principal
+	if interest == nil && principal == nil && req.Date == nil && req.Amount == nil && payment.ManualSplit {
+		interest, principal = &payment.Interest, &payment.Principal
+	}
+
+	if err := h.ledger.UpdatePayment(payment, interest, principal); err != nil {
+		respondWithDebtLedgerError(w, err, "Failed to update payment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, payment)
+}
+
+// DeletePayment handles DELETE /api/v1/debts/{id}/payments/{paymentID}
+func (h *DebtPaymentHandler) DeletePayment(w http.ResponseWriter, r *http.Request) {
+	if err := h.ledger.DeletePayment(chi.URLParam(r, "id"), chi.URLParam(r, "paymentID")); err != nil {
+		respondWithDebtLedgerError(w, err, "Failed to delete payment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Payment deleted successfully"})
+}
+
+// respondWithDebtLedgerError maps debt ledger errors to status codes
+func respondWithDebtLedgerError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrDebtNotFound):
+		respondWithError(w, http.StatusNotFound, "Debt not found")
+	case errors.Is(err, services.ErrPaymentNotFound):
+		respondWithError(w, http.StatusNotFound, "Payment not found")
+	case errors.Is(err, services.ErrInvalidPayment):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, message)
+	}
+}
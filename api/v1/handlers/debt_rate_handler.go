@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/services"
+)
+
+// DebtRateHandler handles debt rate schedule and rate index requests
+type DebtRateHandler struct {
+	rates *services.DebtRateService
+}
+
+// NewDebtRateHandler creates a new debt rate handler
+func NewDebtRateHandler(debtRateService *services.DebtRateService) *DebtRateHandler {
+	return &DebtRateHandler{rates: debtRateService}
+}
+
+// ListRates handles GET /api/v1/debts/{id}/rates
+func (h *DebtRateHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.rates.ListRates(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithDebtRateError(w, err, "Failed to fetch rate changes")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, rates)
+}
+
+// GetRate handles GET /api/v1/debts/{id}/rates/{rateID}
+func (h *DebtRateHandler) GetRate(w http.ResponseWriter, r *http.Request) {
+	rate, err := h.rates.GetRate(chi.URLParam(r, "id"), chi.URLParam(r, "rateID"))
+	if err != nil {
+		respondWithDebtRateError(w, err, "Failed to fetch rate change")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, rate)
+}
+
+// CreateRate handles POST /api/v1/debts/{id}/rates
+func (h *DebtRateHandler) CreateRate(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateDebtRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	effectiveDate, err := time.Parse("2006-01-02", req.EffectiveDate)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid effective_date format (use YYYY-MM-DD)")
+		return
+	}
+
+	rate := models.DebtRate{
+		EffectiveDate: effectiveDate,
+		Rate:          req.Rate,
+		Index:         req.Index,
+		Margin:        req.Margin,
+		Notes:         req.Notes,
+	}
+	if req.EndDate != "" {
+		endDate, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid end_date format (use YYYY-MM-DD)")
+			return
+		}
+		rate.EndDate = &endDate
+	}
+
+	if err := h.rates.CreateRate(chi.URLParam(r, "id"), &rate); err != nil {
+		respondWithDebtRateError(w, err, "Failed to add rate change")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, rate)
+}
+
+// UpdateRate handles PUT /api/v1/debts/{id}/rates/{rateID}
+// Setting a rate switches a rate change to a fixed rate, setting an index
+// switches it to the index.
+func (h *DebtRateHandler) UpdateRate(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateDebtRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	rate, err := h.rates.GetRate(chi.URLParam(r, "id"), chi.URLParam(r, "rateID"))
+	if err != nil {
+		respondWithDebtRateError(w, err, "Failed to fetch rate change")
+		return
+	}
+
+	if req.EffectiveDate != nil {
+		effectiveDate, err := time.Parse("2006-01-02", *req.EffectiveDate)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid effective_date format (use YYYY-MM-DD)")
+			return
+		}
+		rate.EffectiveDate = effectiveDate
+	}
+	if req.EndDate != nil {
+		rate.EndDate = nil
+		if *req.EndDate != "" {
+			endDate, err := time.Parse("2006-01-02", *req.EndDate)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid end_date format (use YYYY-MM-DD)")
+				return
+			}
+			rate.EndDate = &endDate
+		}
+	}
+	if req.Rate != nil {
+		rate.Rate = req.Rate
+		if req.Index == nil {
+			rate.Index, rate.Margin = "", 0
+		}
+	}
+	if req.Index != nil {
+		rate.Index = *req.Index
+		if req.Rate == nil && *req.Index != "" {
+			rate.Rate = nil
+		}
+	}
+	if req.Margin != nil {
+		rate.Margin = *req.Margin
+	}
+	if req.Notes != nil {
+		rate.Notes = *req.Notes
+	}
+
+	if err := h.rates.UpdateRate(rate); err != nil {
+		respondWithDebtRateError(w, err, "Failed to update rate change")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, rate)
+}
+
+// DeleteRate handles DELETE /api/v1/debts/{id}/rates/{rateID}
+func (h *DebtRateHandler) DeleteRate(w http.ResponseWriter, r *http.Request) {
+	if err := h.rates.DeleteRate(chi.URLParam(r, "id"), chi.URLParam(r, "rateID")); err != nil {
+		respondWithDebtRateError(w, err, "Failed to delete rate change")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Rate change deleted successfully"})
+}
+
+// ListRateIndexes handles GET /api/v1/rate-indexes
+func (h *DebtRateHandler) ListRateIndexes(w http.ResponseWriter, r *http.Request) {
+	indexes, err := h.rates.ListRateIndexes()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch rate indexes")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, indexes)
+}
+
+// GetRateIndex handles GET /api/v1/rate-indexes/{name}
+// It returns every value of the index, oldest first.
+func (h *DebtRateHandler) GetRateIndex(w http.ResponseWriter, r *http.Request) {
+	values, err := h.rates.RateIndexValues(chi.URLParam(r, "name"))
+	if err != nil {
+		respondWithDebtRateError(w, err, "Failed to fetch rate index")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, values)
+}
+
+// SetRateIndexValue handles POST /api/v1/rate-indexes/{name}
+// The value applies from its date until the next one; debts following the
+// index accrue at the new rate.
+func (h *DebtRateHandler) SetRateIndexValue(w http.ResponseWriter, r *http.Request) {
+	var req models.SetRateIndexValueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)")
+		return
+	}
+
+	value := models.RateIndexValue{
+		Index: chi.URLParam(r, "name"),
+		Date:  date,
+		Rate:  req.Rate,
+	}
+	if err := h.rates.SetRateIndexValue(&value); err != nil {
+		respondWithDebtRateError(w, err, "Failed to set rate index value")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, value)
+}
+
+// DeleteRateIndexValue handles DELETE /api/v1/rate-indexes/{name}/{date}
+func (h *DebtRateHandler) DeleteRateIndexValue(w http.ResponseWriter, r *http.Request) {
+	date, err := time.Parse("2006-01-02", chi.URLParam(r, "date"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)")
+		return
+	}
+
+	if err := h.rates.DeleteRateIndexValue(chi.URLParam(r, "name"), date); err != nil {
+		respondWithDebtRateError(w, err, "Failed to delete rate index value")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Rate index value deleted successfully"})
+}
+
+// respondWithDebtRateError maps debt rate errors to status codes
+func respondWithDebtRateError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrDebtNotFound):
+		respondWithError(w, http.StatusNotFound, "Debt not found")
+	case errors.Is(err, services.ErrRateNotFound):
+		respondWithError(w, http.StatusNotFound, "Rate change not found")
+	case errors.Is(err, services.ErrRateIndexNotFound):
+		respondWithError(w, http.StatusNotFound, "Rate index not found")
+	case errors.Is(err, services.ErrInvalidRate):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"personal-finance/api/v1/db"
 	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+	"personal-finance/api/v1/services"
 
 	"github.com/google/uuid"
 )
@@ -44,18 +48,20 @@ func parseDate(dateStr string) (time.Time, error) {
 
 // ExportHandler handles export/import operations
 type ExportHandler struct {
-	db *db.PostgresDB
+	db     *db.PostgresDB
+	fx     *services.FXService
+	ledger *services.LedgerService
 }
 
 // NewExportHandler creates a new export handler
-func NewExportHandler(database *db.PostgresDB) *ExportHandler {
-	return &ExportHandler{db: database}
+func NewExportHandler(database *db.PostgresDB, fxService *services.FXService, ledgerService *services.LedgerService) *ExportHandler {
+	return &ExportHandler{db: database, fx: fxService, ledger: ledgerService}
 }
 
 // ExportAssetsJSON handles GET /api/v1/export/assets/json
 func (h *ExportHandler) ExportAssetsJSON(w http.ResponseWriter, r *http.Request) {
 	query := `
-		SELECT id, type, name, buy_price, current_value, currency, quantity, purchase_date, source, created_at, updated_at
+		SELECT ` + assetColumns + `
 		FROM assets
 		ORDER BY created_at DESC
 	`
@@ -70,11 +76,7 @@ func (h *ExportHandler) ExportAssetsJSON(w http.ResponseWriter, r *http.Request)
 	assets := []models.Asset{}
 	for rows.Next() {
 		var asset models.Asset
-		err := rows.Scan(
-			&asset.ID, &asset.Type, &asset.Name, &asset.BuyPrice, &asset.CurrentValue,
-			&asset.Currency, &asset.Quantity, &asset.PurchaseDate, &asset.Source,
-			&asset.CreatedAt, &asset.UpdatedAt,
-		)
+		err := scanAsset(rows, &asset)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Failed to parse assets")
 			return
@@ -92,7 +94,7 @@ func (h *ExportHandler) ExportAssetsJSON(w http.ResponseWriter, r *http.Request)
 // ExportAssetsCSV handles GET /api/v1/export/assets/csv
 func (h *ExportHandler) ExportAssetsCSV(w http.ResponseWriter, r *http.Request) {
 	query := `
-		SELECT id, type, name, buy_price, current_value, currency, quantity, purchase_date, source, created_at, updated_at
+		SELECT ` + assetColumns + `
 		FROM assets
 		ORDER BY created_at DESC
 	`
@@ -112,17 +114,14 @@ func (h *ExportHandler) ExportAssetsCSV(w http.ResponseWriter, r *http.Request)
 	defer writer.Flush()
 
 	// Write CSV header
-	header := []string{"ID", "Type", "Name", "Buy Price", "Current Value", "Currency", "Quantity", "Purchase Date", "Source", "Created At", "Updated At"}
+	header := []string{"ID", "Type", "Name", "Buy Price", "Current Value", "Currency", "Quantity", "Purchase Date", "Source", "Created At", "Updated At", "Provider", "Cost Basis", "Cost Basis Method", "Symbol", "Exchange",
+		"Kind", "Face Value", "Clean Price", "Interest Rate", "Coupon Frequency", "Maturity Date"}
 	writer.Write(header)
 
 	// Write data rows
 	for rows.Next() {
 		var asset models.Asset
-		err := rows.Scan(
-			&asset.ID, &asset.Type, &asset.Name, &asset.BuyPrice, &asset.CurrentValue,
-			&asset.Currency, &asset.Quantity, &asset.PurchaseDate, &asset.Source,
-			&asset.CreatedAt, &asset.UpdatedAt,
-		)
+		err := scanAsset(rows, &asset)
 		if err != nil {
 			continue
 		}
@@ -131,14 +130,25 @@ func (h *ExportHandler) ExportAssetsCSV(w http.ResponseWriter, r *http.Request)
 			asset.ID,
 			string(asset.Type),
 			asset.Name,
-			fmt.Sprintf("%.2f", asset.BuyPrice),
-			fmt.Sprintf("%.2f", asset.CurrentValue),
+			asset.BuyPrice.String(),
+			asset.CurrentValue.String(),
 			asset.Currency,
-			fmt.Sprintf("%.4f", asset.Quantity),
+			asset.Quantity.String(),
 			asset.PurchaseDate.Format("2006-01-02"),
 			string(asset.Source),
 			asset.CreatedAt.Format(time.RFC3339),
 			asset.UpdatedAt.Format(time.RFC3339),
+			asset.Provider,
+			asset.CostBasis.String(),
+			string(asset.CostBasisMethod),
+			asset.Symbol,
+			asset.Exchange,
+			string(asset.Kind),
+			optionalDecimal(asset.FaceValue),
+			optionalDecimal(asset.CleanPrice),
+			strconv.FormatFloat(asset.InterestRate, 'f', -1, 64),
+			strconv.Itoa(asset.CouponFrequency),
+			optionalDate(asset.MaturityDate),
 		}
 		writer.Write(row)
 	}
@@ -147,7 +157,7 @@ func (h *ExportHandler) ExportAssetsCSV(w http.ResponseWriter, r *http.Request)
 // ExportDebtsJSON handles GET /api/v1/export/debts/json
 func (h *ExportHandler) ExportDebtsJSON(w http.ResponseWriter, r *http.Request) {
 	query := `
-		SELECT id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at
+		SELECT ` + debtColumns + `
 		FROM debts
 		ORDER BY created_at DESC
 	`
@@ -162,12 +172,7 @@ func (h *ExportHandler) ExportDebtsJSON(w http.ResponseWriter, r *http.Request)
 	debts := []models.Debt{}
 	for rows.Next() {
 		var debt models.Debt
-		err := rows.Scan(
-			&debt.ID, &debt.Type, &debt.Name, &debt.Principal, &debt.CurrentValue,
-			&debt.Currency, &debt.InterestRate, &debt.StartDate,
-			&debt.CreatedAt, &debt.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanDebt(rows, &debt); err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Failed to parse debts")
 			return
 		}
@@ -184,7 +189,7 @@ func (h *ExportHandler) ExportDebtsJSON(w http.ResponseWriter, r *http.Request)
 // ExportDebtsCSV handles GET /api/v1/export/debts/csv
 func (h *ExportHandler) ExportDebtsCSV(w http.ResponseWriter, r *http.Request) {
 	query := `
-		SELECT id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at
+		SELECT ` + debtColumns + `
 		FROM debts
 		ORDER BY created_at DESC
 	`
@@ -204,18 +209,14 @@ func (h *ExportHandler) ExportDebtsCSV(w http.ResponseWriter, r *http.Request) {
 	defer writer.Flush()
 
 	// Write CSV header
-	header := []string{"ID", "Type", "Name", "Principal", "Current Value", "Currency", "Interest Rate", "Start Date", "Created At", "Updated At"}
+	header := []string{"ID", "Type", "Name", "Principal", "Current Value", "Currency", "Interest Rate", "Start Date", "Created At", "Updated At",
+		"Term Months", "Payment Frequency", "Payment Amount", "Compounding", "Rate Type", "Interest Since"}
 	writer.Write(header)
 
 	// Write data rows
 	for rows.Next() {
 		var debt models.Debt
-		err := rows.Scan(
-			&debt.ID, &debt.Type, &debt.Name, &debt.Principal, &debt.CurrentValue,
-			&debt.Currency, &debt.InterestRate, &debt.StartDate,
-			&debt.CreatedAt, &debt.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanDebt(rows, &debt); err != nil {
 			continue
 		}
 
@@ -223,18 +224,232 @@ func (h *ExportHandler) ExportDebtsCSV(w http.ResponseWriter, r *http.Request) {
 			debt.ID,
 			string(debt.Type),
 			debt.Name,
-			fmt.Sprintf("%.2f", debt.Principal),
-			fmt.Sprintf("%.2f", debt.CurrentValue),
+			debt.Principal.String(),
+			debt.CurrentValue.String(),
 			debt.Currency,
 			fmt.Sprintf("%.2f", debt.InterestRate),
 			debt.StartDate.Format("2006-01-02"),
 			debt.CreatedAt.Format(time.RFC3339),
 			debt.UpdatedAt.Format(time.RFC3339),
+			optionalInt(debt.TermMonths),
+			string(debt.PaymentFrequency),
+			optionalDecimal(debt.PaymentAmount),
+			string(debt.Compounding),
+			string(debt.RateType),
+			optionalDate(debt.InterestSince),
 		}
 		writer.Write(row)
 	}
 }
 
+// ExportGainsCSV handles GET /api/v1/export/gains/csv?year=2024&currency=USD
+// It writes one Form 8949-style row per lot sold in the tax year, short-term
+// disposals first as on Part I of the form, then long-term as on Part II,
+// each followed by its subtotal and finally the overall total.
+func (h *ExportHandler) ExportGainsCSV(w http.ResponseWriter, r *http.Request) {
+	year, ok := taxYear(w, r)
+	if !ok {
+		return
+	}
+	converter, ok := requestConverter(w, r, h.fx)
+	if !ok {
+		return
+	}
+
+	report, err := h.ledger.RealizedGains(year, converter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to calculate realized gains: "+err.Error())
+		return
+	}
+
+	// Set headers for CSV download
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=capital_gains_%d.csv", year))
+
+	writeGainsCSV(w, report)
+}
+
+// writeGainsCSV writes a gains report as CSV: short-term disposals and their
+// subtotal, long-term disposals and their subtotal, then the year's total
+func writeGainsCSV(w io.Writer, report *models.GainsReport) {
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	// Write CSV header
+	header := []string{"Description", "Date Acquired", "Date Sold", "Proceeds", "Cost Basis", "Gain or Loss", "Term", "Currency"}
+	writer.Write(header)
+
+	var total [3]money.Decimal
+	for _, term := range []models.GainTerm{models.GainTermShort, models.GainTermLong} {
+		var subtotal [3]money.Decimal
+		for _, disposal := range report.Disposals {
+			if disposal.Term != term {
+				continue
+			}
+			writer.Write([]string{
+				disposal.Description,
+				disposal.DateAcquired.Format("01/02/2006"),
+				disposal.DateSold.Format("01/02/2006"),
+				disposal.Proceeds.String(),
+				disposal.CostBasis.String(),
+				disposal.Gain.String(),
+				string(disposal.Term),
+				report.Currency,
+			})
+			subtotal[0] = subtotal[0].Add(disposal.Proceeds)
+			subtotal[1] = subtotal[1].Add(disposal.CostBasis)
+			subtotal[2] = subtotal[2].Add(disposal.Gain)
+		}
+		writeGainsTotal(writer, fmt.Sprintf("Total %s-term", term), subtotal, report.Currency)
+		for i := range total {
+			total[i] = total[i].Add(subtotal[i])
+		}
+	}
+	writeGainsTotal(writer, fmt.Sprintf("Total %d", report.Year), total, report.Currency)
+}
+
+// writeGainsTotal writes a summary row of proceeds, cost basis and gain
+func writeGainsTotal(writer *csv.Writer, label string, amounts [3]money.Decimal, currency string) {
+	writer.Write([]string{
+		label, "", "",
+		money.RoundTo(amounts[0], currency).String(),
+		money.RoundTo(amounts[1], currency).String(),
+		money.RoundTo(amounts[2], currency).String(),
+		"",
+		currency,
+	})
+}
+
+// optionalDecimal formats a decimal that may be unset as a CSV cell
+func optionalDecimal(d *money.Decimal) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
+
+// optionalInt formats a count that is unset when zero as a CSV cell
+func optionalInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// optionalDate formats a date that may be unset as a CSV cell
+func optionalDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// parseInvestmentTerms reads the Kind, Face Value, Clean Price, Interest
+// Rate, Coupon Frequency and Maturity Date cells of an asset CSV row
+func parseInvestmentTerms(cells []string, asset *models.Asset) error {
+	asset.Kind = models.InvestmentKind(cells[0])
+	if cells[1] != "" {
+		faceValue, err := money.Parse(cells[1])
+		if err != nil {
+			return fmt.Errorf("invalid face value '%s'", cells[1])
+		}
+		asset.FaceValue = &faceValue
+	}
+	if cells[2] != "" {
+		cleanPrice, err := money.Parse(cells[2])
+		if err != nil {
+			return fmt.Errorf("invalid clean price '%s'", cells[2])
+		}
+		asset.CleanPrice = &cleanPrice
+	}
+	if cells[3] != "" {
+		rate, err := strconv.ParseFloat(cells[3], 64)
+		if err != nil {
+			return fmt.Errorf("invalid interest rate '%s'", cells[3])
+		}
+		asset.InterestRate = rate
+	}
+	if cells[4] != "" {
+		frequency, err := strconv.Atoi(cells[4])
+		if err != nil {
+			return fmt.Errorf("invalid coupon frequency '%s'", cells[4])
+		}
+		asset.CouponFrequency = frequency
+	}
+	if cells[5] != "" {
+		maturityDate, err := parseDate(cells[5])
+		if err != nil {
+			return fmt.Errorf("invalid maturity date '%s'", cells[5])
+		}
+		asset.MaturityDate = &maturityDate
+	}
+	return nil
+}
+
+// parseLoanTerms reads the Term Months, Payment Frequency and Payment Amount
+// cells of a debt CSV row
+func parseLoanTerms(cells []string, debt *models.Debt) error {
+	if cells[0] != "" {
+		termMonths, err := strconv.Atoi(cells[0])
+		if err != nil {
+			return fmt.Errorf("invalid term months '%s'", cells[0])
+		}
+		debt.TermMonths = termMonths
+	}
+	debt.PaymentFrequency = models.PaymentFrequency(cells[1])
+	if cells[2] != "" {
+		paymentAmount, err := money.Parse(cells[2])
+		if err != nil {
+			return fmt.Errorf("invalid payment amount '%s'", cells[2])
+		}
+		debt.PaymentAmount = &paymentAmount
+	}
+	return nil
+}
+
+// parseInterestTerms reads the Compounding, Rate Type and Interest Since
+// cells of a debt CSV row
+func parseInterestTerms(cells []string, debt *models.Debt) error {
+	debt.Compounding = models.Compounding(cells[0])
+	debt.RateType = models.RateType(cells[1])
+	if cells[2] != "" {
+		since, err := parseDate(cells[2])
+		if err != nil {
+			return fmt.Errorf("invalid interest since '%s'", cells[2])
+		}
+		debt.InterestSince = &since
+	}
+	return nil
+}
+
+// importAsset writes an imported asset together with the opening buy its
+// ledger starts from
+func (h *ExportHandler) importAsset(asset *models.Asset) error {
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Exports from before the symbol column named market_api stocks by their ticker
+	if asset.Symbol == "" && asset.Type == models.AssetTypeStock &&
+		asset.Source == models.AssetSourceMarketAPI && services.IsStockSymbol(asset.Name) {
+		asset.Symbol = strings.TrimSpace(asset.Name)
+	}
+
+	if err := services.ValidateInvestmentTerms(asset); err != nil {
+		return err
+	}
+
+	if err := insertAsset(tx, asset); err != nil {
+		return err
+	}
+	if err := services.InsertOpeningTransaction(tx, asset); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // ImportAssetsJSON handles POST /api/v1/import/assets/json
 func (h *ExportHandler) ImportAssetsJSON(w http.ResponseWriter, r *http.Request) {
 	var assets []models.Asset
@@ -271,19 +486,12 @@ func (h *ExportHandler) ImportAssetsJSON(w http.ResponseWriter, r *http.Request)
 			asset.UpdatedAt = time.Now()
 		}
 
-		// Import asset
-		query := `
-			INSERT INTO assets (id, type, name, buy_price, current_value, currency, quantity, purchase_date, source, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		`
-
-		_, err := h.db.DB.Exec(query,
-			asset.ID, asset.Type, asset.Name, asset.BuyPrice, asset.CurrentValue,
-			asset.Currency, asset.Quantity, asset.PurchaseDate, asset.Source,
-			asset.CreatedAt, asset.UpdatedAt,
-		)
+		if asset.CostBasis.IsZero() {
+			asset.CostBasis = asset.PurchaseCost()
+		}
 
-		if err != nil {
+		// Import asset
+		if err := h.importAsset(&asset); err != nil {
 			errors = append(errors, fmt.Sprintf("Failed to import %s: %v", asset.Name, err))
 			continue
 		}
@@ -342,19 +550,19 @@ func (h *ExportHandler) ImportAssetsCSV(w http.ResponseWriter, r *http.Request)
 		}
 
 		// Parse values
-		buyPrice, err := strconv.ParseFloat(record[3], 64)
+		buyPrice, err := money.Parse(record[3])
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Row %d: invalid buy price '%s'", i+2, record[3]))
 			continue
 		}
 
-		currentValue, err := strconv.ParseFloat(record[4], 64)
+		currentValue, err := money.Parse(record[4])
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Row %d: invalid current value '%s'", i+2, record[4]))
 			continue
 		}
 
-		quantity, err := strconv.ParseFloat(record[6], 64)
+		quantity, err := money.Parse(record[6])
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Row %d: invalid quantity '%s'", i+2, record[6]))
 			continue
@@ -383,19 +591,56 @@ func (h *ExportHandler) ImportAssetsCSV(w http.ResponseWriter, r *http.Request)
 			updatedAt = time.Now()
 		}
 
-		// Import asset
-		query := `
-			INSERT INTO assets (id, type, name, buy_price, current_value, currency, quantity, purchase_date, source, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		`
-
-		_, err = h.db.DB.Exec(query,
-			assetID, record[1], record[2], buyPrice, currentValue,
-			record[5], quantity, purchaseDate, record[8],
-			createdAt, updatedAt,
-		)
+		var provider string
+		if len(record) > 11 {
+			provider = record[11]
+		}
+
+		asset := models.Asset{
+			ID:           assetID,
+			Type:         models.AssetType(record[1]),
+			Name:         record[2],
+			BuyPrice:     buyPrice,
+			CurrentValue: currentValue,
+			Currency:     record[5],
+			Quantity:     quantity,
+			PurchaseDate: purchaseDate,
+			Source:       models.AssetSource(record[8]),
+			Provider:     provider,
+			CreatedAt:    createdAt,
+			UpdatedAt:    updatedAt,
+		}
+		asset.CostBasis = asset.PurchaseCost()
+		if len(record) > 12 && record[12] != "" {
+			costBasis, err := money.Parse(record[12])
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("Row %d: invalid cost basis '%s'", i+2, record[12]))
+				continue
+			}
+			asset.CostBasis = costBasis
+		}
+		if len(record) > 13 && record[13] != "" {
+			asset.CostBasisMethod = models.CostBasisMethod(record[13])
+			if !asset.CostBasisMethod.Valid() {
+				errors = append(errors, fmt.Sprintf("Row %d: invalid cost basis method '%s'", i+2, record[13]))
+				continue
+			}
+		}
+		if len(record) > 14 {
+			asset.Symbol = record[14]
+		}
+		if len(record) > 15 {
+			asset.Exchange = record[15]
+		}
+		if len(record) > 21 {
+			if err := parseInvestmentTerms(record[16:22], &asset); err != nil {
+				errors = append(errors, fmt.Sprintf("Row %d: %v", i+2, err))
+				continue
+			}
+		}
 
-		if err != nil {
+		// Import asset
+		if err := h.importAsset(&asset); err != nil {
 			errors = append(errors, fmt.Sprintf("Row %d: %v", i+2, err))
 			continue
 		}
@@ -447,19 +692,18 @@ func (h *ExportHandler) ImportDebtsJSON(w http.ResponseWriter, r *http.Request)
 			debt.UpdatedAt = time.Now()
 		}
 
-		// Import debt
-		query := `
-			INSERT INTO debts (id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		`
-
-		_, err := h.db.DB.Exec(query,
-			debt.ID, debt.Type, debt.Name, debt.Principal, debt.CurrentValue,
-			debt.Currency, debt.InterestRate, debt.StartDate,
-			debt.CreatedAt, debt.UpdatedAt,
-		)
+		if err := services.ValidateLoanTerms(&debt); err != nil {
+			errors = append(errors, fmt.Sprintf("Failed to import %s: %v", debt.Name, err))
+			continue
+		}
+		if err := services.ValidateInterestTerms(&debt); err != nil {
+			errors = append(errors, fmt.Sprintf("Failed to import %s: %v", debt.Name, err))
+			continue
+		}
+		accrueFromToday(&debt)
 
-		if err != nil {
+		// Import debt
+		if err := createDebt(h.db.DB, &debt); err != nil {
 			errors = append(errors, fmt.Sprintf("Failed to import %s: %v", debt.Name, err))
 			continue
 		}
@@ -518,13 +762,13 @@ func (h *ExportHandler) ImportDebtsCSV(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Parse values
-		principal, err := strconv.ParseFloat(record[3], 64)
+		principal, err := money.Parse(record[3])
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Row %d: invalid principal '%s'", i+2, record[3]))
 			continue
 		}
 
-		currentValue, err := strconv.ParseFloat(record[4], 64)
+		currentValue, err := money.Parse(record[4])
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Row %d: invalid current value '%s'", i+2, record[4]))
 			continue
@@ -559,19 +803,45 @@ func (h *ExportHandler) ImportDebtsCSV(w http.ResponseWriter, r *http.Request) {
 			updatedAt = time.Now()
 		}
 
-		// Import debt
-		query := `
-			INSERT INTO debts (id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		`
-
-		_, err = h.db.DB.Exec(query,
-			debtID, record[1], record[2], principal, currentValue,
-			record[5], interestRate, startDate,
-			createdAt, updatedAt,
-		)
+		debt := models.Debt{
+			ID:           debtID,
+			Type:         models.DebtType(record[1]),
+			Name:         record[2],
+			Principal:    principal,
+			CurrentValue: currentValue,
+			Currency:     record[5],
+			InterestRate: interestRate,
+			StartDate:    startDate,
+			CreatedAt:    createdAt,
+			UpdatedAt:    updatedAt,
+		}
+
+		// Loan terms are optional; exports from before them stop at Updated At
+		if len(record) > 12 {
+			if err := parseLoanTerms(record[10:13], &debt); err != nil {
+				errors = append(errors, fmt.Sprintf("Row %d: %v", i+2, err))
+				continue
+			}
+		}
+		// Interest terms follow; without them the balance accrues from today
+		if len(record) > 15 {
+			if err := parseInterestTerms(record[13:16], &debt); err != nil {
+				errors = append(errors, fmt.Sprintf("Row %d: %v", i+2, err))
+				continue
+			}
+		}
+		if err := services.ValidateLoanTerms(&debt); err != nil {
+			errors = append(errors, fmt.Sprintf("Row %d: %v", i+2, err))
+			continue
+		}
+		if err := services.ValidateInterestTerms(&debt); err != nil {
+			errors = append(errors, fmt.Sprintf("Row %d: %v", i+2, err))
+			continue
+		}
+		accrueFromToday(&debt)
 
-		if err != nil {
+		// Import debt
+		if err := createDebt(h.db.DB, &debt); err != nil {
 			errors = append(errors, fmt.Sprintf("Row %d: %v", i+2, err))
 			continue
 		}
@@ -587,10 +857,20 @@ func (h *ExportHandler) ImportDebtsCSV(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ExportAll handles GET /api/v1/export/all/json
+// ExportAll handles GET /api/v1/export/all
+// The export is a full backup: "tables" holds every row of the user data
+// tables, which ImportAll restores. It also lists the assets and debts as
+// the API returns them and values every holding in the base currency (or
+// ?currency=): costs and principals at the rate of their purchase or start
+// date, current values at today's rate.
 func (h *ExportHandler) ExportAll(w http.ResponseWriter, r *http.Request) {
+	converter, ok := requestConverter(w, r, h.fx)
+	if !ok {
+		return
+	}
+
 	// Fetch all assets
-	assetsQuery := `SELECT id, type, name, buy_price, current_value, currency, quantity, purchase_date, source, created_at, updated_at FROM assets ORDER BY created_at DESC`
+	assetsQuery := `SELECT ` + assetColumns + ` FROM assets ORDER BY created_at DESC`
 	assetsRows, err := h.db.DB.Query(assetsQuery)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to fetch assets")
@@ -601,14 +881,12 @@ func (h *ExportHandler) ExportAll(w http.ResponseWriter, r *http.Request) {
 	assets := []models.Asset{}
 	for assetsRows.Next() {
 		var asset models.Asset
-		assetsRows.Scan(&asset.ID, &asset.Type, &asset.Name, &asset.BuyPrice, &asset.CurrentValue,
-			&asset.Currency, &asset.Quantity, &asset.PurchaseDate, &asset.Source,
-			&asset.CreatedAt, &asset.UpdatedAt)
+		scanAsset(assetsRows, &asset)
 		assets = append(assets, asset)
 	}
 
 	// Fetch all debts
-	debtsQuery := `SELECT id, type, name, principal, current_value, currency, interest_rate, start_date, created_at, updated_at FROM debts ORDER BY created_at DESC`
+	debtsQuery := `SELECT ` + debtColumns + ` FROM debts ORDER BY created_at DESC`
 	debtsRows, err := h.db.DB.Query(debtsQuery)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to fetch debts")
@@ -619,17 +897,30 @@ func (h *ExportHandler) ExportAll(w http.ResponseWriter, r *http.Request) {
 	debts := []models.Debt{}
 	for debtsRows.Next() {
 		var debt models.Debt
-		debtsRows.Scan(&debt.ID, &debt.Type, &debt.Name, &debt.Principal, &debt.CurrentValue,
-			&debt.Currency, &debt.InterestRate, &debt.StartDate,
-			&debt.CreatedAt, &debt.UpdatedAt)
+		scanDebt(debtsRows, &debt)
 		debts = append(debts, debt)
 	}
 
+	tables, err := services.BackupTables(h.db.DB)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to back up data")
+		return
+	}
+
 	exportData := map[string]interface{}{
 		"assets":      assets,
 		"debts":       debts,
+		"tables":      tables,
 		"exported_at": time.Now(),
-		"version":     "1.0",
+		"version":     "2.0",
+	}
+
+	// A missing exchange rate must not prevent a backup, so report it instead
+	valuation, err := exportValuation(assets, debts, converter)
+	if err != nil {
+		exportData["valuation_error"] = err.Error()
+	} else {
+		exportData["valuation"] = valuation
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -637,3 +928,55 @@ func (h *ExportHandler) ExportAll(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(exportData)
 }
+
+// ImportAll handles POST /api/v1/import/all
+// It restores the "tables" of a backup made by ExportAll in one transaction,
+// skipping rows that already exist.
+func (h *ExportHandler) ImportAll(w http.ResponseWriter, r *http.Request) {
+	var backup struct {
+		Tables map[string]json.RawMessage `json:"tables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&backup); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if len(backup.Tables) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Backup has no tables (export it with GET /api/v1/export/all)")
+		return
+	}
+
+	result, err := services.RestoreTables(h.db.DB, backup.Tables)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to restore backup: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// exportValuation converts every asset and debt into the converter's currency
+func exportValuation(assets []models.Asset, debts []models.Debt, converter *services.Converter) (map[string]interface{}, error) {
+	assetValuations := make([]models.AssetValuation, 0, len(assets))
+	for i := range assets {
+		valuation, err := converter.ValueAsset(&assets[i])
+		if err != nil {
+			return nil, err
+		}
+		assetValuations = append(assetValuations, *valuation)
+	}
+
+	debtValuations := make([]models.DebtValuation, 0, len(debts))
+	for i := range debts {
+		valuation, err := converter.ValueDebt(&debts[i])
+		if err != nil {
+			return nil, err
+		}
+		debtValuations = append(debtValuations, *valuation)
+	}
+
+	return map[string]interface{}{
+		"currency": converter.Target(),
+		"assets":   assetValuations,
+		"debts":    debtValuations,
+	}, nil
+}
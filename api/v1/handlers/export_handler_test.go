@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func TestWriteGainsCSV(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	disposal := func(description, acquired, sold, proceeds, cost, gain string, term models.GainTerm) models.Disposal {
+		return models.Disposal{
+			Description:  description,
+			DateAcquired: date(acquired),
+			DateSold:     date(sold),
+			Proceeds:     money.MustParse(proceeds),
+			CostBasis:    money.MustParse(cost),
+			Gain:         money.MustParse(gain),
+			Term:         term,
+			Currency:     "USD",
+		}
+	}
+
+	// Disposals come sorted by sale date, terms mixed
+	report := &models.GainsReport{
+		Year:     2024,
+		Currency: "USD",
+		Disposals: []models.Disposal{
+			disposal("4 Apple", "2023-03-15", "2024-03-15", "600.00", "400.00", "200.00", models.GainTermShort),
+			disposal("4 Apple", "2023-03-15", "2024-03-16", "600.00", "400.00", "200.00", models.GainTermLong),
+			disposal("3 Tesla", "2024-01-02", "2024-05-01", "450.10", "600.25", "-150.15", models.GainTermShort),
+			disposal("10 SAP", "2022-01-10", "2024-06-03", "1296.00", "1130.00", "166.00", models.GainTermLong),
+		},
+	}
+
+	var out strings.Builder
+	writeGainsCSV(&out, report)
+
+	want := strings.Join([]string{
+		"Description,Date Acquired,Date Sold,Proceeds,Cost Basis,Gain or Loss,Term,Currency",
+		"4 Apple,03/15/2023,03/15/2024,600.00,400.00,200.00,short,USD",
+		"3 Tesla,01/02/2024,05/01/2024,450.10,600.25,-150.15,short,USD",
+		"Total short-term,,,1050.10,1000.25,49.85,,USD",
+		"4 Apple,03/15/2023,03/16/2024,600.00,400.00,200.00,long,USD",
+		"10 SAP,01/10/2022,06/03/2024,1296.00,1130.00,166.00,long,USD",
+		"Total long-term,,,1896.00,1530.00,366.00,,USD",
+		"Total 2024,,,2946.10,2530.25,415.85,,USD",
+		"",
+	}, "\n")
+	if out.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestWriteGainsCSVWithoutDisposals(t *testing.T) {
+	var out strings.Builder
+	writeGainsCSV(&out, &models.GainsReport{Year: 2024, Currency: "JPY", Disposals: []models.Disposal{}})
+
+	want := strings.Join([]string{
+		"Description,Date Acquired,Date Sold,Proceeds,Cost Basis,Gain or Loss,Term,Currency",
+		"Total short-term,,,0,0,0,,JPY",
+		"Total long-term,,,0,0,0,,JPY",
+		"Total 2024,,,0,0,0,,JPY",
+		"",
+	}, "\n")
+	if out.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", out.String(), want)
+	}
+}
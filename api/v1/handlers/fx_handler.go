@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"personal-finance/api/v1/services"
+)
+
+// FXHandler handles exchange rate requests
+type FXHandler struct {
+	fx *services.FXService
+}
+
+// NewFXHandler creates a new FX handler
+func NewFXHandler(fxService *services.FXService) *FXHandler {
+	return &FXHandler{fx: fxService}
+}
+
+// GetRate handles GET /api/v1/fx/rate?base=EUR&quote=USD&date=2024-01-02
+func (h *FXHandler) GetRate(w http.ResponseWriter, r *http.Request) {
+	base, quote, ok := h.pair(w, r)
+	if !ok {
+		return
+	}
+
+	date := time.Now()
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)")
+			return
+		}
+		date = parsed
+	}
+
+	rate, err := h.fx.GetRateOn(base, quote, date)
+	if errors.Is(err, services.ErrRateUnavailable) {
+		respondWithError(w, http.StatusNotFound, err.Error()+" (backfill it with POST /api/v1/fx/rates/backfill)")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Failed to fetch rate: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, services.FXRate{Base: base, Quote: quote, Date: date, Rate: rate})
+}
+
+// ListRates handles GET /api/v1/fx/rates?base=EUR&quote=USD&from=&to=
+func (h *FXHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	base, quote, ok := h.pair(w, r)
+	if !ok {
+		return
+	}
+	from, to, ok := dateRange(w, r)
+	if !ok {
+		return
+	}
+
+	rates, err := h.fx.ListRates(base, quote, from, to)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch rates")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, rates)
+}
+
+// BackfillRates handles POST /api/v1/fx/rates/backfill?base=EUR&quote=USD&from=&to=
+func (h *FXHandler) BackfillRates(w http.ResponseWriter, r *http.Request) {
+	base, quote, ok := h.pair(w, r)
+	if !ok {
+		return
+	}
+	if base == quote {
+		respondWithError(w, http.StatusBadRequest, "base and quote must differ")
+		return
+	}
+	from, to, ok := dateRange(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.fx.BackfillRates(base, quote, from, to)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Failed to backfill rates: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// ImportRates handles POST /api/v1/fx/rates/import with an ECB-style or
+// date,base,quote,rate CSV body
+func (h *FXHandler) ImportRates(w http.ResponseWriter, r *http.Request) {
+	result, err := h.fx.ImportRatesCSV(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to import rates: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// pair reads ?base= and ?quote= (which defaults to the base currency)
+func (h *FXHandler) pair(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	base := services.NormalizeCurrency(r.URL.Query().Get("base"))
+	if base == "" {
+		respondWithError(w, http.StatusBadRequest, "base is required (use a 3-letter code such as EUR)")
+		return "", "", false
+	}
+
+	quote := h.fx.BaseCurrency()
+	if value := r.URL.Query().Get("quote"); value != "" {
+		if quote = services.NormalizeCurrency(value); quote == "" {
+			respondWithError(w, http.StatusBadRequest, "Invalid quote currency (use a 3-letter code such as USD)")
+			return "", "", false
+		}
+	}
+	return base, quote, true
+}
+
+// dateRange reads ?from= and ?to=, defaulting to the year up to today
+func dateRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	to := time.Now()
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid to format (use YYYY-MM-DD)")
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(-1, 0, 0)
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid from format (use YYYY-MM-DD)")
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	return from, to, true
+}
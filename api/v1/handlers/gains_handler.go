@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"personal-finance/api/v1/services"
+)
+
+// GainsHandler handles realized gains reporting
+type GainsHandler struct {
+	ledger *services.LedgerService
+	fx     *services.FXService
+}
+
+// NewGainsHandler creates a new gains handler
+func NewGainsHandler(ledgerService *services.LedgerService, fxService *services.FXService) *GainsHandler {
+	return &GainsHandler{ledger: ledgerService, fx: fxService}
+}
+
+// GetGains handles GET /api/v1/gains?year=2024&currency=USD
+func (h *GainsHandler) GetGains(w http.ResponseWriter, r *http.Request) {
+	year, ok := taxYear(w, r)
+	if !ok {
+		return
+	}
+	converter, ok := requestConverter(w, r, h.fx)
+	if !ok {
+		return
+	}
+
+	report, err := h.ledger.RealizedGains(year, converter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to calculate realized gains: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// taxYear reads ?year=, defaulting to the current year
+func taxYear(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("year")
+	if value == "" {
+		return time.Now().Year(), true
+	}
+
+	year, err := strconv.Atoi(value)
+	if err != nil || year < 1900 || year > 9999 {
+		respondWithError(w, http.StatusBadRequest, "Invalid year (use YYYY)")
+		return 0, false
+	}
+	return year, true
+}
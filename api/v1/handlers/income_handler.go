@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/services"
+)
+
+// IncomeHandler handles dividend, interest and rent income requests
+type IncomeHandler struct {
+	income *services.IncomeService
+	fx     *services.FXService
+}
+
+// NewIncomeHandler creates a new income handler
+func NewIncomeHandler(incomeService *services.IncomeService, fxService *services.FXService) *IncomeHandler {
+	return &IncomeHandler{income: incomeService, fx: fxService}
+}
+
+// GetIncome handles GET /api/v1/income?from=&to=&group_by=month|asset&currency=
+func (h *IncomeHandler) GetIncome(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := dateRange(w, r)
+	if !ok {
+		return
+	}
+
+	groupBy := models.IncomeGroupBy(r.URL.Query().Get("group_by"))
+	switch groupBy {
+	case "":
+		groupBy = models.IncomeByMonth
+	case models.IncomeByMonth, models.IncomeByAsset:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid group_by (use month or asset)")
+		return
+	}
+
+	converter, ok := requestConverter(w, r, h.fx)
+	if !ok {
+		return
+	}
+
+	report, err := h.income.Report(from, to, groupBy, converter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build income report: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// SyncDividends handles POST /api/v1/assets/{id}/income/sync
+func (h *IncomeHandler) SyncDividends(w http.ResponseWriter, r *http.Request) {
+	result, err := h.income.SyncDividends(chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, services.ErrAssetNotFound):
+		respondWithError(w, http.StatusNotFound, "Asset not found")
+		return
+	case errors.Is(err, services.ErrNotMarketPriced):
+		respondWithError(w, http.StatusBadRequest, "Only market_api stock assets have provider dividends")
+		return
+	case err != nil:
+		respondWithError(w, http.StatusBadGateway, "Failed to sync dividends: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"personal-finance/api/v1/services"
+)
+
+// MarketDataHandler handles market data administration requests
+type MarketDataHandler struct {
+	marketData *services.MarketDataService
+}
+
+// NewMarketDataHandler creates a new market data handler
+func NewMarketDataHandler(marketDataService *services.MarketDataService) *MarketDataHandler {
+	return &MarketDataHandler{marketData: marketDataService}
+}
+
+// GetProviders handles GET /api/v1/market-data/providers
+func (h *MarketDataHandler) GetProviders(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"default":    h.marketData.DefaultProvider(),
+		"chain":      h.marketData.Chain(""),
+		"registered": services.RegisteredProviders(),
+		"breakers":   h.marketData.BreakerStatuses(),
+	})
+}
+
+// SearchSymbols handles GET /api/v1/symbols/search?q=&provider=
+func (h *MarketDataHandler) SearchSymbols(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing search query (q)")
+		return
+	}
+
+	provider := r.URL.Query().Get("provider")
+	if provider != "" && !services.IsRegisteredProvider(provider) {
+		respondWithError(w, http.StatusBadRequest, "Unknown market data provider")
+		return
+	}
+
+	matches, providerName, err := h.marketData.SearchSymbols(provider, query)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Failed to search symbols: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"query":    query,
+		"provider": providerName,
+		"results":  matches,
+	})
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
 	"time"
 
 	"personal-finance/api/v1/db"
 	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
 	"personal-finance/api/v1/services"
 )
 
@@ -13,28 +15,47 @@ import (
 type SummaryHandler struct {
 	db         *db.PostgresDB
 	marketData *services.MarketDataService
+	snapshots  *services.SnapshotService
+	fx         *services.FXService
+	income     *services.IncomeService
 }
 
 // NewSummaryHandler creates a new summary handler
-func NewSummaryHandler(database *db.PostgresDB, marketDataService *services.MarketDataService) *SummaryHandler {
+func NewSummaryHandler(database *db.PostgresDB, marketDataService *services.MarketDataService, snapshotService *services.SnapshotService, fxService *services.FXService, incomeService *services.IncomeService) *SummaryHandler {
 	return &SummaryHandler{
 		db:         database,
 		marketData: marketDataService,
+		snapshots:  snapshotService,
+		fx:         fxService,
+		income:     incomeService,
 	}
 }
 
 // GetNetWorth handles GET /api/v1/networth
 func (h *SummaryHandler) GetNetWorth(w http.ResponseWriter, r *http.Request) {
+	converter, ok := requestConverter(w, r, h.fx)
+	if !ok {
+		return
+	}
+
 	// Fetch all assets with real-time prices
-	totalAssets := h.calculateTotalAssetsWithMarketData()
+	assets, err := h.loadAssetsWithMarketData()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch assets")
+		return
+	}
+	var totalAssets money.Decimal
+	for i := range assets {
+		value, err := converter.Convert(assets[i].TotalValue(), assets[i].Currency)
+		if err != nil {
+			respondWithError(w, http.StatusBadGateway, "Failed to convert assets: "+err.Error())
+			return
+		}
+		totalAssets = totalAssets.Add(value)
+	}
 
 	// Calculate total debts
-	var totalDebts float64
-	debtQuery := `
-		SELECT COALESCE(SUM(current_value), 0)
-		FROM debts
-	`
-	err := h.db.DB.QueryRow(debtQuery).Scan(&totalDebts)
+	totalDebts, err := h.totalDebts(converter)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to calculate total debts")
 		return
@@ -43,121 +64,227 @@ func (h *SummaryHandler) GetNetWorth(w http.ResponseWriter, r *http.Request) {
 	netWorth := models.NetWorth{
 		TotalAssets:  totalAssets,
 		TotalDebts:   totalDebts,
-		NetWorth:     totalAssets - totalDebts,
-		Currency:     "USD",
+		NetWorth:     totalAssets.Sub(totalDebts),
+		Currency:     converter.Target(),
+		Rates:        converter.Rates(),
 		CalculatedAt: time.Now(),
 	}
 
 	respondWithJSON(w, http.StatusOK, netWorth)
 }
 
-// calculateTotalAssetsWithMarketData fetches real-time prices for stocks and calculates total
-func (h *SummaryHandler) calculateTotalAssetsWithMarketData() float64 {
-	query := `
-		SELECT id, type, name, buy_price, current_value, quantity, source
-		FROM assets
+// totalDebts sums every debt balance, with the interest stored by the
+// accrual job, in the converter's currency
+func (h *SummaryHandler) totalDebts(converter *services.Converter) (money.Decimal, error) {
+	debtQuery := `
+		SELECT currency, COALESCE(SUM(current_value + COALESCE(accrued_interest, 0)), 0)
+		FROM debts
+		GROUP BY currency
 	`
-
-	rows, err := h.db.DB.Query(query)
+	rows, err := h.db.DB.Query(debtQuery)
 	if err != nil {
-		return 0
+		return money.Zero, err
 	}
 	defer rows.Close()
 
-	var total float64
+	var total money.Decimal
 	for rows.Next() {
-		var id, assetType, name, source string
-		var buyPrice, currentValue, quantity float64
-
-		err := rows.Scan(&id, &assetType, &name, &buyPrice, &currentValue, &quantity, &source)
+		var currency sql.NullString
+		var amount money.Decimal
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return money.Zero, err
+		}
+		converted, err := converter.Convert(amount, currency.String)
 		if err != nil {
-			continue
+			return money.Zero, err
 		}
+		total = total.Add(converted)
+	}
+	return total, nil
+}
+
+// GetNetWorthHistory handles GET /api/v1/networth/history
+func (h *SummaryHandler) GetNetWorthHistory(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := dateRange(w, r)
+	if !ok {
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval != "" && interval != "day" && interval != "week" && interval != "month" {
+		respondWithError(w, http.StatusBadRequest, "Invalid interval (use day, week or month)")
+		return
+	}
+
+	converter, ok := requestConverter(w, r, h.fx)
+	if !ok {
+		return
+	}
 
-		// Get real-time price for stocks
-		if assetType == "stock" && source == "market_api" {
-			price, err := h.marketData.GetCurrentValue(assetType, name, currentValue, source)
-			if err == nil {
-				currentValue = price
-			}
+	snapshots, err := h.snapshots.ListSnapshots(from, to, interval)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch net worth history")
+		return
+	}
+
+	// Snapshots are stored in the base currency of the day they were taken
+	for i := range snapshots {
+		if err := services.ConvertSnapshot(&snapshots[i], converter); err != nil {
+			respondWithError(w, http.StatusBadGateway, "Failed to convert net worth history: "+err.Error())
+			return
 		}
+	}
 
-		total += currentValue * quantity
+	respondWithJSON(w, http.StatusOK, snapshots)
+}
+
+// CreateNetWorthSnapshot handles POST /api/v1/networth/snapshots
+func (h *SummaryHandler) CreateNetWorthSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.snapshots.TakeSnapshot()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create net worth snapshot")
+		return
 	}
 
-	return total
+	respondWithJSON(w, http.StatusCreated, snapshot)
 }
 
-// calculateTotalProfitLossWithMarketData calculates profit/loss with real-time prices
-func (h *SummaryHandler) calculateTotalProfitLossWithMarketData() float64 {
-	query := `
-		SELECT type, name, buy_price, current_value, quantity, source
-		FROM assets
-	`
+// loadAssetsWithMarketData loads every asset and applies real-time prices in one batch
+func (h *SummaryHandler) loadAssetsWithMarketData() ([]models.Asset, error) {
+	query := `SELECT ` + assetColumns + ` FROM assets`
 
 	rows, err := h.db.DB.Query(query)
 	if err != nil {
-		return 0
+		return nil, err
 	}
 	defer rows.Close()
 
-	var totalProfitLoss float64
+	assets := []models.Asset{}
 	for rows.Next() {
-		var assetType, name, source string
-		var buyPrice, currentValue, quantity float64
-
-		err := rows.Scan(&assetType, &name, &buyPrice, &currentValue, &quantity, &source)
-		if err != nil {
+		var asset models.Asset
+		if err := scanAsset(rows, &asset); err != nil {
 			continue
 		}
+		assets = append(assets, asset)
+	}
 
-		// Get real-time price for stocks
-		if assetType == "stock" && source == "market_api" {
-			price, err := h.marketData.GetCurrentValue(assetType, name, currentValue, source)
-			if err == nil {
-				currentValue = price
-			}
-		}
+	h.marketData.ApplyMarketPrices(assets)
+	services.ApplyFixedIncomeValues(assets, time.Now())
+	return assets, nil
+}
 
-		totalProfitLoss += (currentValue - buyPrice) * quantity
+// totalAssetValues returns the total value of the assets in the converter's
+// currency and their profit/loss since purchase split into price and FX gains
+func totalAssetValues(assets []models.Asset, converter *services.Converter) (money.Decimal, models.GainBreakdown, error) {
+	var total money.Decimal
+	var gains models.GainBreakdown
+	for i := range assets {
+		valuation, err := converter.ValueAsset(&assets[i])
+		if err != nil {
+			return money.Zero, models.GainBreakdown{}, err
+		}
+		total = total.Add(valuation.Value)
+		gains.Add(valuation.GainBreakdown)
 	}
+	return total, gains, nil
+}
 
-	return totalProfitLoss
+// totalRealizedGains returns the gains realized by past sales of the assets,
+// converted at today's rate
+func totalRealizedGains(assets []models.Asset, converter *services.Converter) (money.Decimal, error) {
+	total := money.Zero
+	for _, asset := range assets {
+		gain, err := converter.Convert(asset.RealizedGain, asset.Currency)
+		if err != nil {
+			return money.Zero, err
+		}
+		total = total.Add(gain)
+	}
+	return total, nil
 }
 
 // GetSummary handles GET /api/v1/summary
 func (h *SummaryHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
-	// Calculate total assets with real-time prices
-	totalAssets := h.calculateTotalAssetsWithMarketData()
+	converter, ok := requestConverter(w, r, h.fx)
+	if !ok {
+		return
+	}
+
+	// Calculate total assets and profit/loss with real-time prices
+	assets, err := h.loadAssetsWithMarketData()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch assets")
+		return
+	}
+	totalAssets, totalGains, err := totalAssetValues(assets, converter)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Failed to convert assets: "+err.Error())
+		return
+	}
 
 	// Calculate total debts
-	var totalDebts float64
-	debtQuery := `
-		SELECT COALESCE(SUM(current_value), 0)
-		FROM debts
-	`
-	err := h.db.DB.QueryRow(debtQuery).Scan(&totalDebts)
+	totalDebts, err := h.totalDebts(converter)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to calculate total debts")
 		return
 	}
 
-	// Calculate total profit/loss with real-time prices
-	totalProfitLoss := h.calculateTotalProfitLossWithMarketData()
+	// Daily profit/loss: today's net worth against the previous trading day's close
+	now := time.Now()
+	dailyGains, err := services.ProfitLossSince(h.db.DB, assets, now.AddDate(0, 0, -1), converter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to calculate daily profit/loss")
+		return
+	}
 
-	// For daily profit/loss, we would need yesterday's data
-	// For MVP, we'll set it to 0 or calculate from history if available
-	var dailyProfitLoss float64 = 0.0
+	// Total return adds what holding the assets already paid out: gains
+	// realized by sales and dividend, interest and rent income
+	realizedGain, err := totalRealizedGains(assets, converter)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Failed to convert realized gains: "+err.Error())
+		return
+	}
+	income, err := h.income.TotalIncome(converter)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Failed to calculate income: "+err.Error())
+		return
+	}
 
 	summary := models.Summary{
-		Date:            time.Now(),
+		Date:            now,
 		TotalAssets:     totalAssets,
 		TotalDebts:      totalDebts,
-		NetWorth:        totalAssets - totalDebts,
-		DailyProfitLoss: dailyProfitLoss,
-		TotalProfitLoss: totalProfitLoss,
-		Currency:        "USD",
+		NetWorth:        totalAssets.Sub(totalDebts),
+		DailyProfitLoss: dailyGains.Total(),
+		TotalProfitLoss: totalGains.Total(),
+		Currency:        converter.Target(),
+		DailyBreakdown:  dailyGains,
+		TotalBreakdown:  totalGains,
+		RealizedGain:    realizedGain,
+		Income:          income,
+		TotalReturn:     totalGains.Total().Add(realizedGain).Add(income),
+	}
+
+	if period := r.URL.Query().Get("period"); period != "" {
+		start, err := services.PeriodStart(period, now)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		periodGains, err := services.ProfitLossSince(h.db.DB, assets, start, converter)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to calculate period profit/loss")
+			return
+		}
+		periodProfitLoss := periodGains.Total()
+
+		summary.Period = period
+		summary.PeriodStart = &start
+		summary.PeriodProfitLoss = &periodProfitLoss
+		summary.PeriodBreakdown = &periodGains
 	}
 
+	summary.Rates = converter.Rates()
 	respondWithJSON(w, http.StatusOK, summary)
 }
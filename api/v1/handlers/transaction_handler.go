@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/services"
+)
+
+// TransactionHandler handles asset ledger requests
+type TransactionHandler struct {
+	ledger *services.LedgerService
+}
+
+// NewTransactionHandler creates a new transaction handler
+func NewTransactionHandler(ledgerService *services.LedgerService) *TransactionHandler {
+	return &TransactionHandler{ledger: ledgerService}
+}
+
+// ListTransactions handles GET /api/v1/assets/{id}/transactions
+func (h *TransactionHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	transactions, err := h.ledger.ListTransactions(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithLedgerError(w, err, "Failed to fetch transactions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, transactions)
+}
+
+// GetTransaction handles GET /api/v1/assets/{id}/transactions/{txID}
+func (h *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	transaction, err := h.ledger.GetTransaction(chi.URLParam(r, "id"), chi.URLParam(r, "txID"))
+	if err != nil {
+		respondWithLedgerError(w, err, "Failed to fetch transaction")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, transaction)
+}
+
+// CreateTransaction handles POST /api/v1/assets/{id}/transactions
+func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)")
+		return
+	}
+
+	transaction := models.Transaction{
+		Type:     req.Type,
+		Date:     date,
+		Quantity: req.Quantity,
+		Price:    req.Price,
+		Amount:   req.Amount,
+		Fee:      req.Fee,
+		Ratio:    req.Ratio,
+		LotID:    req.LotID,
+		Notes:    req.Notes,
+	}
+	if err := h.ledger.CreateTransaction(chi.URLParam(r, "id"), &transaction); err != nil {
+		respondWithLedgerError(w, err, "Failed to create transaction")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, transaction)
+}
+
+// UpdateTransaction handles PUT /api/v1/assets/{id}/transactions/{txID}
+func (h *TransactionHandler) UpdateTransaction(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	transaction, err := h.ledger.GetTransaction(chi.URLParam(r, "id"), chi.URLParam(r, "txID"))
+	if err != nil {
+		respondWithLedgerError(w, err, "Failed to fetch transaction")
+		return
+	}
+
+	if req.Type != nil {
+		transaction.Type = *req.Type
+	}
+	if req.Date != nil {
+		date, err := time.Parse("2006-01-02", *req.Date)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid date format (use YYYY-MM-DD)")
+			return
+		}
+		transaction.Date = date
+	}
+	if req.Quantity != nil {
+		transaction.Quantity = *req.Quantity
+	}
+	if req.Price != nil {
+		transaction.Price = *req.Price
+	}
+	if req.Amount != nil {
+		transaction.Amount = *req.Amount
+	}
+	if req.Fee != nil {
+		transaction.Fee = *req.Fee
+	}
+	if req.Ratio != nil {
+		transaction.Ratio = *req.Ratio
+	}
+	if req.LotID != nil {
+		transaction.LotID = *req.LotID
+	}
+	if req.Notes != nil {
+		transaction.Notes = *req.Notes
+	}
+
+	if err := h.ledger.UpdateTransaction(transaction); err != nil {
+		respondWithLedgerError(w, err, "Failed to update transaction")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, transaction)
+}
+
+// DeleteTransaction handles DELETE /api/v1/assets/{id}/transactions/{txID}
+func (h *TransactionHandler) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
+	if err := h.ledger.DeleteTransaction(chi.URLParam(r, "id"), chi.URLParam(r, "txID")); err != nil {
+		respondWithLedgerError(w, err, "Failed to delete transaction")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Transaction deleted successfully"})
+}
+
+// GetPosition handles GET /api/v1/assets/{id}/position
+func (h *TransactionHandler) GetPosition(w http.ResponseWriter, r *http.Request) {
+	position, err := h.ledger.GetPosition(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithLedgerError(w, err, "Failed to build position")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, position)
+}
+
+// respondWithLedgerError maps ledger errors to status codes
+func respondWithLedgerError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrAssetNotFound):
+		respondWithError(w, http.StatusNotFound, "Asset not found")
+	case errors.Is(err, services.ErrTransactionNotFound):
+		respondWithError(w, http.StatusNotFound, "Transaction not found")
+	case errors.Is(err, services.ErrInvalidTransaction):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, message)
+	}
+}
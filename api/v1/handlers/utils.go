@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+
+	"personal-finance/api/v1/services"
 )
 
 // respondWithError sends an error response
@@ -23,3 +25,15 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+// requestConverter builds the currency converter for a request from
+// ?currency=, defaulting to the base currency. It writes a 400 response and
+// returns false if the currency is invalid.
+func requestConverter(w http.ResponseWriter, r *http.Request, fx *services.FXService) (*services.Converter, bool) {
+	currency := r.URL.Query().Get("currency")
+	if currency != "" && services.NormalizeCurrency(currency) == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid currency (use a 3-letter code such as EUR)")
+		return nil, false
+	}
+	return fx.NewConverter(currency), true
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"personal-finance/api/v1/money"
 )
 
 // AssetType represents the type of asset
@@ -13,8 +15,32 @@ const (
 	AssetTypeCar        AssetType = "car"
 	AssetTypeCash       AssetType = "cash"
 	AssetTypeInvestment AssetType = "investment"
+	AssetTypeCrypto     AssetType = "crypto"
+)
+
+// InvestmentKind refines the investment asset type by how the holding is valued
+type InvestmentKind string
+
+const (
+	// InvestmentKindFund is a mutual fund or ETF priced once a day at its NAV
+	// or closing price
+	InvestmentKindFund InvestmentKind = "fund"
+	// InvestmentKindBond is valued at its clean price, a percentage of face
+	// value, plus the coupon interest accrued since the last payment
+	InvestmentKindBond InvestmentKind = "bond"
+	// InvestmentKindCD is a certificate of deposit growing at its APY until maturity
+	InvestmentKindCD InvestmentKind = "cd"
 )
 
+// Valid reports whether k is a known investment kind
+func (k InvestmentKind) Valid() bool {
+	switch k {
+	case InvestmentKindFund, InvestmentKindBond, InvestmentKindCD:
+		return true
+	}
+	return false
+}
+
 // AssetSource represents the data source
 type AssetSource string
 
@@ -25,54 +51,127 @@ const (
 
 // Asset represents a financial asset
 type Asset struct {
-	ID           string      `json:"id"`
-	Type         AssetType   `json:"type"`
-	Name         string      `json:"name"`
-	BuyPrice     float64     `json:"buy_price"`
-	CurrentValue float64     `json:"current_value"`
-	Currency     string      `json:"currency"`
-	Quantity     float64     `json:"quantity"`
-	PurchaseDate time.Time   `json:"purchase_date"`
-	Source       AssetSource `json:"source"`
-	CreatedAt    time.Time   `json:"created_at"`
-	UpdatedAt    time.Time   `json:"updated_at"`
+	ID           string        `json:"id"`
+	Type         AssetType     `json:"type"`
+	Name         string        `json:"name"`
+	BuyPrice     money.Decimal `json:"buy_price"`
+	CurrentValue money.Decimal `json:"current_value"`
+	Currency     string        `json:"currency"`
+	Quantity     money.Decimal `json:"quantity"`
+	CostBasis    money.Decimal `json:"cost_basis"`
+	PurchaseDate time.Time     `json:"purchase_date"`
+	Source       AssetSource   `json:"source"`
+	Provider     string        `json:"provider,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+
+	// Symbol is the ticker a market-priced asset is quoted under, while Name
+	// is free text; Exchange and DisplayName come from the provider's lookup
+	Symbol      string `json:"symbol,omitempty"`
+	Exchange    string `json:"exchange,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+
+	// Kind refines investments. Bonds and CDs are valued from their terms
+	// rather than a quote: FaceValue is the par value of one bond and
+	// CleanPrice its quoted price in percent of par (98.75); InterestRate is
+	// the bond's annual coupon rate, paid CouponFrequency times a year, or
+	// the CD's APY, in percent.
+	Kind            InvestmentKind `json:"kind,omitempty"`
+	FaceValue       *money.Decimal `json:"face_value,omitempty"`
+	CleanPrice      *money.Decimal `json:"clean_price,omitempty"`
+	InterestRate    float64        `json:"interest_rate,omitempty"`
+	CouponFrequency int            `json:"coupon_frequency,omitempty"`
+	MaturityDate    *time.Time     `json:"maturity_date,omitempty"`
+	// AccruedInterest is the part of a bond's or CD's value that is interest
+	// not yet paid out, for the whole holding
+	AccruedInterest *money.Decimal `json:"accrued_interest,omitempty"`
+
+	// Lot selection for sales, the gain realized by them so far and the
+	// dividend, interest and rent income received
+	CostBasisMethod CostBasisMethod `json:"cost_basis_method"`
+	RealizedGain    money.Decimal   `json:"realized_gain"`
+	Income          money.Decimal   `json:"income"`
+
+	// Set when the current value was resolved through the market data service
+	PriceProvider string `json:"price_provider,omitempty"`
+	PriceStale    bool   `json:"price_stale,omitempty"`
 }
 
 // AssetHistory represents historical values of an asset
 type AssetHistory struct {
-	ID        string    `json:"id"`
-	AssetID   string    `json:"asset_id"`
-	Value     float64   `json:"value"`
-	Date      time.Time `json:"date"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        string        `json:"id"`
+	AssetID   string        `json:"asset_id"`
+	Value     money.Decimal `json:"value"`
+	Date      time.Time     `json:"date"`
+	CreatedAt time.Time     `json:"created_at"`
+
+	// Set when the value was converted into another currency at the rate of Date
+	Currency string         `json:"currency,omitempty"`
+	FXRate   *money.Decimal `json:"fx_rate,omitempty"`
+}
+
+// UnrealizedGain is the gain on the units still held: current value less
+// the cost basis of the open lots under the asset's cost basis method
+func (a *Asset) UnrealizedGain() money.Decimal {
+	return a.TotalValue().Sub(a.CostBasis)
+}
+
+// TotalValue returns the total current value, rounded to the currency's minor unit
+func (a *Asset) TotalValue() money.Decimal {
+	return money.RoundTo(a.CurrentValue.Mul(a.Quantity), a.Currency)
 }
 
-// ProfitLoss calculates the profit or loss for the asset
-func (a *Asset) ProfitLoss() float64 {
-	return (a.CurrentValue - a.BuyPrice) * a.Quantity
+// PurchaseCost returns buy price × quantity, rounded to the currency's minor
+// unit. It is the cost basis of a position opened by a single buy; once the
+// asset has a ledger, CostBasis is derived from its transactions instead.
+func (a *Asset) PurchaseCost() money.Decimal {
+	return money.RoundTo(a.BuyPrice.Mul(a.Quantity), a.Currency)
 }
 
-// TotalValue returns the total current value
-func (a *Asset) TotalValue() float64 {
-	return a.CurrentValue * a.Quantity
+// IsFixedIncome reports whether the asset is a bond or CD valued from its terms
+func (a *Asset) IsFixedIncome() bool {
+	return a.Type == AssetTypeInvestment && (a.Kind == InvestmentKindBond || a.Kind == InvestmentKindCD)
 }
 
 // CreateAssetRequest represents the request body for creating an asset
 type CreateAssetRequest struct {
-	Type         AssetType   `json:"type"`
-	Name         string      `json:"name"`
-	BuyPrice     float64     `json:"buy_price"`
-	CurrentValue *float64    `json:"current_value,omitempty"`
-	Currency     string      `json:"currency"`
-	Quantity     float64     `json:"quantity"`
-	PurchaseDate string      `json:"purchase_date"`
-	Source       AssetSource `json:"source"`
+	Type         AssetType      `json:"type"`
+	Name         string         `json:"name"`
+	Symbol       string         `json:"symbol,omitempty"`
+	BuyPrice     money.Decimal  `json:"buy_price"`
+	CurrentValue *money.Decimal `json:"current_value,omitempty"`
+	Currency     string         `json:"currency"`
+	Quantity     money.Decimal  `json:"quantity"`
+	PurchaseDate string         `json:"purchase_date"`
+	Source       AssetSource    `json:"source"`
+	Provider     string         `json:"provider,omitempty"`
+
+	CostBasisMethod CostBasisMethod `json:"cost_basis_method,omitempty"`
+
+	// Investment kind and bond or CD terms
+	Kind            InvestmentKind `json:"kind,omitempty"`
+	FaceValue       *money.Decimal `json:"face_value,omitempty"`
+	CleanPrice      *money.Decimal `json:"clean_price,omitempty"`
+	InterestRate    float64        `json:"interest_rate,omitempty"`
+	CouponFrequency int            `json:"coupon_frequency,omitempty"`
+	MaturityDate    string         `json:"maturity_date,omitempty"`
 }
 
 // UpdateAssetRequest represents the request body for updating an asset
 type UpdateAssetRequest struct {
-	Name         *string      `json:"name,omitempty"`
-	CurrentValue *float64     `json:"current_value,omitempty"`
-	Quantity     *float64     `json:"quantity,omitempty"`
-	Source       *AssetSource `json:"source,omitempty"`
+	Name         *string        `json:"name,omitempty"`
+	Symbol       *string        `json:"symbol,omitempty"`
+	CurrentValue *money.Decimal `json:"current_value,omitempty"`
+	Quantity     *money.Decimal `json:"quantity,omitempty"`
+	Source       *AssetSource   `json:"source,omitempty"`
+	Provider     *string        `json:"provider,omitempty"`
+
+	CostBasisMethod *CostBasisMethod `json:"cost_basis_method,omitempty"`
+
+	// Bond or CD terms; a new clean price revalues a bond
+	FaceValue       *money.Decimal `json:"face_value,omitempty"`
+	CleanPrice      *money.Decimal `json:"clean_price,omitempty"`
+	InterestRate    *float64       `json:"interest_rate,omitempty"`
+	CouponFrequency *int           `json:"coupon_frequency,omitempty"`
+	MaturityDate    *string        `json:"maturity_date,omitempty"`
 }
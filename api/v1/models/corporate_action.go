@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+// CorporateActionType represents an event that changes what one share of an
+// asset is, rather than whether it is held
+type CorporateActionType string
+
+const (
+	// CorporateActionSplit changes the number of shares without changing the
+	// position's value; a reverse split has fewer new shares than old ones
+	CorporateActionSplit CorporateActionType = "split"
+	// CorporateActionTickerChange renames the symbol the asset trades under
+	CorporateActionTickerChange CorporateActionType = "ticker_change"
+	// CorporateActionMerger exchanges the shares for shares of the acquirer
+	CorporateActionMerger CorporateActionType = "merger"
+)
+
+// Valid reports whether t is a known corporate action type
+func (t CorporateActionType) Valid() bool {
+	switch t {
+	case CorporateActionSplit, CorporateActionTickerChange, CorporateActionMerger:
+		return true
+	}
+	return false
+}
+
+// CorporateAction is a split, ticker change or merger applied to an asset.
+// Numerator new shares replace every Denominator old shares (4 and 1 for a
+// 4-for-1 split, 1 and 10 for a 1-for-10 reverse split).
+type CorporateAction struct {
+	ID          string              `json:"id"`
+	AssetID     string              `json:"asset_id"`
+	Type        CorporateActionType `json:"type"`
+	Date        time.Time           `json:"date"`
+	Numerator   money.Decimal       `json:"numerator"`
+	Denominator money.Decimal       `json:"denominator"`
+	OldSymbol   string              `json:"old_symbol,omitempty"`
+	NewSymbol   string              `json:"new_symbol,omitempty"`
+	// Source is "manual" or the market data provider the action came from
+	Source string `json:"source"`
+	// TransactionID is the split transaction recording the share conversion
+	TransactionID string    `json:"transaction_id,omitempty"`
+	Notes         string    `json:"notes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CreateCorporateActionRequest represents the request to record a corporate action
+type CreateCorporateActionRequest struct {
+	Type        CorporateActionType `json:"type"`
+	Date        string              `json:"date"`
+	Numerator   money.Decimal       `json:"numerator"`
+	Denominator money.Decimal       `json:"denominator"`
+	NewSymbol   string              `json:"new_symbol"`
+	Notes       string              `json:"notes"`
+}
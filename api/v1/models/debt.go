@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"personal-finance/api/v1/money"
 )
 
 // DebtType represents the type of debt
@@ -14,34 +16,386 @@ const (
 	DebtTypeOther      DebtType = "other"
 )
 
+// PaymentFrequency is how often a loan is paid
+type PaymentFrequency string
+
+const (
+	PaymentFrequencyMonthly  PaymentFrequency = "monthly"
+	PaymentFrequencyBiweekly PaymentFrequency = "biweekly"
+	PaymentFrequencyWeekly   PaymentFrequency = "weekly"
+)
+
+// PeriodsPerYear returns the number of payments a year, or 0 for an unknown frequency
+func (f PaymentFrequency) PeriodsPerYear() int {
+	switch f {
+	case PaymentFrequencyMonthly:
+		return 12
+	case PaymentFrequencyBiweekly:
+		return 26
+	case PaymentFrequencyWeekly:
+		return 52
+	}
+	return 0
+}
+
+// Compounding is how often accrued interest is added to a debt's balance
+type Compounding string
+
+const (
+	CompoundingDaily   Compounding = "daily"
+	CompoundingMonthly Compounding = "monthly"
+)
+
+// Valid reports whether the compounding basis is known
+func (c Compounding) Valid() bool {
+	return c == CompoundingDaily || c == CompoundingMonthly
+}
+
+// RateType says how a debt's interest rate is quoted
+type RateType string
+
+const (
+	// RateTypeAPR is a nominal annual rate, divided evenly over the periods
+	RateTypeAPR RateType = "apr"
+	// RateTypeAPY is the effective annual rate including compounding
+	RateTypeAPY RateType = "apy"
+)
+
+// Valid reports whether the rate type is known
+func (t RateType) Valid() bool {
+	return t == RateTypeAPR || t == RateTypeAPY
+}
+
 // Debt represents a financial debt
 type Debt struct {
-	ID           string    `json:"id"`
-	Type         DebtType  `json:"type"`
-	Name         string    `json:"name"`
-	Principal    float64   `json:"principal"`
-	CurrentValue float64   `json:"current_value"`
-	Currency     string    `json:"currency"`
-	InterestRate float64   `json:"interest_rate"`
-	StartDate    time.Time `json:"start_date"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           string        `json:"id"`
+	Type         DebtType      `json:"type"`
+	Name         string        `json:"name"`
+	Principal    money.Decimal `json:"principal"`
+	CurrentValue money.Decimal `json:"current_value"`
+	Currency     string        `json:"currency"`
+	InterestRate float64       `json:"interest_rate"`
+	StartDate    time.Time     `json:"start_date"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+
+	// Loan terms the amortization schedule is built from: the principal is
+	// repaid over TermMonths, or by a fixed PaymentAmount until paid off
+	TermMonths       int              `json:"term_months,omitempty"`
+	PaymentFrequency PaymentFrequency `json:"payment_frequency,omitempty"`
+	PaymentAmount    *money.Decimal   `json:"payment_amount,omitempty"`
+
+	// Interest accrues on CurrentValue from InterestSince or the last payment,
+	// whichever is later, compounding daily for credit cards and monthly for
+	// other debts unless set; InterestRate is an APR unless RateType says APY
+	Compounding   Compounding `json:"compounding,omitempty"`
+	RateType      RateType    `json:"rate_type,omitempty"`
+	InterestSince *time.Time  `json:"interest_since,omitempty"`
+
+	// Set when the balance includes interest accrued up to AsOf
+	AccruedInterest *money.Decimal `json:"accrued_interest,omitempty"`
+	AsOf            *time.Time     `json:"as_of,omitempty"`
+
+	// RateSchedule changes InterestRate over time; without one the rate is fixed
+	RateSchedule *RateSchedule `json:"-"`
+	// Set for debts with a rate schedule: the rate in effect on AsOf (or
+	// today) and the changes after it
+	EffectiveRate       *float64     `json:"effective_rate,omitempty"`
+	UpcomingRateChanges []RateChange `json:"upcoming_rate_changes,omitempty"`
+}
+
+// CompoundingBasis returns the debt's compounding, defaulting to daily for
+// credit cards and monthly for other debts
+func (d *Debt) CompoundingBasis() Compounding {
+	if d.Compounding != "" {
+		return d.Compounding
+	}
+	if d.Type == DebtTypeCreditCard {
+		return CompoundingDaily
+	}
+	return CompoundingMonthly
+}
+
+// HasLoanTerms reports whether the debt has a term or fixed payment to amortize by
+func (d *Debt) HasLoanTerms() bool {
+	return d.TermMonths > 0 || d.PaymentAmount != nil
+}
+
+// AmortizationPayment is one scheduled payment split into interest and principal
+type AmortizationPayment struct {
+	Number    int           `json:"number"`
+	Date      time.Time     `json:"date"`
+	Payment   money.Decimal `json:"payment"`
+	Interest  money.Decimal `json:"interest"`
+	Principal money.Decimal `json:"principal"`
+	Balance   money.Decimal `json:"balance"`
+	// InterestRate is the rate the period's interest was charged at
+	InterestRate float64 `json:"interest_rate"`
+}
+
+// AmortizationSchedule is the full repayment table of a loan
+type AmortizationSchedule struct {
+	DebtID           string           `json:"debt_id"`
+	Currency         string           `json:"currency"`
+	Principal        money.Decimal    `json:"principal"`
+	InterestRate     float64          `json:"interest_rate"`
+	PaymentFrequency PaymentFrequency `json:"payment_frequency"`
+	PaymentAmount    money.Decimal    `json:"payment_amount"`
+	TotalInterest    money.Decimal    `json:"total_interest"`
+	TotalPaid        money.Decimal    `json:"total_paid"`
+	PayoffDate       time.Time        `json:"payoff_date"`
+	// BalanceToday is the scheduled balance after the payments due by today
+	BalanceToday money.Decimal         `json:"balance_today"`
+	Payments     []AmortizationPayment `json:"payments"`
 }
 
 // CreateDebtRequest represents the request body for creating a debt
 type CreateDebtRequest struct {
-	Type         DebtType `json:"type"`
-	Name         string   `json:"name"`
-	Principal    float64  `json:"principal"`
-	CurrentValue *float64 `json:"current_value,omitempty"`
-	Currency     string   `json:"currency"`
-	InterestRate float64  `json:"interest_rate"`
-	StartDate    string   `json:"start_date"`
+	Type         DebtType       `json:"type"`
+	Name         string         `json:"name"`
+	Principal    money.Decimal  `json:"principal"`
+	CurrentValue *money.Decimal `json:"current_value,omitempty"`
+	Currency     string         `json:"currency"`
+	InterestRate float64        `json:"interest_rate"`
+	StartDate    string         `json:"start_date"`
+
+	TermMonths       int              `json:"term_months,omitempty"`
+	PaymentFrequency PaymentFrequency `json:"payment_frequency,omitempty"`
+	PaymentAmount    *money.Decimal   `json:"payment_amount,omitempty"`
+
+	Compounding Compounding `json:"compounding,omitempty"`
+	RateType    RateType    `json:"rate_type,omitempty"`
 }
 
 // UpdateDebtRequest represents the request body for updating a debt
 type UpdateDebtRequest struct {
-	Name         *string  `json:"name,omitempty"`
-	CurrentValue *float64 `json:"current_value,omitempty"`
-	InterestRate *float64 `json:"interest_rate,omitempty"`
+	Name         *string        `json:"name,omitempty"`
+	CurrentValue *money.Decimal `json:"current_value,omitempty"`
+	InterestRate *float64       `json:"interest_rate,omitempty"`
+
+	TermMonths       *int              `json:"term_months,omitempty"`
+	PaymentFrequency *PaymentFrequency `json:"payment_frequency,omitempty"`
+	PaymentAmount    *money.Decimal    `json:"payment_amount,omitempty"`
+
+	Compounding *Compounding `json:"compounding,omitempty"`
+	RateType    *RateType    `json:"rate_type,omitempty"`
+}
+
+// PayoffStrategy is the order extra payments are put toward debts in
+type PayoffStrategy string
+
+const (
+	// PayoffSnowball pays off the smallest balance first
+	PayoffSnowball PayoffStrategy = "snowball"
+	// PayoffAvalanche pays off the highest interest rate first
+	PayoffAvalanche PayoffStrategy = "avalanche"
+	// PayoffCustom pays off debts in the order given
+	PayoffCustom PayoffStrategy = "custom"
+)
+
+// Valid reports whether the strategy is known
+func (s PayoffStrategy) Valid() bool {
+	switch s {
+	case PayoffSnowball, PayoffAvalanche, PayoffCustom:
+		return true
+	}
+	return false
+}
+
+// PayoffPlanRequest represents the request body for planning debt payoff
+type PayoffPlanRequest struct {
+	MonthlyBudget money.Decimal  `json:"monthly_budget"`
+	Strategy      PayoffStrategy `json:"strategy"`
+	// Order lists debt IDs for the custom strategy; unlisted debts follow
+	// in avalanche order
+	Order []string `json:"order,omitempty"`
+	// MinimumPayments overrides the monthly minimum of a debt by ID, in
+	// the debt's currency
+	MinimumPayments map[string]money.Decimal `json:"minimum_payments,omitempty"`
+	// Currency the budget and results are in, the base currency if empty
+	Currency string `json:"currency,omitempty"`
+}
+
+// DebtPayoff is how one debt is repaid under a payoff plan
+type DebtPayoff struct {
+	DebtID         string        `json:"debt_id"`
+	Name           string        `json:"name"`
+	Priority       int           `json:"priority"`
+	Balance        money.Decimal `json:"balance"`
+	InterestRate   float64       `json:"interest_rate"`
+	MinimumPayment money.Decimal `json:"minimum_payment"`
+	PayoffMonth    int           `json:"payoff_month"`
+	PayoffDate     time.Time     `json:"payoff_date"`
+	TotalInterest  money.Decimal `json:"total_interest"`
+	TotalPaid      money.Decimal `json:"total_paid"`
+	// Interest and payoff date when only the minimum is paid
+	MinimumOnlyInterest   money.Decimal `json:"minimum_only_interest"`
+	MinimumOnlyPayoffDate *time.Time    `json:"minimum_only_payoff_date,omitempty"`
+}
+
+// PayoffSummary totals a simulated repayment of every debt
+type PayoffSummary struct {
+	Months        int           `json:"months"`
+	PayoffDate    *time.Time    `json:"payoff_date,omitempty"`
+	TotalInterest money.Decimal `json:"total_interest"`
+	TotalPaid     money.Decimal `json:"total_paid"`
+	// PaidOff is false when the payments never clear the debts
+	PaidOff bool `json:"paid_off"`
+}
+
+// PayoffPlan is the month-by-month repayment of all debts within a budget,
+// compared with paying only the minimums
+type PayoffPlan struct {
+	Strategy       PayoffStrategy `json:"strategy"`
+	Currency       string         `json:"currency"`
+	MonthlyBudget  money.Decimal  `json:"monthly_budget"`
+	MinimumPayment money.Decimal  `json:"minimum_payment"`
+	PayoffSummary
+	MinimumOnly   PayoffSummary `json:"minimum_only"`
+	InterestSaved money.Decimal `json:"interest_saved"`
+	MonthsSaved   int           `json:"months_saved"`
+	Debts         []DebtPayoff  `json:"debts"`
+	CalculatedAt  time.Time     `json:"calculated_at"`
+}
+
+// DebtPayment is one payment made on a debt, split into the interest it
+// covered and the principal it repaid
+type DebtPayment struct {
+	ID        string        `json:"id"`
+	DebtID    string        `json:"debt_id"`
+	Date      time.Time     `json:"date"`
+	Amount    money.Decimal `json:"amount"`
+	Interest  money.Decimal `json:"interest"`
+	Principal money.Decimal `json:"principal"`
+	// CapitalizedInterest is the interest accrued up to the payment that it
+	// did not cover; it is added to the balance and accrues interest itself
+	CapitalizedInterest money.Decimal `json:"capitalized_interest"`
+	// ManualSplit is set when the interest or principal was given rather
+	// than derived from the accrued interest
+	ManualSplit bool      `json:"manual_split"`
+	Notes       string    `json:"notes,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// BalanceReduction is how much the payment reduces the debt's balance: the
+// principal it repays less the interest it capitalized
+func (p *DebtPayment) BalanceReduction() money.Decimal {
+	return p.Principal.Sub(p.CapitalizedInterest)
+}
+
+// CreateDebtPaymentRequest represents the request body for recording a debt
+// payment. Interest and principal are derived from the amount when omitted.
+type CreateDebtPaymentRequest struct {
+	Date      string         `json:"date"`
+	Amount    money.Decimal  `json:"amount"`
+	Interest  *money.Decimal `json:"interest,omitempty"`
+	Principal *money.Decimal `json:"principal,omitempty"`
+	Notes     string         `json:"notes,omitempty"`
+}
+
+// UpdateDebtPaymentRequest represents the request body for updating a debt payment
+type UpdateDebtPaymentRequest struct {
+	Date      *string        `json:"date,omitempty"`
+	Amount    *money.Decimal `json:"amount,omitempty"`
+	Interest  *money.Decimal `json:"interest,omitempty"`
+	Principal *money.Decimal `json:"principal,omitempty"`
+	Notes     *string        `json:"notes,omitempty"`
+}
+
+// DebtHistory represents the balance of a debt on a date
+type DebtHistory struct {
+	ID        string        `json:"id"`
+	DebtID    string        `json:"debt_id"`
+	Balance   money.Decimal `json:"balance"`
+	Date      time.Time     `json:"date"`
+	CreatedAt time.Time     `json:"created_at"`
+
+	// Set when the balance was converted into another currency at the rate of Date
+	Currency string         `json:"currency,omitempty"`
+	FXRate   *money.Decimal `json:"fx_rate,omitempty"`
+}
+
+// DebtRate is an effective-dated change to a debt's interest rate: a fixed
+// Rate, or the value of a rate index plus Margin. With an EndDate it is a
+// promotional rate, and the rate it replaced applies again from EndDate.
+type DebtRate struct {
+	ID            string     `json:"id"`
+	DebtID        string     `json:"debt_id"`
+	EffectiveDate time.Time  `json:"effective_date"`
+	EndDate       *time.Time `json:"end_date,omitempty"`
+	Rate          *float64   `json:"rate,omitempty"`
+	Index         string     `json:"index,omitempty"`
+	Margin        float64    `json:"margin,omitempty"`
+	Notes         string     `json:"notes,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Promotional reports whether the rate ends on a set date
+func (r *DebtRate) Promotional() bool {
+	return r.EndDate != nil
+}
+
+// CreateDebtRateRequest represents the request body for adding a rate change
+// to a debt. Set either rate or index (with an optional margin).
+type CreateDebtRateRequest struct {
+	EffectiveDate string   `json:"effective_date"`
+	EndDate       string   `json:"end_date,omitempty"`
+	Rate          *float64 `json:"rate,omitempty"`
+	Index         string   `json:"index,omitempty"`
+	Margin        float64  `json:"margin,omitempty"`
+	Notes         string   `json:"notes,omitempty"`
+}
+
+// UpdateDebtRateRequest represents the request body for updating a rate
+// change. An empty end_date removes it; setting rate or index replaces the other.
+type UpdateDebtRateRequest struct {
+	EffectiveDate *string  `json:"effective_date,omitempty"`
+	EndDate       *string  `json:"end_date,omitempty"`
+	Rate          *float64 `json:"rate,omitempty"`
+	Index         *string  `json:"index,omitempty"`
+	Margin        *float64 `json:"margin,omitempty"`
+	Notes         *string  `json:"notes,omitempty"`
+}
+
+// RateIndexValue is the value of a rate index, such as the prime rate, from a date on
+type RateIndexValue struct {
+	Index     string    `json:"index"`
+	Date      time.Time `json:"date"`
+	Rate      float64   `json:"rate"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetRateIndexValueRequest represents the request body for setting the value
+// of a rate index from a date
+type SetRateIndexValueRequest struct {
+	Date string  `json:"date"`
+	Rate float64 `json:"rate"`
+}
+
+// RateIndex is a rate index with its value today
+type RateIndex struct {
+	Name  string    `json:"name"`
+	Rate  float64   `json:"rate"`
+	Since time.Time `json:"since"`
+	// Debts is the number of debts whose rate follows the index
+	Debts int `json:"debts"`
+}
+
+// RateSchedule holds a debt's rate changes, oldest first, and the values of
+// the indexes they follow, oldest first by index
+type RateSchedule struct {
+	Rates   []DebtRate
+	Indexes map[string][]RateIndexValue
+}
+
+// RateChange is a date a debt's interest rate changes on
+type RateChange struct {
+	Date         time.Time `json:"date"`
+	Rate         float64   `json:"rate"`
+	PreviousRate float64   `json:"previous_rate"`
+	// Reason is what changes the rate: a rate change, the start or end of a
+	// promotional rate, or a new index value
+	Reason string `json:"reason"`
 }
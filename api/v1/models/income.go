@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+// IncomeGroupBy selects how an income report is bucketed
+type IncomeGroupBy string
+
+const (
+	IncomeByMonth IncomeGroupBy = "month"
+	IncomeByAsset IncomeGroupBy = "asset"
+)
+
+// IncomeGroup is the income received in one month or from one asset
+type IncomeGroup struct {
+	Key    string                   `json:"key"`
+	Name   string                   `json:"name,omitempty"`
+	Amount money.Decimal            `json:"amount"`
+	ByType map[string]money.Decimal `json:"by_type"`
+
+	// Set when grouping by asset: the asset's current value and the income
+	// of the period annualized as a percentage of it
+	Value *money.Decimal `json:"value,omitempty"`
+	Yield *float64       `json:"yield,omitempty"`
+}
+
+// IncomeReport is the dividend, interest and rent income received between
+// two dates, converted into one currency at the rate of each payment date
+type IncomeReport struct {
+	From     time.Time                `json:"from"`
+	To       time.Time                `json:"to"`
+	Currency string                   `json:"currency"`
+	GroupBy  IncomeGroupBy            `json:"group_by"`
+	Total    money.Decimal            `json:"total"`
+	ByType   map[string]money.Decimal `json:"by_type"`
+	Groups   []IncomeGroup            `json:"groups"`
+}
@@ -2,24 +2,102 @@ package models
 
 import (
 	"time"
+
+	"personal-finance/api/v1/money"
 )
 
 // NetWorth represents the net worth summary
 type NetWorth struct {
-	TotalAssets  float64   `json:"total_assets"`
-	TotalDebts   float64   `json:"total_debts"`
-	NetWorth     float64   `json:"net_worth"`
-	Currency     string    `json:"currency"`
-	CalculatedAt time.Time `json:"calculated_at"`
+	TotalAssets  money.Decimal `json:"total_assets"`
+	TotalDebts   money.Decimal `json:"total_debts"`
+	NetWorth     money.Decimal `json:"net_worth"`
+	Currency     string        `json:"currency"`
+	CalculatedAt time.Time     `json:"calculated_at"`
+
+	// Exchange rates used to convert holdings into Currency, keyed by source currency
+	Rates map[string]money.Decimal `json:"rates,omitempty"`
 }
 
 // Summary represents daily summary of profit/loss and net worth
 type Summary struct {
-	Date            time.Time `json:"date"`
-	TotalAssets     float64   `json:"total_assets"`
-	TotalDebts      float64   `json:"total_debts"`
-	NetWorth        float64   `json:"net_worth"`
-	DailyProfitLoss float64   `json:"daily_profit_loss"`
-	TotalProfitLoss float64   `json:"total_profit_loss"`
-	Currency        string    `json:"currency"`
+	Date            time.Time     `json:"date"`
+	TotalAssets     money.Decimal `json:"total_assets"`
+	TotalDebts      money.Decimal `json:"total_debts"`
+	NetWorth        money.Decimal `json:"net_worth"`
+	DailyProfitLoss money.Decimal `json:"daily_profit_loss"`
+	TotalProfitLoss money.Decimal `json:"total_profit_loss"`
+	Currency        string        `json:"currency"`
+
+	// Profit/loss split into price and exchange rate effects
+	DailyBreakdown GainBreakdown `json:"daily_breakdown"`
+	TotalBreakdown GainBreakdown `json:"total_breakdown"`
+
+	// Total return: the unrealized TotalProfitLoss plus gains realized by
+	// sales (at today's rate) and income received (at each payment's rate)
+	RealizedGain money.Decimal `json:"realized_gain"`
+	Income       money.Decimal `json:"income"`
+	TotalReturn  money.Decimal `json:"total_return"`
+
+	// Set when a ?period= window is requested
+	Period           string         `json:"period,omitempty"`
+	PeriodStart      *time.Time     `json:"period_start,omitempty"`
+	PeriodProfitLoss *money.Decimal `json:"period_profit_loss,omitempty"`
+	PeriodBreakdown  *GainBreakdown `json:"period_breakdown,omitempty"`
+
+	// Exchange rates used to convert holdings into Currency, keyed by source currency
+	Rates map[string]money.Decimal `json:"rates,omitempty"`
+}
+
+// NetWorthSnapshot is the recorded net worth at the end of a day
+type NetWorthSnapshot struct {
+	ID           string                   `json:"id"`
+	Date         time.Time                `json:"date"`
+	TotalAssets  money.Decimal            `json:"total_assets"`
+	TotalDebts   money.Decimal            `json:"total_debts"`
+	NetWorth     money.Decimal            `json:"net_worth"`
+	Currency     string                   `json:"currency"`
+	AssetsByType map[string]money.Decimal `json:"assets_by_type"`
+	DebtsByType  map[string]money.Decimal `json:"debts_by_type"`
+	CreatedAt    time.Time                `json:"created_at"`
+}
+
+// GainBreakdown splits a change in value into the part caused by price moves
+// and the part caused by exchange rate moves
+type GainBreakdown struct {
+	PriceGain money.Decimal `json:"price_gain"`
+	FXGain    money.Decimal `json:"fx_gain"`
+}
+
+// Total returns the combined gain
+func (g GainBreakdown) Total() money.Decimal {
+	return g.PriceGain.Add(g.FXGain)
+}
+
+// Add accumulates another breakdown
+func (g *GainBreakdown) Add(other GainBreakdown) {
+	g.PriceGain = g.PriceGain.Add(other.PriceGain)
+	g.FXGain = g.FXGain.Add(other.FXGain)
+}
+
+// AssetValuation is an asset converted into a reporting currency: its cost at
+// the rate of the purchase date and its value at today's rate
+type AssetValuation struct {
+	AssetID      string        `json:"asset_id"`
+	Currency     string        `json:"currency"`
+	CostBasis    money.Decimal `json:"cost_basis"`
+	PurchaseRate money.Decimal `json:"purchase_rate"`
+	Value        money.Decimal `json:"value"`
+	Rate         money.Decimal `json:"rate"`
+	GainBreakdown
+}
+
+// DebtValuation is a debt converted into a reporting currency: its principal
+// at the rate of the start date and its balance at today's rate
+type DebtValuation struct {
+	DebtID    string        `json:"debt_id"`
+	Currency  string        `json:"currency"`
+	Principal money.Decimal `json:"principal"`
+	StartRate money.Decimal `json:"start_rate"`
+	Balance   money.Decimal `json:"balance"`
+	Rate      money.Decimal `json:"rate"`
 }
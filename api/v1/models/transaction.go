@@ -0,0 +1,176 @@
+package models
+
+import (
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+// TransactionType represents the kind of ledger entry
+type TransactionType string
+
+const (
+	TransactionTypeBuy      TransactionType = "buy"
+	TransactionTypeSell     TransactionType = "sell"
+	TransactionTypeDividend TransactionType = "dividend"
+	TransactionTypeSplit    TransactionType = "split"
+	TransactionTypeFee      TransactionType = "fee"
+	TransactionTypeTransfer TransactionType = "transfer"
+	TransactionTypeInterest TransactionType = "interest"
+	TransactionTypeRent     TransactionType = "rent"
+)
+
+// Valid reports whether t is a known transaction type
+func (t TransactionType) Valid() bool {
+	switch t {
+	case TransactionTypeBuy, TransactionTypeSell, TransactionTypeDividend,
+		TransactionTypeSplit, TransactionTypeFee, TransactionTypeTransfer,
+		TransactionTypeInterest, TransactionTypeRent:
+		return true
+	}
+	return false
+}
+
+// IsIncome reports whether t is cash income earned by holding the asset
+func (t TransactionType) IsIncome() bool {
+	return t == TransactionTypeDividend || t == TransactionTypeInterest || t == TransactionTypeRent
+}
+
+// CostBasisMethod selects which lots a sale or transfer out consumes
+type CostBasisMethod string
+
+const (
+	// CostBasisFIFO consumes the oldest lots first
+	CostBasisFIFO CostBasisMethod = "fifo"
+	// CostBasisLIFO consumes the newest lots first
+	CostBasisLIFO CostBasisMethod = "lifo"
+	// CostBasisAverage prices every unit at the position's average cost;
+	// units still leave oldest first for holding periods
+	CostBasisAverage CostBasisMethod = "average"
+	// CostBasisSpecific consumes the lot named by the transaction's LotID,
+	// falling back to FIFO when none is given
+	CostBasisSpecific CostBasisMethod = "specific"
+)
+
+// Valid reports whether m is a known cost basis method
+func (m CostBasisMethod) Valid() bool {
+	switch m {
+	case CostBasisFIFO, CostBasisLIFO, CostBasisAverage, CostBasisSpecific:
+		return true
+	}
+	return false
+}
+
+// GainTerm is the holding period class of a realized gain
+type GainTerm string
+
+const (
+	// GainTermShort applies to lots held one year or less
+	GainTermShort GainTerm = "short"
+	// GainTermLong applies to lots held more than one year
+	GainTermLong GainTerm = "long"
+)
+
+// Transaction is one entry in an asset's ledger. Which fields apply depends
+// on the type:
+//   - buy/sell: Quantity units at Price per unit, plus Fee
+//   - dividend, interest, rent: Amount of cash income
+//   - fee: Amount of cash paid
+//   - split: Ratio new units per old unit (2 for a 2-for-1 split)
+//   - transfer: signed Quantity moved in (positive) or out (negative) at Price
+//
+// LotID optionally names the buy a sell or transfer out consumes under the
+// specific lot method.
+type Transaction struct {
+	ID        string          `json:"id"`
+	AssetID   string          `json:"asset_id"`
+	Type      TransactionType `json:"type"`
+	Date      time.Time       `json:"date"`
+	Quantity  money.Decimal   `json:"quantity"`
+	Price     money.Decimal   `json:"price"`
+	Amount    money.Decimal   `json:"amount"`
+	Fee       money.Decimal   `json:"fee"`
+	Ratio     money.Decimal   `json:"ratio"`
+	LotID     string          `json:"lot_id,omitempty"`
+	Notes     string          `json:"notes,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// CreateTransactionRequest represents the request body for recording a transaction
+type CreateTransactionRequest struct {
+	Type     TransactionType `json:"type"`
+	Date     string          `json:"date"`
+	Quantity money.Decimal   `json:"quantity"`
+	Price    money.Decimal   `json:"price"`
+	Amount   money.Decimal   `json:"amount"`
+	Fee      money.Decimal   `json:"fee"`
+	Ratio    money.Decimal   `json:"ratio"`
+	LotID    string          `json:"lot_id,omitempty"`
+	Notes    string          `json:"notes,omitempty"`
+}
+
+// UpdateTransactionRequest represents the request body for updating a transaction
+type UpdateTransactionRequest struct {
+	Type     *TransactionType `json:"type,omitempty"`
+	Date     *string          `json:"date,omitempty"`
+	Quantity *money.Decimal   `json:"quantity,omitempty"`
+	Price    *money.Decimal   `json:"price,omitempty"`
+	Amount   *money.Decimal   `json:"amount,omitempty"`
+	Fee      *money.Decimal   `json:"fee,omitempty"`
+	Ratio    *money.Decimal   `json:"ratio,omitempty"`
+	LotID    *string          `json:"lot_id,omitempty"`
+	Notes    *string          `json:"notes,omitempty"`
+}
+
+// Lot is an open tax lot: units acquired together and the cost still
+// attributed to them
+type Lot struct {
+	TransactionID string        `json:"transaction_id"`
+	Date          time.Time     `json:"date"`
+	Quantity      money.Decimal `json:"quantity"`
+	Cost          money.Decimal `json:"cost"`
+}
+
+// Disposal is the part of a sale that consumed one lot
+type Disposal struct {
+	AssetID          string        `json:"asset_id"`
+	Description      string        `json:"description"`
+	SaleID           string        `json:"sale_id"`
+	LotID            string        `json:"lot_id"`
+	Quantity         money.Decimal `json:"quantity"`
+	DateAcquired     time.Time     `json:"date_acquired"`
+	DateSold         time.Time     `json:"date_sold"`
+	Proceeds         money.Decimal `json:"proceeds"`
+	CostBasis        money.Decimal `json:"cost_basis"`
+	Gain             money.Decimal `json:"gain"`
+	Term             GainTerm      `json:"term"`
+	Currency         string        `json:"currency"`
+	OriginalCurrency string        `json:"original_currency,omitempty"`
+}
+
+// Position is an asset's holding derived by replaying its ledger
+type Position struct {
+	AssetID      string          `json:"asset_id"`
+	Currency     string          `json:"currency"`
+	Method       CostBasisMethod `json:"cost_basis_method"`
+	Quantity     money.Decimal   `json:"quantity"`
+	CostBasis    money.Decimal   `json:"cost_basis"`
+	RealizedGain money.Decimal   `json:"realized_gain"`
+	Income       money.Decimal   `json:"income"`
+	Fees         money.Decimal   `json:"fees"`
+	Lots         []Lot           `json:"lots"`
+	Disposals    []Disposal      `json:"disposals"`
+}
+
+// GainsReport lists the realized gains of a tax year in one currency
+type GainsReport struct {
+	Year      int           `json:"year"`
+	Currency  string        `json:"currency"`
+	ShortTerm money.Decimal `json:"short_term"`
+	LongTerm  money.Decimal `json:"long_term"`
+	Total     money.Decimal `json:"total"`
+	Proceeds  money.Decimal `json:"proceeds"`
+	CostBasis money.Decimal `json:"cost_basis"`
+	Disposals []Disposal    `json:"disposals"`
+}
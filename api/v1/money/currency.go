@@ -0,0 +1,35 @@
+package money
+
+import "strings"
+
+// DefaultMinorUnits is the number of decimal places of most currencies
+const DefaultMinorUnits = 2
+
+// minorUnits lists the ISO 4217 currencies whose minor unit differs from
+// two decimal places
+var minorUnits = map[string]int32{
+	// No minor unit
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	// Three decimal places
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	// Four decimal places
+	"CLF": 4, "UYW": 4,
+}
+
+// MinorUnits returns the number of decimal places amounts in the currency
+// are rounded to (2 for USD and EUR, 0 for JPY, 3 for KWD)
+func MinorUnits(currency string) int32 {
+	if units, ok := minorUnits[strings.ToUpper(strings.TrimSpace(currency))]; ok {
+		return units
+	}
+	return DefaultMinorUnits
+}
+
+// RoundTo rounds an amount half away from zero to the currency's minor unit.
+// Line totals (price × quantity), converted amounts and reported totals are
+// rounded this way so they match brokerage and bank statements to the cent;
+// unit prices and quantities keep their full precision.
+func RoundTo(amount Decimal, currency string) Decimal {
+	return amount.Round(MinorUnits(currency))
+}
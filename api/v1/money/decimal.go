@@ -0,0 +1,375 @@
+// Package money provides an exact decimal type for monetary amounts,
+// prices and quantities, and the rounding rules applied per currency.
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact base-10 number: coef × 10^-scale. The zero value is 0.
+// Values are immutable; every operation returns a new Decimal. The scale is
+// preserved as written (like Postgres NUMERIC), so "150.00" stays "150.00".
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+var (
+	bigOne = big.NewInt(1)
+	bigTen = big.NewInt(10)
+)
+
+// Zero is the decimal 0
+var Zero = Decimal{}
+
+// Parse rejects exponents and scales beyond these limits, so a single input
+// such as "1e2000000000" cannot make it build a number with billions of digits
+const (
+	maxExponent = 64
+	maxScale    = 38
+)
+
+// New returns coef × 10^-scale, e.g. New(12345, 2) is 123.45
+func New(coef int64, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{coef: new(big.Int).Mul(big.NewInt(coef), pow10(-scale))}
+	}
+	return Decimal{coef: big.NewInt(coef), scale: scale}
+}
+
+// NewFromInt returns the integer n
+func NewFromInt(n int64) Decimal {
+	return New(n, 0)
+}
+
+// NewFromFloat converts a float64 using its shortest exact decimal
+// representation, so 0.1 becomes exactly 0.1. It is meant for values that
+// arrive as floats from external APIs; NaN and infinities become zero.
+func NewFromFloat(f float64) Decimal {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Zero
+	}
+	d, _ := Parse(strconv.FormatFloat(f, 'f', -1, 64))
+	return d
+}
+
+// Parse reads a decimal string such as "-1234.5678", "1e-3" or "+.5"
+func Parse(s string) (Decimal, error) {
+	original := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Zero, fmt.Errorf("invalid decimal: empty string")
+	}
+
+	var exp int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Zero, fmt.Errorf("invalid decimal: %q", original)
+		}
+		if e > maxExponent || e < -maxExponent {
+			return Zero, fmt.Errorf("invalid decimal: %q (exponent out of range)", original)
+		}
+		exp = e
+		s = s[:i]
+	}
+
+	negative := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return Zero, fmt.Errorf("invalid decimal: %q", original)
+	}
+	digits := intPart + fracPart
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Zero, fmt.Errorf("invalid decimal: %q", original)
+		}
+	}
+
+	scale := int64(len(fracPart)) - exp
+	if scale > maxScale {
+		return Zero, fmt.Errorf("invalid decimal: %q (more than %d decimal places)", original, maxScale)
+	}
+
+	coef, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Zero, fmt.Errorf("invalid decimal: %q", original)
+	}
+	if negative {
+		coef.Neg(coef)
+	}
+	if scale < 0 {
+		coef.Mul(coef, pow10(int32(-scale)))
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+// MustParse is like Parse but panics on invalid input. Use it for constants.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Sum adds up the given values
+func Sum(values ...Decimal) Decimal {
+	total := Zero
+	for _, v := range values {
+		total = total.Add(v)
+	}
+	return total
+}
+
+// pow10 returns 10^n
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// int returns the coefficient, treating nil as 0
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// rescale returns the coefficient of d expressed at a larger scale
+func (d Decimal) rescale(scale int32) *big.Int {
+	if scale == d.scale {
+		return new(big.Int).Set(d.int())
+	}
+	return new(big.Int).Mul(d.int(), pow10(scale-d.scale))
+}
+
+// align returns the coefficients of d and other at their common scale
+func (d Decimal) align(other Decimal) (*big.Int, *big.Int, int32) {
+	scale := d.scale
+	if other.scale > scale {
+		scale = other.scale
+	}
+	return d.rescale(scale), other.rescale(scale), scale
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b, scale := d.align(other)
+	return Decimal{coef: a.Add(a, b), scale: scale}
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b, scale := d.align(other)
+	return Decimal{coef: a.Sub(a, b), scale: scale}
+}
+
+// Mul returns d × other exactly; the scale is the sum of both scales
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.int(), other.int()), scale: d.scale + other.scale}
+}
+
+// Div returns d ÷ other rounded half away from zero to the given number of
+// decimal places. Dividing by zero returns zero.
+func (d Decimal) Div(other Decimal, places int32) Decimal {
+	if other.IsZero() {
+		return Zero
+	}
+	// d/other = (dc × 10^-ds) / (oc × 10^-os); compute with two extra digits and round
+	shift := places + 2 + other.scale - d.scale
+	num := new(big.Int).Set(d.int())
+	den := new(big.Int).Set(other.int())
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	quotient := new(big.Int).Quo(num, den)
+	return Decimal{coef: quotient, scale: places + 2}.Round(places)
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// Abs returns |d|
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+// Round rounds half away from zero to the given number of decimal places,
+// which is how statements round (2.345 → 2.35, -2.345 → -2.35)
+func (d Decimal) Round(places int32) Decimal {
+	if places >= d.scale {
+		return Decimal{coef: d.rescale(places), scale: places}
+	}
+
+	divisor := pow10(d.scale - places)
+	quotient, remainder := new(big.Int).QuoRem(d.int(), divisor, new(big.Int))
+	// Compare 2×|remainder| with the divisor to decide whether to round away from zero
+	twice := new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2))
+	if twice.Cmp(divisor) >= 0 {
+		if d.Sign() < 0 {
+			quotient.Sub(quotient, bigOne)
+		} else {
+			quotient.Add(quotient, bigOne)
+		}
+	}
+	return Decimal{coef: quotient, scale: places}
+}
+
+// Truncate drops digits beyond the given number of decimal places
+func (d Decimal) Truncate(places int32) Decimal {
+	if places >= d.scale {
+		return d
+	}
+	return Decimal{coef: new(big.Int).Quo(d.int(), pow10(d.scale-places)), scale: places}
+}
+
+// Cmp compares d and other: -1 if d < other, 0 if equal, +1 if d > other
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _ := d.align(other)
+	return a.Cmp(b)
+}
+
+// Equal reports whether d and other are numerically equal (1.50 equals 1.5)
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// LessThan reports whether d < other
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.Cmp(other) < 0
+}
+
+// GreaterThan reports whether d > other
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.Cmp(other) > 0
+}
+
+// Sign returns -1, 0 or +1
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// IsZero reports whether d is 0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// IsNegative reports whether d < 0
+func (d Decimal) IsNegative() bool {
+	return d.Sign() < 0
+}
+
+// IsPositive reports whether d > 0
+func (d Decimal) IsPositive() bool {
+	return d.Sign() > 0
+}
+
+// Scale returns the number of decimal places d is written with
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Float64 returns the nearest float64. Use it only for display or for
+// ratios such as percentages, never to feed amounts back into arithmetic.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String returns d with exactly its scale's decimal places, e.g. "-12.50"
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= int(d.scale) {
+		digits = strings.Repeat("0", int(d.scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(d.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// StringFixed returns d rounded to the given number of decimal places
+func (d Decimal) StringFixed(places int32) string {
+	return d.Round(places).String()
+}
+
+// MarshalJSON writes d as a JSON number without losing precision
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number, a numeric string or null (zero)
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*d = Zero
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for NUMERIC/DECIMAL columns
+func (d *Decimal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Zero
+		return nil
+	case []byte:
+		parsed, err := Parse(string(v))
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case string:
+		parsed, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case int64:
+		*d = NewFromInt(v)
+		return nil
+	case float64:
+		*d = NewFromFloat(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into money.Decimal", src)
+	}
+}
+
+// Value implements driver.Valuer, sending the exact decimal string
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
@@ -0,0 +1,101 @@
+package money
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "123.45", want: "123.45"},
+		{in: "-1234.5678", want: "-1234.5678"},
+		{in: "+.5", want: "0.5"},
+		{in: "150.00", want: "150.00"},
+		{in: " 7 ", want: "7"},
+		{in: "1e-3", want: "0.001"},
+		{in: "1.5E2", want: "150"},
+		{in: "2.5e64", want: "25000000000000000000000000000000000000000000000000000000000000000"},
+		{in: "1e-38", want: "0.00000000000000000000000000000000000001"},
+		{in: "", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "1e", wantErr: true},
+		{in: "1e65", wantErr: true},
+		{in: "1e2000000000", wantErr: true},
+		{in: "1e-2000000000", wantErr: true},
+		{in: "1e-39", wantErr: true},
+		{in: "0.000000000000000000000000000000000000001", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) returned error %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalJSONRejectsHugeExponent(t *testing.T) {
+	var d Decimal
+	if err := d.UnmarshalJSON([]byte(`"1e2000000000"`)); err == nil {
+		t.Fatalf("UnmarshalJSON accepted an exponent of 2e9: %s", d)
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		places int32
+		want   string
+	}{
+		{"10", "3", 2, "3.33"},
+		{"20", "3", 2, "6.67"},
+		{"-20", "3", 2, "-6.67"},
+		{"1", "8", 3, "0.125"},
+		{"1", "8", 2, "0.13"},
+		{"1.005", "1", 2, "1.01"},
+		{"100", "0.25", 0, "400"},
+		{"0.0001", "3", 6, "0.000033"},
+		{"5", "0", 2, "0"},
+	}
+	for _, tt := range tests {
+		got := MustParse(tt.a).Div(MustParse(tt.b), tt.places)
+		if got.String() != tt.want {
+			t.Errorf("%s / %s to %d places = %s, want %s", tt.a, tt.b, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int32
+		want   string
+	}{
+		{"2.345", 2, "2.35"},
+		{"-2.345", 2, "-2.35"},
+		{"2.344", 2, "2.34"},
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"1.2", 3, "1.200"},
+		{"0.0049", 2, "0.00"},
+		{"999.995", 2, "1000.00"},
+	}
+	for _, tt := range tests {
+		got := MustParse(tt.in).Round(tt.places)
+		if got.String() != tt.want {
+			t.Errorf("Round(%s, %d) = %s, want %s", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
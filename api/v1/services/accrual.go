@@ -0,0 +1,254 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// DebtAccrualJobName is the scheduler name of the job that accrues interest on debts
+const DebtAccrualJobName = "debt_interest_accrual"
+
+// ErrInvalidInterestTerms is returned for an unknown compounding basis or rate type
+var ErrInvalidInterestTerms = errors.New("invalid interest terms")
+
+// accrualDebtColumns lists the debt columns in the order scanAccrualDebt expects
+const accrualDebtColumns = `id, type, current_value, COALESCE(currency, ''), COALESCE(interest_rate, 0), start_date,
+	COALESCE(compounding, ''), COALESCE(rate_type, ''), interest_since`
+
+// scanAccrualDebt scans a row selected with accrualDebtColumns into a debt
+func scanAccrualDebt(row scanner, debt *models.Debt) error {
+	return row.Scan(
+		&debt.ID, &debt.Type, &debt.CurrentValue, &debt.Currency, &debt.InterestRate, &debt.StartDate,
+		&debt.Compounding, &debt.RateType, &debt.InterestSince,
+	)
+}
+
+// queryExecer is satisfied by both *sql.DB and *sql.Tx
+type queryExecer interface {
+	querier
+	execer
+}
+
+// ValidateInterestTerms checks a debt's rate, compounding basis and rate type
+func ValidateInterestTerms(debt *models.Debt) error {
+	if debt.InterestRate < 0 {
+		return fmt.Errorf("%w: interest_rate cannot be negative", ErrInvalidInterestTerms)
+	}
+	if debt.Compounding != "" && !debt.Compounding.Valid() {
+		return fmt.Errorf("%w: unknown compounding %q (use daily or monthly)", ErrInvalidInterestTerms, debt.Compounding)
+	}
+	if debt.RateType != "" && !debt.RateType.Valid() {
+		return fmt.Errorf("%w: unknown rate_type %q (use apr or apy)", ErrInvalidInterestTerms, debt.RateType)
+	}
+	return nil
+}
+
+// AccruedInterest returns the interest a balance accrues from one date to a
+// later one at the debt's rate and compounding basis, rounded to its
+// currency. Each stretch between changes in the debt's rate accrues at the
+// rate in effect, and the stretches compound on each other.
+func AccruedInterest(debt *models.Debt, balance money.Decimal, from, to time.Time) money.Decimal {
+	from, to = truncateToDate(from), truncateToDate(to)
+	if !balance.IsPositive() || !to.After(from) {
+		return money.Zero
+	}
+
+	growth := 1.0
+	start, rate := from, RateOn(debt, from)
+	for _, change := range append(rateChanges(debt, from, to), models.RateChange{Date: to}) {
+		growth *= 1 + accrualFactor(rate, debt.RateType, debt.CompoundingBasis(), start, change.Date)
+		start, rate = change.Date, change.Rate
+	}
+	if growth == 1 {
+		return money.Zero
+	}
+	return money.RoundTo(balance.Mul(money.NewFromFloat(growth-1)), debt.Currency)
+}
+
+// accrualFactor returns how much one unit of balance grows between two
+// dates. An APR is split evenly over the periods of a year, an APY into the
+// periodic rate that compounds to it. Daily compounding applies the daily
+// rate for every day; monthly compounding applies the monthly rate for every
+// whole month and simple interest for the days left over.
+func accrualFactor(rate float64, rateType models.RateType, compounding models.Compounding, from, to time.Time) float64 {
+	annual := rate / 100
+
+	if compounding == models.CompoundingDaily {
+		daily := annual / 365
+		if rateType == models.RateTypeAPY {
+			daily = math.Pow(1+annual, 1.0/365) - 1
+		}
+		return math.Pow(1+daily, float64(daysBetween(from, to))) - 1
+	}
+
+	monthly := annual / 12
+	if rateType == models.RateTypeAPY {
+		monthly = math.Pow(1+annual, 1.0/12) - 1
+	}
+	months := 0
+	for !addMonthsClamped(from, months+1).After(to) {
+		months++
+	}
+	rest := daysBetween(addMonthsClamped(from, months), to)
+	return math.Pow(1+monthly, float64(months))*(1+monthly*12*float64(rest)/365) - 1
+}
+
+// accrualStart returns the date a debt's balance accrues interest from: its
+// interest_since date (the start date if unset) or the last payment,
+// whichever is later
+func accrualStart(debt *models.Debt, lastPayment *time.Time) time.Time {
+	start := debt.StartDate
+	if debt.InterestSince != nil {
+		start = *debt.InterestSince
+	}
+	if lastPayment != nil && lastPayment.After(start) {
+		start = *lastPayment
+	}
+	return start
+}
+
+// ApplyAccrual adds the interest a debt's balance accrues up to a date to its
+// current value and sets its accrued interest, assuming no further payments.
+// Like ApplyFixedIncomeValue only the debt passed in is changed; the stored
+// accrued interest is updated by the accrual job and by payments.
+func ApplyAccrual(debt *models.Debt, lastPayment *time.Time, on time.Time) {
+	on = truncateToDate(on)
+	accrued := AccruedInterest(debt, debt.CurrentValue, accrualStart(debt, lastPayment), on)
+	debt.CurrentValue = debt.CurrentValue.Add(accrued)
+	debt.AccruedInterest = &accrued
+	debt.AsOf = &on
+}
+
+// ApplyAccruals applies ApplyAccrual to every debt, looking up their last
+// payments on or before the date
+func ApplyAccruals(db querier, debts []models.Debt, on time.Time) error {
+	payments, err := lastPayments(db, on)
+	if err != nil {
+		return err
+	}
+	for i := range debts {
+		var last *time.Time
+		if date, ok := payments[debts[i].ID]; ok {
+			last = &date
+		}
+		ApplyAccrual(&debts[i], last, on)
+	}
+	return nil
+}
+
+// lastPayments returns the date of each debt's last payment on or before a date
+func lastPayments(db querier, on time.Time) (map[string]time.Time, error) {
+	rows, err := db.Query(
+		`SELECT debt_id, MAX(date) FROM debt_payments WHERE date <= $1 GROUP BY debt_id`,
+		on.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make(map[string]time.Time)
+	for rows.Next() {
+		var debtID string
+		var date time.Time
+		if err := rows.Scan(&debtID, &date); err != nil {
+			return nil, err
+		}
+		payments[debtID] = date
+	}
+	return payments, rows.Err()
+}
+
+// lastPayment returns the date of a debt's last payment on or before a date,
+// skipping the payment excludeID, or nil without one
+func lastPayment(db querier, debtID, excludeID string, on time.Time) (*time.Time, error) {
+	var date sql.NullTime
+	err := db.QueryRow(`
+		SELECT MAX(date) FROM debt_payments
+		WHERE debt_id = $1 AND date <= $2 AND id::text <> $3
+	`, debtID, on.Format("2006-01-02"), excludeID).Scan(&date)
+	if err != nil || !date.Valid {
+		return nil, err
+	}
+	return &date.Time, nil
+}
+
+// storeAccruedInterest stores the interest a debt has accrued up to a date
+// and its balance including that interest in the debt's history
+func storeAccruedInterest(db queryExecer, debtID string, on time.Time) error {
+	var debt models.Debt
+	if err := scanAccrualDebt(db.QueryRow(`SELECT `+accrualDebtColumns+` FROM debts WHERE id = $1`, debtID), &debt); err != nil {
+		return err
+	}
+	if err := LoadRateSchedules(db, &debt); err != nil {
+		return err
+	}
+	last, err := lastPayment(db, debtID, "", on)
+	if err != nil {
+		return err
+	}
+
+	ApplyAccrual(&debt, last, on)
+	if _, err := db.Exec(`UPDATE debts SET accrued_interest = $1 WHERE id = $2`, *debt.AccruedInterest, debtID); err != nil {
+		return err
+	}
+	return RecordDebtBalance(db, debtID, debt.CurrentValue, on)
+}
+
+// DebtAccrualService stores the interest accrued on every debt
+type DebtAccrualService struct {
+	db *sql.DB
+}
+
+// NewDebtAccrualService creates a new debt accrual service
+func NewDebtAccrualService(db *sql.DB) *DebtAccrualService {
+	return &DebtAccrualService{db: db}
+}
+
+// DebtAccrualResult summarizes one run of the debt accrual job
+type DebtAccrualResult struct {
+	DebtsAccrued int      `json:"debts_accrued"`
+	Failed       []string `json:"failed"`
+}
+
+// AccrueAll stores today's accrued interest of every debt and records its
+// balance including that interest in debt_history
+func (s *DebtAccrualService) AccrueAll() (*DebtAccrualResult, error) {
+	rows, err := s.db.Query(`SELECT id FROM debts WHERE current_value > 0`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch debts: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse debts: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	result := &DebtAccrualResult{Failed: []string{}}
+	today := time.Now()
+	for _, id := range ids {
+		if err := storeAccruedInterest(s.db, id, today); err != nil {
+			log.Printf("[Accrual] Failed to accrue interest on debt %s: %v", id, err)
+			result.Failed = append(result.Failed, id)
+			continue
+		}
+		result.DebtsAccrued++
+	}
+
+	if len(result.Failed) > 0 && result.DebtsAccrued == 0 {
+		return result, fmt.Errorf("no debt could be accrued")
+	}
+	return result, nil
+}
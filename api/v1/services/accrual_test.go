@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func datePtr(s string) *time.Time {
+	d := mustDate(s)
+	return &d
+}
+
+func TestAccruedInterest(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate        float64
+		rateType    models.RateType
+		compounding models.Compounding
+		currency    string
+		balance     string
+		from, to    string
+		want        string
+	}{
+		{name: "one month", rate: 12, balance: "1000.00", from: "2024-01-01", to: "2024-02-01", want: "10.00"},
+		// A month from the 31st ends on the last day of a shorter month
+		{name: "one month from the end of a month", rate: 12, balance: "1000.00", from: "2024-01-31", to: "2024-02-29", want: "10.00"},
+		// One month compounded, then 14 days of simple interest
+		{name: "month and days", rate: 12, balance: "1000.00", from: "2024-01-01", to: "2024-02-15", want: "14.65"},
+		{name: "APY over a year", rate: 12, rateType: models.RateTypeAPY, balance: "1000.00", from: "2023-01-01", to: "2024-01-01", want: "120.00"},
+		{name: "daily", rate: 18, compounding: models.CompoundingDaily, balance: "1000.00", from: "2024-03-01", to: "2024-03-31", want: "14.90"},
+		{name: "daily APR over a leap year", rate: 12, compounding: models.CompoundingDaily, balance: "1000.00", from: "2024-01-01", to: "2025-01-01", want: "127.85"},
+		{name: "daily APY over a leap year", rate: 12, rateType: models.RateTypeAPY, compounding: models.CompoundingDaily, balance: "1000.00", from: "2024-01-01", to: "2025-01-01", want: "120.35"},
+		{name: "rounded to the currency", rate: 12, currency: "JPY", balance: "100000", from: "2024-01-01", to: "2024-02-15", want: "1465"},
+		{name: "zero rate", rate: 0, balance: "1000.00", from: "2024-01-01", to: "2024-02-01", want: "0"},
+		{name: "no balance", rate: 12, balance: "0", from: "2024-01-01", to: "2024-02-01", want: "0"},
+		{name: "dates reversed", rate: 12, balance: "1000.00", from: "2024-02-01", to: "2024-01-01", want: "0"},
+	}
+	for _, tt := range tests {
+		currency := tt.currency
+		if currency == "" {
+			currency = "USD"
+		}
+		debt := &models.Debt{InterestRate: tt.rate, RateType: tt.rateType, Compounding: tt.compounding, Currency: currency}
+		got := AccruedInterest(debt, money.MustParse(tt.balance), mustDate(tt.from), mustDate(tt.to))
+		if got.String() != tt.want {
+			t.Errorf("%s: AccruedInterest on %s from %s to %s = %s, want %s", tt.name, tt.balance, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestAccrualStart(t *testing.T) {
+	tests := []struct {
+		name          string
+		interestSince string
+		lastPayment   string
+		want          string
+	}{
+		{name: "start date", want: "2024-01-01"},
+		{name: "interest since", interestSince: "2024-02-01", want: "2024-02-01"},
+		{name: "payment after interest since", interestSince: "2024-02-01", lastPayment: "2024-03-01", want: "2024-03-01"},
+		{name: "payment before interest since", interestSince: "2024-02-01", lastPayment: "2024-01-15", want: "2024-02-01"},
+	}
+	for _, tt := range tests {
+		debt := &models.Debt{StartDate: mustDate("2024-01-01")}
+		if tt.interestSince != "" {
+			debt.InterestSince = datePtr(tt.interestSince)
+		}
+		var last *time.Time
+		if tt.lastPayment != "" {
+			last = datePtr(tt.lastPayment)
+		}
+		if got := accrualStart(debt, last).Format("2006-01-02"); got != tt.want {
+			t.Errorf("%s: accrualStart = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateInterestTerms(t *testing.T) {
+	tests := []struct {
+		name    string
+		debt    models.Debt
+		wantErr bool
+	}{
+		{name: "defaults", debt: models.Debt{InterestRate: 5}},
+		{name: "daily APY", debt: models.Debt{InterestRate: 5, Compounding: models.CompoundingDaily, RateType: models.RateTypeAPY}},
+		{name: "negative rate", debt: models.Debt{InterestRate: -1}, wantErr: true},
+		{name: "unknown compounding", debt: models.Debt{Compounding: "weekly"}, wantErr: true},
+		{name: "unknown rate type", debt: models.Debt{RateType: "ear"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := ValidateInterestTerms(&tt.debt)
+		if tt.wantErr != errors.Is(err, ErrInvalidInterestTerms) {
+			t.Errorf("%s: ValidateInterestTerms returned %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+const alphaVantageBaseURL = "https://www.alphavantage.co/query"
+
+// AlphaVantageProvider fetches market data from Alpha Vantage (requires API key)
+type AlphaVantageProvider struct {
+	httpClient *http.Client
+	apiKey     string
+	baseURL    string
+}
+
+// NewAlphaVantageProvider creates a new Alpha Vantage provider.
+// The API key is read from ALPHA_VANTAGE_API_KEY.
+func NewAlphaVantageProvider(httpClient *http.Client) *AlphaVantageProvider {
+	apiKey := os.Getenv("ALPHA_VANTAGE_API_KEY")
+	if apiKey == "" {
+		apiKey = "demo"
+	}
+
+	return &AlphaVantageProvider{
+		httpClient: httpClient,
+		apiKey:     apiKey,
+		baseURL:    alphaVantageBaseURL,
+	}
+}
+
+// Name returns the provider name
+func (p *AlphaVantageProvider) Name() string {
+	return string(ProviderAlphaVantage)
+}
+
+// query calls the Alpha Vantage API and returns the decoded top-level object
+func (p *AlphaVantageProvider) query(params url.Values) (map[string]json.RawMessage, error) {
+	params.Set("apikey", p.apiKey)
+	endpoint := p.baseURL + "?" + params.Encode()
+
+	var result map[string]json.RawMessage
+	if err := fetchJSON(p.httpClient, endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	// Check for error messages. Quota exhaustion is reported with a 200
+	// status and a "Note" (per-minute) or "Information" (daily) message.
+	if raw, ok := result["Note"]; ok {
+		var note string
+		json.Unmarshal(raw, &note)
+		return nil, &RateLimitError{Message: note, RetryAfter: time.Minute}
+	}
+
+	if raw, ok := result["Information"]; ok {
+		var info string
+		json.Unmarshal(raw, &info)
+		return nil, &RateLimitError{Message: info, RetryAfter: time.Hour}
+	}
+
+	if raw, ok := result["Error Message"]; ok {
+		var errMsg string
+		json.Unmarshal(raw, &errMsg)
+		return nil, fmt.Errorf("API error: %s", errMsg)
+	}
+
+	return result, nil
+}
+
+// GetQuote fetches the latest price for a symbol
+func (p *AlphaVantageProvider) GetQuote(symbol string) (*Quote, error) {
+	result, err := p.query(url.Values{"function": {"GLOBAL_QUOTE"}, "symbol": {symbol}})
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract price from Global Quote
+	var globalQuote map[string]string
+	if err := json.Unmarshal(result["Global Quote"], &globalQuote); err != nil || globalQuote == nil {
+		return nil, fmt.Errorf("invalid response format: missing Global Quote")
+	}
+	// Unknown symbols get an empty quote rather than an error message
+	if len(globalQuote) == 0 {
+		return nil, fmt.Errorf("%w: no quote for %s", ErrSymbolNotFound, symbol)
+	}
+
+	priceStr, ok := globalQuote["05. price"]
+	if !ok {
+		return nil, fmt.Errorf("invalid response format: missing price field")
+	}
+
+	price, err := money.Parse(priceStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse price: %w", err)
+	}
+
+	timestamp := time.Now()
+	if day, err := time.Parse("2006-01-02", globalQuote["07. latest trading day"]); err == nil {
+		timestamp = day
+	}
+
+	return &Quote{
+		Symbol:    symbol,
+		Price:     price,
+		Timestamp: timestamp,
+		Provider:  p.Name(),
+	}, nil
+}
+
+// GetQuotes fetches the latest prices for several symbols
+func (p *AlphaVantageProvider) GetQuotes(symbols []string) (map[string]*Quote, error) {
+	return fetchQuotesSequentially(p, symbols)
+}
+
+// GetHistory returns daily closes between from and to
+func (p *AlphaVantageProvider) GetHistory(symbol string, from, to time.Time) ([]Bar, error) {
+	params := url.Values{"function": {"TIME_SERIES_DAILY"}, "symbol": {symbol}}
+	// The compact output only covers the last 100 trading days
+	if time.Since(from) > 100*24*time.Hour {
+		params.Set("outputsize", "full")
+	}
+
+	result, err := p.query(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var series map[string]map[string]string
+	if err := json.Unmarshal(result["Time Series (Daily)"], &series); err != nil || series == nil {
+		return nil, fmt.Errorf("invalid response format: missing Time Series (Daily)")
+	}
+
+	fromDate, toDate := truncateToDate(from), truncateToDate(to)
+	bars := make([]Bar, 0, len(series))
+	for day, values := range series {
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil || date.Before(fromDate) || date.After(toDate) {
+			continue
+		}
+		closePrice, err := money.Parse(values["4. close"])
+		if err != nil {
+			continue
+		}
+		bars = append(bars, Bar{Date: date, Close: closePrice})
+	}
+
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+	return bars, nil
+}
+
+// SearchSymbols looks up symbols matching the query
+func (p *AlphaVantageProvider) SearchSymbols(query string) ([]SymbolInfo, error) {
+	result, err := p.query(url.Values{"function": {"SYMBOL_SEARCH"}, "keywords": {query}})
+	if err != nil {
+		return nil, err
+	}
+
+	var bestMatches []map[string]string
+	if err := json.Unmarshal(result["bestMatches"], &bestMatches); err != nil {
+		return nil, fmt.Errorf("invalid response format: missing bestMatches")
+	}
+
+	matches := make([]SymbolInfo, 0, len(bestMatches))
+	for _, m := range bestMatches {
+		matches = append(matches, SymbolInfo{
+			Symbol:   m["1. symbol"],
+			Name:     m["2. name"],
+			Type:     m["3. type"],
+			Exchange: m["4. region"],
+			Currency: m["8. currency"],
+		})
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// amortizationPlaces is the precision of periodic interest before it is
+// rounded to the debt's currency
+const amortizationPlaces = 10
+
+// maxSchedulePayments caps a schedule driven by a fixed payment alone, which
+// has no term to end it (100 years of weekly payments)
+const maxSchedulePayments = 100 * 52
+
+// ErrInvalidLoanTerms is returned when a debt's term, payment frequency or
+// payment amount cannot be amortized
+var ErrInvalidLoanTerms = errors.New("invalid loan terms")
+
+// ValidateLoanTerms checks a debt's loan terms, defaulting the payment
+// frequency to monthly when a term or payment is set
+func ValidateLoanTerms(debt *models.Debt) error {
+	if debt.TermMonths < 0 {
+		return fmt.Errorf("%w: term_months cannot be negative", ErrInvalidLoanTerms)
+	}
+	if debt.PaymentAmount != nil && !debt.PaymentAmount.IsPositive() {
+		return fmt.Errorf("%w: payment_amount must be positive", ErrInvalidLoanTerms)
+	}
+	if debt.InterestRate < 0 {
+		return fmt.Errorf("%w: interest_rate cannot be negative", ErrInvalidLoanTerms)
+	}
+	if !debt.HasLoanTerms() {
+		if debt.PaymentFrequency != "" {
+			return fmt.Errorf("%w: payment_frequency needs term_months or payment_amount", ErrInvalidLoanTerms)
+		}
+		return nil
+	}
+
+	if debt.PaymentFrequency == "" {
+		debt.PaymentFrequency = models.PaymentFrequencyMonthly
+	}
+	if debt.PaymentFrequency.PeriodsPerYear() == 0 {
+		return fmt.Errorf("%w: unknown payment_frequency %q (use monthly, biweekly or weekly)", ErrInvalidLoanTerms, debt.PaymentFrequency)
+	}
+	return nil
+}
+
+// BuildAmortizationSchedule lays out every payment of a debt from its start
+// date until the principal is repaid.
+//
+// Each payment first covers the interest on the remaining balance for the
+// period (the annual rate divided by the payments per year) and the rest
+// repays principal. Without a payment amount the level payment that repays
+// the principal over the term is used; with both, the fixed payment is made
+// and the balance left at the end of the term is due with the last payment.
+// Rounding differences are settled by the last payment.
+//
+// A period is charged the rate in effect on the day it starts. When the rate
+// changes, a level payment is recalculated to repay the remaining balance
+// over the rest of the term; a fixed payment stays the same.
+func BuildAmortizationSchedule(debt *models.Debt) (*models.AmortizationSchedule, error) {
+	if !debt.HasLoanTerms() {
+		return nil, fmt.Errorf("%w: debt has no term_months or payment_amount", ErrInvalidLoanTerms)
+	}
+	if err := ValidateLoanTerms(debt); err != nil {
+		return nil, err
+	}
+
+	periodsPerYear := debt.PaymentFrequency.PeriodsPerYear()
+	payments := 0
+	if debt.TermMonths > 0 {
+		payments = int(math.Round(float64(debt.TermMonths*periodsPerYear) / 12))
+		if payments < 1 {
+			payments = 1
+		}
+	}
+
+	start := truncateToDate(debt.StartDate)
+	rate := RateOn(debt, start)
+	payment := money.Zero
+	if debt.PaymentAmount != nil {
+		payment = *debt.PaymentAmount
+	} else {
+		payment = levelPayment(debt.Principal, rate, periodsPerYear, payments, debt.Currency)
+	}
+
+	// Interest for a period is balance × rate% / payments per year
+	periodDivisor := money.NewFromInt(int64(100 * periodsPerYear))
+
+	schedule := &models.AmortizationSchedule{
+		DebtID:           debt.ID,
+		Currency:         debt.Currency,
+		Principal:        debt.Principal,
+		InterestRate:     debt.InterestRate,
+		PaymentFrequency: debt.PaymentFrequency,
+		PaymentAmount:    payment,
+		TotalInterest:    money.Zero,
+		TotalPaid:        money.Zero,
+		Payments:         []models.AmortizationPayment{},
+	}
+
+	balance := debt.Principal
+	periodStart := start
+	for number := 1; balance.IsPositive(); number++ {
+		if number > maxSchedulePayments {
+			return nil, fmt.Errorf("%w: payment_amount does not repay the debt within 100 years", ErrInvalidLoanTerms)
+		}
+
+		if periodRate := RateOn(debt, periodStart); periodRate != rate {
+			rate = periodRate
+			if debt.PaymentAmount == nil {
+				payment = levelPayment(balance, rate, periodsPerYear, payments-number+1, debt.Currency)
+			}
+		}
+
+		interest := money.RoundTo(balance.Mul(money.NewFromFloat(rate)).Div(periodDivisor, amortizationPlaces), debt.Currency)
+		amount := payment
+		if number == payments || !amount.LessThan(balance.Add(interest)) {
+			amount = balance.Add(interest)
+		}
+		principal := amount.Sub(interest)
+		if !principal.IsPositive() {
+			return nil, fmt.Errorf("%w: payment_amount %s does not cover the %s interest due each period",
+				ErrInvalidLoanTerms, payment.String(), interest.String())
+		}
+		balance = balance.Sub(principal)
+
+		date := paymentDate(start, debt.PaymentFrequency, number)
+		schedule.Payments = append(schedule.Payments, models.AmortizationPayment{
+			Number:       number,
+			Date:         date,
+			Payment:      amount,
+			Interest:     interest,
+			Principal:    principal,
+			Balance:      balance,
+			InterestRate: rate,
+		})
+		schedule.TotalInterest = schedule.TotalInterest.Add(interest)
+		schedule.TotalPaid = schedule.TotalPaid.Add(amount)
+		periodStart = date
+	}
+
+	if len(schedule.Payments) > 0 {
+		schedule.PayoffDate = schedule.Payments[len(schedule.Payments)-1].Date
+	}
+	schedule.BalanceToday = ScheduledBalance(schedule, time.Now())
+	return schedule, nil
+}
+
+// levelPayment returns the equal payment that repays principal with interest
+// over the given number of payments, rounded to the currency
+func levelPayment(principal money.Decimal, rate float64, periodsPerYear, payments int, currency string) money.Decimal {
+	if payments < 1 {
+		return principal
+	}
+	r := rate / 100 / float64(periodsPerYear)
+	if r == 0 {
+		return money.RoundTo(principal.Div(money.NewFromInt(int64(payments)), amortizationPlaces), currency)
+	}
+	factor := r / (1 - math.Pow(1+r, -float64(payments)))
+	return money.RoundTo(principal.Mul(money.NewFromFloat(factor)), currency)
+}
+
+// paymentDate returns the due date of the given payment: whole months after
+// the start date for monthly loans, whole weeks for weekly and biweekly ones
+func paymentDate(start time.Time, frequency models.PaymentFrequency, number int) time.Time {
+	switch frequency {
+	case models.PaymentFrequencyBiweekly:
+		return start.AddDate(0, 0, 14*number)
+	case models.PaymentFrequencyWeekly:
+		return start.AddDate(0, 0, 7*number)
+	}
+	return addMonthsClamped(start, number)
+}
+
+// ScheduledBalance returns the balance a schedule leaves after the payments
+// due on or before a date, or the principal before the first payment
+func ScheduledBalance(schedule *models.AmortizationSchedule, on time.Time) money.Decimal {
+	on = truncateToDate(on)
+	balance := schedule.Principal
+	for _, payment := range schedule.Payments {
+		if payment.Date.After(on) {
+			break
+		}
+		balance = payment.Balance
+	}
+	return balance
+}
+
+// MonthlyPayment returns a loan's scheduled payment as a monthly amount (26
+// biweekly payments a year are 26/12 of a payment a month): the next payment
+// due, unless that is the last one, so a payment recalculated for a new rate
+// counts. ok is false for debts without loan terms.
+func MonthlyPayment(debt *models.Debt) (payment money.Decimal, ok bool, err error) {
+	if !debt.HasLoanTerms() {
+		return money.Zero, false, nil
+	}
+	schedule, err := BuildAmortizationSchedule(debt)
+	if err != nil {
+		return money.Zero, false, err
+	}
+	payment = schedule.PaymentAmount
+	now := time.Now()
+	for i, scheduled := range schedule.Payments {
+		if scheduled.Date.After(now) {
+			if i < len(schedule.Payments)-1 {
+				payment = scheduled.Payment
+			}
+			break
+		}
+	}
+
+	periodsPerYear := money.NewFromInt(int64(debt.PaymentFrequency.PeriodsPerYear()))
+	monthly := payment.Mul(periodsPerYear).Div(money.NewFromInt(12), amortizationPlaces)
+	return money.RoundTo(monthly, debt.Currency), true, nil
+}
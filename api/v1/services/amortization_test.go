@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func decimalPtr(s string) *money.Decimal {
+	d := money.MustParse(s)
+	return &d
+}
+
+func TestLevelPayment(t *testing.T) {
+	tests := []struct {
+		principal string
+		rate      float64
+		periods   int
+		payments  int
+		currency  string
+		want      string
+	}{
+		{principal: "200000", rate: 6, periods: 12, payments: 360, currency: "USD", want: "1199.10"},
+		{principal: "10000", rate: 12, periods: 12, payments: 12, currency: "USD", want: "888.49"},
+		{principal: "10000", rate: 12, periods: 26, payments: 26, currency: "USD", want: "409.04"},
+		{principal: "10000", rate: 0, periods: 12, payments: 12, currency: "USD", want: "833.33"},
+		{principal: "1000000", rate: 5, periods: 12, payments: 360, currency: "JPY", want: "5368"},
+		{principal: "750", rate: 10, periods: 12, payments: 0, currency: "USD", want: "750"},
+	}
+	for _, tt := range tests {
+		got := levelPayment(money.MustParse(tt.principal), tt.rate, tt.periods, tt.payments, tt.currency)
+		if got.String() != tt.want {
+			t.Errorf("levelPayment(%s at %v%%, %d a year, %d payments) = %s, want %s",
+				tt.principal, tt.rate, tt.periods, tt.payments, got, tt.want)
+		}
+	}
+}
+
+func TestBuildAmortizationSchedule(t *testing.T) {
+	// A start at the end of January checks that monthly due dates clamp to
+	// the end of shorter months
+	start := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	type payment struct{ date, amount, interest, principal, balance string }
+	tests := []struct {
+		name          string
+		debt          models.Debt
+		payments      int
+		totalInterest string
+		first, last   payment
+		secondDate    string
+	}{
+		{
+			name:          "level monthly payment",
+			debt:          models.Debt{Principal: money.MustParse("10000"), InterestRate: 12, TermMonths: 12},
+			payments:      12,
+			totalInterest: "661.86",
+			first:         payment{"2024-02-29", "888.49", "100.00", "788.49", "9211.51"},
+			last:          payment{"2025-01-31", "888.47", "8.80", "879.67", "0.00"},
+			secondDate:    "2024-03-31",
+		},
+		{
+			name:          "level biweekly payment",
+			debt:          models.Debt{Principal: money.MustParse("10000"), InterestRate: 12, TermMonths: 12, PaymentFrequency: models.PaymentFrequencyBiweekly},
+			payments:      26,
+			totalInterest: "635.05",
+			first:         payment{"2024-02-14", "409.04", "46.15", "362.89", "9637.11"},
+			last:          payment{"2025-01-29", "409.05", "1.88", "407.17", "0.00"},
+			secondDate:    "2024-02-28",
+		},
+		{
+			name:          "fixed payment until paid off",
+			debt:          models.Debt{Principal: money.MustParse("1000"), InterestRate: 18, PaymentAmount: decimalPtr("100")},
+			payments:      11,
+			totalInterest: "91.62",
+			first:         payment{"2024-02-29", "100", "15.00", "85.00", "915.00"},
+			last:          payment{"2024-12-31", "91.62", "1.35", "90.27", "0.00"},
+			secondDate:    "2024-03-31",
+		},
+		{
+			name:          "fixed payment with a balloon at the end of the term",
+			debt:          models.Debt{Principal: money.MustParse("10000"), InterestRate: 6, TermMonths: 12, PaymentAmount: decimalPtr("500")},
+			payments:      12,
+			totalInterest: "449.00",
+			first:         payment{"2024-02-29", "500", "50.00", "450.00", "9550.00"},
+			last:          payment{"2025-01-31", "4949.00", "24.62", "4924.38", "0.00"},
+			secondDate:    "2024-03-31",
+		},
+	}
+	for _, tt := range tests {
+		debt := tt.debt
+		debt.Currency, debt.StartDate = "USD", start
+		schedule, err := BuildAmortizationSchedule(&debt)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if len(schedule.Payments) != tt.payments || schedule.TotalInterest.String() != tt.totalInterest {
+			t.Errorf("%s: %d payments with %s interest, want %d with %s",
+				tt.name, len(schedule.Payments), schedule.TotalInterest, tt.payments, tt.totalInterest)
+			continue
+		}
+		if total := schedule.TotalInterest.Add(debt.Principal); !schedule.TotalPaid.Equal(total) {
+			t.Errorf("%s: total paid %s, want principal plus interest %s", tt.name, schedule.TotalPaid, total)
+		}
+		for _, check := range []struct {
+			got  models.AmortizationPayment
+			want payment
+		}{{schedule.Payments[0], tt.first}, {schedule.Payments[len(schedule.Payments)-1], tt.last}} {
+			got := payment{check.got.Date.Format("2006-01-02"), check.got.Payment.String(), check.got.Interest.String(),
+				check.got.Principal.String(), check.got.Balance.String()}
+			if got != check.want {
+				t.Errorf("%s: payment %d = %v, want %v", tt.name, check.got.Number, got, check.want)
+			}
+		}
+		if got := schedule.Payments[1].Date.Format("2006-01-02"); got != tt.secondDate {
+			t.Errorf("%s: second payment due %s, want %s", tt.name, got, tt.secondDate)
+		}
+	}
+}
+
+func TestBuildAmortizationScheduleRejectsTerms(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		debt models.Debt
+	}{
+		{name: "no terms", debt: models.Debt{Principal: money.MustParse("1000"), InterestRate: 5}},
+		{name: "payment below the interest", debt: models.Debt{Principal: money.MustParse("10000"), InterestRate: 24, PaymentAmount: decimalPtr("150")}},
+		{name: "negative term", debt: models.Debt{Principal: money.MustParse("1000"), TermMonths: -12}},
+		{name: "unknown frequency", debt: models.Debt{Principal: money.MustParse("1000"), TermMonths: 12, PaymentFrequency: "daily"}},
+	}
+	for _, tt := range tests {
+		debt := tt.debt
+		debt.Currency, debt.StartDate = "USD", start
+		if _, err := BuildAmortizationSchedule(&debt); !errors.Is(err, ErrInvalidLoanTerms) {
+			t.Errorf("%s: got error %v, want ErrInvalidLoanTerms", tt.name, err)
+		}
+	}
+}
+
+func TestScheduledBalance(t *testing.T) {
+	debt := models.Debt{
+		Principal:    money.MustParse("10000"),
+		InterestRate: 12,
+		TermMonths:   12,
+		Currency:     "USD",
+		StartDate:    time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+	}
+	schedule, err := BuildAmortizationSchedule(&debt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		on   time.Time
+		want string
+	}{
+		{on: time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), want: "10000"},
+		{on: time.Date(2024, 2, 29, 15, 0, 0, 0, time.UTC), want: "9211.51"},
+		{on: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), want: "0.00"},
+	}
+	for _, tt := range tests {
+		if got := ScheduledBalance(schedule, tt.on); got.String() != tt.want {
+			t.Errorf("ScheduledBalance on %s = %s, want %s", tt.on.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HistoryBackfillJobName is the scheduler name of the bulk history backfill job
+const HistoryBackfillJobName = "history_backfill"
+
+// backfillSlack tolerates history that starts a few days after the purchase
+// date because of weekends and exchange holidays
+const backfillSlack = 7 * 24 * time.Hour
+
+var (
+	// ErrAssetNotFound is returned when the requested asset does not exist
+	ErrAssetNotFound = errors.New("asset not found")
+	// ErrNotMarketPriced is returned for assets without a market data source
+	ErrNotMarketPriced = errors.New("asset is not priced from market data")
+)
+
+// BackfillResult describes the history imported for one asset
+type BackfillResult struct {
+	AssetID  string `json:"asset_id"`
+	Symbol   string `json:"symbol"`
+	Provider string `json:"provider,omitempty"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Bars     int    `json:"bars"`
+	Inserted int    `json:"inserted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// GetHistory returns daily closes for a symbol, walking the failover chain
+// starting at the named provider. It also returns the provider that answered.
+func (s *MarketDataService) GetHistory(providerName, symbol string, from, to time.Time) ([]Bar, string, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	var bars []Bar
+	name, err := s.withFailover(providerName, func(p QuoteProvider) error {
+		var err error
+		bars, err = p.GetHistory(symbol, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("all providers failed for %s history (%v)", symbol, err)
+	}
+	return bars, name, nil
+}
+
+// GetDividends returns dividends per share for a symbol from the first
+// provider in the failover chain that serves dividend history
+func (s *MarketDataService) GetDividends(providerName, symbol string, from, to time.Time) ([]Dividend, string, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	var dividends []Dividend
+	name, err := s.withFailover(providerName, func(p QuoteProvider) error {
+		dp, ok := p.(DividendProvider)
+		if !ok {
+			return errNotSupported
+		}
+		var err error
+		dividends, err = dp.GetDividends(symbol, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("all providers failed for %s dividends (%v)", symbol, err)
+	}
+	return dividends, name, nil
+}
+
+// GetSplits returns stock splits for a symbol from the first provider in the
+// failover chain that serves split history
+func (s *MarketDataService) GetSplits(providerName, symbol string, from, to time.Time) ([]Split, string, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	var splits []Split
+	name, err := s.withFailover(providerName, func(p QuoteProvider) error {
+		sp, ok := p.(SplitProvider)
+		if !ok {
+			return errNotSupported
+		}
+		var err error
+		splits, err = sp.GetSplits(symbol, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("all providers failed for %s splits (%v)", symbol, err)
+	}
+	return splits, name, nil
+}
+
+// BackfillAssetHistory imports daily closes for a market-priced asset into
+// asset_history. By default it only fetches what is missing: the full range
+// from the purchase date when history does not reach back that far, and
+// otherwise the days since the last recorded value. full forces a fetch of
+// the whole range. Existing rows are never overwritten.
+func (s *MarketDataService) BackfillAssetHistory(assetID string, full bool) (*BackfillResult, error) {
+	var assetType, symbol, source, provider string
+	var purchaseDate time.Time
+	query := `SELECT type, COALESCE(symbol, ''), source, COALESCE(provider, ''), purchase_date FROM assets WHERE id = $1`
+	err := s.db.QueryRow(query, assetID).Scan(&assetType, &symbol, &source, &provider, &purchaseDate)
+	if err == sql.ErrNoRows {
+		return nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch asset: %w", err)
+	}
+
+	if !IsMarketPriced(assetType, source, symbol) {
+		return nil, ErrNotMarketPriced
+	}
+
+	to := truncateToDate(time.Now()).AddDate(0, 0, -1)
+	from := truncateToDate(purchaseDate)
+	if !full {
+		from, err = s.backfillStart(assetID, from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &BackfillResult{
+		AssetID: assetID,
+		Symbol:  symbol,
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+	}
+	if from.After(to) {
+		// Already up to date
+		return result, nil
+	}
+
+	bars, providerName, err := s.GetHistory(s.PreferredProvider(assetType, provider), symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+	result.Provider = providerName
+	result.Bars = len(bars)
+
+	result.Inserted, err = s.insertHistoryBars(assetID, bars)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Backfill] %s (%s): %d bars from %s, %d new rows", symbol, assetID, len(bars), providerName, result.Inserted)
+	return result, nil
+}
+
+// BackfillAllAssetHistory backfills every market-priced asset. Failures are
+// reported per asset and do not stop the run.
+func (s *MarketDataService) BackfillAllAssetHistory() ([]BackfillResult, error) {
+	rows, err := s.db.Query(`SELECT id FROM assets WHERE type IN ('stock', 'crypto', 'investment') AND source = 'market_api' ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch assets: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	results := []BackfillResult{}
+	failed := 0
+	for _, id := range ids {
+		result, err := s.BackfillAssetHistory(id, false)
+		if errors.Is(err, ErrNotMarketPriced) {
+			continue
+		}
+		if err != nil {
+			failed++
+			results = append(results, BackfillResult{AssetID: id, Error: err.Error()})
+			continue
+		}
+		results = append(results, *result)
+	}
+
+	if failed > 0 && failed == len(results) {
+		return results, fmt.Errorf("history backfill failed for every asset")
+	}
+	return results, nil
+}
+
+// backfillStart decides where an incremental backfill should begin
+func (s *MarketDataService) backfillStart(assetID string, purchaseDate time.Time) (time.Time, error) {
+	var earliest, latest sql.NullTime
+	query := `
+		SELECT MIN(date), MAX(date) FILTER (WHERE date < CURRENT_DATE)
+		FROM asset_history
+		WHERE asset_id = $1
+	`
+	if err := s.db.QueryRow(query, assetID).Scan(&earliest, &latest); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read asset history: %w", err)
+	}
+
+	// History does not reach back to the purchase: fetch everything
+	if !earliest.Valid || earliest.Time.Sub(purchaseDate) > backfillSlack || !latest.Valid {
+		return purchaseDate, nil
+	}
+
+	return truncateToDate(latest.Time).AddDate(0, 0, 1), nil
+}
+
+// insertHistoryBars stores bars as asset_history rows, keeping existing rows
+func (s *MarketDataService) insertHistoryBars(assetID string, bars []Bar) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO asset_history (id, asset_id, value, date, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (asset_id, date) DO NOTHING
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	now := time.Now()
+	for _, bar := range bars {
+		result, err := stmt.Exec(uuid.New().String(), assetID, bar.Close, bar.Date.Format("2006-01-02"), now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert history for %s: %w", bar.Date.Format("2006-01-02"), err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			inserted++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return inserted, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+// historyTable keeps asset_history rows by date the way the database does
+// for the backfill: the range of dates stored, and inserts that keep
+// existing rows
+func historyTable(fake *fakeDB, dates ...string) map[string]string {
+	rows := make(map[string]string)
+	for _, date := range dates {
+		rows[date] = "100.00"
+	}
+
+	fake.on("SELECT MIN(date), MAX(date)", func([]driver.Value) (fakeRows, error) {
+		var earliest, latest string
+		for date := range rows {
+			if earliest == "" || date < earliest {
+				earliest = date
+			}
+			if date > latest {
+				latest = date
+			}
+		}
+		if earliest == "" {
+			return fakeRows{columns: []string{"min", "max"}, values: [][]driver.Value{{nil, nil}}}, nil
+		}
+		return fakeRows{columns: []string{"min", "max"}, values: [][]driver.Value{{mustDate(earliest), mustDate(latest)}}}, nil
+	})
+	fake.on("INSERT INTO asset_history", func(args []driver.Value) (fakeRows, error) {
+		date := args[3].(string)
+		if _, ok := rows[date]; ok {
+			return fakeRows{affected: 0}, nil
+		}
+		rows[date] = args[2].(string)
+		return fakeRows{affected: 1}, nil
+	})
+	return rows
+}
+
+func TestBackfillAssetHistory(t *testing.T) {
+	fixtures, err := NewFixtureProvider(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The AAPL fixture has the nine trading days from 2023-12-18 to 2023-12-29
+	tests := []struct {
+		name     string
+		stored   []string
+		full     bool
+		from     string
+		bars     int
+		inserted int
+	}{
+		{name: "no history", from: "2023-12-18", bars: 9, inserted: 9},
+		{name: "days since the last value", stored: []string{"2023-12-18", "2023-12-19", "2023-12-20", "2023-12-21"}, from: "2023-12-22", bars: 5, inserted: 5},
+		// History starting over a week after the purchase is fetched again in full
+		{name: "history starts late", stored: []string{"2023-12-27", "2023-12-28", "2023-12-29"}, from: "2023-12-18", bars: 9, inserted: 6},
+		{name: "full refetch keeps rows", stored: []string{"2023-12-18", "2023-12-29"}, full: true, from: "2023-12-18", bars: 9, inserted: 7},
+	}
+	for _, tt := range tests {
+		db, fake := newFakeDB(t)
+		fake.returns("FROM assets WHERE id = $1", []string{"type", "symbol", "source", "provider", "purchase_date"},
+			[]driver.Value{"stock", "AAPL", "market_api", "", mustDate("2023-12-18")},
+		)
+		rows := historyTable(fake, tt.stored...)
+		s := newStubMarketData(fixtures)
+		s.db = db
+
+		result, err := s.BackfillAssetHistory("aapl", tt.full)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if result.From != tt.from || result.Bars != tt.bars || result.Inserted != tt.inserted || result.Provider != string(ProviderFixture) {
+			t.Errorf("%s: %+v, want %d bars from %s with %d new rows", tt.name, result, tt.bars, tt.from, tt.inserted)
+		}
+		if len(rows) != len(tt.stored)+tt.inserted {
+			t.Errorf("%s: %d history rows, want %d", tt.name, len(rows), len(tt.stored)+tt.inserted)
+		}
+
+		// Run again, the history is complete: nothing is fetched or written
+		again, err := s.BackfillAssetHistory("aapl", false)
+		if err != nil {
+			t.Errorf("%s: second run failed: %v", tt.name, err)
+			continue
+		}
+		if again.From != "2023-12-30" || again.Inserted != 0 {
+			t.Errorf("%s: second run %+v, want it to start on 2023-12-30 and insert nothing", tt.name, again)
+		}
+	}
+}
+
+func TestBackfillAssetHistoryRejects(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.on("FROM assets WHERE id = $1", func(args []driver.Value) (fakeRows, error) {
+		columns := []string{"type", "symbol", "source", "provider", "purchase_date"}
+		if args[0] == "cash" {
+			return fakeRows{columns: columns, values: [][]driver.Value{{"cash", "", "manual", "", mustDate("2023-12-18")}}}, nil
+		}
+		return fakeRows{columns: columns}, nil
+	})
+	s := newStubMarketData(&stubProvider{name: "stub"})
+	s.db = db
+
+	if _, err := s.BackfillAssetHistory("cash", false); !errors.Is(err, ErrNotMarketPriced) {
+		t.Errorf("cash backfill returned %v, want ErrNotMarketPriced", err)
+	}
+	if _, err := s.BackfillAssetHistory("missing", false); !errors.Is(err, ErrAssetNotFound) {
+		t.Errorf("missing asset backfill returned %v, want ErrAssetNotFound", err)
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// backupTables lists the tables a backup holds, each after the tables it
+// references so they can be restored in order. Income is stored in
+// transactions. Prices and exchange rates are caches fetched again from the
+// providers and are left out.
+var backupTables = []string{
+	"assets",
+	"asset_history",
+	"transactions",
+	"corporate_actions",
+	"debts",
+	"debt_payments",
+	"debt_history",
+	"debt_rates",
+	"rate_index_values",
+	"networth_snapshots",
+}
+
+// BackupTables returns every row of the backed up tables as JSON arrays
+// keyed by table name. Rows keep all their columns with the stored values,
+// so RestoreTables can put them back exactly.
+func BackupTables(db querier) (map[string]json.RawMessage, error) {
+	tables := make(map[string]json.RawMessage, len(backupTables))
+	for _, table := range backupTables {
+		var rows []byte
+		err := db.QueryRow(`SELECT COALESCE(json_agg(t), '[]'::json) FROM ` + table + ` t`).Scan(&rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", table, err)
+		}
+		tables[table] = rows
+	}
+	return tables, nil
+}
+
+// RestoreResult counts the rows of each table in a backup and those restored
+type RestoreResult struct {
+	Restored map[string]int64 `json:"restored"`
+	Skipped  map[string]int64 `json:"skipped"`
+}
+
+// RestoreTables inserts the rows of a backup made by BackupTables in one
+// transaction. Rows whose id (or other unique key) already exists are
+// skipped, so restoring into a database that holds part of the backup adds
+// only what is missing. Tables not in the backup are left alone.
+func RestoreTables(db *sql.DB, tables map[string]json.RawMessage) (*RestoreResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &RestoreResult{Restored: make(map[string]int64), Skipped: make(map[string]int64)}
+	for _, table := range backupTables {
+		rows, ok := tables[table]
+		if !ok {
+			continue
+		}
+		var list []json.RawMessage
+		if err := json.Unmarshal(rows, &list); err != nil {
+			return nil, fmt.Errorf("%s: expected an array of rows: %w", table, err)
+		}
+		if len(list) == 0 {
+			result.Restored[table], result.Skipped[table] = 0, 0
+			continue
+		}
+
+		res, err := tx.Exec(
+			`INSERT INTO `+table+` SELECT * FROM json_populate_recordset(NULL::`+table+`, $1::json) ON CONFLICT DO NOTHING`,
+			string(rows),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", table, err)
+		}
+		restored, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		result.Restored[table] = restored
+		result.Skipped[table] = int64(len(list)) - restored
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
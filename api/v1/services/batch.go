@@ -0,0 +1,283 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+const defaultMaxConcurrency = 8
+
+// inflightQuote is a quote fetch shared by every caller asking for the same key
+type inflightQuote struct {
+	wg    sync.WaitGroup
+	quote *Quote
+	err   error
+}
+
+// quoteGroup coalesces concurrent fetches for the same provider and symbol
+// so only one request reaches the provider
+type quoteGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inflightQuote
+}
+
+// do runs fn once per key at a time; concurrent callers wait for and share its result
+func (g *quoteGroup) do(key string, fn func() (*Quote, error)) (*Quote, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*inflightQuote)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return copyQuote(call.quote), call.err
+	}
+
+	call := &inflightQuote{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.quote, call.err = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return copyQuote(call.quote), call.err
+}
+
+// doMany is do for several keys at once. Keys already being fetched wait for
+// that fetch, and fn fetches all the others in one call. Keys fn returns no
+// quote for get its error. The result holds the keys that have a quote.
+func (g *quoteGroup) doMany(keys []string, fn func(keys []string) (map[string]*Quote, error)) map[string]*Quote {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*inflightQuote)
+	}
+	waiting := make(map[string]*inflightQuote)
+	owned := make(map[string]*inflightQuote)
+	var fetch []string
+	for _, key := range keys {
+		if call, ok := g.calls[key]; ok {
+			waiting[key] = call
+			continue
+		}
+		call := &inflightQuote{}
+		call.wg.Add(1)
+		g.calls[key] = call
+		owned[key] = call
+		fetch = append(fetch, key)
+	}
+	g.mu.Unlock()
+
+	quotes := make(map[string]*Quote, len(keys))
+	if len(fetch) > 0 {
+		fetched, err := fn(fetch)
+		if err == nil {
+			err = errors.New("no quote returned")
+		}
+
+		g.mu.Lock()
+		for key, call := range owned {
+			if call.quote = fetched[key]; call.quote == nil {
+				call.err = err
+			}
+			call.wg.Done()
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+	}
+
+	for key, call := range owned {
+		if call.quote != nil {
+			quotes[key] = copyQuote(call.quote)
+		}
+	}
+	for key, call := range waiting {
+		call.wg.Wait()
+		if call.err == nil {
+			quotes[key] = copyQuote(call.quote)
+		}
+	}
+	return quotes
+}
+
+// copyQuote gives each caller its own Quote so results can be annotated safely
+func copyQuote(q *Quote) *Quote {
+	if q == nil {
+		return nil
+	}
+	c := *q
+	return &c
+}
+
+// maxConcurrencyFromEnv reads MARKET_DATA_MAX_CONCURRENCY
+func maxConcurrencyFromEnv() int {
+	if value := os.Getenv("MARKET_DATA_MAX_CONCURRENCY"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("[MarketData] Invalid MARKET_DATA_MAX_CONCURRENCY %q, using %d", value, defaultMaxConcurrency)
+	}
+	return defaultMaxConcurrency
+}
+
+// GetQuotes returns quotes for many symbols at once. Symbols are de-duplicated,
+// fresh prices are read from the database cache in a single query, and the
+// rest are split into at most MARKET_DATA_MAX_CONCURRENCY groups fetched
+// concurrently, each with one GetQuotes call to the provider. Symbols that
+// could not be priced are omitted.
+func (s *MarketDataService) GetQuotes(providerName string, symbols []string) map[string]*Quote {
+	return s.fetchQuotes(providerName, symbols, true)
+}
+
+// fetchQuotes implements GetQuotes. When useCache is false every symbol is
+// fetched from the providers and the cache only serves as last-known fallback.
+func (s *MarketDataService) fetchQuotes(providerName string, symbols []string, useCache bool) map[string]*Quote {
+	unique := make([]string, 0, len(symbols))
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		unique = append(unique, symbol)
+	}
+
+	quotes := make(map[string]*Quote, len(unique))
+	if len(unique) == 0 {
+		return quotes
+	}
+
+	stored := s.cachedQuotes(unique)
+
+	var missing []string
+	cached := make(map[string]*Quote, len(stored))
+	for _, symbol := range unique {
+		fresh, last := s.pickCached(providerName, stored[symbol])
+		if fresh != nil && useCache {
+			quotes[symbol] = fresh
+			continue
+		}
+		if last != nil {
+			cached[symbol] = last
+		}
+		missing = append(missing, symbol)
+	}
+	if len(missing) == 0 {
+		return quotes
+	}
+
+	// Split the symbols into one group per concurrent request
+	groups := s.maxConcurrency
+	if groups > len(missing) {
+		groups = len(missing)
+	}
+	size := (len(missing) + groups - 1) / groups
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for start := 0; start < len(missing); start += size {
+		end := start + size
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		wg.Add(1)
+		go func(symbols []string) {
+			defer wg.Done()
+			resolved := s.resolveQuotes(providerName, symbols, cached)
+
+			mu.Lock()
+			for symbol, quote := range resolved {
+				quotes[symbol] = quote
+			}
+			mu.Unlock()
+		}(missing[start:end])
+	}
+
+	wg.Wait()
+	return quotes
+}
+
+// resolveQuotes is resolveQuote for several symbols: the symbols not already
+// being fetched are asked of the failover chain together, and those no
+// provider prices fall back to their cached quote
+func (s *MarketDataService) resolveQuotes(providerName string, symbols []string, cached map[string]*Quote) map[string]*Quote {
+	keys := make([]string, len(symbols))
+	symbolOf := make(map[string]string, len(symbols))
+	for i, symbol := range symbols {
+		keys[i] = quoteKey(providerName, symbol)
+		symbolOf[keys[i]] = symbol
+	}
+
+	byKey := s.inflight.doMany(keys, func(keys []string) (map[string]*Quote, error) {
+		fetch := make([]string, len(keys))
+		for i, key := range keys {
+			fetch[i] = symbolOf[key]
+		}
+
+		fetched, err := s.fetchManyFromChain(providerName, fetch)
+		byKey := make(map[string]*Quote, len(keys))
+		for _, key := range keys {
+			symbol := symbolOf[key]
+			if quote, ok := fetched[symbol]; ok {
+				s.storeQuote(quote)
+				byKey[key] = quote
+				continue
+			}
+			if last := cached[symbol]; last != nil {
+				log.Printf("[MarketData] %v - using last known price for %s from %v", err, symbol, last.Timestamp)
+				stale := *last
+				stale.Provider = PriceSourceLastKnown
+				stale.Stale = true
+				byKey[key] = &stale
+				continue
+			}
+			log.Printf("[MarketData] ERROR fetching %s: %v", symbol, err)
+		}
+		return byKey, err
+	})
+
+	quotes := make(map[string]*Quote, len(byKey))
+	for key, quote := range byKey {
+		quotes[symbolOf[key]] = quote
+	}
+	return quotes
+}
+
+// cachedQuotes reads the stored prices of several symbols in one query: every
+// provider's price of each symbol, most recent first
+func (s *MarketDataService) cachedQuotes(symbols []string) map[string][]*Quote {
+	quotes := make(map[string][]*Quote, len(symbols))
+
+	query := `
+		SELECT symbol, price, last_updated, provider FROM stock_prices
+		WHERE symbol = ANY($1)
+		ORDER BY last_updated DESC
+	`
+	rows, err := s.db.Query(query, pq.Array(symbols))
+	if err != nil {
+		log.Printf("[MarketData] DB cache check error: %v", err)
+		return quotes
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var q Quote
+		if err := rows.Scan(&q.Symbol, &q.Price, &q.Timestamp, &q.Provider); err != nil {
+			continue
+		}
+		quotes[q.Symbol] = append(quotes[q.Symbol], &q)
+	}
+	return quotes
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+func TestQuoteGroupCoalesces(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		err       error
+		wantCalls int32
+	}{
+		{name: "same symbol", keys: []string{"yahoo:AAPL", "yahoo:AAPL", "yahoo:AAPL", "yahoo:AAPL"}, wantCalls: 1},
+		{name: "same symbol failing", keys: []string{"yahoo:AAPL", "yahoo:AAPL", "yahoo:AAPL"}, err: errors.New("provider down"), wantCalls: 1},
+		{name: "different providers", keys: []string{"yahoo:AAPL", "alphavantage:AAPL", "yahoo:AAPL"}, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		var g quoteGroup
+		var calls int32
+		release := make(chan struct{})
+		fetch := func() (*Quote, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			return &Quote{Symbol: "AAPL", Price: money.MustParse("189.95")}, nil
+		}
+
+		quotes := make([]*Quote, len(tt.keys))
+		errs := make([]error, len(tt.keys))
+		var wg sync.WaitGroup
+		for i, key := range tt.keys {
+			wg.Add(1)
+			go func(i int, key string) {
+				defer wg.Done()
+				quotes[i], errs[i] = g.do(key, fetch)
+			}(i, key)
+		}
+		// Let every caller reach the group before the fetches return
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if calls != tt.wantCalls {
+			t.Errorf("%s: provider called %d times, want %d", tt.name, calls, tt.wantCalls)
+		}
+		for i := range tt.keys {
+			if !errors.Is(errs[i], tt.err) {
+				t.Errorf("%s: caller %d got error %v, want %v", tt.name, i, errs[i], tt.err)
+			}
+			if tt.err == nil && (quotes[i] == nil || quotes[i].Price.String() != "189.95") {
+				t.Errorf("%s: caller %d got quote %+v, want 189.95", tt.name, i, quotes[i])
+			}
+		}
+		// Each caller gets its own copy to annotate
+		if tt.err == nil && quotes[0] == quotes[1] {
+			t.Errorf("%s: callers share one Quote", tt.name)
+		}
+	}
+}
+
+func TestQuoteGroupForgetsFinishedFetches(t *testing.T) {
+	var g quoteGroup
+	calls := 0
+	fetch := func() (*Quote, error) {
+		calls++
+		return &Quote{Symbol: "MSFT"}, nil
+	}
+
+	// Sequential requests each reach the provider
+	for i := 0; i < 3; i++ {
+		if _, err := g.do("yahoo:MSFT", fetch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("provider called %d times for 3 sequential requests, want 3", calls)
+	}
+	if len(g.calls) != 0 {
+		t.Errorf("%d fetches still in flight, want none", len(g.calls))
+	}
+}
+
+func TestQuoteGroupDoManyJoinsFetchesInFlight(t *testing.T) {
+	var g quoteGroup
+	release := make(chan struct{})
+	single := make(chan *Quote)
+	go func() {
+		q, _ := g.do("yahoo|AAPL", func() (*Quote, error) {
+			<-release
+			return &Quote{Symbol: "AAPL", Price: money.MustParse("189.95")}, nil
+		})
+		single <- q
+	}()
+	// Let the single fetch register before the batch starts
+	time.Sleep(50 * time.Millisecond)
+
+	var asked []string
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	quotes := g.doMany([]string{"yahoo|AAPL", "yahoo|MSFT", "yahoo|NOPE"}, func(keys []string) (map[string]*Quote, error) {
+		asked = keys
+		return map[string]*Quote{"yahoo|MSFT": {Symbol: "MSFT", Price: money.MustParse("410.10")}}, errors.New("no price for NOPE")
+	})
+
+	if len(asked) != 2 || asked[0] != "yahoo|MSFT" || asked[1] != "yahoo|NOPE" {
+		t.Errorf("batch fetched %v, want the keys not already in flight", asked)
+	}
+	if q := quotes["yahoo|AAPL"]; q == nil || q.Price.String() != "189.95" {
+		t.Errorf("AAPL = %+v, want the in-flight quote at 189.95", q)
+	}
+	if q := quotes["yahoo|MSFT"]; q == nil || q.Price.String() != "410.10" {
+		t.Errorf("MSFT = %+v, want 410.10", q)
+	}
+	if _, ok := quotes["yahoo|NOPE"]; ok {
+		t.Errorf("NOPE priced, want it left out")
+	}
+	if q := <-single; q == quotes["yahoo|AAPL"] {
+		t.Errorf("batch and single callers share one Quote")
+	}
+	if len(g.calls) != 0 {
+		t.Errorf("%d fetches still in flight, want none", len(g.calls))
+	}
+}
+
+func TestFetchManyFromChain(t *testing.T) {
+	tests := []struct {
+		name        string
+		primary     *stubProvider
+		secondary   *stubProvider
+		symbols     []string
+		wantPriced  map[string]string
+		wantBatches []int
+		wantErr     bool
+	}{
+		{
+			name:    "one batch",
+			primary: &stubProvider{name: "primary"}, secondary: &stubProvider{name: "secondary"},
+			symbols:    []string{"AAPL", "MSFT"},
+			wantPriced: map[string]string{"AAPL": "primary", "MSFT": "primary"},
+			// Only the primary is asked
+			wantBatches: []int{2, 0},
+		},
+		{
+			// The symbols the primary has no price for go to the next provider
+			name:    "partial answer",
+			primary: &stubProvider{name: "primary", unknown: []string{"BTC-USD"}}, secondary: &stubProvider{name: "secondary"},
+			symbols:     []string{"AAPL", "BTC-USD", "MSFT"},
+			wantPriced:  map[string]string{"AAPL": "primary", "BTC-USD": "secondary", "MSFT": "primary"},
+			wantBatches: []int{3, 1},
+		},
+		{
+			name:    "primary down",
+			primary: &stubProvider{name: "primary", err: errors.New("status 500")}, secondary: &stubProvider{name: "secondary"},
+			symbols:     []string{"AAPL", "MSFT"},
+			wantPriced:  map[string]string{"AAPL": "secondary", "MSFT": "secondary"},
+			wantBatches: []int{2, 2},
+		},
+		{
+			name:    "nobody has it",
+			primary: &stubProvider{name: "primary", unknown: []string{"NOPE"}}, secondary: &stubProvider{name: "secondary", unknown: []string{"NOPE"}},
+			symbols:     []string{"AAPL", "NOPE"},
+			wantPriced:  map[string]string{"AAPL": "primary"},
+			wantBatches: []int{2, 1},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		s := newStubMarketData(tt.primary, tt.secondary)
+		quotes, err := s.fetchManyFromChain("", tt.symbols)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+
+		priced := make(map[string]string, len(quotes))
+		for symbol, quote := range quotes {
+			priced[symbol] = quote.Provider
+		}
+		if len(priced) != len(tt.wantPriced) {
+			t.Errorf("%s: priced %v, want %v", tt.name, priced, tt.wantPriced)
+		}
+		for symbol, provider := range tt.wantPriced {
+			if priced[symbol] != provider {
+				t.Errorf("%s: %s priced by %q, want %q", tt.name, symbol, priced[symbol], provider)
+			}
+		}
+
+		batches := []int{0, 0}
+		for i, p := range []*stubProvider{tt.primary, tt.secondary} {
+			for _, batch := range p.batches {
+				batches[i] += len(batch)
+			}
+		}
+		if batches[0] != tt.wantBatches[0] || batches[1] != tt.wantBatches[1] {
+			t.Errorf("%s: providers asked for %v symbols, want %v", tt.name, batches, tt.wantBatches)
+		}
+		// Leaving symbols out is an answer, not an outage
+		if tt.primary.err == nil && s.breakers["primary"].Status().State != BreakerClosed {
+			t.Errorf("%s: primary breaker %s, want closed", tt.name, s.breakers["primary"].Status().State)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a provider circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+const (
+	defaultBreakerWindow         = 20
+	defaultBreakerMinRequests    = 5
+	defaultBreakerErrorRate      = 0.5
+	defaultBreakerMaxConsecutive = 3
+	defaultBreakerCooldown       = 2 * time.Minute
+)
+
+// RateLimitError is returned when a provider rejects a request because a quota was exhausted
+type RateLimitError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return "API limit reached: " + e.Message
+}
+
+// CircuitBreaker tracks the recent error rate of a provider and stops
+// calling it for a cooldown period once it looks unhealthy
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	name     string
+	cooldown time.Duration
+
+	state       BreakerState
+	openUntil   time.Time
+	outcomes    []bool // ring buffer of recent results, true = failure
+	next        int
+	consecutive int
+
+	totalRequests int64
+	totalFailures int64
+	lastError     string
+	lastErrorAt   time.Time
+}
+
+// BreakerStatus is a point-in-time view of a circuit breaker
+type BreakerStatus struct {
+	Provider            string       `json:"provider"`
+	State               BreakerState `json:"state"`
+	ErrorRate           float64      `json:"error_rate"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	TotalRequests       int64        `json:"total_requests"`
+	TotalFailures       int64        `json:"total_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time   `json:"last_error_at,omitempty"`
+	OpenUntil           *time.Time   `json:"open_until,omitempty"`
+}
+
+// NewCircuitBreaker creates a closed breaker for the named provider
+func NewCircuitBreaker(name string, cooldown time.Duration) *CircuitBreaker {
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &CircuitBreaker{
+		name:     name,
+		cooldown: cooldown,
+		state:    BreakerClosed,
+		outcomes: make([]bool, 0, defaultBreakerWindow),
+	}
+}
+
+// Allow reports whether a request may be sent to the provider. Once the
+// cooldown of an open breaker has elapsed a single trial request is allowed.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Now().Before(b.openUntil) {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// A trial request is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+// Release gives back a request allowed by Allow that never reached the
+// provider, so it counts neither way. A half-open breaker hands out its trial
+// request again.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		// The cooldown has already elapsed, so the next Allow starts a new trial
+		b.state = BreakerOpen
+	}
+}
+
+// RecordSuccess records a successful call and closes the breaker
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.totalRequests++
+	b.consecutive = 0
+	b.record(false)
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerClosed
+		b.outcomes = b.outcomes[:0]
+		b.next = 0
+	}
+}
+
+// RecordFailure records a failed call and opens the breaker when the
+// provider is rate limited or its recent error rate is too high
+func (b *CircuitBreaker) RecordFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.totalRequests++
+	b.totalFailures++
+	b.consecutive++
+	b.lastErrorAt = time.Now()
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	b.record(true)
+
+	var rateLimited *RateLimitError
+	switch {
+	case errors.As(err, &rateLimited):
+		cooldown := b.cooldown
+		if rateLimited.RetryAfter > cooldown {
+			cooldown = rateLimited.RetryAfter
+		}
+		b.trip(cooldown)
+	case b.state == BreakerHalfOpen:
+		b.trip(b.cooldown)
+	case b.consecutive >= defaultBreakerMaxConsecutive:
+		b.trip(b.cooldown)
+	case len(b.outcomes) >= defaultBreakerMinRequests && b.errorRate() >= defaultBreakerErrorRate:
+		b.trip(b.cooldown)
+	}
+}
+
+// Status returns a snapshot of the breaker
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Provider:            b.name,
+		State:               b.state,
+		ErrorRate:           b.errorRate(),
+		ConsecutiveFailures: b.consecutive,
+		TotalRequests:       b.totalRequests,
+		TotalFailures:       b.totalFailures,
+		LastError:           b.lastError,
+	}
+	if !b.lastErrorAt.IsZero() {
+		lastErrorAt := b.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	if b.state == BreakerOpen {
+		openUntil := b.openUntil
+		status.OpenUntil = &openUntil
+	}
+	return status
+}
+
+// record appends an outcome to the rolling window
+func (b *CircuitBreaker) record(failed bool) {
+	if len(b.outcomes) < defaultBreakerWindow {
+		b.outcomes = append(b.outcomes, failed)
+		return
+	}
+	b.outcomes[b.next] = failed
+	b.next = (b.next + 1) % defaultBreakerWindow
+}
+
+// errorRate returns the failure ratio over the rolling window
+func (b *CircuitBreaker) errorRate() float64 {
+	if len(b.outcomes) == 0 {
+		return 0
+	}
+	failures := 0
+	for _, failed := range b.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(b.outcomes))
+}
+
+// trip opens the breaker for the given cooldown
+func (b *CircuitBreaker) trip(cooldown time.Duration) {
+	b.state = BreakerOpen
+	b.openUntil = time.Now().Add(cooldown)
+}
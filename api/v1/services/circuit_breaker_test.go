@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTrips(t *testing.T) {
+	failure := errors.New("status 500")
+	rateLimited := &RateLimitError{Message: "too many requests", RetryAfter: 10 * time.Minute}
+
+	tests := []struct {
+		name     string
+		outcomes []error // nil is a success
+		want     BreakerState
+		// minimum time the breaker stays open
+		openFor time.Duration
+	}{
+		{name: "two failures", outcomes: []error{failure, failure}, want: BreakerClosed},
+		{name: "three consecutive failures", outcomes: []error{failure, failure, failure}, want: BreakerOpen, openFor: time.Minute},
+		// Four requests are too few to judge the error rate
+		{name: "half failing below the minimum", outcomes: []error{nil, failure, nil, failure}, want: BreakerClosed},
+		{name: "error rate of 60%", outcomes: []error{nil, failure, nil, failure, failure}, want: BreakerOpen},
+		{name: "error rate of 40%", outcomes: []error{nil, failure, nil, failure, nil}, want: BreakerClosed},
+		// Rate limits open the breaker at once, for at least the retry delay
+		{name: "rate limited", outcomes: []error{rateLimited}, want: BreakerOpen, openFor: 9 * time.Minute},
+	}
+	for _, tt := range tests {
+		b := NewCircuitBreaker("test", 2*time.Minute)
+		for _, err := range tt.outcomes {
+			if err == nil {
+				b.RecordSuccess()
+			} else {
+				b.RecordFailure(err)
+			}
+		}
+
+		status := b.Status()
+		if status.State != tt.want {
+			t.Errorf("%s: state %s, want %s", tt.name, status.State, tt.want)
+			continue
+		}
+		if tt.want == BreakerOpen {
+			if b.Allow() {
+				t.Errorf("%s: open breaker allowed a request", tt.name)
+			}
+			if status.OpenUntil == nil || time.Until(*status.OpenUntil) < tt.openFor {
+				t.Errorf("%s: open until %v, want at least %s from now", tt.name, status.OpenUntil, tt.openFor)
+			}
+		}
+		if status.TotalRequests != int64(len(tt.outcomes)) {
+			t.Errorf("%s: counted %d requests, want %d", tt.name, status.TotalRequests, len(tt.outcomes))
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name     string
+		trial    error
+		released bool
+		want     BreakerState
+	}{
+		{name: "trial succeeds", want: BreakerClosed},
+		{name: "trial fails", trial: errors.New("timeout"), want: BreakerOpen},
+		// A released trial is handed out again by the next Allow
+		{name: "trial released", released: true, want: BreakerHalfOpen},
+	}
+	for _, tt := range tests {
+		b := NewCircuitBreaker("test", time.Nanosecond)
+		for i := 0; i < defaultBreakerMaxConsecutive; i++ {
+			b.RecordFailure(errors.New("timeout"))
+		}
+		time.Sleep(time.Millisecond)
+
+		if !b.Allow() {
+			t.Fatalf("%s: breaker refused the trial request after its cooldown", tt.name)
+		}
+		if b.Allow() {
+			t.Errorf("%s: breaker allowed a second request during the trial", tt.name)
+		}
+		switch {
+		case tt.released:
+			b.Release()
+			if !b.Allow() {
+				t.Errorf("%s: breaker refused a new trial after the first was released", tt.name)
+			}
+		case tt.trial == nil:
+			b.RecordSuccess()
+		default:
+			b.RecordFailure(tt.trial)
+		}
+		if state := b.Status().State; state != tt.want {
+			t.Errorf("%s: state %s, want %s", tt.name, state, tt.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+const (
+	ProviderCoinbase MarketDataProvider = "coinbase"
+
+	coinbaseBaseURL         = "https://api.coinbase.com"
+	coinbaseExchangeBaseURL = "https://api.exchange.coinbase.com"
+
+	// coinbaseMaxCandles is the most candles the exchange API returns per request
+	coinbaseMaxCandles = 300
+	// coinbaseProductsTTL is how long the list of trading pairs is reused for search
+	coinbaseProductsTTL = 6 * time.Hour
+)
+
+// CoinbaseProvider fetches crypto prices for BASE-QUOTE pairs (BTC-USD,
+// ETH-EUR) from Coinbase's public market data (FREE, no API key). Prices are
+// exchange spot prices, nothing is read from a blockchain.
+type CoinbaseProvider struct {
+	httpClient      *http.Client
+	baseURL         string
+	exchangeBaseURL string
+
+	mu         sync.Mutex
+	products   []SymbolInfo
+	productsAt time.Time
+}
+
+// NewCoinbaseProvider creates a new Coinbase provider
+func NewCoinbaseProvider(httpClient *http.Client) *CoinbaseProvider {
+	return &CoinbaseProvider{
+		httpClient:      httpClient,
+		baseURL:         coinbaseBaseURL,
+		exchangeBaseURL: coinbaseExchangeBaseURL,
+	}
+}
+
+func init() {
+	RegisterProvider(string(ProviderCoinbase), func(cfg ProviderConfig) (QuoteProvider, error) {
+		return NewCoinbaseProvider(cfg.HTTPClient), nil
+	})
+}
+
+// Name returns the provider name
+func (p *CoinbaseProvider) Name() string {
+	return string(ProviderCoinbase)
+}
+
+// GetQuote fetches the spot price of a pair
+func (p *CoinbaseProvider) GetQuote(symbol string) (*Quote, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if _, _, ok := CryptoPair(symbol); !ok {
+		return nil, fmt.Errorf("%w: %s is not a crypto pair (use BASE-QUOTE, e.g. BTC-USD)", ErrSymbolNotFound, symbol)
+	}
+
+	var result struct {
+		Data struct {
+			Base     string        `json:"base"`
+			Currency string        `json:"currency"`
+			Amount   money.Decimal `json:"amount"`
+		} `json:"data"`
+	}
+	endpoint := fmt.Sprintf("%s/v2/prices/%s/spot", p.baseURL, url.PathEscape(symbol))
+	if err := fetchJSON(p.httpClient, endpoint, &result); err != nil {
+		return nil, err
+	}
+	if !result.Data.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: no price for %s", ErrSymbolNotFound, symbol)
+	}
+
+	return &Quote{
+		Symbol:    symbol,
+		Price:     result.Data.Amount,
+		Currency:  result.Data.Currency,
+		Exchange:  "Coinbase",
+		Timestamp: time.Now(),
+		Provider:  p.Name(),
+	}, nil
+}
+
+// GetQuotes fetches spot prices for several pairs
+func (p *CoinbaseProvider) GetQuotes(symbols []string) (map[string]*Quote, error) {
+	return fetchQuotesSequentially(p, symbols)
+}
+
+// GetHistory returns daily closes (UTC days) between from and to, requested
+// in windows of at most coinbaseMaxCandles days
+func (p *CoinbaseProvider) GetHistory(symbol string, from, to time.Time) ([]Bar, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if _, _, ok := CryptoPair(symbol); !ok {
+		return nil, fmt.Errorf("%w: %s is not a crypto pair (use BASE-QUOTE, e.g. BTC-USD)", ErrSymbolNotFound, symbol)
+	}
+
+	fromDate, toDate := truncateToDate(from), truncateToDate(to)
+	byDate := make(map[time.Time]money.Decimal)
+	for start := fromDate; !start.After(toDate); start = start.AddDate(0, 0, coinbaseMaxCandles) {
+		end := start.AddDate(0, 0, coinbaseMaxCandles-1)
+		if end.After(toDate) {
+			end = toDate
+		}
+
+		params := url.Values{
+			"granularity": {"86400"},
+			"start":       {start.Format(time.RFC3339)},
+			"end":         {end.Add(24*time.Hour - time.Second).Format(time.RFC3339)},
+		}
+		endpoint := fmt.Sprintf("%s/products/%s/candles?%s", p.exchangeBaseURL, url.PathEscape(symbol), params.Encode())
+
+		// Each candle is [time, low, high, open, close, volume], newest first
+		var candles [][]money.Decimal
+		if err := fetchJSON(p.httpClient, endpoint, &candles); err != nil {
+			return nil, err
+		}
+		for _, candle := range candles {
+			if len(candle) < 5 {
+				continue
+			}
+			date := truncateToDate(time.Unix(int64(candle[0].Float64()), 0).UTC())
+			if date.Before(fromDate) || date.After(toDate) {
+				continue
+			}
+			byDate[date] = candle[4]
+		}
+	}
+
+	bars := make([]Bar, 0, len(byDate))
+	for date, price := range byDate {
+		bars = append(bars, Bar{Date: date, Close: price})
+	}
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+	return bars, nil
+}
+
+// SearchSymbols matches the query against the pairs traded on Coinbase, by
+// pair (BTC-USD), base asset (BTC) or the base asset's name (Bitcoin)
+func (p *CoinbaseProvider) SearchSymbols(query string) ([]SymbolInfo, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	products, err := p.listProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []SymbolInfo{}
+	for _, product := range products {
+		base, _, _ := CryptoPair(product.Symbol)
+		if strings.ToLower(product.Symbol) == query || strings.ToLower(base) == query ||
+			strings.Contains(strings.ToLower(product.Name), query) {
+			matches = append(matches, product)
+		}
+	}
+	return matches, nil
+}
+
+// listProducts returns the online trading pairs, named after their base
+// asset, reusing the last list for coinbaseProductsTTL
+func (p *CoinbaseProvider) listProducts() ([]SymbolInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.products != nil && time.Since(p.productsAt) < coinbaseProductsTTL {
+		return p.products, nil
+	}
+
+	var products []struct {
+		ID            string `json:"id"`
+		BaseCurrency  string `json:"base_currency"`
+		QuoteCurrency string `json:"quote_currency"`
+		Status        string `json:"status"`
+	}
+	if err := fetchJSON(p.httpClient, p.exchangeBaseURL+"/products", &products); err != nil {
+		return nil, err
+	}
+
+	// Asset names are optional; pairs are still searchable by ticker without them
+	var currencies struct {
+		Data []struct {
+			Code string `json:"code"`
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	names := make(map[string]string)
+	if err := fetchJSON(p.httpClient, p.baseURL+"/v2/currencies/crypto", &currencies); err == nil {
+		for _, c := range currencies.Data {
+			names[strings.ToUpper(c.Code)] = c.Name
+		}
+	}
+
+	list := make([]SymbolInfo, 0, len(products))
+	for _, product := range products {
+		if product.Status != "" && product.Status != "online" {
+			continue
+		}
+		base := strings.ToUpper(product.BaseCurrency)
+		name := names[base]
+		if name == "" {
+			name = base
+		}
+		list = append(list, SymbolInfo{
+			Symbol:   strings.ToUpper(product.ID),
+			Name:     name,
+			Exchange: "Coinbase",
+			Currency: strings.ToUpper(product.QuoteCurrency),
+			Type:     "crypto",
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Symbol < list[j].Symbol })
+
+	p.products = list
+	p.productsAt = time.Now()
+	return list, nil
+}
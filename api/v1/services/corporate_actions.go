@@ -0,0 +1,432 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// CorporateActionSyncJobName is the scheduler name of the provider split import job
+const CorporateActionSyncJobName = "corporate_action_sync"
+
+// splitRatioPlaces is the precision of split ratios that do not divide
+// evenly, such as 1-for-3
+const splitRatioPlaces = 16
+
+var (
+	// ErrCorporateActionNotFound is returned when the requested corporate action does not exist
+	ErrCorporateActionNotFound = errors.New("corporate action not found")
+	// ErrInvalidCorporateAction is returned for corporate actions with missing
+	// or inconsistent fields
+	ErrInvalidCorporateAction = errors.New("invalid corporate action")
+)
+
+// corporateActionColumns lists the columns in the order scanCorporateAction expects
+const corporateActionColumns = `id, asset_id, type, date, numerator, denominator, COALESCE(old_symbol, ''), COALESCE(new_symbol, ''),
+	COALESCE(source, ''), COALESCE(transaction_id::text, ''), COALESCE(notes, ''), created_at`
+
+// scanCorporateAction scans a row selected with corporateActionColumns
+func scanCorporateAction(row scanner, a *models.CorporateAction) error {
+	return row.Scan(
+		&a.ID, &a.AssetID, &a.Type, &a.Date, &a.Numerator, &a.Denominator, &a.OldSymbol,
+		&a.NewSymbol, &a.Source, &a.TransactionID, &a.Notes, &a.CreatedAt,
+	)
+}
+
+// CorporateActionSyncResult describes the splits imported for one asset
+type CorporateActionSyncResult struct {
+	AssetID  string `json:"asset_id"`
+	Symbol   string `json:"symbol"`
+	Provider string `json:"provider,omitempty"`
+	Splits   int    `json:"splits"`
+	Applied  int    `json:"applied"`
+	Error    string `json:"error,omitempty"`
+}
+
+// CorporateActionService applies splits, ticker changes and mergers to
+// assets so quantity, cost basis and price history stay comparable across
+// the event
+type CorporateActionService struct {
+	db         *sql.DB
+	ledger     *LedgerService
+	marketData *MarketDataService
+}
+
+// NewCorporateActionService creates a new corporate action service
+func NewCorporateActionService(db *sql.DB, ledger *LedgerService, marketData *MarketDataService) *CorporateActionService {
+	return &CorporateActionService{db: db, ledger: ledger, marketData: marketData}
+}
+
+// ListCorporateActions returns an asset's corporate actions by date
+func (s *CorporateActionService) ListCorporateActions(assetID string) ([]models.CorporateAction, error) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM assets WHERE id = $1)`, assetID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrAssetNotFound
+	}
+
+	rows, err := s.db.Query(
+		`SELECT `+corporateActionColumns+` FROM corporate_actions WHERE asset_id = $1 ORDER BY date, created_at`,
+		assetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []models.CorporateAction{}
+	for rows.Next() {
+		var a models.CorporateAction
+		if err := scanCorporateAction(rows, &a); err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
+
+// ApplyCorporateAction records a corporate action and applies it in one
+// database transaction:
+//
+//   - splits and mergers add a split transaction converting every lot at
+//     numerator/denominator, so the quantity changes and the cost basis
+//     carries over
+//   - history values recorded before the ex-date are rescaled to the new
+//     shares; rows the provider backfilled later are already adjusted
+//   - ticker changes and mergers move the asset to the new symbol
+func (s *CorporateActionService) ApplyCorporateAction(assetID string, action *models.CorporateAction) error {
+	if err := validateCorporateAction(action); err != nil {
+		return err
+	}
+
+	return s.ledger.withLedger(assetID, func(tx *sql.Tx, asset *ledgerAsset) error {
+		var exists bool
+		err := tx.QueryRow(
+			`SELECT EXISTS(SELECT 1 FROM corporate_actions WHERE asset_id = $1 AND type = $2 AND date = $3)`,
+			assetID, action.Type, action.Date.Format("2006-01-02"),
+		).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: a %s on %s is already recorded", ErrInvalidCorporateAction, action.Type, action.Date.Format("2006-01-02"))
+		}
+
+		if err := tx.QueryRow(`SELECT COALESCE(symbol, '') FROM assets WHERE id = $1`, assetID).Scan(&action.OldSymbol); err != nil {
+			return err
+		}
+
+		if !action.Numerator.Equal(action.Denominator) {
+			split := models.Transaction{
+				Type:  models.TransactionTypeSplit,
+				Date:  action.Date,
+				Ratio: splitRatio(action.Numerator, action.Denominator),
+				Notes: describeCorporateAction(action),
+			}
+			if err := validateTransaction(&split, asset.currency); err != nil {
+				return err
+			}
+			if err := insertTransaction(tx, assetID, &split); err != nil {
+				return err
+			}
+			action.TransactionID = split.ID
+
+			if err := rescaleHistory(tx, assetID, action.Date, action.Denominator, action.Numerator); err != nil {
+				return err
+			}
+			// A cached quote may predate the ex-date; fetch a fresh one
+			if _, err := tx.Exec(`DELETE FROM stock_prices WHERE symbol = $1`, action.OldSymbol); err != nil {
+				return err
+			}
+		}
+
+		if action.NewSymbol != "" {
+			if _, err := tx.Exec(`UPDATE assets SET symbol = $1 WHERE id = $2`, action.NewSymbol, assetID); err != nil {
+				return err
+			}
+		}
+
+		action.ID = uuid.New().String()
+		action.AssetID = assetID
+		action.CreatedAt = time.Now()
+		if action.Source == "" {
+			action.Source = "manual"
+		}
+		_, err = tx.Exec(`
+			INSERT INTO corporate_actions (id, asset_id, type, date, numerator, denominator, old_symbol, new_symbol, source, transaction_id, notes, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`,
+			action.ID, action.AssetID, action.Type, action.Date.Format("2006-01-02"), action.Numerator, action.Denominator,
+			action.OldSymbol, action.NewSymbol, action.Source, nullableID(action.TransactionID), action.Notes, action.CreatedAt,
+		)
+		return err
+	})
+}
+
+// DeleteCorporateAction reverts a corporate action: its split transaction is
+// removed, history is scaled back and the asset gets its old symbol back,
+// unless the symbol has changed again since
+func (s *CorporateActionService) DeleteCorporateAction(assetID, id string) error {
+	return s.ledger.withLedger(assetID, func(tx *sql.Tx, asset *ledgerAsset) error {
+		var action models.CorporateAction
+		err := scanCorporateAction(tx.QueryRow(
+			`SELECT `+corporateActionColumns+` FROM corporate_actions WHERE id = $1 AND asset_id = $2`,
+			id, assetID,
+		), &action)
+		if err == sql.ErrNoRows {
+			return ErrCorporateActionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if action.TransactionID != "" {
+			if _, err := tx.Exec(`DELETE FROM transactions WHERE id = $1`, action.TransactionID); err != nil {
+				return err
+			}
+		}
+		if !action.Numerator.Equal(action.Denominator) {
+			if err := rescaleHistory(tx, assetID, action.Date, action.Numerator, action.Denominator); err != nil {
+				return err
+			}
+		}
+		if action.NewSymbol != "" && action.OldSymbol != "" {
+			_, err := tx.Exec(`UPDATE assets SET symbol = $1 WHERE id = $2 AND symbol = $3`, action.OldSymbol, assetID, action.NewSymbol)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(`DELETE FROM corporate_actions WHERE id = $1`, id)
+		return err
+	})
+}
+
+// SyncSplits records the provider's split history for a market-priced
+// asset since its first transaction. Dates that already have a split, as a
+// corporate action or a split transaction, are skipped, so reruns are
+// idempotent.
+func (s *CorporateActionService) SyncSplits(assetID string) (*CorporateActionSyncResult, error) {
+	var assetType, symbol, source, provider string
+	query := `SELECT type, COALESCE(symbol, ''), source, COALESCE(provider, '') FROM assets WHERE id = $1`
+	err := s.db.QueryRow(query, assetID).Scan(&assetType, &symbol, &source, &provider)
+	if err == sql.ErrNoRows {
+		return nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch asset: %w", err)
+	}
+	if assetType != "stock" || !IsMarketPriced(assetType, source, symbol) {
+		return nil, ErrNotMarketPriced
+	}
+
+	transactions, err := s.ledger.ListTransactions(assetID)
+	if err != nil {
+		return nil, err
+	}
+	result := &CorporateActionSyncResult{AssetID: assetID, Symbol: symbol}
+	if len(transactions) == 0 {
+		return result, nil
+	}
+
+	recorded := make(map[string]bool)
+	for _, t := range transactions {
+		if t.Type == models.TransactionTypeSplit {
+			recorded[t.Date.Format("2006-01-02")] = true
+		}
+	}
+
+	splits, providerName, err := s.marketData.GetSplits(provider, symbol, transactions[0].Date, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result.Provider = providerName
+	result.Splits = len(splits)
+
+	for _, split := range splits {
+		if recorded[split.Date.Format("2006-01-02")] {
+			continue
+		}
+
+		err := s.ApplyCorporateAction(assetID, &models.CorporateAction{
+			Type:        models.CorporateActionSplit,
+			Date:        split.Date,
+			Numerator:   split.Numerator,
+			Denominator: split.Denominator,
+			Source:      providerName,
+		})
+		if errors.Is(err, ErrInvalidCorporateAction) {
+			// Already recorded manually, or not a usable ratio
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Applied++
+	}
+
+	log.Printf("[CorporateActions] %s (%s): %d splits from %s, %d applied", symbol, assetID, result.Splits, providerName, result.Applied)
+	return result, nil
+}
+
+// SyncAllSplits imports splits for every market-priced asset. Failures are
+// reported per asset and do not stop the run.
+func (s *CorporateActionService) SyncAllSplits() ([]CorporateActionSyncResult, error) {
+	rows, err := s.db.Query(`SELECT id FROM assets WHERE type = 'stock' AND source = 'market_api' ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch assets: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	results := []CorporateActionSyncResult{}
+	failed := 0
+	for _, id := range ids {
+		result, err := s.SyncSplits(id)
+		if errors.Is(err, ErrNotMarketPriced) {
+			continue
+		}
+		if err != nil {
+			failed++
+			results = append(results, CorporateActionSyncResult{AssetID: id, Error: err.Error()})
+			continue
+		}
+		results = append(results, *result)
+	}
+
+	if failed > 0 && failed == len(results) {
+		return results, fmt.Errorf("split sync failed for every asset")
+	}
+	return results, nil
+}
+
+// validateCorporateAction checks the fields each type needs and normalizes
+// the ratio and symbol
+func validateCorporateAction(action *models.CorporateAction) error {
+	if !action.Type.Valid() {
+		return fmt.Errorf("%w: unknown type %q (use split, ticker_change or merger)", ErrInvalidCorporateAction, action.Type)
+	}
+	if action.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidCorporateAction)
+	}
+	if action.Date.After(time.Now()) {
+		return fmt.Errorf("%w: record the action on or after its ex-date", ErrInvalidCorporateAction)
+	}
+	action.NewSymbol = strings.ToUpper(strings.TrimSpace(action.NewSymbol))
+
+	switch action.Type {
+	case models.CorporateActionTickerChange:
+		action.Numerator, action.Denominator = money.NewFromInt(1), money.NewFromInt(1)
+	case models.CorporateActionSplit:
+		action.NewSymbol = ""
+	}
+
+	if !action.Numerator.IsPositive() || !action.Denominator.IsPositive() {
+		return fmt.Errorf("%w: numerator and denominator must be positive", ErrInvalidCorporateAction)
+	}
+	if action.Type == models.CorporateActionSplit && action.Numerator.Equal(action.Denominator) {
+		return fmt.Errorf("%w: a split needs a ratio other than 1:1", ErrInvalidCorporateAction)
+	}
+	if action.Type != models.CorporateActionSplit && !IsStockSymbol(action.NewSymbol) {
+		return fmt.Errorf("%w: %s needs the new_symbol the shares trade under", ErrInvalidCorporateAction, action.Type)
+	}
+	return nil
+}
+
+// describeCorporateAction returns the note of the split transaction an action records
+func describeCorporateAction(action *models.CorporateAction) string {
+	ratio := action.Numerator.String() + "-for-" + action.Denominator.String()
+	if action.Type == models.CorporateActionMerger {
+		return fmt.Sprintf("Merger into %s, %s", action.NewSymbol, ratio)
+	}
+	if action.Numerator.LessThan(action.Denominator) {
+		return ratio + " reverse split"
+	}
+	return ratio + " split"
+}
+
+// splitRatio returns numerator/denominator without trailing zeros, so a
+// 4-for-1 split is stored as 4 and a 1-for-8 reverse split as 0.125
+func splitRatio(numerator, denominator money.Decimal) money.Decimal {
+	ratio := numerator.Div(denominator, splitRatioPlaces)
+	for ratio.Scale() > 0 && ratio.Equal(ratio.Round(ratio.Scale()-1)) {
+		ratio = ratio.Round(ratio.Scale() - 1)
+	}
+	return ratio
+}
+
+// rescaleHistory multiplies by multiplier/divisor the values an asset
+// recorded before date that were still quoted in the old shares: history
+// rows written before the ex-date, and the current value when no price has
+// been recorded since. Values are rounded to the asset's currency.
+func rescaleHistory(db queryExecer, assetID string, date time.Time, multiplier, divisor money.Decimal) error {
+	exDate := date.Format("2006-01-02")
+
+	var currency string
+	var currentValue money.Decimal
+	var pricedSince bool
+	err := db.QueryRow(`
+		SELECT COALESCE(currency, ''), current_value,
+			EXISTS (SELECT 1 FROM asset_history WHERE asset_id = $1 AND date >= $2)
+		FROM assets WHERE id = $1
+	`, assetID, exDate).Scan(&currency, &currentValue, &pricedSince)
+	if err != nil {
+		return fmt.Errorf("failed to fetch asset: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT id, value FROM asset_history
+		WHERE asset_id = $1 AND date < $2 AND created_at < $2
+	`, assetID, exDate)
+	if err != nil {
+		return fmt.Errorf("failed to fetch asset history: %w", err)
+	}
+	var ids []string
+	var values []money.Decimal
+	for rows.Next() {
+		var id string
+		var value money.Decimal
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse asset history: %w", err)
+		}
+		ids = append(ids, id)
+		values = append(values, value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch asset history: %w", err)
+	}
+
+	for i, id := range ids {
+		_, err := db.Exec(`UPDATE asset_history SET value = $1 WHERE id = $2`, prorate(values[i], multiplier, divisor, currency), id)
+		if err != nil {
+			return fmt.Errorf("failed to adjust asset history: %w", err)
+		}
+	}
+
+	if !pricedSince {
+		_, err := db.Exec(`UPDATE assets SET current_value = $1 WHERE id = $2`, prorate(currentValue, multiplier, divisor, currency), assetID)
+		if err != nil {
+			return fmt.Errorf("failed to adjust current value: %w", err)
+		}
+	}
+	return nil
+}
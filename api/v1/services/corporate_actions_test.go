@@ -0,0 +1,156 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func TestSplitRatio(t *testing.T) {
+	tests := []struct {
+		numerator, denominator string
+		want                   string
+	}{
+		{numerator: "4", denominator: "1", want: "4"},
+		{numerator: "3", denominator: "2", want: "1.5"},
+		{numerator: "1", denominator: "8", want: "0.125"},
+		{numerator: "1.000", denominator: "10", want: "0.1"},
+		// Ratios that do not divide evenly keep 16 places
+		{numerator: "1", denominator: "3", want: "0.3333333333333333"},
+		{numerator: "2", denominator: "3", want: "0.6666666666666667"},
+	}
+	for _, tt := range tests {
+		got := splitRatio(money.MustParse(tt.numerator), money.MustParse(tt.denominator))
+		if got.String() != tt.want {
+			t.Errorf("splitRatio(%s, %s) = %s, want %s", tt.numerator, tt.denominator, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCorporateAction(t *testing.T) {
+	date := mustDate("2024-06-10")
+	action := func(kind models.CorporateActionType, numerator, denominator, newSymbol string) models.CorporateAction {
+		return models.CorporateAction{
+			Type:        kind,
+			Date:        date,
+			Numerator:   money.MustParse(numerator),
+			Denominator: money.MustParse(denominator),
+			NewSymbol:   newSymbol,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		action        models.CorporateAction
+		wantErr       bool
+		wantSymbol    string
+		wantNumerator string
+	}{
+		{name: "split", action: action(models.CorporateActionSplit, "4", "1", "AAPL"), wantNumerator: "4"},
+		{name: "reverse split", action: action(models.CorporateActionSplit, "1", "8", ""), wantNumerator: "1"},
+		{name: "one-for-one split", action: action(models.CorporateActionSplit, "2", "2", ""), wantErr: true},
+		{name: "split without a ratio", action: action(models.CorporateActionSplit, "0", "1", ""), wantErr: true},
+		// A ticker change keeps the shares one for one
+		{name: "ticker change", action: action(models.CorporateActionTickerChange, "0", "0", " meta "), wantSymbol: "META", wantNumerator: "1"},
+		{name: "ticker change without a symbol", action: action(models.CorporateActionTickerChange, "1", "1", ""), wantErr: true},
+		{name: "merger", action: action(models.CorporateActionMerger, "0.5", "1", "xom"), wantSymbol: "XOM", wantNumerator: "0.5"},
+		{name: "merger with a negative ratio", action: action(models.CorporateActionMerger, "-1", "1", "XOM"), wantErr: true},
+		{name: "unknown type", action: action("spinoff", "1", "1", "NEW"), wantErr: true},
+		{name: "no date", action: models.CorporateAction{Type: models.CorporateActionSplit, Numerator: money.NewFromInt(2), Denominator: money.NewFromInt(1)}, wantErr: true},
+		{name: "future date", action: models.CorporateAction{Type: models.CorporateActionSplit, Date: time.Now().AddDate(0, 0, 2), Numerator: money.NewFromInt(2), Denominator: money.NewFromInt(1)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateCorporateAction(&tt.action)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidCorporateAction) {
+				t.Errorf("%s: got error %v, want ErrInvalidCorporateAction", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if tt.action.NewSymbol != tt.wantSymbol || tt.action.Numerator.String() != tt.wantNumerator {
+			t.Errorf("%s: normalized to %s at %s, want %s at %s",
+				tt.name, tt.action.NewSymbol, tt.action.Numerator, tt.wantSymbol, tt.wantNumerator)
+		}
+	}
+}
+
+func TestRescaleHistory(t *testing.T) {
+	tests := []struct {
+		name                string
+		currency            string
+		multiplier, divisor string
+		history             []string
+		currentValue        string
+		pricedSince         bool
+		wantHistory         []string
+		wantCurrentValue    string
+	}{
+		{
+			// A 2-for-1 split halves the old per-share values
+			name: "USD split", currency: "USD", multiplier: "1", divisor: "2",
+			history: []string{"1000.01", "998.50"}, currentValue: "1010.00",
+			wantHistory: []string{"500.01", "499.25"}, wantCurrentValue: "505.00",
+		},
+		{
+			name: "JPY split", currency: "JPY", multiplier: "1", divisor: "3",
+			history: []string{"100000"}, currentValue: "100001",
+			wantHistory: []string{"33333"}, wantCurrentValue: "33334",
+		},
+		{
+			// Three decimals are kept for dinars
+			name: "KWD reverse split", currency: "KWD", multiplier: "8", divisor: "3",
+			history: []string{"12.345"}, currentValue: "12.346",
+			wantHistory: []string{"32.920"}, wantCurrentValue: "32.923",
+		},
+		{
+			// A price recorded since the ex-date is already in the new shares
+			name: "priced since the ex-date", currency: "USD", multiplier: "1", divisor: "2",
+			history: []string{"1000.00"}, currentValue: "505.00", pricedSince: true,
+			wantHistory: []string{"500.00"},
+		},
+	}
+	for _, tt := range tests {
+		db, fake := newFakeDB(t)
+		fake.returns("FROM assets WHERE id = $1", []string{"currency", "current_value", "exists"},
+			[]driver.Value{tt.currency, tt.currentValue, tt.pricedSince})
+		var rows [][]driver.Value
+		for i, value := range tt.history {
+			rows = append(rows, []driver.Value{string(rune('a' + i)), value})
+		}
+		fake.returns("SELECT id, value FROM asset_history", []string{"id", "value"}, rows...)
+		fake.on("UPDATE", func([]driver.Value) (fakeRows, error) { return fakeRows{affected: 1}, nil })
+
+		err := rescaleHistory(db, "asset-1", mustDate("2024-06-10"), money.MustParse(tt.multiplier), money.MustParse(tt.divisor))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+
+		updates := fake.executed("UPDATE asset_history")
+		if len(updates) != len(tt.wantHistory) {
+			t.Errorf("%s: %d history updates, want %d", tt.name, len(updates), len(tt.wantHistory))
+			continue
+		}
+		for i, update := range updates {
+			if update.args[0] != tt.wantHistory[i] {
+				t.Errorf("%s: history row %d set to %v, want %s", tt.name, i, update.args[0], tt.wantHistory[i])
+			}
+		}
+
+		updates = fake.executed("UPDATE assets SET current_value")
+		switch {
+		case tt.wantCurrentValue == "" && len(updates) > 0:
+			t.Errorf("%s: current value set to %v, want it left alone", tt.name, updates[0].args[0])
+		case tt.wantCurrentValue != "" && (len(updates) != 1 || updates[0].args[0] != tt.wantCurrentValue):
+			t.Errorf("%s: current value updates %v, want one to %s", tt.name, updates, tt.wantCurrentValue)
+		}
+	}
+}
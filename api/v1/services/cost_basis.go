@@ -0,0 +1,240 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// splitQuantityPlaces bounds the precision of lot quantities after a split
+const splitQuantityPlaces = 8
+
+// BuildPosition replays transactions in date order and returns the
+// resulting position. Sales and transfers out consume lots in the order the
+// cost basis method dictates; every sale records one disposal per lot it
+// consumed. The cost of a partly consumed lot is split pro rata and rounded
+// to the currency, with the remainder staying on the lot so no cents are lost.
+func BuildPosition(transactions []models.Transaction, currency string, method models.CostBasisMethod) (*models.Position, error) {
+	if !method.Valid() {
+		method = models.CostBasisFIFO
+	}
+
+	ordered := make([]models.Transaction, len(transactions))
+	copy(ordered, transactions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if !ordered[i].Date.Equal(ordered[j].Date) {
+			return ordered[i].Date.Before(ordered[j].Date)
+		}
+		return ordered[i].CreatedAt.Before(ordered[j].CreatedAt)
+	})
+
+	position := &models.Position{
+		Currency:  converterCurrency(currency),
+		Method:    method,
+		Lots:      []models.Lot{},
+		Disposals: []models.Disposal{},
+	}
+	for _, t := range ordered {
+		switch t.Type {
+		case models.TransactionTypeBuy:
+			position.Lots = append(position.Lots, models.Lot{
+				TransactionID: t.ID,
+				Date:          t.Date,
+				Quantity:      t.Quantity,
+				Cost:          money.RoundTo(t.Quantity.Mul(t.Price), currency).Add(t.Fee),
+			})
+
+		case models.TransactionTypeSell:
+			consumed, err := consumeLots(position, t.Quantity, t, currency)
+			if err != nil {
+				return nil, err
+			}
+			proceeds := money.RoundTo(t.Quantity.Mul(t.Price), currency).Sub(t.Fee)
+			recordDisposals(position, t, consumed, proceeds, currency)
+
+		case models.TransactionTypeTransfer:
+			if t.Quantity.IsPositive() {
+				position.Lots = append(position.Lots, models.Lot{
+					TransactionID: t.ID,
+					Date:          t.Date,
+					Quantity:      t.Quantity,
+					Cost:          money.RoundTo(t.Quantity.Mul(t.Price), currency),
+				})
+			} else {
+				if _, err := consumeLots(position, t.Quantity.Neg(), t, currency); err != nil {
+					return nil, err
+				}
+			}
+			position.Fees = position.Fees.Add(t.Fee)
+
+		case models.TransactionTypeSplit:
+			for i := range position.Lots {
+				quantity := position.Lots[i].Quantity.Mul(t.Ratio)
+				// Ratios such as 1-for-3 are stored rounded; round the
+				// result back so 300 units become 100, not 99.99…
+				if quantity.Scale() > splitQuantityPlaces {
+					quantity = quantity.Round(splitQuantityPlaces)
+				}
+				position.Lots[i].Quantity = quantity
+			}
+
+		case models.TransactionTypeDividend, models.TransactionTypeInterest, models.TransactionTypeRent:
+			position.Income = position.Income.Add(t.Amount)
+
+		case models.TransactionTypeFee:
+			position.Fees = position.Fees.Add(t.Amount)
+		}
+	}
+
+	for _, lot := range position.Lots {
+		position.Quantity = position.Quantity.Add(lot.Quantity)
+		position.CostBasis = position.CostBasis.Add(lot.Cost)
+	}
+	return position, nil
+}
+
+// consumeLots removes quantity units from the position's lots and returns
+// the consumed parts with the cost attributed to each. It fails if fewer
+// units are held on t's date, or the named lot holds fewer units.
+func consumeLots(position *models.Position, quantity money.Decimal, t models.Transaction, currency string) ([]models.Lot, error) {
+	held := money.Zero
+	for _, lot := range position.Lots {
+		held = held.Add(lot.Quantity)
+	}
+	if quantity.GreaterThan(held) {
+		return nil, fmt.Errorf("%w: %s of %s on %s exceeds the %s units held",
+			ErrInvalidTransaction, t.Type, quantity, t.Date.Format("2006-01-02"), held)
+	}
+
+	order, err := lotOrder(position, t)
+	if err != nil {
+		return nil, err
+	}
+	if position.Method == models.CostBasisAverage {
+		averageLots(position.Lots, currency)
+	}
+
+	consumed := []models.Lot{}
+	remaining := quantity
+	for _, i := range order {
+		if !remaining.IsPositive() {
+			break
+		}
+		lot := &position.Lots[i]
+		part := *lot
+		if remaining.LessThan(lot.Quantity) {
+			part.Quantity = remaining
+			part.Cost = prorate(lot.Cost, remaining, lot.Quantity, currency)
+		}
+		lot.Quantity = lot.Quantity.Sub(part.Quantity)
+		lot.Cost = lot.Cost.Sub(part.Cost)
+		remaining = remaining.Sub(part.Quantity)
+		consumed = append(consumed, part)
+	}
+	if remaining.IsPositive() {
+		return nil, fmt.Errorf("%w: %s of %s on %s exceeds the units left in lot %s",
+			ErrInvalidTransaction, t.Type, quantity, t.Date.Format("2006-01-02"), t.LotID)
+	}
+
+	open := position.Lots[:0]
+	for _, lot := range position.Lots {
+		if lot.Quantity.IsPositive() {
+			open = append(open, lot)
+		}
+	}
+	position.Lots = open
+	return consumed, nil
+}
+
+// lotOrder returns the indexes of the lots t consumes, in consumption order
+func lotOrder(position *models.Position, t models.Transaction) ([]int, error) {
+	order := make([]int, len(position.Lots))
+	for i := range order {
+		order[i] = i
+	}
+
+	switch position.Method {
+	case models.CostBasisLIFO:
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	case models.CostBasisSpecific:
+		if t.LotID == "" {
+			// Without a designated lot, sales are identified first in, first out
+			return order, nil
+		}
+		for i, lot := range position.Lots {
+			if lot.TransactionID == t.LotID {
+				return []int{i}, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: lot %s is not open on %s", ErrInvalidTransaction, t.LotID, t.Date.Format("2006-01-02"))
+	}
+	return order, nil
+}
+
+// averageLots spreads the position's total cost over its lots so every unit
+// carries the average cost. The last lot takes the rounding remainder.
+func averageLots(lots []models.Lot, currency string) {
+	if len(lots) < 2 {
+		return
+	}
+
+	quantity, cost := money.Zero, money.Zero
+	for _, lot := range lots {
+		quantity = quantity.Add(lot.Quantity)
+		cost = cost.Add(lot.Cost)
+	}
+
+	assigned := money.Zero
+	for i := range lots[:len(lots)-1] {
+		lots[i].Cost = prorate(cost, lots[i].Quantity, quantity, currency)
+		assigned = assigned.Add(lots[i].Cost)
+	}
+	lots[len(lots)-1].Cost = cost.Sub(assigned)
+}
+
+// recordDisposals splits a sale's proceeds over the lots it consumed, pro
+// rata by quantity with the last lot taking the remainder, and adds one
+// disposal per lot to the position
+func recordDisposals(position *models.Position, sale models.Transaction, consumed []models.Lot, proceeds money.Decimal, currency string) {
+	allocated := money.Zero
+	for i, lot := range consumed {
+		share := proceeds.Sub(allocated)
+		if i < len(consumed)-1 {
+			share = prorate(proceeds, lot.Quantity, sale.Quantity, currency)
+		}
+		allocated = allocated.Add(share)
+
+		gain := share.Sub(lot.Cost)
+		position.RealizedGain = position.RealizedGain.Add(gain)
+		position.Disposals = append(position.Disposals, models.Disposal{
+			SaleID:       sale.ID,
+			LotID:        lot.TransactionID,
+			Quantity:     lot.Quantity,
+			DateAcquired: lot.Date,
+			DateSold:     sale.Date,
+			Proceeds:     share,
+			CostBasis:    lot.Cost,
+			Gain:         gain,
+			Term:         holdingTerm(lot, sale),
+			Currency:     position.Currency,
+		})
+	}
+}
+
+// holdingTerm classifies a disposal as long-term when the lot was held for
+// more than one year, i.e. sold after the anniversary of its acquisition
+func holdingTerm(lot models.Lot, sale models.Transaction) models.GainTerm {
+	if sale.Date.After(lot.Date.AddDate(1, 0, 0)) {
+		return models.GainTermLong
+	}
+	return models.GainTermShort
+}
+
+// prorate returns amount × part / whole rounded to the currency
+func prorate(amount, part, whole money.Decimal, currency string) money.Decimal {
+	return amount.Mul(part).Div(whole, money.MinorUnits(currency))
+}
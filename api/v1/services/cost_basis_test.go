@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func mustDate(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// trade returns a buy or sell of quantity units at price
+func trade(id string, kind models.TransactionType, on, quantity, price, fee string) models.Transaction {
+	return models.Transaction{
+		ID:       id,
+		Type:     kind,
+		Date:     mustDate(on),
+		Quantity: money.MustParse(quantity),
+		Price:    money.MustParse(price),
+		Fee:      money.MustParse(fee),
+	}
+}
+
+func TestBuildPosition(t *testing.T) {
+	// The first lot is held over a year at the sale, the second is not
+	buys := []models.Transaction{
+		trade("b1", models.TransactionTypeBuy, "2023-01-10", "10", "100", "1.00"),
+		trade("b2", models.TransactionTypeBuy, "2023-06-01", "10", "150", "0"),
+	}
+	sale := trade("s1", models.TransactionTypeSell, "2024-03-01", "15", "200", "2.00")
+	specificSale := sale
+	specificSale.Quantity, specificSale.LotID = money.MustParse("5"), "b2"
+
+	type disposal struct {
+		lot, quantity, proceeds, cost, gain string
+		term                                models.GainTerm
+	}
+	tests := []struct {
+		method       models.CostBasisMethod
+		sale         models.Transaction
+		disposals    []disposal
+		realizedGain string
+		quantity     string
+		costBasis    string
+	}{
+		{
+			method: models.CostBasisFIFO,
+			sale:   sale,
+			disposals: []disposal{
+				{"b1", "10", "1998.67", "1001.00", "997.67", models.GainTermLong},
+				{"b2", "5", "999.33", "750.00", "249.33", models.GainTermShort},
+			},
+			realizedGain: "1247.00", quantity: "5", costBasis: "750.00",
+		},
+		{
+			method: models.CostBasisLIFO,
+			sale:   sale,
+			disposals: []disposal{
+				{"b2", "10", "1998.67", "1500.00", "498.67", models.GainTermShort},
+				{"b1", "5", "999.33", "500.50", "498.83", models.GainTermLong},
+			},
+			realizedGain: "997.50", quantity: "5", costBasis: "500.50",
+		},
+		{
+			// Every unit costs 2501.00 / 20, but units still leave oldest first
+			method: models.CostBasisAverage,
+			sale:   sale,
+			disposals: []disposal{
+				{"b1", "10", "1998.67", "1250.50", "748.17", models.GainTermLong},
+				{"b2", "5", "999.33", "625.25", "374.08", models.GainTermShort},
+			},
+			realizedGain: "1122.25", quantity: "5", costBasis: "625.25",
+		},
+		{
+			method:       models.CostBasisSpecific,
+			sale:         specificSale,
+			disposals:    []disposal{{"b2", "5", "998.00", "750.00", "248.00", models.GainTermShort}},
+			realizedGain: "248.00", quantity: "15", costBasis: "1751.00",
+		},
+		{
+			// Without a lot, specific identification sells first in, first out
+			method: models.CostBasisSpecific,
+			sale:   sale,
+			disposals: []disposal{
+				{"b1", "10", "1998.67", "1001.00", "997.67", models.GainTermLong},
+				{"b2", "5", "999.33", "750.00", "249.33", models.GainTermShort},
+			},
+			realizedGain: "1247.00", quantity: "5", costBasis: "750.00",
+		},
+	}
+	for _, tt := range tests {
+		// The sale is listed first to check transactions are replayed by date
+		position, err := BuildPosition(append([]models.Transaction{tt.sale}, buys...), "USD", tt.method)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.method, err)
+			continue
+		}
+		if len(position.Disposals) != len(tt.disposals) {
+			t.Errorf("%s: got %d disposals, want %d", tt.method, len(position.Disposals), len(tt.disposals))
+			continue
+		}
+		for i, want := range tt.disposals {
+			d := position.Disposals[i]
+			got := disposal{d.LotID, d.Quantity.String(), d.Proceeds.String(), d.CostBasis.String(), d.Gain.String(), d.Term}
+			if got != want {
+				t.Errorf("%s: disposal %d = %+v, want %+v", tt.method, i, got, want)
+			}
+		}
+		if position.RealizedGain.String() != tt.realizedGain || position.Quantity.String() != tt.quantity ||
+			position.CostBasis.String() != tt.costBasis {
+			t.Errorf("%s: realized %s, holding %s costing %s; want realized %s, holding %s costing %s", tt.method,
+				position.RealizedGain, position.Quantity, position.CostBasis, tt.realizedGain, tt.quantity, tt.costBasis)
+		}
+	}
+}
+
+func TestBuildPositionEvents(t *testing.T) {
+	split := models.Transaction{ID: "x", Type: models.TransactionTypeSplit, Date: mustDate("2023-03-01"), Ratio: money.MustParse("2")}
+	dividend := models.Transaction{ID: "d", Type: models.TransactionTypeDividend, Date: mustDate("2023-04-01"), Amount: money.MustParse("12.50")}
+	fee := models.Transaction{ID: "f", Type: models.TransactionTypeFee, Date: mustDate("2023-04-01"), Amount: money.MustParse("3.00")}
+	transferOut := trade("t", models.TransactionTypeTransfer, "2023-05-01", "-4", "0", "1.00")
+
+	tests := []struct {
+		name         string
+		transactions []models.Transaction
+		quantity     string
+		costBasis    string
+		income       string
+		fees         string
+		wantErr      bool
+	}{
+		{
+			name:         "split keeps the cost",
+			transactions: []models.Transaction{trade("b1", models.TransactionTypeBuy, "2023-01-10", "10", "100", "0"), split},
+			quantity:     "20", costBasis: "1000.00", income: "0", fees: "0",
+		},
+		{
+			name: "income, fees and a transfer out",
+			transactions: []models.Transaction{
+				trade("b1", models.TransactionTypeBuy, "2023-01-10", "10", "100", "0"), dividend, fee, transferOut,
+			},
+			quantity: "6", costBasis: "600.00", income: "12.50", fees: "4.00",
+		},
+		{
+			name: "selling more than is held",
+			transactions: []models.Transaction{
+				trade("b1", models.TransactionTypeBuy, "2023-01-10", "10", "100", "0"),
+				trade("s1", models.TransactionTypeSell, "2023-02-01", "11", "100", "0"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		position, err := BuildPosition(tt.transactions, "USD", models.CostBasisFIFO)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidTransaction) {
+				t.Errorf("%s: got error %v, want ErrInvalidTransaction", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		got := []string{position.Quantity.String(), position.CostBasis.String(), position.Income.String(), position.Fees.String()}
+		want := []string{tt.quantity, tt.costBasis, tt.income, tt.fees}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: quantity, cost, income and fees = %v, want %v", tt.name, got, want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+var (
+	// ErrDebtNotFound is returned when the requested debt does not exist
+	ErrDebtNotFound = errors.New("debt not found")
+	// ErrPaymentNotFound is returned when the requested debt payment does not exist
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrInvalidPayment is returned for payments with missing or inconsistent
+	// fields, or that repay more than is owed
+	ErrInvalidPayment = errors.New("invalid payment")
+)
+
+// debtPaymentColumns lists the payment columns in the order scanDebtPayment expects
+const debtPaymentColumns = `id, debt_id, date, amount, interest, principal, capitalized_interest, manual_split, COALESCE(notes, ''), created_at, updated_at`
+
+// scanDebtPayment scans a row selected with debtPaymentColumns into a payment
+func scanDebtPayment(row scanner, p *models.DebtPayment) error {
+	return row.Scan(
+		&p.ID, &p.DebtID, &p.Date, &p.Amount, &p.Interest, &p.Principal,
+		&p.CapitalizedInterest, &p.ManualSplit, &p.Notes, &p.CreatedAt, &p.UpdatedAt,
+	)
+}
+
+// DebtLedgerService records payments on debts, reduces each debt's balance
+// by the principal they repay and keeps the debt's balance history
+type DebtLedgerService struct {
+	db *sql.DB
+}
+
+// NewDebtLedgerService creates a new debt ledger service
+func NewDebtLedgerService(db *sql.DB) *DebtLedgerService {
+	return &DebtLedgerService{db: db}
+}
+
+// loadLedgerDebt reads the debt a payment is checked and split against,
+// with its rate schedule, locking its row inside a transaction
+func loadLedgerDebt(db querier, debtID string, lock bool) (*models.Debt, error) {
+	query := `SELECT ` + accrualDebtColumns + ` FROM debts WHERE id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	var debt models.Debt
+	err := scanAccrualDebt(db.QueryRow(query, debtID), &debt)
+	if err == sql.ErrNoRows {
+		return nil, ErrDebtNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := LoadRateSchedules(db, &debt); err != nil {
+		return nil, err
+	}
+	return &debt, nil
+}
+
+// ListPayments returns a debt's payments, oldest first
+func (s *DebtLedgerService) ListPayments(debtID string) ([]models.DebtPayment, error) {
+	if _, err := loadLedgerDebt(s.db, debtID, false); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(
+		`SELECT `+debtPaymentColumns+` FROM debt_payments WHERE debt_id = $1 ORDER BY date, created_at`,
+		debtID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []models.DebtPayment{}
+	for rows.Next() {
+		var p models.DebtPayment
+		if err := scanDebtPayment(rows, &p); err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// GetPayment returns one payment of a debt
+func (s *DebtLedgerService) GetPayment(debtID, id string) (*models.DebtPayment, error) {
+	return getDebtPayment(s.db, debtID, id)
+}
+
+// getDebtPayment reads a payment of a debt
+func getDebtPayment(db querier, debtID, id string) (*models.DebtPayment, error) {
+	var p models.DebtPayment
+	err := scanDebtPayment(db.QueryRow(
+		`SELECT `+debtPaymentColumns+` FROM debt_payments WHERE id = $1 AND debt_id = $2`,
+		id, debtID,
+	), &p)
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreatePayment records a payment and reduces the debt's balance by the
+// principal it repays, less the interest it left unpaid. A nil interest or
+// principal is derived from the amount (see splitPayment). The payments
+// after a backdated one are split again (see resplitAfter).
+func (s *DebtLedgerService) CreatePayment(debtID string, p *models.DebtPayment, interest, principal *money.Decimal) error {
+	return withDebt(s.db, debtID, func(tx *sql.Tx, debt *models.Debt) error {
+		if err := splitPayment(tx, debtID, "", debt, p, interest, principal); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		p.ID = uuid.New().String()
+		p.DebtID = debtID
+		p.CreatedAt = now
+		p.UpdatedAt = now
+		if _, err := tx.Exec(`
+			INSERT INTO debt_payments (id, debt_id, date, amount, interest, principal, capitalized_interest, manual_split, notes, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		`,
+			p.ID, p.DebtID, p.Date.Format("2006-01-02"), p.Amount, p.Interest, p.Principal,
+			p.CapitalizedInterest, p.ManualSplit, p.Notes, p.CreatedAt, p.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		if err := applyPrincipal(tx, debtID, p.Date, p.BalanceReduction()); err != nil {
+			return err
+		}
+		if err := resplitAfter(tx, debtID, p.Date); err != nil {
+			return err
+		}
+		return storeAccruedInterest(tx, debtID, time.Now())
+	})
+}
+
+// UpdatePayment saves changes to a payment, moving the debt's balance from
+// the old balance reduction to the new one, and splits the payments after
+// the earlier of its old and new dates again
+func (s *DebtLedgerService) UpdatePayment(p *models.DebtPayment, interest, principal *money.Decimal) error {
+	return withDebt(s.db, p.DebtID, func(tx *sql.Tx, debt *models.Debt) error {
+		old, err := getDebtPayment(tx, p.DebtID, p.ID)
+		if err != nil {
+			return err
+		}
+		if err := applyPrincipal(tx, p.DebtID, old.Date, old.BalanceReduction().Neg()); err != nil {
+			return err
+		}
+		debt.CurrentValue = debt.CurrentValue.Add(old.BalanceReduction())
+
+		if err := splitPayment(tx, p.DebtID, p.ID, debt, p, interest, principal); err != nil {
+			return err
+		}
+
+		p.UpdatedAt = time.Now()
+		if _, err := tx.Exec(`
+			UPDATE debt_payments
+			SET date = $1, amount = $2, interest = $3, principal = $4, capitalized_interest = $5, manual_split = $6, notes = $7, updated_at = $8
+			WHERE id = $9 AND debt_id = $10
+		`,
+			p.Date.Format("2006-01-02"), p.Amount, p.Interest, p.Principal, p.CapitalizedInterest, p.ManualSplit, p.Notes, p.UpdatedAt,
+			p.ID, p.DebtID,
+		); err != nil {
+			return err
+		}
+		if err := applyPrincipal(tx, p.DebtID, p.Date, p.BalanceReduction()); err != nil {
+			return err
+		}
+
+		if old.Date.Before(p.Date) {
+			// A payment moved later is split again with the ones it passed
+			if err := resplitAfter(tx, p.DebtID, old.Date); err != nil {
+				return err
+			}
+			updated, err := getDebtPayment(tx, p.DebtID, p.ID)
+			if err != nil {
+				return err
+			}
+			*p = *updated
+		} else if err := resplitAfter(tx, p.DebtID, p.Date); err != nil {
+			return err
+		}
+		return storeAccruedInterest(tx, p.DebtID, time.Now())
+	})
+}
+
+// DeletePayment removes a payment, reverses its change to the debt's balance
+// and splits the payments after it again
+func (s *DebtLedgerService) DeletePayment(debtID, id string) error {
+	return withDebt(s.db, debtID, func(tx *sql.Tx, debt *models.Debt) error {
+		p, err := getDebtPayment(tx, debtID, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM debt_payments WHERE id = $1 AND debt_id = $2`, id, debtID); err != nil {
+			return err
+		}
+		if err := applyPrincipal(tx, debtID, p.Date, p.BalanceReduction().Neg()); err != nil {
+			return err
+		}
+		if err := resplitAfter(tx, debtID, p.Date); err != nil {
+			return err
+		}
+		return storeAccruedInterest(tx, debtID, time.Now())
+	})
+}
+
+// withDebt runs fn inside a database transaction with the debt row locked.
+// Any error rolls back.
+func withDebt(db *sql.DB, debtID string, fn func(tx *sql.Tx, debt *models.Debt) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	debt, err := loadLedgerDebt(tx, debtID, true)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx, debt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitPayment validates a payment and sets its interest, principal and
+// capitalized interest from the interest the balance on the payment date
+// accrued since the previous payment (or interest_since) at the debt's rate.
+// excludeID skips the payment being updated when looking for the previous one.
+func splitPayment(db querier, debtID, excludeID string, debt *models.Debt, p *models.DebtPayment, interest, principal *money.Decimal) error {
+	accrued, err := accruedSinceLastPayment(db, debtID, excludeID, debt, p.Date)
+	if err != nil {
+		return err
+	}
+	return allocatePayment(debt, p, accrued, interest, principal)
+}
+
+// allocatePayment validates a payment and splits it given the interest
+// accrued up to its date. Given only one of interest and principal, the
+// other is the rest of the amount; given neither, the payment covers the
+// accrued interest first and repays principal with the rest. Accrued
+// interest the payment does not cover is capitalized, so it is owed (and
+// accrues interest) from the payment on instead of being forgiven.
+func allocatePayment(debt *models.Debt, p *models.DebtPayment, accrued money.Decimal, interest, principal *money.Decimal) error {
+	if p.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidPayment)
+	}
+	if truncateToDate(p.Date).Before(truncateToDate(debt.StartDate)) {
+		return fmt.Errorf("%w: date is before the debt's start_date", ErrInvalidPayment)
+	}
+	if !p.Amount.IsPositive() {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidPayment)
+	}
+	p.Amount = money.RoundTo(p.Amount, debt.Currency)
+	p.ManualSplit = interest != nil || principal != nil
+
+	switch {
+	case interest != nil && principal != nil:
+		p.Interest, p.Principal = money.RoundTo(*interest, debt.Currency), money.RoundTo(*principal, debt.Currency)
+		if !p.Interest.Add(p.Principal).Equal(p.Amount) {
+			return fmt.Errorf("%w: interest and principal must add up to the amount", ErrInvalidPayment)
+		}
+	case interest != nil:
+		p.Interest = money.RoundTo(*interest, debt.Currency)
+		p.Principal = p.Amount.Sub(p.Interest)
+	case principal != nil:
+		p.Principal = money.RoundTo(*principal, debt.Currency)
+		p.Interest = p.Amount.Sub(p.Principal)
+	default:
+		p.Interest = accrued
+		if p.Amount.LessThan(accrued) {
+			p.Interest = p.Amount
+		}
+		p.Principal = p.Amount.Sub(p.Interest)
+	}
+
+	if p.Interest.IsNegative() || p.Principal.IsNegative() {
+		return fmt.Errorf("%w: interest and principal cannot be negative or exceed the amount", ErrInvalidPayment)
+	}
+	p.CapitalizedInterest = money.Zero
+	if p.Interest.LessThan(accrued) {
+		p.CapitalizedInterest = accrued.Sub(p.Interest)
+	}
+	if owed := debt.CurrentValue.Add(p.CapitalizedInterest); owed.LessThan(p.Principal) {
+		return fmt.Errorf("%w: principal %s exceeds the %s balance owed", ErrInvalidPayment, p.Principal.String(), owed.String())
+	}
+	return nil
+}
+
+// resplitAfter splits the payments of a debt after a date again once a
+// payment on or before it was recorded, changed or deleted: each accrued its
+// interest on a different balance, or since a different payment. Manual
+// splits keep their interest and principal and only their capitalized
+// interest moves. Changed payments are saved and their difference in balance
+// reduction applied to the debt and its history.
+func resplitAfter(tx *sql.Tx, debtID string, date time.Time) error {
+	rows, err := tx.Query(
+		`SELECT `+debtPaymentColumns+` FROM debt_payments WHERE debt_id = $1 AND date > $2 ORDER BY date, created_at`,
+		debtID, date.Format("2006-01-02"),
+	)
+	if err != nil {
+		return err
+	}
+	var payments []models.DebtPayment
+	for rows.Next() {
+		var p models.DebtPayment
+		if err := scanDebtPayment(rows, &p); err != nil {
+			rows.Close()
+			return err
+		}
+		payments = append(payments, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(payments) == 0 {
+		return err
+	}
+
+	debt, err := loadLedgerDebt(tx, debtID, false)
+	if err != nil {
+		return err
+	}
+	previous, err := lastPayment(tx, debtID, "", date)
+	if err != nil {
+		return err
+	}
+
+	// The balance before the first of them is today's plus what they repaid
+	balance := debt.CurrentValue
+	for i := range payments {
+		balance = balance.Add(payments[i].BalanceReduction())
+	}
+
+	recorded := append([]models.DebtPayment(nil), payments...)
+	if err := resplitPayments(debt, balance, previous, payments); err != nil {
+		return err
+	}
+
+	for i := range payments {
+		p, old := &payments[i], &recorded[i]
+		if p.Interest.Equal(old.Interest) && p.Principal.Equal(old.Principal) && p.CapitalizedInterest.Equal(old.CapitalizedInterest) {
+			continue
+		}
+		if _, err := tx.Exec(`
+			UPDATE debt_payments SET interest = $1, principal = $2, capitalized_interest = $3, updated_at = $4
+			WHERE id = $5 AND debt_id = $6
+		`, p.Interest, p.Principal, p.CapitalizedInterest, time.Now(), p.ID, debtID); err != nil {
+			return err
+		}
+		if err := applyPrincipal(tx, debtID, p.Date, p.BalanceReduction().Sub(old.BalanceReduction())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resplitPayments splits payments again in date order, starting from the
+// balance before the first of them and the date of the payment before it
+// (nil for none). Each accrues interest on the balance the previous one left.
+func resplitPayments(debt *models.Debt, balance money.Decimal, previous *time.Time, payments []models.DebtPayment) error {
+	owed := *debt
+	for i := range payments {
+		p := &payments[i]
+		accrued := AccruedInterest(debt, balance, accrualStart(debt, previous), p.Date)
+
+		var interest, principal *money.Decimal
+		if p.ManualSplit {
+			given, repaid := p.Interest, p.Principal
+			interest, principal = &given, &repaid
+		}
+		owed.CurrentValue = balance
+		if err := allocatePayment(&owed, p, accrued, interest, principal); err != nil {
+			return fmt.Errorf("payment on %s no longer fits: %w", p.Date.Format("2006-01-02"), err)
+		}
+
+		balance = balance.Sub(p.BalanceReduction())
+		date := p.Date
+		previous = &date
+	}
+	return nil
+}
+
+// accruedSinceLastPayment returns the interest the debt's balance on a date
+// accrued since the previous payment, or since interest_since. The balance
+// on the date is the current one plus the balance reductions of the
+// payments after it.
+func accruedSinceLastPayment(db querier, debtID, excludeID string, debt *models.Debt, date time.Time) (money.Decimal, error) {
+	previous, err := lastPayment(db, debtID, excludeID, date)
+	if err != nil {
+		return money.Zero, err
+	}
+
+	var repaidSince money.Decimal
+	err = db.QueryRow(`
+		SELECT COALESCE(SUM(principal - capitalized_interest), 0) FROM debt_payments
+		WHERE debt_id = $1 AND date > $2 AND id::text <> $3
+	`, debtID, date.Format("2006-01-02"), excludeID).Scan(&repaidSince)
+	if err != nil {
+		return money.Zero, err
+	}
+
+	balance := debt.CurrentValue.Add(repaidSince)
+	return AccruedInterest(debt, balance, accrualStart(debt, previous), date), nil
+}
+
+// applyPrincipal reduces a debt's balance by principal repaid on a date (a
+// negative principal adds it back): the current balance and every recorded
+// balance from that date on. The balance before the date is first carried
+// into a row for the date, so the change shows on the day it was made.
+func applyPrincipal(db execer, debtID string, date time.Time, principal money.Decimal) error {
+	if principal.IsZero() {
+		return nil
+	}
+	day := date.Format("2006-01-02")
+
+	if _, err := db.Exec(`
+		INSERT INTO debt_history (id, debt_id, balance, date, created_at)
+		SELECT $1, d.id, COALESCE((
+			SELECT h.balance FROM debt_history h
+			WHERE h.debt_id = d.id AND h.date <= $3
+			ORDER BY h.date DESC LIMIT 1
+		), d.principal), $3, $4
+		FROM debts d
+		WHERE d.id = $2
+		ON CONFLICT (debt_id, date) DO NOTHING
+	`, uuid.New().String(), debtID, day, time.Now()); err != nil {
+		return err
+	}
+	if _, err := db.Exec(
+		`UPDATE debt_history SET balance = balance - $1 WHERE debt_id = $2 AND date >= $3`,
+		principal, debtID, day,
+	); err != nil {
+		return err
+	}
+	_, err := db.Exec(
+		`UPDATE debts SET current_value = current_value - $1, updated_at = $2 WHERE id = $3`,
+		principal, time.Now(), debtID,
+	)
+	return err
+}
+
+// RecordDebtBalance stores a debt's balance for a date, replacing any
+// balance already recorded that day
+func RecordDebtBalance(db execer, debtID string, balance money.Decimal, date time.Time) error {
+	query := `
+		INSERT INTO debt_history (id, debt_id, balance, date, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (debt_id, date) DO UPDATE SET balance = $3
+	`
+	_, err := db.Exec(query, uuid.New().String(), debtID, balance, date.Format("2006-01-02"), time.Now())
+	return err
+}
+
+// StartDebtHistory records a new debt's principal on its start date and its
+// current balance today
+func StartDebtHistory(db execer, debt *models.Debt) error {
+	if err := RecordDebtBalance(db, debt.ID, debt.Principal, debt.StartDate); err != nil {
+		return err
+	}
+	if truncateToDate(debt.StartDate).Before(truncateToDate(time.Now())) {
+		return RecordDebtBalance(db, debt.ID, debt.CurrentValue, time.Now())
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// recordingExecer records the statements run through it
+type recordingExecer struct {
+	statements []string
+}
+
+func (e *recordingExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	fields := strings.Fields(query)
+	statement := strings.Join(fields[:3], " ")
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case money.Decimal:
+			statement += " " + arg.String()
+		case string:
+			// Skip the generated row IDs
+			if len(arg) != 36 {
+				statement += " " + arg
+			}
+		}
+	}
+	e.statements = append(e.statements, statement)
+	return nil, nil
+}
+
+func TestApplyPrincipal(t *testing.T) {
+	date := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		principal string
+		want      []string
+	}{
+		{name: "no principal", principal: "0"},
+		{
+			name:      "repayment",
+			principal: "250.00",
+			// The balance is carried into the payment date, then reduced from it on
+			want: []string{
+				"INSERT INTO debt_history debt-1 2024-03-15",
+				"UPDATE debt_history SET 250.00 debt-1 2024-03-15",
+				"UPDATE debts SET 250.00 debt-1",
+			},
+		},
+		{
+			name:      "reversal",
+			principal: "-250.00",
+			want: []string{
+				"INSERT INTO debt_history debt-1 2024-03-15",
+				"UPDATE debt_history SET -250.00 debt-1 2024-03-15",
+				"UPDATE debts SET -250.00 debt-1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		db := &recordingExecer{}
+		if err := applyPrincipal(db, "debt-1", date, money.MustParse(tt.principal)); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprint(db.statements) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: ran %q, want %q", tt.name, db.statements, tt.want)
+		}
+	}
+}
+
+func TestStartDebtHistory(t *testing.T) {
+	today := time.Now().UTC().Format("2006-01-02")
+	tests := []struct {
+		name  string
+		start time.Time
+		want  []string
+	}{
+		{
+			name:  "debt started in the past",
+			start: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"INSERT INTO debt_history debt-1 15000.00 2020-06-01", "INSERT INTO debt_history debt-1 12000.00 " + today},
+		},
+		{
+			name:  "debt starting today",
+			start: time.Now().UTC(),
+			want:  []string{"INSERT INTO debt_history debt-1 15000.00 " + today},
+		},
+	}
+	for _, tt := range tests {
+		db := &recordingExecer{}
+		debt := &models.Debt{ID: "debt-1", Principal: money.MustParse("15000.00"), CurrentValue: money.MustParse("12000.00"), StartDate: tt.start}
+		if err := StartDebtHistory(db, debt); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprint(db.statements) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: ran %q, want %q", tt.name, db.statements, tt.want)
+		}
+	}
+}
+
+func TestAllocatePayment(t *testing.T) {
+	tests := []struct {
+		name                string
+		amount              string
+		accrued             string
+		interest, principal *money.Decimal
+		want                [3]string // interest, principal, capitalized interest
+		wantErr             bool
+	}{
+		{name: "covers interest first", amount: "250.00", accrued: "10.00", want: [3]string{"10.00", "240.00", "0"}},
+		{name: "short of the interest", amount: "6.00", accrued: "10.00", want: [3]string{"6.00", "0.00", "4.00"}},
+		{name: "explicit interest below accrued", amount: "100.00", accrued: "10.00", interest: decimalPtr("2.50"), want: [3]string{"2.50", "97.50", "7.50"}},
+		{name: "explicit principal", amount: "100.00", accrued: "10.00", principal: decimalPtr("100"), want: [3]string{"0.00", "100.00", "10.00"}},
+		{name: "explicit interest above accrued", amount: "100.00", accrued: "10.00", interest: decimalPtr("12"), want: [3]string{"12.00", "88.00", "0"}},
+		{name: "both parts", amount: "100.00", accrued: "0", interest: decimalPtr("30"), principal: decimalPtr("70"), want: [3]string{"30.00", "70.00", "0"}},
+		{name: "parts do not add up", amount: "100.00", accrued: "0", interest: decimalPtr("30"), principal: decimalPtr("60"), wantErr: true},
+		{name: "principal above amount", amount: "100.00", accrued: "0", principal: decimalPtr("120"), wantErr: true},
+		{name: "principal above balance", amount: "1500.00", accrued: "10.00", wantErr: true},
+		{name: "zero amount", amount: "0", accrued: "10.00", wantErr: true},
+	}
+	for _, tt := range tests {
+		debt := &models.Debt{
+			CurrentValue: money.MustParse("1000.00"),
+			Currency:     "USD",
+			StartDate:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		p := &models.DebtPayment{Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Amount: money.MustParse(tt.amount)}
+
+		err := allocatePayment(debt, p, money.MustParse(tt.accrued), tt.interest, tt.principal)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidPayment) {
+				t.Errorf("%s: got error %v, want ErrInvalidPayment", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		got := [3]string{p.Interest.String(), p.Principal.String(), p.CapitalizedInterest.String()}
+		if got != tt.want {
+			t.Errorf("%s: interest, principal, capitalized = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// A payment short of the accrued interest must leave the rest owed: the
+// next month accrues on the balance including it
+func TestUnpaidInterestCarriesForward(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	paid := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	debt := &models.Debt{
+		Type:         models.DebtTypeLoan,
+		CurrentValue: money.MustParse("1000.00"),
+		Currency:     "USD",
+		InterestRate: 12,
+		StartDate:    start,
+	}
+
+	accrued := AccruedInterest(debt, debt.CurrentValue, accrualStart(debt, nil), paid)
+	if accrued.String() != "10.00" {
+		t.Fatalf("January interest = %s, want 10.00", accrued)
+	}
+
+	p := &models.DebtPayment{Date: paid, Amount: money.MustParse("6.00")}
+	if err := allocatePayment(debt, p, accrued, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	debt.CurrentValue = debt.CurrentValue.Sub(p.BalanceReduction())
+	if debt.CurrentValue.String() != "1004.00" {
+		t.Fatalf("balance after paying 6.00 of 10.00 interest = %s, want 1004.00", debt.CurrentValue)
+	}
+
+	ApplyAccrual(debt, &paid, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	if debt.AccruedInterest.String() != "10.04" || debt.CurrentValue.String() != "1014.04" {
+		t.Errorf("February accrual = %s to %s, want 10.04 to 1014.04", debt.AccruedInterest, debt.CurrentValue)
+	}
+}
+
+// ledgerPayment is a payment of debt-1 as stored, split into interest,
+// principal and capitalized interest
+func ledgerPayment(id, date, amount string, split [3]string, manual bool) models.DebtPayment {
+	return models.DebtPayment{
+		ID: id, DebtID: "debt-1", Date: mustDate(date), Amount: money.MustParse(amount),
+		Interest: money.MustParse(split[0]), Principal: money.MustParse(split[1]), CapitalizedInterest: money.MustParse(split[2]),
+		ManualSplit: manual,
+	}
+}
+
+// Splits recorded against a 1000.00 balance, before a 500.00 payment on
+// 2024-01-15 was backdated in front of them
+func splitBeforeBackdating() []models.DebtPayment {
+	return []models.DebtPayment{
+		ledgerPayment("feb", "2024-02-01", "100.00", [3]string{"10.00", "90.00", "0"}, false),
+		ledgerPayment("mar", "2024-03-01", "100.00", [3]string{"9.10", "90.90", "0"}, false),
+		// Given as all principal: the interest it accrued is capitalized
+		ledgerPayment("mid-mar", "2024-03-15", "50.00", [3]string{"0.00", "50.00", "3.77"}, true),
+	}
+}
+
+func TestResplitPayments(t *testing.T) {
+	debt := &models.Debt{Type: models.DebtTypeLoan, Currency: "USD", InterestRate: 12, StartDate: mustDate("2024-01-01")}
+	payments := splitBeforeBackdating()
+
+	if err := resplitPayments(debt, money.MustParse("500.00"), datePtr("2024-01-15"), payments); err != nil {
+		t.Fatal(err)
+	}
+
+	// 17 days on 500.00, a month on 402.79, then 14 days on 306.82
+	want := [][3]string{{"2.79", "97.21", "0"}, {"4.03", "95.97", "0"}, {"0.00", "50.00", "1.41"}}
+	for i, p := range payments {
+		got := [3]string{p.Interest.String(), p.Principal.String(), p.CapitalizedInterest.String()}
+		if got != want[i] {
+			t.Errorf("%s: interest, principal, capitalized = %v, want %v", p.ID, got, want[i])
+		}
+	}
+
+	// A later payment repaying more than the backdated one left owed is rejected
+	payments = []models.DebtPayment{ledgerPayment("big", "2024-02-01", "600.00", [3]string{"10.00", "590.00", "0"}, false)}
+	if err := resplitPayments(debt, money.MustParse("500.00"), datePtr("2024-01-15"), payments); !errors.Is(err, ErrInvalidPayment) {
+		t.Errorf("overpaying payment: got error %v, want ErrInvalidPayment", err)
+	}
+}
+
+func TestResplitAfterBackdatedPayment(t *testing.T) {
+	db, fake := newFakeDB(t)
+	var rows [][]driver.Value
+	for _, p := range splitBeforeBackdating() {
+		rows = append(rows, []driver.Value{p.ID, p.DebtID, p.Date, p.Amount.String(), p.Interest.String(), p.Principal.String(),
+			p.CapitalizedInterest.String(), p.ManualSplit, "", time.Now(), time.Now()})
+	}
+	fake.returns("FROM debt_payments WHERE debt_id = $1 AND date > $2", []string{"id", "debt_id", "date", "amount", "interest",
+		"principal", "capitalized_interest", "manual_split", "notes", "created_at", "updated_at"}, rows...)
+	// 500.00 owed before the later payments, less the 227.13 they repaid
+	fake.returns("FROM debts WHERE id = $1", []string{"id", "type", "current_value", "currency", "interest_rate", "start_date",
+		"compounding", "rate_type", "interest_since"},
+		[]driver.Value{"debt-1", "loan", "272.87", "USD", 12.0, mustDate("2024-01-01"), "", "", nil})
+	fake.returns("FROM debt_rates", []string{"id"})
+	fake.returns("SELECT MAX(date) FROM debt_payments", []string{"max"}, []driver.Value{mustDate("2024-01-15")})
+	for _, statement := range []string{"UPDATE debt_payments", "INSERT INTO debt_history", "UPDATE debt_history", "UPDATE debts"} {
+		fake.on(statement, func([]driver.Value) (fakeRows, error) { return fakeRows{affected: 1}, nil })
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resplitAfter(tx, "debt-1", mustDate("2024-01-15")); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+
+	var got []string
+	for _, update := range fake.executed("UPDATE debt_payments") {
+		got = append(got, fmt.Sprintf("%v %v %v %v", update.args[4], update.args[0], update.args[1], update.args[2]))
+	}
+	want := []string{"feb 2.79 97.21 0", "mar 4.03 95.97 0", "mid-mar 0.00 50.00 1.41"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("payments saved as %v, want %v", got, want)
+	}
+
+	// Each payment now repays more, which comes off the balance from its date on
+	got = nil
+	for _, update := range fake.executed("UPDATE debt_history") {
+		got = append(got, fmt.Sprintf("%v %v", update.args[2], update.args[0]))
+	}
+	want = []string{"2024-02-01 7.21", "2024-03-01 5.07", "2024-03-15 2.36"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("history changes %v, want %v", got, want)
+	}
+	if updates := fake.executed("UPDATE debts SET current_value"); len(updates) != 3 {
+		t.Errorf("%d balance updates, want 3", len(updates))
+	}
+}
@@ -0,0 +1,594 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"personal-finance/api/v1/models"
+)
+
+var (
+	// ErrRateNotFound is returned when the requested rate change does not exist
+	ErrRateNotFound = errors.New("rate change not found")
+	// ErrRateIndexNotFound is returned when a rate index has no values
+	ErrRateIndexNotFound = errors.New("rate index not found")
+	// ErrInvalidRate is returned for rate changes and index values with
+	// missing or inconsistent fields
+	ErrInvalidRate = errors.New("invalid rate")
+)
+
+// rateIndexPattern matches rate index names such as prime or sofr_30d
+var rateIndexPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,49}$`)
+
+// farFuture bounds the search for upcoming rate changes
+var farFuture = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// debtRateColumns lists the rate change columns in the order scanDebtRate expects
+const debtRateColumns = `id, debt_id, effective_date, end_date, rate, COALESCE(rate_index, ''), COALESCE(margin, 0),
+	COALESCE(notes, ''), created_at, updated_at`
+
+// scanDebtRate scans a row selected with debtRateColumns into a rate change
+func scanDebtRate(row scanner, r *models.DebtRate) error {
+	return row.Scan(
+		&r.ID, &r.DebtID, &r.EffectiveDate, &r.EndDate, &r.Rate, &r.Index, &r.Margin,
+		&r.Notes, &r.CreatedAt, &r.UpdatedAt,
+	)
+}
+
+// NormalizeRateIndex returns a rate index name in lower case without
+// surrounding spaces
+func NormalizeRateIndex(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// LoadRateSchedules reads the rate changes of the given debts and the values
+// of the indexes they follow. Debts without rate changes keep a fixed rate.
+func LoadRateSchedules(db querier, debts ...*models.Debt) error {
+	if len(debts) == 0 {
+		return nil
+	}
+	byID := make(map[string]*models.Debt, len(debts))
+	ids := make([]string, 0, len(debts))
+	for _, debt := range debts {
+		debt.RateSchedule = nil
+		byID[debt.ID] = debt
+		ids = append(ids, debt.ID)
+	}
+
+	rows, err := db.Query(
+		`SELECT `+debtRateColumns+` FROM debt_rates WHERE debt_id = ANY($1::uuid[]) ORDER BY effective_date, created_at`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var r models.DebtRate
+		if err := scanDebtRate(rows, &r); err != nil {
+			rows.Close()
+			return err
+		}
+		debt := byID[r.DebtID]
+		if debt.RateSchedule == nil {
+			debt.RateSchedule = &models.RateSchedule{}
+		}
+		debt.RateSchedule.Rates = append(debt.RateSchedule.Rates, r)
+		if r.Index != "" && !seen[r.Index] {
+			seen[r.Index] = true
+			names = append(names, r.Index)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	indexes, err := rateIndexValues(db, names)
+	if err != nil {
+		return err
+	}
+	for _, debt := range debts {
+		if debt.RateSchedule != nil {
+			debt.RateSchedule.Indexes = indexes
+		}
+	}
+	return nil
+}
+
+// rateIndexValues returns the values of the given indexes, oldest first
+func rateIndexValues(db querier, names []string) (map[string][]models.RateIndexValue, error) {
+	rows, err := db.Query(
+		`SELECT name, date, rate, created_at FROM rate_index_values WHERE name = ANY($1) ORDER BY name, date`,
+		pq.Array(names),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := make(map[string][]models.RateIndexValue)
+	for rows.Next() {
+		var v models.RateIndexValue
+		if err := rows.Scan(&v.Index, &v.Date, &v.Rate, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		indexes[v.Index] = append(indexes[v.Index], v)
+	}
+	return indexes, rows.Err()
+}
+
+// RateOn returns a debt's interest rate on a date: a promotional rate that
+// covers the date, otherwise the last rate change on or before it, otherwise
+// the debt's interest_rate
+func RateOn(debt *models.Debt, date time.Time) float64 {
+	schedule := debt.RateSchedule
+	if schedule == nil {
+		return debt.InterestRate
+	}
+	date = truncateToDate(date)
+
+	rate := debt.InterestRate
+	var promotional *models.DebtRate
+	for i := range schedule.Rates {
+		r := &schedule.Rates[i]
+		if truncateToDate(r.EffectiveDate).After(date) {
+			break
+		}
+		if !r.Promotional() {
+			rate = scheduledRate(schedule, r, date)
+		} else if truncateToDate(*r.EndDate).After(date) {
+			promotional = r
+		}
+	}
+	if promotional != nil {
+		return scheduledRate(schedule, promotional, date)
+	}
+	return rate
+}
+
+// scheduledRate returns the rate a rate change sets on a date: its fixed
+// rate, or its index's value on the date plus its margin, at least zero
+func scheduledRate(schedule *models.RateSchedule, r *models.DebtRate, date time.Time) float64 {
+	if r.Rate != nil {
+		return *r.Rate
+	}
+	values := schedule.Indexes[r.Index]
+	if len(values) == 0 {
+		return 0
+	}
+	// Dates before the index's first value take that value
+	value := values[0].Rate
+	for _, v := range values {
+		if truncateToDate(v.Date).After(date) {
+			break
+		}
+		value = v.Rate
+	}
+	rate := math.Round((value+r.Margin)*10000) / 10000
+	return math.Max(rate, 0)
+}
+
+// rateBoundary is a date a debt's rate may change on and why
+type rateBoundary struct {
+	date   time.Time
+	reason string
+}
+
+// rateBoundaries returns every date a debt's rate schedule may change its
+// rate on, oldest first
+func rateBoundaries(debt *models.Debt) []rateBoundary {
+	schedule := debt.RateSchedule
+	if schedule == nil {
+		return nil
+	}
+
+	var boundaries []rateBoundary
+	for _, r := range schedule.Rates {
+		if r.Promotional() {
+			boundaries = append(boundaries,
+				rateBoundary{r.EffectiveDate, "promotional rate begins"},
+				rateBoundary{*r.EndDate, "promotional rate ends"},
+			)
+		} else {
+			boundaries = append(boundaries, rateBoundary{r.EffectiveDate, "rate change"})
+		}
+		if r.Index == "" {
+			continue
+		}
+		for _, v := range schedule.Indexes[r.Index] {
+			if v.Date.After(r.EffectiveDate) && (r.EndDate == nil || v.Date.Before(*r.EndDate)) {
+				boundaries = append(boundaries, rateBoundary{v.Date, r.Index + " index changes"})
+			}
+		}
+	}
+	sort.SliceStable(boundaries, func(i, j int) bool { return boundaries[i].date.Before(boundaries[j].date) })
+	return boundaries
+}
+
+// rateChanges returns the changes to a debt's rate after one date and up to
+// another, oldest first
+func rateChanges(debt *models.Debt, from, to time.Time) []models.RateChange {
+	from, to = truncateToDate(from), truncateToDate(to)
+
+	var changes []models.RateChange
+	previous := RateOn(debt, from)
+	for _, boundary := range rateBoundaries(debt) {
+		date := truncateToDate(boundary.date)
+		if !date.After(from) || date.After(to) {
+			continue
+		}
+		if rate := RateOn(debt, date); rate != previous {
+			changes = append(changes, models.RateChange{Date: date, Rate: rate, PreviousRate: previous, Reason: boundary.reason})
+			previous = rate
+		}
+	}
+	return changes
+}
+
+// UpcomingRateChanges returns the known changes to a debt's rate after a date
+func UpcomingRateChanges(debt *models.Debt, on time.Time) []models.RateChange {
+	return rateChanges(debt, on, farFuture)
+}
+
+// ApplyRateSchedule sets the rate a debt with a rate schedule has on a date
+// and the changes to it after the date
+func ApplyRateSchedule(debt *models.Debt, on time.Time) {
+	if debt.RateSchedule == nil {
+		return
+	}
+	rate := RateOn(debt, on)
+	debt.EffectiveRate = &rate
+	debt.UpcomingRateChanges = UpcomingRateChanges(debt, on)
+}
+
+// DebtRateService keeps the rate schedules of debts and the rate indexes
+// variable rates follow
+type DebtRateService struct {
+	db *sql.DB
+}
+
+// NewDebtRateService creates a new debt rate service
+func NewDebtRateService(db *sql.DB) *DebtRateService {
+	return &DebtRateService{db: db}
+}
+
+// ListRates returns a debt's rate changes, oldest first
+func (s *DebtRateService) ListRates(debtID string) ([]models.DebtRate, error) {
+	if _, err := loadLedgerDebt(s.db, debtID, false); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(
+		`SELECT `+debtRateColumns+` FROM debt_rates WHERE debt_id = $1 ORDER BY effective_date, created_at`,
+		debtID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.DebtRate{}
+	for rows.Next() {
+		var r models.DebtRate
+		if err := scanDebtRate(rows, &r); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+// GetRate returns one rate change of a debt
+func (s *DebtRateService) GetRate(debtID, id string) (*models.DebtRate, error) {
+	var r models.DebtRate
+	err := scanDebtRate(s.db.QueryRow(
+		`SELECT `+debtRateColumns+` FROM debt_rates WHERE id = $1 AND debt_id = $2`,
+		id, debtID,
+	), &r)
+	if err == sql.ErrNoRows {
+		return nil, ErrRateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateRate adds a rate change to a debt and accrues its interest again
+func (s *DebtRateService) CreateRate(debtID string, r *models.DebtRate) error {
+	return withDebt(s.db, debtID, func(tx *sql.Tx, debt *models.Debt) error {
+		if err := validateDebtRate(tx, debt, r, ""); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		r.ID = uuid.New().String()
+		r.DebtID = debtID
+		r.CreatedAt = now
+		r.UpdatedAt = now
+		if _, err := tx.Exec(`
+			INSERT INTO debt_rates (id, debt_id, effective_date, end_date, rate, rate_index, margin, notes, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`,
+			r.ID, r.DebtID, r.EffectiveDate.Format("2006-01-02"), r.EndDate, r.Rate, r.Index, r.Margin,
+			r.Notes, r.CreatedAt, r.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		return storeAccruedInterest(tx, debtID, now)
+	})
+}
+
+// UpdateRate saves changes to a rate change and accrues the debt's interest again
+func (s *DebtRateService) UpdateRate(r *models.DebtRate) error {
+	return withDebt(s.db, r.DebtID, func(tx *sql.Tx, debt *models.Debt) error {
+		if err := validateDebtRate(tx, debt, r, r.ID); err != nil {
+			return err
+		}
+
+		r.UpdatedAt = time.Now()
+		result, err := tx.Exec(`
+			UPDATE debt_rates
+			SET effective_date = $1, end_date = $2, rate = $3, rate_index = $4, margin = $5, notes = $6, updated_at = $7
+			WHERE id = $8 AND debt_id = $9
+		`,
+			r.EffectiveDate.Format("2006-01-02"), r.EndDate, r.Rate, r.Index, r.Margin, r.Notes, r.UpdatedAt,
+			r.ID, r.DebtID,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrRateNotFound
+		}
+		return storeAccruedInterest(tx, r.DebtID, r.UpdatedAt)
+	})
+}
+
+// DeleteRate removes a rate change and accrues the debt's interest again
+func (s *DebtRateService) DeleteRate(debtID, id string) error {
+	return withDebt(s.db, debtID, func(tx *sql.Tx, debt *models.Debt) error {
+		result, err := tx.Exec(`DELETE FROM debt_rates WHERE id = $1 AND debt_id = $2`, id, debtID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrRateNotFound
+		}
+		return storeAccruedInterest(tx, debtID, time.Now())
+	})
+}
+
+// validateDebtRate checks a rate change against its debt and the debt's
+// other rate changes, skipping excludeID: only one regular change can take
+// effect on a date and promotional periods cannot overlap
+func validateDebtRate(db querier, debt *models.Debt, r *models.DebtRate, excludeID string) error {
+	if r.EffectiveDate.IsZero() {
+		return fmt.Errorf("%w: effective_date is required", ErrInvalidRate)
+	}
+	if truncateToDate(r.EffectiveDate).Before(truncateToDate(debt.StartDate)) {
+		return fmt.Errorf("%w: effective_date is before the debt's start_date", ErrInvalidRate)
+	}
+	if r.EndDate != nil && !r.EndDate.After(r.EffectiveDate) {
+		return fmt.Errorf("%w: end_date must be after effective_date", ErrInvalidRate)
+	}
+
+	r.Index = NormalizeRateIndex(r.Index)
+	switch {
+	case r.Rate != nil && r.Index != "":
+		return fmt.Errorf("%w: set either rate or index, not both", ErrInvalidRate)
+	case r.Rate == nil && r.Index == "":
+		return fmt.Errorf("%w: rate or index is required", ErrInvalidRate)
+	case r.Rate != nil && *r.Rate < 0:
+		return fmt.Errorf("%w: rate cannot be negative", ErrInvalidRate)
+	case r.Rate != nil && r.Margin != 0:
+		return fmt.Errorf("%w: margin only applies to an index", ErrInvalidRate)
+	}
+
+	if r.Index != "" {
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM rate_index_values WHERE name = $1)`, r.Index).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: index %q has no values; set one with POST /api/v1/rate-indexes/%s", ErrInvalidRate, r.Index, r.Index)
+		}
+	}
+
+	var conflict bool
+	var err error
+	if r.Promotional() {
+		err = db.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM debt_rates
+			WHERE debt_id = $1 AND id::text <> $2 AND end_date IS NOT NULL AND effective_date < $4 AND end_date > $3)
+		`, debt.ID, excludeID, r.EffectiveDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02")).Scan(&conflict)
+		if err == nil && conflict {
+			return fmt.Errorf("%w: the promotional period overlaps another one", ErrInvalidRate)
+		}
+	} else {
+		err = db.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM debt_rates
+			WHERE debt_id = $1 AND id::text <> $2 AND end_date IS NULL AND effective_date = $3)
+		`, debt.ID, excludeID, r.EffectiveDate.Format("2006-01-02")).Scan(&conflict)
+		if err == nil && conflict {
+			return fmt.Errorf("%w: another rate change takes effect on %s", ErrInvalidRate, r.EffectiveDate.Format("2006-01-02"))
+		}
+	}
+	return err
+}
+
+// ListRateIndexes returns every rate index with its value today and the
+// number of debts that follow it
+func (s *DebtRateService) ListRateIndexes() ([]models.RateIndex, error) {
+	rows, err := s.db.Query(`SELECT name, date, rate FROM rate_index_values ORDER BY name, date`)
+	if err != nil {
+		return nil, err
+	}
+	today := truncateToDate(time.Now())
+	indexes := []models.RateIndex{}
+	for rows.Next() {
+		var name string
+		var date time.Time
+		var rate float64
+		if err := rows.Scan(&name, &date, &rate); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// Each index takes its last value on or before today, or its first
+		last := len(indexes) - 1
+		if last < 0 || indexes[last].Name != name {
+			indexes = append(indexes, models.RateIndex{Name: name, Rate: rate, Since: date})
+		} else if !truncateToDate(date).After(today) {
+			indexes[last].Rate, indexes[last].Since = rate, date
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	followers, err := s.db.Query(`SELECT rate_index, COUNT(DISTINCT debt_id) FROM debt_rates WHERE rate_index <> '' GROUP BY rate_index`)
+	if err != nil {
+		return nil, err
+	}
+	defer followers.Close()
+	counts := make(map[string]int)
+	for followers.Next() {
+		var name string
+		var count int
+		if err := followers.Scan(&name, &count); err != nil {
+			return nil, err
+		}
+		counts[name] = count
+	}
+	for i := range indexes {
+		indexes[i].Debts = counts[indexes[i].Name]
+	}
+	return indexes, followers.Err()
+}
+
+// RateIndexValues returns the values of a rate index, oldest first
+func (s *DebtRateService) RateIndexValues(name string) ([]models.RateIndexValue, error) {
+	name = NormalizeRateIndex(name)
+	indexes, err := rateIndexValues(s.db, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	values, ok := indexes[name]
+	if !ok {
+		return nil, ErrRateIndexNotFound
+	}
+	return values, nil
+}
+
+// SetRateIndexValue sets the value of a rate index from a date, replacing
+// any value already set for that date, and accrues the interest of the debts
+// that follow the index again
+func (s *DebtRateService) SetRateIndexValue(v *models.RateIndexValue) error {
+	v.Index = NormalizeRateIndex(v.Index)
+	if !rateIndexPattern.MatchString(v.Index) {
+		return fmt.Errorf("%w: index names use lower-case letters, digits, '.', '_' and '-' (at most 50)", ErrInvalidRate)
+	}
+	if v.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidRate)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	v.CreatedAt = time.Now()
+	if _, err := tx.Exec(`
+		INSERT INTO rate_index_values (name, date, rate, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name, date) DO UPDATE SET rate = EXCLUDED.rate, created_at = EXCLUDED.created_at
+	`, v.Index, v.Date.Format("2006-01-02"), v.Rate, v.CreatedAt); err != nil {
+		return err
+	}
+	if err := accrueIndexFollowers(tx, v.Index); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRateIndexValue removes the value of a rate index set for a date.
+// The last value of an index that debts follow cannot be removed.
+func (s *DebtRateService) DeleteRateIndexValue(name string, date time.Time) error {
+	name = NormalizeRateIndex(name)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM rate_index_values WHERE name = $1 AND date = $2`, name, date.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrRateIndexNotFound
+	}
+
+	var remaining, followers int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM rate_index_values WHERE name = $1`, name).Scan(&remaining); err != nil {
+		return err
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM debt_rates WHERE rate_index = $1`, name).Scan(&followers); err != nil {
+		return err
+	}
+	if remaining == 0 && followers > 0 {
+		return fmt.Errorf("%w: debts follow index %q; remove their rate changes first", ErrInvalidRate, name)
+	}
+
+	if err := accrueIndexFollowers(tx, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// accrueIndexFollowers stores the accrued interest of every debt with a rate
+// change that follows an index
+func accrueIndexFollowers(tx *sql.Tx, name string) error {
+	rows, err := tx.Query(`SELECT DISTINCT debt_id FROM debt_rates WHERE rate_index = $1`, name)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		if err := storeAccruedInterest(tx, id, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
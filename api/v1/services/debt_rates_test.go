@@ -0,0 +1,168 @@
+package services
+
+import (
+	"testing"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func ratePtr(rate float64) *float64 {
+	return &rate
+}
+
+// promoCard is a 24% card with a 0% promotional rate for the first half of 2024
+func promoCard() *models.Debt {
+	return &models.Debt{
+		ID:           "card",
+		Type:         models.DebtTypeCreditCard,
+		Currency:     "USD",
+		InterestRate: 24,
+		RateSchedule: &models.RateSchedule{Rates: []models.DebtRate{
+			{EffectiveDate: mustDate("2024-01-01"), EndDate: datePtr("2024-07-01"), Rate: ratePtr(0)},
+		}},
+	}
+}
+
+// primeHELOC is a line of credit at prime plus 1%, with prime cut from 8.5%
+// to 8% on 2024-07-01
+func primeHELOC() *models.Debt {
+	return &models.Debt{
+		ID:           "heloc",
+		Currency:     "USD",
+		Principal:    money.MustParse("50000"),
+		InterestRate: 9.5,
+		TermMonths:   120,
+		StartDate:    mustDate("2024-01-01"),
+		RateSchedule: &models.RateSchedule{
+			Rates: []models.DebtRate{{EffectiveDate: mustDate("2024-01-01"), Index: "prime", Margin: 1}},
+			Indexes: map[string][]models.RateIndexValue{"prime": {
+				{Index: "prime", Date: mustDate("2024-01-10"), Rate: 8.5},
+				{Index: "prime", Date: mustDate("2024-07-01"), Rate: 8},
+			}},
+		},
+	}
+}
+
+func TestRateOn(t *testing.T) {
+	raisedDuringPromo := promoCard()
+	raisedDuringPromo.RateSchedule.Rates = append(raisedDuringPromo.RateSchedule.Rates,
+		models.DebtRate{EffectiveDate: mustDate("2024-03-01"), Rate: ratePtr(26.99)})
+
+	negativeMargin := primeHELOC()
+	negativeMargin.RateSchedule.Rates[0].Margin = -9
+
+	tests := []struct {
+		name string
+		debt *models.Debt
+		on   string
+		want float64
+	}{
+		{name: "no schedule", debt: &models.Debt{InterestRate: 6.5}, on: "2024-05-01", want: 6.5},
+		{name: "before the promotion", debt: promoCard(), on: "2023-12-31", want: 24},
+		{name: "promotion starts", debt: promoCard(), on: "2024-01-01", want: 0},
+		{name: "last day of the promotion", debt: promoCard(), on: "2024-06-30", want: 0},
+		{name: "promotion ends", debt: promoCard(), on: "2024-07-01", want: 24},
+		// A rate change during a promotion applies once the promotion ends
+		{name: "rate raised during the promotion", debt: raisedDuringPromo, on: "2024-04-15", want: 0},
+		{name: "raised rate after the promotion", debt: raisedDuringPromo, on: "2024-07-01", want: 26.99},
+		// Dates before the index's first value take that value
+		{name: "before the first index value", debt: primeHELOC(), on: "2024-01-01", want: 9.5},
+		{name: "index plus margin", debt: primeHELOC(), on: "2024-06-30", want: 9.5},
+		{name: "index cut", debt: primeHELOC(), on: "2024-07-01", want: 9},
+		{name: "rate floored at zero", debt: negativeMargin, on: "2024-07-01", want: 0},
+	}
+	for _, tt := range tests {
+		if got := RateOn(tt.debt, mustDate(tt.on)); got != tt.want {
+			t.Errorf("%s: RateOn(%s) = %v, want %v", tt.name, tt.on, got, tt.want)
+		}
+	}
+}
+
+func TestUpcomingRateChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		debt *models.Debt
+		on   string
+		want []models.RateChange
+	}{
+		{
+			name: "promotion ends",
+			debt: promoCard(),
+			on:   "2024-03-15",
+			want: []models.RateChange{{Date: mustDate("2024-07-01"), Rate: 24, PreviousRate: 0, Reason: "promotional rate ends"}},
+		},
+		{
+			name: "index changes",
+			debt: primeHELOC(),
+			on:   "2024-03-15",
+			want: []models.RateChange{{Date: mustDate("2024-07-01"), Rate: 9, PreviousRate: 9.5, Reason: "prime index changes"}},
+		},
+		{name: "after the last change", debt: primeHELOC(), on: "2024-07-01"},
+	}
+	for _, tt := range tests {
+		got := UpcomingRateChanges(tt.debt, mustDate(tt.on))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d changes %v, want %v", tt.name, len(got), got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Date.Equal(tt.want[i].Date) || got[i].Rate != tt.want[i].Rate ||
+				got[i].PreviousRate != tt.want[i].PreviousRate || got[i].Reason != tt.want[i].Reason {
+				t.Errorf("%s: change %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestAccruedInterestAcrossRateChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		debt     *models.Debt
+		from, to string
+		want     string
+	}{
+		{name: "within the promotion", debt: promoCard(), from: "2024-03-01", to: "2024-04-01", want: "0"},
+		// 10 days at 0% then 10 days at 24% compounded daily
+		{name: "across the end of the promotion", debt: promoCard(), from: "2024-06-21", to: "2024-07-11", want: "6.59"},
+		{name: "after the promotion", debt: promoCard(), from: "2024-07-01", to: "2024-07-11", want: "6.59"},
+	}
+	for _, tt := range tests {
+		got := AccruedInterest(tt.debt, money.MustParse("1000.00"), mustDate(tt.from), mustDate(tt.to))
+		if got.String() != tt.want {
+			t.Errorf("%s: AccruedInterest from %s to %s = %s, want %s", tt.name, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestAmortizationRecastOnRateChange(t *testing.T) {
+	schedule, err := BuildAmortizationSchedule(primeHELOC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The level payment at 9.5% is recast over the remaining 114 payments
+	// once the period starting 2024-07-01 is charged 9%
+	tests := []struct {
+		number  int
+		payment string
+		rate    float64
+		balance string
+	}{
+		{number: 1, payment: "646.99", rate: 9.5},
+		{number: 6, payment: "646.99", rate: 9.5, balance: "48462.91"},
+		{number: 7, payment: "633.94", rate: 9},
+	}
+	for _, tt := range tests {
+		p := schedule.Payments[tt.number-1]
+		if p.Payment.String() != tt.payment || p.InterestRate != tt.rate {
+			t.Errorf("payment %d = %s at %v%%, want %s at %v%%", tt.number, p.Payment, p.InterestRate, tt.payment, tt.rate)
+		}
+		if tt.balance != "" && p.Balance.String() != tt.balance {
+			t.Errorf("balance after payment %d = %s, want %s", tt.number, p.Balance, tt.balance)
+		}
+	}
+	if len(schedule.Payments) != 120 {
+		t.Errorf("schedule has %d payments, want 120", len(schedule.Payments))
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeDB is a database/sql driver for tests. Each statement is answered by
+// the first handler whose fragment it contains, and every statement is
+// recorded with its arguments. Statements without a handler fail.
+type fakeDB struct {
+	mu         sync.Mutex
+	handlers   []fakeHandler
+	statements []fakeStatement
+}
+
+type fakeHandler struct {
+	fragment string
+	answer   func(args []driver.Value) (fakeRows, error)
+}
+
+// fakeRows answers a statement: the rows a query returns or the number of
+// rows an update affects
+type fakeRows struct {
+	columns  []string
+	values   [][]driver.Value
+	affected int64
+}
+
+// fakeStatement is a statement run against a fakeDB, with its whitespace collapsed
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+var (
+	fakeDBs     sync.Map
+	fakeDBCount int64
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+// newFakeDB opens a database answered by a new fakeDB
+func newFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	fake := &fakeDB{}
+	name := fmt.Sprintf("fake-%d", atomic.AddInt64(&fakeDBCount, 1))
+	fakeDBs.Store(name, fake)
+
+	db, err := sql.Open("fakedb", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeDBs.Delete(name)
+	})
+	return db, fake
+}
+
+// on answers statements containing fragment
+func (f *fakeDB) on(fragment string, answer func(args []driver.Value) (fakeRows, error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = append(f.handlers, fakeHandler{fragment: collapseSpace(fragment), answer: answer})
+}
+
+// returns answers queries containing fragment with the same rows every time
+func (f *fakeDB) returns(fragment string, columns []string, values ...[]driver.Value) {
+	f.on(fragment, func([]driver.Value) (fakeRows, error) {
+		return fakeRows{columns: columns, values: values}, nil
+	})
+}
+
+// executed returns the recorded statements containing fragment, in order
+func (f *fakeDB) executed(fragment string) []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fragment = collapseSpace(fragment)
+	var matched []fakeStatement
+	for _, s := range f.statements {
+		if strings.Contains(s.query, fragment) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// record adds a statement to the log without answering it
+func (f *fakeDB) record(query string, args []driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, fakeStatement{query: collapseSpace(query), args: args})
+}
+
+func (f *fakeDB) handle(query string, args []driver.Value) (fakeRows, error) {
+	f.record(query, args)
+	query = collapseSpace(query)
+
+	f.mu.Lock()
+	var answer func([]driver.Value) (fakeRows, error)
+	for _, h := range f.handlers {
+		if strings.Contains(query, h.fragment) {
+			answer = h.answer
+			break
+		}
+	}
+	f.mu.Unlock()
+
+	if answer == nil {
+		return fakeRows{}, fmt.Errorf("fakedb: unexpected statement %q", query)
+	}
+	return answer(args)
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fake, ok := fakeDBs.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: unknown database %s", name)
+	}
+	return &fakeConn{db: fake.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN", nil)
+	return fakeTx{db: c.db}, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx fakeTx) Commit() error {
+	tx.db.record("COMMIT", nil)
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.db.record("ROLLBACK", nil)
+	return nil
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	rows, err := s.db.handle(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(rows.affected), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.db.handle(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeResultRows{rows: rows}, nil
+}
+
+type fakeResultRows struct {
+	rows fakeRows
+	next int
+}
+
+func (r *fakeResultRows) Columns() []string { return r.rows.columns }
+func (r *fakeResultRows) Close() error      { return nil }
+
+func (r *fakeResultRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.next])
+	r.next++
+	return nil
+}
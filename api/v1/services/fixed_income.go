@@ -0,0 +1,271 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// FixedIncomeJobName is the scheduler name of the job that revalues bonds and CDs
+const FixedIncomeJobName = "fixed_income_valuation"
+
+// fixedIncomePlaces is the precision of accrued interest and CD growth per unit
+const fixedIncomePlaces = 10
+
+// ErrInvalidInvestmentTerms is returned when an investment's kind or its
+// bond or CD terms are inconsistent
+var ErrInvalidInvestmentTerms = errors.New("invalid investment terms")
+
+// ValidateInvestmentTerms checks an asset's investment kind and the terms its
+// kind is valued from, filling in the defaults: a clean price of par and
+// semi-annual coupons for bonds
+func ValidateInvestmentTerms(asset *models.Asset) error {
+	if asset.Kind == "" {
+		return nil
+	}
+	if asset.Type != models.AssetTypeInvestment {
+		return fmt.Errorf("%w: kind only applies to investments", ErrInvalidInvestmentTerms)
+	}
+	if !asset.Kind.Valid() {
+		return fmt.Errorf("%w: unknown kind %q (use fund, bond or cd)", ErrInvalidInvestmentTerms, asset.Kind)
+	}
+	if !asset.IsFixedIncome() {
+		return nil
+	}
+
+	if asset.Source == models.AssetSourceMarketAPI {
+		return fmt.Errorf("%w: %ss are valued from their terms, use source manual", ErrInvalidInvestmentTerms, asset.Kind)
+	}
+	if asset.MaturityDate == nil {
+		return fmt.Errorf("%w: %s maturity_date is required", ErrInvalidInvestmentTerms, asset.Kind)
+	}
+	if !asset.MaturityDate.After(asset.PurchaseDate) {
+		return fmt.Errorf("%w: maturity_date must be after purchase_date", ErrInvalidInvestmentTerms)
+	}
+	if asset.InterestRate < 0 {
+		return fmt.Errorf("%w: interest_rate cannot be negative", ErrInvalidInvestmentTerms)
+	}
+
+	if asset.Kind == models.InvestmentKindBond {
+		if asset.FaceValue == nil || !asset.FaceValue.IsPositive() {
+			return fmt.Errorf("%w: bond face_value must be positive", ErrInvalidInvestmentTerms)
+		}
+		if asset.CleanPrice == nil {
+			par := money.NewFromInt(100)
+			asset.CleanPrice = &par
+		}
+		if !asset.CleanPrice.IsPositive() {
+			return fmt.Errorf("%w: clean_price must be positive (percent of face value)", ErrInvalidInvestmentTerms)
+		}
+		if asset.CouponFrequency == 0 && asset.InterestRate > 0 {
+			asset.CouponFrequency = 2
+		}
+		switch asset.CouponFrequency {
+		case 0, 1, 2, 4, 12:
+		default:
+			return fmt.Errorf("%w: coupon_frequency must be 1, 2, 4 or 12 payments a year", ErrInvalidInvestmentTerms)
+		}
+	}
+	return nil
+}
+
+// FixedIncomeUnitValue returns the value of one unit of a bond or CD on a
+// date and the accrued interest included in it. ok is false for other assets.
+//
+// A bond is worth its clean price in percent of face value plus the coupon
+// accrued since the last payment date (actual/actual), and its face value
+// from maturity. A CD grows from its purchase price at its APY (the annual
+// yield including compounding) until maturity.
+func FixedIncomeUnitValue(asset *models.Asset, on time.Time) (value, accrued money.Decimal, ok bool) {
+	if !asset.IsFixedIncome() || asset.MaturityDate == nil {
+		return money.Zero, money.Zero, false
+	}
+	on = truncateToDate(on)
+	maturity := truncateToDate(*asset.MaturityDate)
+
+	switch asset.Kind {
+	case models.InvestmentKindBond:
+		if asset.FaceValue == nil {
+			return money.Zero, money.Zero, false
+		}
+		face := *asset.FaceValue
+		if !on.Before(maturity) {
+			return face, money.Zero, true
+		}
+
+		cleanPrice := money.NewFromInt(100)
+		if asset.CleanPrice != nil {
+			cleanPrice = *asset.CleanPrice
+		}
+		accrued = bondAccruedInterest(face, asset.InterestRate, asset.CouponFrequency, maturity, on)
+		value = face.Mul(cleanPrice).Div(money.NewFromInt(100), fixedIncomePlaces).Add(accrued)
+		return value, accrued, true
+
+	case models.InvestmentKindCD:
+		if on.After(maturity) {
+			on = maturity
+		}
+		days := daysBetween(truncateToDate(asset.PurchaseDate), on)
+		if days <= 0 {
+			return asset.BuyPrice, money.Zero, true
+		}
+		growth := math.Pow(1+asset.InterestRate/100, float64(days)/365)
+		value = asset.BuyPrice.Mul(money.NewFromFloat(growth)).Round(fixedIncomePlaces)
+		return value, value.Sub(asset.BuyPrice), true
+	}
+	return money.Zero, money.Zero, false
+}
+
+// bondAccruedInterest returns the coupon interest on one bond earned from
+// the last coupon date before on, counting actual days in the period.
+// Coupon dates step back from maturity by whole periods.
+func bondAccruedInterest(face money.Decimal, rate float64, frequency int, maturity, on time.Time) money.Decimal {
+	if rate == 0 || frequency == 0 {
+		return money.Zero
+	}
+
+	months := 12 / frequency
+	next := maturity
+	previous := addMonthsClamped(maturity, -months)
+	for k := 2; previous.After(on); k++ {
+		next = previous
+		previous = addMonthsClamped(maturity, -k*months)
+	}
+
+	elapsed := daysBetween(previous, on)
+	period := daysBetween(previous, next)
+	if elapsed <= 0 || period <= 0 {
+		return money.Zero
+	}
+
+	// face × rate% / frequency × elapsed / period
+	coupon := face.Mul(money.NewFromFloat(rate)).Mul(money.NewFromInt(int64(elapsed)))
+	return coupon.Div(money.NewFromInt(int64(100*frequency*period)), fixedIncomePlaces)
+}
+
+// addMonthsClamped adds months to a date, keeping its day of month but
+// clamping it to the end of shorter months (Aug 31 - 6 months is Feb 28)
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// daysBetween counts the calendar days from one date to another
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(truncateToDate(to).Sub(truncateToDate(from)).Hours() / 24))
+}
+
+// ApplyFixedIncomeValue replaces the stored value of a bond or CD with its
+// value on the given date and sets its accrued interest; other assets are
+// left alone. Like ApplyMarketPrices only the asset passed in is changed;
+// stored values are updated by the fixed income valuation job.
+func ApplyFixedIncomeValue(asset *models.Asset, on time.Time) {
+	value, accrued, ok := FixedIncomeUnitValue(asset, on)
+	if !ok {
+		return
+	}
+	asset.CurrentValue = money.RoundTo(value, asset.Currency)
+	total := money.RoundTo(accrued.Mul(asset.Quantity), asset.Currency)
+	asset.AccruedInterest = &total
+}
+
+// ApplyFixedIncomeValues applies ApplyFixedIncomeValue to every asset
+func ApplyFixedIncomeValues(assets []models.Asset, on time.Time) {
+	for i := range assets {
+		ApplyFixedIncomeValue(&assets[i], on)
+	}
+}
+
+// FixedIncomeService stores the daily value of bonds and CDs
+type FixedIncomeService struct {
+	db *sql.DB
+}
+
+// NewFixedIncomeService creates a new fixed income service
+func NewFixedIncomeService(db *sql.DB) *FixedIncomeService {
+	return &FixedIncomeService{db: db}
+}
+
+// FixedIncomeResult summarizes one run of the fixed income valuation job
+type FixedIncomeResult struct {
+	AssetsUpdated int      `json:"assets_updated"`
+	Failed        []string `json:"failed"`
+}
+
+// RevalueAll stores today's value of every bond and CD as its current value
+// and in asset_history
+func (s *FixedIncomeService) RevalueAll() (*FixedIncomeResult, error) {
+	query := `
+		SELECT id, type, COALESCE(kind, ''), buy_price, COALESCE(currency, ''), purchase_date,
+			face_value, clean_price, COALESCE(interest_rate, 0), COALESCE(coupon_frequency, 0), maturity_date
+		FROM assets
+		WHERE type = 'investment' AND kind IN ('bond', 'cd')
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch assets: %w", err)
+	}
+
+	var assets []models.Asset
+	for rows.Next() {
+		var asset models.Asset
+		if err := rows.Scan(&asset.ID, &asset.Type, &asset.Kind, &asset.BuyPrice, &asset.Currency, &asset.PurchaseDate,
+			&asset.FaceValue, &asset.CleanPrice, &asset.InterestRate, &asset.CouponFrequency, &asset.MaturityDate); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse assets: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	rows.Close()
+
+	result := &FixedIncomeResult{Failed: []string{}}
+	today := time.Now()
+	for _, asset := range assets {
+		value, _, ok := FixedIncomeUnitValue(&asset, today)
+		if !ok {
+			continue
+		}
+		if err := s.storeValue(asset.ID, money.RoundTo(value, asset.Currency), today); err != nil {
+			log.Printf("[FixedIncome] Failed to store value for asset %s: %v", asset.ID, err)
+			result.Failed = append(result.Failed, asset.ID)
+			continue
+		}
+		result.AssetsUpdated++
+	}
+
+	if len(result.Failed) > 0 && result.AssetsUpdated == 0 {
+		return result, fmt.Errorf("no bond or CD could be revalued")
+	}
+	return result, nil
+}
+
+// storeValue updates an asset's current value and its history row for the day
+func (s *FixedIncomeService) storeValue(assetID string, value money.Decimal, date time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`UPDATE assets SET current_value = $1, updated_at = $2 WHERE id = $3`,
+		value, time.Now(), assetID,
+	); err != nil {
+		return err
+	}
+	if err := upsertAssetHistory(tx, assetID, value, date); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// bond returns a bond with a face value of 1000 and the given terms
+func bond(maturity, cleanPrice string, rate float64, frequency int) models.Asset {
+	face, clean := money.MustParse("1000"), money.MustParse(cleanPrice)
+	return models.Asset{
+		Type:            models.AssetTypeInvestment,
+		Kind:            models.InvestmentKindBond,
+		Currency:        "USD",
+		FaceValue:       &face,
+		CleanPrice:      &clean,
+		InterestRate:    rate,
+		CouponFrequency: frequency,
+		MaturityDate:    datePtr(maturity),
+	}
+}
+
+func TestFixedIncomeUnitValueBonds(t *testing.T) {
+	tests := []struct {
+		name    string
+		asset   models.Asset
+		on      string
+		value   string
+		accrued string
+	}{
+		// 92 of the 183 days since the June coupon: 1000 × 5% / 2 × 92 / 183
+		{name: "mid period", asset: bond("2030-06-15", "98", 5, 2), on: "2024-09-15", value: "992.5683060109", accrued: "12.5683060109"},
+		{name: "on a coupon date", asset: bond("2030-06-15", "98", 5, 2), on: "2024-06-15", value: "980.0000000000", accrued: "0"},
+		{name: "day after a coupon", asset: bond("2030-06-15", "98", 5, 2), on: "2024-06-16", value: "980.1366120219", accrued: "0.1366120219"},
+		// Coupons from an August 31 maturity fall on the last day of February:
+		// 32 of the 184 days from 2024-02-29 to 2024-08-31
+		{name: "end of month coupons", asset: bond("2030-08-31", "100", 5, 2), on: "2024-04-01", value: "1004.3478260870", accrued: "4.3478260870"},
+		// 46 of the 92 days from June 30 to September 30
+		{name: "quarterly", asset: bond("2030-03-31", "100", 5, 4), on: "2024-08-15", value: "1006.2500000000", accrued: "6.2500000000"},
+		{name: "zero coupon", asset: bond("2030-06-15", "97.5", 0, 0), on: "2024-09-15", value: "975.0000000000", accrued: "0"},
+		{name: "at maturity", asset: bond("2030-06-15", "98", 5, 2), on: "2030-06-15", value: "1000", accrued: "0"},
+		{name: "after maturity", asset: bond("2030-06-15", "98", 5, 2), on: "2031-01-01", value: "1000", accrued: "0"},
+	}
+	for _, tt := range tests {
+		value, accrued, ok := FixedIncomeUnitValue(&tt.asset, mustDate(tt.on))
+		if !ok {
+			t.Errorf("%s: bond not valued", tt.name)
+			continue
+		}
+		if value.String() != tt.value || accrued.String() != tt.accrued {
+			t.Errorf("%s: value %s with %s accrued, want %s with %s accrued", tt.name, value, accrued, tt.value, tt.accrued)
+		}
+	}
+}
+
+func TestFixedIncomeUnitValueCDs(t *testing.T) {
+	cd := models.Asset{
+		Type:         models.AssetTypeInvestment,
+		Kind:         models.InvestmentKindCD,
+		Currency:     "USD",
+		BuyPrice:     money.MustParse("10000"),
+		InterestRate: 5,
+		PurchaseDate: mustDate("2024-01-01"),
+		MaturityDate: datePtr("2025-01-01"),
+	}
+	tests := []struct {
+		on      string
+		value   string
+		accrued string
+	}{
+		{on: "2023-12-01", value: "10000", accrued: "0"},
+		{on: "2024-01-01", value: "10000", accrued: "0"},
+		// 10000 × 1.05^(182/365)
+		{on: "2024-07-01", value: "10246.2659252703", accrued: "246.2659252703"},
+		// The APY is earned over 365 days
+		{on: "2024-12-31", value: "10500.0000000000", accrued: "500.0000000000"},
+		// 2024 has 366 days, so the CD grows one day past its APY
+		{on: "2025-01-01", value: "10501.4036464797", accrued: "501.4036464797"},
+		// Nothing grows after maturity
+		{on: "2025-06-01", value: "10501.4036464797", accrued: "501.4036464797"},
+	}
+	for _, tt := range tests {
+		value, accrued, ok := FixedIncomeUnitValue(&cd, mustDate(tt.on))
+		if !ok {
+			t.Errorf("%s: CD not valued", tt.on)
+			continue
+		}
+		if value.String() != tt.value || accrued.String() != tt.accrued {
+			t.Errorf("%s: value %s with %s accrued, want %s with %s accrued", tt.on, value, accrued, tt.value, tt.accrued)
+		}
+	}
+}
+
+func TestFixedIncomeUnitValueSkipsOtherAssets(t *testing.T) {
+	assets := []models.Asset{
+		{Type: models.AssetTypeInvestment, Kind: models.InvestmentKindFund, MaturityDate: datePtr("2030-01-01")},
+		// A bond without a maturity date has no schedule to value it by
+		{Type: models.AssetTypeInvestment, Kind: models.InvestmentKindBond},
+	}
+	for _, asset := range assets {
+		if _, _, ok := FixedIncomeUnitValue(&asset, mustDate("2024-06-01")); ok {
+			t.Errorf("%s valued from fixed income terms", asset.Kind)
+		}
+	}
+}
+
+func TestApplyFixedIncomeValue(t *testing.T) {
+	asset := bond("2030-06-15", "98", 5, 2)
+	asset.Quantity = money.MustParse("3")
+	ApplyFixedIncomeValue(&asset, mustDate("2024-09-15"))
+
+	// Each unit is 992.5683060109 with 12.5683060109 accrued
+	if asset.CurrentValue.String() != "992.57" || asset.AccruedInterest == nil || asset.AccruedInterest.String() != "37.70" {
+		t.Errorf("value %s with %v accrued, want 992.57 with 37.70 accrued", asset.CurrentValue, asset.AccruedInterest)
+	}
+}
+
+func TestSnapshotValuesFixedIncome(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.returns("FROM assets", []string{
+		"id", "type", "name", "symbol", "buy_price", "current_value", "quantity", "currency", "purchase_date",
+		"source", "provider", "kind", "face_value", "clean_price", "interest_rate", "coupon_frequency", "maturity_date",
+	},
+		// A matured CD whose stored value is still its purchase price
+		[]driver.Value{"cd", "investment", "CD", "", "10000", "10000", "1", "USD", mustDate("2024-01-01"),
+			"manual", "", "cd", nil, nil, "5", int64(0), mustDate("2025-01-01")},
+		[]driver.Value{"cash", "cash", "Checking", "", "500.00", "500.00", "1", "USD", mustDate("2024-01-01"),
+			"manual", "", "", nil, nil, "0", int64(0), nil},
+	)
+
+	s := &SnapshotService{db: db, marketData: newStubMarketData(&stubProvider{name: "stub"})}
+	totals, err := s.assetTotalsByType(&Converter{target: "USD"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if totals["investment"].String() != "10501.40" || totals["cash"].String() != "500.00" {
+		t.Errorf("totals %v, want the CD at its maturity value of 10501.40 and 500.00 cash", totals)
+	}
+}
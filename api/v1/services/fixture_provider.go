@@ -0,0 +1,482 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+const (
+	ProviderFixture MarketDataProvider = "fixture"
+
+	defaultFixtureDir = "fixtures/market_data"
+)
+
+// fixtureQuote is one entry of quotes.json / quotes.csv
+type fixtureQuote struct {
+	Symbol   string        `json:"symbol"`
+	Price    money.Decimal `json:"price"`
+	Currency string        `json:"currency"`
+	Exchange string        `json:"exchange"`
+	Name     string        `json:"name"`
+	Type     string        `json:"type"`
+}
+
+// FixtureProvider serves quotes and history from local files so the API
+// works offline and returns the same numbers on every run.
+//
+// Layout of the fixture directory:
+//
+//	quotes.json or quotes.csv   latest prices (symbol,price,currency,exchange,name,type)
+//	history/<SYMBOL>.csv        daily closes (date,close)
+//	history/<SYMBOL>.json       daily closes ([{"date": "2024-01-02", "close": 185.64}])
+//	dividends/<SYMBOL>.csv      dividends per share by ex-date (date,amount)
+//	splits/<SYMBOL>.csv         stock splits by ex-date (date,numerator,denominator)
+//	replay/                     recorded HTTP responses, see RecordingTransport
+//
+// Symbols missing from the fixtures are answered from the replay directory
+// by the provider named in MARKET_DATA_REPLAY_PROVIDER, if set.
+type FixtureProvider struct {
+	dir       string
+	quotes    map[string]fixtureQuote
+	history   map[string][]Bar
+	dividends map[string][]Dividend
+	splits    map[string][]Split
+	replay    QuoteProvider
+}
+
+// NewFixtureProvider loads the fixtures found in dir
+func NewFixtureProvider(dir string) (*FixtureProvider, error) {
+	p := &FixtureProvider{
+		dir:       dir,
+		quotes:    make(map[string]fixtureQuote),
+		history:   make(map[string][]Bar),
+		dividends: make(map[string][]Dividend),
+		splits:    make(map[string][]Split),
+	}
+
+	if err := p.loadQuotes(); err != nil {
+		return nil, err
+	}
+	if err := p.loadHistory(); err != nil {
+		return nil, err
+	}
+	if err := p.loadDividends(); err != nil {
+		return nil, err
+	}
+	if err := p.loadSplits(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// newFixtureProviderFromEnv builds the fixture provider configured by environment variables
+func newFixtureProviderFromEnv(cfg ProviderConfig) (QuoteProvider, error) {
+	dir := os.Getenv("MARKET_DATA_FIXTURE_DIR")
+	if dir == "" {
+		dir = defaultFixtureDir
+	}
+
+	p, err := NewFixtureProvider(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if upstream := os.Getenv("MARKET_DATA_REPLAY_PROVIDER"); upstream != "" {
+		client := &http.Client{Transport: NewReplayTransport(filepath.Join(dir, "replay"))}
+		replay, err := newProvider(upstream, ProviderConfig{HTTPClient: client})
+		if err != nil {
+			return nil, err
+		}
+		p.replay = replay
+	}
+
+	return p, nil
+}
+
+func init() {
+	RegisterProvider(string(ProviderFixture), newFixtureProviderFromEnv)
+}
+
+// Name returns the provider name
+func (p *FixtureProvider) Name() string {
+	return string(ProviderFixture)
+}
+
+// GetQuote returns the fixture price for a symbol, falling back to the last
+// historical close and then to the replay provider
+func (p *FixtureProvider) GetQuote(symbol string) (*Quote, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	if q, ok := p.quotes[symbol]; ok {
+		timestamp := time.Now()
+		if bars := p.history[symbol]; len(bars) > 0 {
+			timestamp = bars[len(bars)-1].Date
+		}
+		return &Quote{
+			Symbol:    symbol,
+			Price:     q.Price,
+			Currency:  q.Currency,
+			Exchange:  q.Exchange,
+			Timestamp: timestamp,
+			Provider:  p.Name(),
+		}, nil
+	}
+
+	if bars := p.history[symbol]; len(bars) > 0 {
+		last := bars[len(bars)-1]
+		return &Quote{
+			Symbol:    symbol,
+			Price:     last.Close,
+			Timestamp: last.Date,
+			Provider:  p.Name(),
+		}, nil
+	}
+
+	if p.replay != nil {
+		return p.replay.GetQuote(symbol)
+	}
+
+	return nil, fmt.Errorf("%w: no fixture for %s", ErrSymbolNotFound, symbol)
+}
+
+// GetQuotes returns fixture prices for several symbols
+func (p *FixtureProvider) GetQuotes(symbols []string) (map[string]*Quote, error) {
+	return fetchQuotesSequentially(p, symbols)
+}
+
+// GetHistory returns the fixture closes between from and to
+func (p *FixtureProvider) GetHistory(symbol string, from, to time.Time) ([]Bar, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	bars, ok := p.history[symbol]
+	if !ok {
+		if p.replay != nil {
+			return p.replay.GetHistory(symbol, from, to)
+		}
+		return nil, fmt.Errorf("%w: no history fixture for %s", ErrSymbolNotFound, symbol)
+	}
+
+	fromDate, toDate := truncateToDate(from), truncateToDate(to)
+	result := []Bar{}
+	for _, bar := range bars {
+		if bar.Date.Before(fromDate) || bar.Date.After(toDate) {
+			continue
+		}
+		result = append(result, bar)
+	}
+	return result, nil
+}
+
+// GetDividends returns the fixture dividends between from and to
+func (p *FixtureProvider) GetDividends(symbol string, from, to time.Time) ([]Dividend, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	dividends, ok := p.dividends[symbol]
+	if !ok {
+		if replay, ok := p.replay.(DividendProvider); ok {
+			return replay.GetDividends(symbol, from, to)
+		}
+		// A symbol without a dividend fixture pays no dividends
+		return []Dividend{}, nil
+	}
+
+	fromDate, toDate := truncateToDate(from), truncateToDate(to)
+	result := []Dividend{}
+	for _, dividend := range dividends {
+		if dividend.Date.Before(fromDate) || dividend.Date.After(toDate) {
+			continue
+		}
+		result = append(result, dividend)
+	}
+	return result, nil
+}
+
+// GetSplits returns the fixture splits between from and to
+func (p *FixtureProvider) GetSplits(symbol string, from, to time.Time) ([]Split, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	splits, ok := p.splits[symbol]
+	if !ok {
+		if replay, ok := p.replay.(SplitProvider); ok {
+			return replay.GetSplits(symbol, from, to)
+		}
+		// A symbol without a split fixture never split
+		return []Split{}, nil
+	}
+
+	fromDate, toDate := truncateToDate(from), truncateToDate(to)
+	result := []Split{}
+	for _, split := range splits {
+		if split.Date.Before(fromDate) || split.Date.After(toDate) {
+			continue
+		}
+		result = append(result, split)
+	}
+	return result, nil
+}
+
+// SearchSymbols matches the query against fixture symbols and names
+func (p *FixtureProvider) SearchSymbols(query string) ([]SymbolInfo, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	matches := []SymbolInfo{}
+	for symbol, q := range p.quotes {
+		if strings.Contains(strings.ToLower(symbol), query) || strings.Contains(strings.ToLower(q.Name), query) {
+			matches = append(matches, SymbolInfo{
+				Symbol:   symbol,
+				Name:     q.Name,
+				Exchange: q.Exchange,
+				Currency: q.Currency,
+				Type:     q.Type,
+			})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Symbol < matches[j].Symbol })
+
+	if len(matches) == 0 && p.replay != nil {
+		return p.replay.SearchSymbols(query)
+	}
+	return matches, nil
+}
+
+// loadQuotes reads quotes.json or quotes.csv, whichever exists
+func (p *FixtureProvider) loadQuotes() error {
+	if data, err := os.ReadFile(filepath.Join(p.dir, "quotes.json")); err == nil {
+		var quotes []fixtureQuote
+		if err := json.Unmarshal(data, &quotes); err != nil {
+			return fmt.Errorf("invalid quotes.json: %w", err)
+		}
+		for _, q := range quotes {
+			q.Symbol = strings.ToUpper(q.Symbol)
+			p.quotes[q.Symbol] = q
+		}
+		return nil
+	}
+
+	records, err := readCSVFile(filepath.Join(p.dir, "quotes.csv"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for i, record := range records {
+		if len(record) < 2 {
+			return fmt.Errorf("quotes.csv row %d: need at least symbol and price", i+2)
+		}
+		price, err := money.Parse(record[1])
+		if err != nil {
+			return fmt.Errorf("quotes.csv row %d: invalid price '%s'", i+2, record[1])
+		}
+		q := fixtureQuote{Symbol: strings.ToUpper(record[0]), Price: price}
+		if len(record) > 2 {
+			q.Currency = record[2]
+		}
+		if len(record) > 3 {
+			q.Exchange = record[3]
+		}
+		if len(record) > 4 {
+			q.Name = record[4]
+		}
+		if len(record) > 5 {
+			q.Type = record[5]
+		}
+		p.quotes[q.Symbol] = q
+	}
+	return nil
+}
+
+// loadHistory reads every file in the history directory
+func (p *FixtureProvider) loadHistory() error {
+	entries, err := os.ReadDir(filepath.Join(p.dir, "history"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(p.dir, "history", entry.Name())
+		ext := filepath.Ext(entry.Name())
+		symbol := strings.ToUpper(strings.TrimSuffix(entry.Name(), ext))
+
+		var bars []Bar
+		switch ext {
+		case ".csv":
+			bars, err = readBarsCSV(path)
+		case ".json":
+			bars, err = readBarsJSON(path)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("history fixture %s: %w", entry.Name(), err)
+		}
+
+		sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+		p.history[symbol] = bars
+	}
+	return nil
+}
+
+// loadDividends reads every date,amount CSV file in the dividends directory
+func (p *FixtureProvider) loadDividends() error {
+	entries, err := os.ReadDir(filepath.Join(p.dir, "dividends"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".csv" {
+			continue
+		}
+		symbol := strings.ToUpper(strings.TrimSuffix(entry.Name(), ".csv"))
+
+		// Same date,value layout as history closes
+		bars, err := readBarsCSV(filepath.Join(p.dir, "dividends", entry.Name()))
+		if err != nil {
+			return fmt.Errorf("dividend fixture %s: %w", entry.Name(), err)
+		}
+
+		dividends := make([]Dividend, 0, len(bars))
+		for _, bar := range bars {
+			dividends = append(dividends, Dividend{Date: bar.Date, Amount: bar.Close})
+		}
+		sort.Slice(dividends, func(i, j int) bool { return dividends[i].Date.Before(dividends[j].Date) })
+		p.dividends[symbol] = dividends
+	}
+	return nil
+}
+
+// loadSplits reads every date,numerator,denominator CSV file in the splits directory
+func (p *FixtureProvider) loadSplits() error {
+	entries, err := os.ReadDir(filepath.Join(p.dir, "splits"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".csv" {
+			continue
+		}
+		symbol := strings.ToUpper(strings.TrimSuffix(entry.Name(), ".csv"))
+
+		records, err := readCSVFile(filepath.Join(p.dir, "splits", entry.Name()))
+		if err != nil {
+			return fmt.Errorf("split fixture %s: %w", entry.Name(), err)
+		}
+
+		splits := make([]Split, 0, len(records))
+		for i, record := range records {
+			if len(record) < 3 {
+				return fmt.Errorf("split fixture %s: row %d: need date, numerator and denominator", entry.Name(), i+2)
+			}
+			date, err := time.Parse("2006-01-02", record[0])
+			if err != nil {
+				return fmt.Errorf("split fixture %s: row %d: invalid date '%s'", entry.Name(), i+2, record[0])
+			}
+			numerator, err := money.Parse(record[1])
+			if err != nil || !numerator.IsPositive() {
+				return fmt.Errorf("split fixture %s: row %d: invalid numerator '%s'", entry.Name(), i+2, record[1])
+			}
+			denominator, err := money.Parse(record[2])
+			if err != nil || !denominator.IsPositive() {
+				return fmt.Errorf("split fixture %s: row %d: invalid denominator '%s'", entry.Name(), i+2, record[2])
+			}
+			splits = append(splits, Split{Date: date, Numerator: numerator, Denominator: denominator})
+		}
+		sort.Slice(splits, func(i, j int) bool { return splits[i].Date.Before(splits[j].Date) })
+		p.splits[symbol] = splits
+	}
+	return nil
+}
+
+// readCSVFile reads a CSV file and drops its header row
+func readCSVFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV %s: %w", filepath.Base(path), err)
+	}
+	if len(records) == 0 {
+		return records, nil
+	}
+	return records[1:], nil
+}
+
+// readBarsCSV reads a date,close CSV file
+func readBarsCSV(path string) ([]Bar, error) {
+	records, err := readCSVFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bars := make([]Bar, 0, len(records))
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("row %d: need date and close", i+2)
+		}
+		date, err := time.Parse("2006-01-02", record[0])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid date '%s'", i+2, record[0])
+		}
+		closePrice, err := money.Parse(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid close '%s'", i+2, record[1])
+		}
+		bars = append(bars, Bar{Date: date, Close: closePrice})
+	}
+	return bars, nil
+}
+
+// readBarsJSON reads a [{"date": ..., "close": ...}] JSON file
+func readBarsJSON(path string) ([]Bar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Date  string        `json:"date"`
+		Close money.Decimal `json:"close"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+
+	bars := make([]Bar, 0, len(rows))
+	for _, row := range rows {
+		date, err := time.Parse("2006-01-02", row.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date '%s'", row.Date)
+		}
+		bars = append(bars, Bar{Date: date, Close: row.Close})
+	}
+	return bars, nil
+}
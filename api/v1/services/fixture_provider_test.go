@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// fixtureDir is the repository's market data fixture directory
+const fixtureDir = "../../../fixtures/market_data"
+
+func TestFixtureProviderQuotes(t *testing.T) {
+	provider, err := NewFixtureProvider(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		symbol   string
+		price    string
+		currency string
+		wantErr  bool
+	}{
+		{symbol: "AAPL", price: "192.53", currency: "USD"},
+		{symbol: "btc-usd", price: "42265.19", currency: "USD"},
+		// Crypto pairs keep every decimal of the quote
+		{symbol: "ETH-BTC", price: "0.05397812", currency: "BTC"},
+		{symbol: "NOPE", wantErr: true},
+	}
+	for _, tt := range tests {
+		quote, err := provider.GetQuote(tt.symbol)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("GetQuote(%s) = %s, want an error", tt.symbol, quote.Price)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("GetQuote(%s) returned error %v", tt.symbol, err)
+			continue
+		}
+		if quote.Price.String() != tt.price || quote.Currency != tt.currency || quote.Provider != string(ProviderFixture) {
+			t.Errorf("GetQuote(%s) = %s %s from %s, want %s %s from fixture",
+				tt.symbol, quote.Price, quote.Currency, quote.Provider, tt.price, tt.currency)
+		}
+	}
+}
+
+func TestFixtureProviderHistory(t *testing.T) {
+	provider, err := NewFixtureProvider(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2023, 12, 29, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 12, 31, 18, 30, 0, 0, time.UTC)
+	bars, err := provider.GetHistory("BTC-USD", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"2023-12-29 42099.40", "2023-12-30 42156.90", "2023-12-31 42265.19"}
+	if len(bars) != len(want) {
+		t.Fatalf("GetHistory returned %d bars, want %d", len(bars), len(want))
+	}
+	for i, bar := range bars {
+		if got := bar.Date.Format("2006-01-02") + " " + bar.Close.String(); got != want[i] {
+			t.Errorf("bar %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
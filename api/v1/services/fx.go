@@ -0,0 +1,473 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+const (
+	DefaultBaseCurrency = "USD"
+
+	// fxCacheTTL is how long a rate in exchange_rates is considered fresh
+	fxCacheTTL = 12 * time.Hour
+
+	// fxRatePlaces matches the precision of the rate columns; inverse and
+	// cross rates are rounded to it
+	fxRatePlaces = 10
+)
+
+// fxOne is the decimal 1, the rate of a currency into itself
+var fxOne = money.NewFromInt(1)
+
+// invertRate returns the rate of the reverse pair
+func invertRate(rate money.Decimal) money.Decimal {
+	return fxOne.Div(rate, fxRatePlaces)
+}
+
+// FXRate is the exchange rate of a currency pair on one day: one unit of
+// Base buys Rate units of Quote
+type FXRate struct {
+	Base  string        `json:"base"`
+	Quote string        `json:"quote"`
+	Date  time.Time     `json:"date"`
+	Rate  money.Decimal `json:"rate"`
+}
+
+// FXRateProvider supplies exchange rates between two currencies
+type FXRateProvider interface {
+	// Name returns the provider name (e.g. "frankfurter")
+	Name() string
+	// GetRate returns how many units of quote one unit of base buys
+	GetRate(base, quote string) (money.Decimal, error)
+	// GetHistory returns daily rates between from and to (inclusive), oldest first
+	GetHistory(base, quote string, from, to time.Time) ([]FXRate, error)
+}
+
+// FXService converts amounts between currencies using provider rates cached
+// in the exchange_rates table
+type FXService struct {
+	db           *sql.DB
+	provider     FXRateProvider
+	baseCurrency string
+}
+
+// NewFXService creates a new FX service. The provider is selected with
+// FX_PROVIDER ("frankfurter" or "fixture") and the reporting currency with
+// BASE_CURRENCY.
+func NewFXService(db *sql.DB) *FXService {
+	base := NormalizeCurrency(os.Getenv("BASE_CURRENCY"))
+	if base == "" {
+		base = DefaultBaseCurrency
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	var provider FXRateProvider
+	switch strings.ToLower(os.Getenv("FX_PROVIDER")) {
+	case "fixture":
+		dir := os.Getenv("FX_FIXTURE_DIR")
+		if dir == "" {
+			dir = "fixtures/fx"
+		}
+		provider = NewFixtureFXProvider(dir)
+	default:
+		provider = NewFrankfurterFXProvider(httpClient)
+	}
+
+	return &FXService{
+		db:           db,
+		provider:     provider,
+		baseCurrency: base,
+	}
+}
+
+// BaseCurrency returns the configured reporting currency
+func (s *FXService) BaseCurrency() string {
+	return s.baseCurrency
+}
+
+// NormalizeCurrency upper-cases a currency code, returning "" if it is not a
+// three-letter ISO 4217 style code
+func NormalizeCurrency(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return ""
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+	}
+	return code
+}
+
+// GetRate returns how many units of quote one unit of base buys. Rates are
+// cached in the database; if the provider fails an expired rate is used.
+func (s *FXService) GetRate(base, quote string) (money.Decimal, error) {
+	base, quote = NormalizeCurrency(base), NormalizeCurrency(quote)
+	if base == "" || quote == "" {
+		return money.Zero, fmt.Errorf("invalid currency pair")
+	}
+	if base == quote {
+		return fxOne, nil
+	}
+
+	var rate money.Decimal
+	var lastUpdated time.Time
+	query := `SELECT rate, last_updated FROM exchange_rates WHERE base = $1 AND quote = $2`
+	err := s.db.QueryRow(query, base, quote).Scan(&rate, &lastUpdated)
+	cached := err == nil
+	if cached && time.Since(lastUpdated) < fxCacheTTL {
+		return rate, nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("[FX] DB cache check error for %s/%s: %v", base, quote, err)
+	}
+
+	fresh, err := s.provider.GetRate(base, quote)
+	if err != nil {
+		if cached {
+			log.Printf("[FX] %s failed for %s/%s: %v - using rate from %v", s.provider.Name(), base, quote, err, lastUpdated)
+			return rate, nil
+		}
+		return money.Zero, fmt.Errorf("failed to fetch %s/%s rate: %w", base, quote, err)
+	}
+
+	upsertQuery := `
+		INSERT INTO exchange_rates (base, quote, rate, provider, last_updated)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (base, quote)
+		DO UPDATE SET rate = $3, provider = $4, last_updated = $5
+	`
+	if _, err := s.db.Exec(upsertQuery, base, quote, fresh, s.provider.Name(), time.Now()); err != nil {
+		// Don't fail the request if caching fails
+		log.Printf("[FX] Failed to cache %s/%s rate: %v", base, quote, err)
+	}
+
+	return fresh, nil
+}
+
+// Converter converts amounts in many currencies into one target currency,
+// fetching each rate once and remembering the rates it used
+type Converter struct {
+	fx     *FXService
+	target string
+
+	mu    sync.Mutex
+	rates map[string]money.Decimal
+	// historical rates keyed by currency and date
+	ratesOn map[string]money.Decimal
+}
+
+// NewConverter creates a converter into target (the base currency if empty)
+func (s *FXService) NewConverter(target string) *Converter {
+	target = NormalizeCurrency(target)
+	if target == "" {
+		target = s.baseCurrency
+	}
+	return &Converter{
+		fx:      s,
+		target:  target,
+		rates:   make(map[string]money.Decimal),
+		ratesOn: make(map[string]money.Decimal),
+	}
+}
+
+// Target returns the currency amounts are converted into
+func (c *Converter) Target() string {
+	return c.target
+}
+
+// Convert converts amount from currency into the target currency at the
+// latest rate, rounded to the target currency's minor unit. An empty
+// currency is treated as USD, the default of assets and debts.
+func (c *Converter) Convert(amount money.Decimal, currency string) (money.Decimal, error) {
+	rate, err := c.Rate(currency)
+	if err != nil {
+		return money.Zero, err
+	}
+	return c.apply(amount, rate), nil
+}
+
+// ConvertOn converts amount from currency into the target currency at the
+// rate of the given date
+func (c *Converter) ConvertOn(amount money.Decimal, currency string, date time.Time) (money.Decimal, error) {
+	rate, err := c.RateOn(currency, date)
+	if err != nil {
+		return money.Zero, err
+	}
+	return c.apply(amount, rate), nil
+}
+
+// apply converts amount at rate and rounds to the target currency's minor unit
+func (c *Converter) apply(amount money.Decimal, rate money.Decimal) money.Decimal {
+	return money.RoundTo(amount.Mul(rate), c.target)
+}
+
+// Rate returns the latest rate from currency into the target currency
+func (c *Converter) Rate(currency string) (money.Decimal, error) {
+	currency = converterCurrency(currency)
+	if currency == c.target {
+		return fxOne, nil
+	}
+
+	c.mu.Lock()
+	rate, ok := c.rates[currency]
+	c.mu.Unlock()
+	if ok {
+		return rate, nil
+	}
+
+	rate, err := c.fx.GetRate(currency, c.target)
+	if err != nil {
+		return money.Zero, err
+	}
+	c.mu.Lock()
+	c.rates[currency] = rate
+	c.mu.Unlock()
+	return rate, nil
+}
+
+// RateOn returns the rate from currency into the target currency on the
+// given date. If no rate can be found for that date the latest rate is
+// used, so the holding shows no FX gain rather than failing the request.
+func (c *Converter) RateOn(currency string, date time.Time) (money.Decimal, error) {
+	currency = converterCurrency(currency)
+	if currency == c.target {
+		return fxOne, nil
+	}
+
+	key := currency + "@" + date.Format("2006-01-02")
+	c.mu.Lock()
+	rate, ok := c.ratesOn[key]
+	c.mu.Unlock()
+	if ok {
+		return rate, nil
+	}
+
+	rate, err := c.fx.GetRateOn(currency, c.target, date)
+	if err != nil {
+		log.Printf("[FX] No %s/%s rate for %s (%v) - using latest rate", currency, c.target, date.Format("2006-01-02"), err)
+		if rate, err = c.Rate(currency); err != nil {
+			return money.Zero, err
+		}
+	}
+	c.mu.Lock()
+	c.ratesOn[key] = rate
+	c.mu.Unlock()
+	return rate, nil
+}
+
+// Gain converts the change of a holding from a baseline amount on a past
+// date to its current amount, both in the holding's currency, and splits
+// it into the part caused by the price move (valued at today's rate) and
+// the part caused by the exchange rate move since the baseline date. The
+// FX part is the remainder, so both parts add up exactly to the change in
+// converted value.
+func (c *Converter) Gain(currency string, baseline money.Decimal, baselineDate time.Time, current money.Decimal) (priceGain, fxGain money.Decimal, err error) {
+	rate, err := c.Rate(currency)
+	if err != nil {
+		return money.Zero, money.Zero, err
+	}
+	baselineRate, err := c.RateOn(currency, baselineDate)
+	if err != nil {
+		return money.Zero, money.Zero, err
+	}
+
+	change := c.apply(current, rate).Sub(c.apply(baseline, baselineRate))
+	priceGain = c.apply(current.Sub(baseline), rate)
+	return priceGain, change.Sub(priceGain), nil
+}
+
+// Rates returns the latest rates used so far, keyed by source currency: one
+// unit of the key currency equals rate units of the target currency
+func (c *Converter) Rates() map[string]money.Decimal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rates := make(map[string]money.Decimal, len(c.rates))
+	for currency, rate := range c.rates {
+		rates[currency] = rate
+	}
+	return rates
+}
+
+// converterCurrency normalizes a holding's currency, treating empty as USD
+func converterCurrency(currency string) string {
+	if currency = NormalizeCurrency(currency); currency == "" {
+		return "USD"
+	}
+	return currency
+}
+
+// FrankfurterFXProvider fetches European Central Bank reference rates from
+// the Frankfurter API (FREE, no API key)
+type FrankfurterFXProvider struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewFrankfurterFXProvider creates a new Frankfurter provider
+func NewFrankfurterFXProvider(httpClient *http.Client) *FrankfurterFXProvider {
+	return &FrankfurterFXProvider{
+		httpClient: httpClient,
+		baseURL:    "https://api.frankfurter.app",
+	}
+}
+
+// Name returns the provider name
+func (p *FrankfurterFXProvider) Name() string {
+	return "frankfurter"
+}
+
+// GetRate returns the latest reference rate
+func (p *FrankfurterFXProvider) GetRate(base, quote string) (money.Decimal, error) {
+	endpoint := fmt.Sprintf("%s/latest?%s", p.baseURL, url.Values{"from": {base}, "to": {quote}}.Encode())
+
+	var result struct {
+		Rates map[string]money.Decimal `json:"rates"`
+	}
+	if err := fetchJSON(p.httpClient, endpoint, &result); err != nil {
+		return money.Zero, err
+	}
+
+	rate, ok := result.Rates[quote]
+	if !ok {
+		return money.Zero, fmt.Errorf("invalid response format: missing %s rate", quote)
+	}
+	return rate, nil
+}
+
+// GetHistory returns the reference rates published between from and to
+func (p *FrankfurterFXProvider) GetHistory(base, quote string, from, to time.Time) ([]FXRate, error) {
+	endpoint := fmt.Sprintf("%s/%s..%s?%s", p.baseURL,
+		from.Format("2006-01-02"), to.Format("2006-01-02"),
+		url.Values{"from": {base}, "to": {quote}}.Encode())
+
+	var result struct {
+		Rates map[string]map[string]money.Decimal `json:"rates"`
+	}
+	if err := fetchJSON(p.httpClient, endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	rates := make([]FXRate, 0, len(result.Rates))
+	for day, values := range result.Rates {
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			continue
+		}
+		if rate, ok := values[quote]; ok {
+			rates = append(rates, FXRate{Base: base, Quote: quote, Date: date, Rate: rate})
+		}
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Date.Before(rates[j].Date) })
+	return rates, nil
+}
+
+// FixtureFXProvider serves rates from a local rates.csv file
+// (date,base,quote,rate) for offline development and tests
+type FixtureFXProvider struct {
+	path string
+
+	once    sync.Once
+	history map[string][]FXRate
+	err     error
+}
+
+// NewFixtureFXProvider creates a fixture provider reading dir/rates.csv
+func NewFixtureFXProvider(dir string) *FixtureFXProvider {
+	return &FixtureFXProvider{path: filepath.Join(dir, "rates.csv")}
+}
+
+// Name returns the provider name
+func (p *FixtureFXProvider) Name() string {
+	return "fixture"
+}
+
+// GetRate returns the latest fixture rate for the pair, or the inverse of the reverse pair
+func (p *FixtureFXProvider) GetRate(base, quote string) (money.Decimal, error) {
+	rates, err := p.pairHistory(base, quote)
+	if err != nil {
+		return money.Zero, err
+	}
+	return rates[len(rates)-1].Rate, nil
+}
+
+// GetHistory returns the fixture rates between from and to
+func (p *FixtureFXProvider) GetHistory(base, quote string, from, to time.Time) ([]FXRate, error) {
+	rates, err := p.pairHistory(base, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	fromDate, toDate := truncateToDate(from), truncateToDate(to)
+	result := []FXRate{}
+	for _, rate := range rates {
+		if rate.Date.Before(fromDate) || rate.Date.After(toDate) {
+			continue
+		}
+		result = append(result, rate)
+	}
+	return result, nil
+}
+
+// pairHistory returns every fixture rate of a pair, oldest first, deriving
+// them from the reverse pair when only that one is listed
+func (p *FixtureFXProvider) pairHistory(base, quote string) ([]FXRate, error) {
+	p.once.Do(p.load)
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if rates := p.history[base+"/"+quote]; len(rates) > 0 {
+		return rates, nil
+	}
+	if reverse := p.history[quote+"/"+base]; len(reverse) > 0 {
+		rates := make([]FXRate, 0, len(reverse))
+		for _, rate := range reverse {
+			if rate.Rate.IsPositive() {
+				rates = append(rates, FXRate{Base: base, Quote: quote, Date: rate.Date, Rate: invertRate(rate.Rate)})
+			}
+		}
+		if len(rates) > 0 {
+			return rates, nil
+		}
+	}
+	return nil, fmt.Errorf("no fixture rate for %s/%s", base, quote)
+}
+
+// load reads the fixture file
+func (p *FixtureFXProvider) load() {
+	records, err := readCSVFile(p.path)
+	if err != nil {
+		p.err = err
+		return
+	}
+
+	rates, err := parseLongFXRecords(records)
+	if err != nil {
+		p.err = fmt.Errorf("rates.csv %w", err)
+		return
+	}
+
+	p.history = make(map[string][]FXRate)
+	for _, rate := range rates {
+		pair := rate.Base + "/" + rate.Quote
+		p.history[pair] = append(p.history[pair], rate)
+	}
+	for _, rates := range p.history {
+		sort.Slice(rates, func(i, j int) bool { return rates[i].Date.Before(rates[j].Date) })
+	}
+}
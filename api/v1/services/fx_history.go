@@ -0,0 +1,407 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+// FXBackfillJobName is the scheduler name of the daily FX history backfill job
+const FXBackfillJobName = "fx_backfill"
+
+// fxRateLookback is how far back a point-in-time lookup searches for the
+// last published rate (reference rates are not published on weekends and
+// bank holidays)
+const fxRateLookback = 7 * 24 * time.Hour
+
+// fxPivotCurrency is the currency ECB reference rates are quoted against.
+// Pairs that are not stored directly are crossed through it.
+const fxPivotCurrency = "EUR"
+
+// ErrRateUnavailable is returned for a past date without a stored rate;
+// rates are stored by the fx_backfill job, the backfill endpoint or an import
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// FXBackfillResult describes the rates imported for one currency pair
+type FXBackfillResult struct {
+	Base     string `json:"base"`
+	Quote    string `json:"quote"`
+	Provider string `json:"provider,omitempty"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Rates    int    `json:"rates"`
+	Error    string `json:"error,omitempty"`
+}
+
+// FXImportResult describes an imported rates CSV file
+type FXImportResult struct {
+	Format   string   `json:"format"`
+	Imported int      `json:"imported"`
+	Pairs    []string `json:"pairs"`
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+}
+
+// GetRateOn returns how many units of quote one unit of base bought on the
+// given date: the last rate published on or up to a week before that date.
+// Rates are read from fx_rates, crossed through EUR when only ECB-style
+// rates are stored; a lookup never fetches from the provider, and a pair
+// that is not stored returns ErrRateUnavailable. Today and future dates use
+// the latest rate.
+func (s *FXService) GetRateOn(base, quote string, date time.Time) (money.Decimal, error) {
+	base, quote = NormalizeCurrency(base), NormalizeCurrency(quote)
+	if base == "" || quote == "" {
+		return money.Zero, fmt.Errorf("invalid currency pair")
+	}
+	if base == quote {
+		return fxOne, nil
+	}
+
+	date = truncateToDate(date)
+	if !date.Before(truncateToDate(time.Now())) {
+		return s.GetRate(base, quote)
+	}
+
+	if rate, ok, err := s.storedRateOn(base, quote, date); err != nil || ok {
+		return rate, err
+	}
+	if rate, ok, err := s.crossRateOn(base, quote, date); err != nil || ok {
+		return rate, err
+	}
+	return money.Zero, fmt.Errorf("%w: no %s/%s rate stored for the week to %s", ErrRateUnavailable, base, quote, date.Format("2006-01-02"))
+}
+
+// storedRateOn looks up the pair, or the inverse of the reverse pair, in fx_rates
+func (s *FXService) storedRateOn(base, quote string, date time.Time) (money.Decimal, bool, error) {
+	query := `
+		SELECT rate, inverse FROM (
+			SELECT rate, date, false AS inverse FROM fx_rates
+			WHERE base = $1 AND quote = $2 AND date BETWEEN $3 AND $4
+			UNION ALL
+			SELECT rate, date, true AS inverse FROM fx_rates
+			WHERE base = $2 AND quote = $1 AND date BETWEEN $3 AND $4
+		) candidates
+		ORDER BY date DESC, inverse
+		LIMIT 1
+	`
+	var rate money.Decimal
+	var inverse bool
+	err := s.db.QueryRow(query, base, quote,
+		date.Add(-fxRateLookback).Format("2006-01-02"), date.Format("2006-01-02"),
+	).Scan(&rate, &inverse)
+	if err == sql.ErrNoRows {
+		return money.Zero, false, nil
+	}
+	if err != nil {
+		return money.Zero, false, fmt.Errorf("failed to read %s/%s rate: %w", base, quote, err)
+	}
+	if inverse {
+		if !rate.IsPositive() {
+			return money.Zero, false, nil
+		}
+		rate = invertRate(rate)
+	}
+	return rate, true, nil
+}
+
+// crossRateOn derives the pair from stored rates of both currencies against EUR
+func (s *FXService) crossRateOn(base, quote string, date time.Time) (money.Decimal, bool, error) {
+	if base == fxPivotCurrency || quote == fxPivotCurrency {
+		return money.Zero, false, nil
+	}
+
+	baseRate, ok, err := s.storedRateOn(base, fxPivotCurrency, date)
+	if err != nil || !ok {
+		return money.Zero, false, err
+	}
+	quoteRate, ok, err := s.storedRateOn(fxPivotCurrency, quote, date)
+	if err != nil || !ok {
+		return money.Zero, false, err
+	}
+	return baseRate.Mul(quoteRate).Round(fxRatePlaces), true, nil
+}
+
+// ListRates returns the stored rates of a pair between from and to, oldest first
+func (s *FXService) ListRates(base, quote string, from, to time.Time) ([]FXRate, error) {
+	query := `
+		SELECT base, quote, date, rate
+		FROM fx_rates
+		WHERE base = $1 AND quote = $2 AND date BETWEEN $3 AND $4
+		ORDER BY date
+	`
+	rows, err := s.db.Query(query, NormalizeCurrency(base), NormalizeCurrency(quote),
+		from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []FXRate{}
+	for rows.Next() {
+		var rate FXRate
+		if err := rows.Scan(&rate.Base, &rate.Quote, &rate.Date, &rate.Rate); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// BackfillRates fetches the daily rates of a pair between from and to from
+// the provider and stores them in fx_rates
+func (s *FXService) BackfillRates(base, quote string, from, to time.Time) (*FXBackfillResult, error) {
+	base, quote = NormalizeCurrency(base), NormalizeCurrency(quote)
+	if base == "" || quote == "" || base == quote {
+		return nil, fmt.Errorf("invalid currency pair")
+	}
+
+	from, to = truncateToDate(from), truncateToDate(to)
+	result := &FXBackfillResult{
+		Base:     base,
+		Quote:    quote,
+		Provider: s.provider.Name(),
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+	}
+	if from.After(to) {
+		return result, nil
+	}
+
+	rates, err := s.provider.GetHistory(base, quote, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s/%s history from %s: %w", base, quote, s.provider.Name(), err)
+	}
+
+	result.Rates, err = s.storeRates(rates, s.provider.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[FX] %s/%s: %d rates from %s (%s to %s)", base, quote, result.Rates, s.provider.Name(), result.From, result.To)
+	return result, nil
+}
+
+// BackfillAllRates keeps fx_rates complete for every currency held in assets
+// or debts against the base currency, from the earliest purchase or start
+// date up to yesterday. Only the days after the last stored rate are fetched.
+func (s *FXService) BackfillAllRates() ([]FXBackfillResult, error) {
+	query := `
+		SELECT currency, MIN(since) FROM (
+			SELECT UPPER(currency) AS currency, purchase_date AS since FROM assets
+			UNION ALL
+			SELECT UPPER(currency), start_date FROM debts
+		) holdings
+		WHERE currency IS NOT NULL AND currency <> $1
+		GROUP BY currency
+		ORDER BY currency
+	`
+	rows, err := s.db.Query(query, s.baseCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch currencies: %w", err)
+	}
+
+	since := make(map[string]time.Time)
+	var currencies []string
+	for rows.Next() {
+		var currency string
+		var date time.Time
+		if err := rows.Scan(&currency, &date); err == nil && NormalizeCurrency(currency) != "" {
+			currencies = append(currencies, currency)
+			since[currency] = date
+		}
+	}
+	rows.Close()
+
+	results := []FXBackfillResult{}
+	failed := 0
+	to := truncateToDate(time.Now()).AddDate(0, 0, -1)
+	for _, currency := range currencies {
+		from, err := s.backfillStart(currency, s.baseCurrency, truncateToDate(since[currency]))
+		if err == nil {
+			var result *FXBackfillResult
+			result, err = s.BackfillRates(currency, s.baseCurrency, from, to)
+			if err == nil {
+				results = append(results, *result)
+				continue
+			}
+		}
+		failed++
+		results = append(results, FXBackfillResult{Base: currency, Quote: s.baseCurrency, Error: err.Error()})
+	}
+
+	if failed > 0 && failed == len(results) {
+		return results, fmt.Errorf("FX backfill failed for every currency")
+	}
+	return results, nil
+}
+
+// backfillStart decides where an incremental FX backfill should begin
+func (s *FXService) backfillStart(base, quote string, since time.Time) (time.Time, error) {
+	var earliest, latest sql.NullTime
+	query := `SELECT MIN(date), MAX(date) FROM fx_rates WHERE base = $1 AND quote = $2`
+	if err := s.db.QueryRow(query, base, quote).Scan(&earliest, &latest); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read stored rates: %w", err)
+	}
+
+	// Stored rates do not reach back far enough: fetch everything
+	if !earliest.Valid || earliest.Time.Sub(since) > fxRateLookback {
+		return since, nil
+	}
+	return truncateToDate(latest.Time).AddDate(0, 0, 1), nil
+}
+
+// storeRates upserts rates into fx_rates and returns how many were written
+func (s *FXService) storeRates(rates []FXRate, source string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO fx_rates (base, quote, date, rate, provider, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (base, quote, date) DO UPDATE SET rate = $4, provider = $5
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, rate := range rates {
+		if _, err := stmt.Exec(rate.Base, rate.Quote, rate.Date.Format("2006-01-02"), rate.Rate, source, now); err != nil {
+			return 0, fmt.Errorf("failed to store %s/%s rate for %s: %w", rate.Base, rate.Quote, rate.Date.Format("2006-01-02"), err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// ImportRatesCSV stores rates from a CSV file in one of two layouts:
+//
+//	ECB reference rates (eurofxref-hist.csv): Date,USD,JPY,...  one EUR-based column per currency
+//	long format:                             date,base,quote,rate
+//
+// Existing rates for the same pair and date are replaced.
+func (s *FXService) ImportRatesCSV(r io.Reader) (*FXImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+
+	header := records[0]
+	result := &FXImportResult{Pairs: []string{}}
+
+	var rates []FXRate
+	if len(header) >= 4 && strings.EqualFold(header[1], "base") && strings.EqualFold(header[2], "quote") {
+		result.Format = "long"
+		rates, err = parseLongFXRecords(records[1:])
+	} else {
+		result.Format = "ecb"
+		rates, err = parseECBRecords(header, records[1:])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Imported, err = s.storeRates(rates, "import")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, rate := range rates {
+		pair := rate.Base + "/" + rate.Quote
+		if !seen[pair] {
+			seen[pair] = true
+			result.Pairs = append(result.Pairs, pair)
+		}
+		day := rate.Date.Format("2006-01-02")
+		if result.From == "" || day < result.From {
+			result.From = day
+		}
+		if day > result.To {
+			result.To = day
+		}
+	}
+
+	log.Printf("[FX] Imported %d %s rates for %d pairs", result.Imported, result.Format, len(result.Pairs))
+	return result, nil
+}
+
+// parseLongFXRecords parses date,base,quote,rate rows (without the header)
+func parseLongFXRecords(records [][]string) ([]FXRate, error) {
+	rates := make([]FXRate, 0, len(records))
+	for i, record := range records {
+		if len(record) < 4 {
+			return nil, fmt.Errorf("row %d: need date, base, quote and rate", i+2)
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid date '%s'", i+2, record[0])
+		}
+		base, quote := NormalizeCurrency(record[1]), NormalizeCurrency(record[2])
+		if base == "" || quote == "" {
+			return nil, fmt.Errorf("row %d: invalid currency pair '%s/%s'", i+2, record[1], record[2])
+		}
+		rate, err := money.Parse(record[3])
+		if err != nil || !rate.IsPositive() {
+			return nil, fmt.Errorf("row %d: invalid rate '%s'", i+2, record[3])
+		}
+		rates = append(rates, FXRate{Base: base, Quote: quote, Date: date, Rate: rate})
+	}
+	return rates, nil
+}
+
+// parseECBRecords parses ECB reference rate rows: a date followed by the
+// value of one euro in each header currency. "N/A" and empty cells are skipped.
+func parseECBRecords(header []string, records [][]string) ([]FXRate, error) {
+	if len(header) < 2 || !strings.EqualFold(strings.TrimSpace(header[0]), "date") {
+		return nil, fmt.Errorf("unrecognised header: expected Date,<currency>,... or date,base,quote,rate")
+	}
+
+	currencies := make([]string, len(header))
+	for i, column := range header[1:] {
+		currencies[i+1] = NormalizeCurrency(column)
+	}
+
+	var rates []FXRate
+	for i, record := range records {
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid date '%s'", i+2, record[0])
+		}
+		for col := 1; col < len(record) && col < len(currencies); col++ {
+			value := strings.TrimSpace(record[col])
+			if currencies[col] == "" || value == "" || strings.EqualFold(value, "N/A") {
+				continue
+			}
+			rate, err := money.Parse(value)
+			if err != nil || !rate.IsPositive() {
+				return nil, fmt.Errorf("row %d: invalid %s rate '%s'", i+2, currencies[col], value)
+			}
+			rates = append(rates, FXRate{Base: fxPivotCurrency, Quote: currencies[col], Date: date, Rate: rate})
+		}
+	}
+	return rates, nil
+}
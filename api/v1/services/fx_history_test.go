@@ -0,0 +1,233 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+// fxTable answers fx_rates lookups from rates keyed by "BASE/QUOTE date"
+// the way Postgres does: the latest rate of the pair or its reverse in the
+// date window, the pair itself first on the same day
+func fxTable(fake *fakeDB, rates map[string]string) {
+	fake.on("FROM fx_rates WHERE base = $1 AND quote = $2 AND date BETWEEN", func(args []driver.Value) (fakeRows, error) {
+		base, quote := args[0].(string), args[1].(string)
+		from, to := args[2].(string), args[3].(string)
+
+		var best []driver.Value
+		bestDate := ""
+		for key, rate := range rates {
+			pair, date, _ := strings.Cut(key, " ")
+			if date < from || date > to || date < bestDate {
+				continue
+			}
+			switch pair {
+			case base + "/" + quote:
+				best, bestDate = []driver.Value{rate, false}, date
+			case quote + "/" + base:
+				if date > bestDate {
+					best, bestDate = []driver.Value{rate, true}, date
+				}
+			}
+		}
+		if best == nil {
+			return fakeRows{columns: []string{"rate", "inverse"}}, nil
+		}
+		return fakeRows{columns: []string{"rate", "inverse"}, values: [][]driver.Value{best}}, nil
+	})
+}
+
+func TestGetRateOn(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fxTable(fake, map[string]string{
+		"EUR/USD 2024-01-05": "1.0942",
+		"EUR/USD 2024-01-08": "1.0950",
+		"USD/JPY 2024-01-05": "144.50",
+		"EUR/GBP 2024-01-05": "0.8600",
+		"EUR/JPY 2024-01-05": "158.00",
+		"EUR/CHF 2023-12-01": "0.9500",
+	})
+	s := &FXService{db: db}
+
+	tests := []struct {
+		name        string
+		base, quote string
+		date        string
+		want        string
+		wantErr     error
+	}{
+		{name: "published that day", base: "EUR", quote: "USD", date: "2024-01-08", want: "1.0950"},
+		// Nothing is published at weekends: Friday's rate applies
+		{name: "weekend", base: "EUR", quote: "USD", date: "2024-01-07", want: "1.0942"},
+		{name: "inverse pair", base: "JPY", quote: "USD", date: "2024-01-06", want: "0.0069204152"},
+		// The euro a pound buys (1 / 0.86 to ten places) times 158.00 yen
+		{name: "crossed through EUR", base: "GBP", quote: "JPY", date: "2024-01-05", want: "183.7209302366"},
+		{name: "same currency", base: "USD", quote: "usd", date: "2024-01-05", want: "1"},
+		{name: "older than a week", base: "EUR", quote: "CHF", date: "2024-01-05", wantErr: ErrRateUnavailable},
+		{name: "never stored", base: "EUR", quote: "SEK", date: "2024-01-05", wantErr: ErrRateUnavailable},
+	}
+	for _, tt := range tests {
+		got, err := s.GetRateOn(tt.base, tt.quote, mustDate(tt.date))
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got %s, %v; want error %v", tt.name, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s: GetRateOn(%s, %s, %s) = %s, %v; want %s", tt.name, tt.base, tt.quote, tt.date, got, err, tt.want)
+		}
+	}
+
+	// The lookup window is the week up to the date
+	lookups := fake.executed("FROM fx_rates WHERE base = $1")
+	if first := lookups[0]; first.args[2] != "2024-01-01" || first.args[3] != "2024-01-08" {
+		t.Errorf("first lookup searched %v to %v, want 2024-01-01 to 2024-01-08", first.args[2], first.args[3])
+	}
+}
+
+func TestImportRatesCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		csv       string
+		wantErr   string
+		imported  int
+		pairs     string
+		from, to  string
+		wantFirst []driver.Value
+	}{
+		{
+			name:     "long format",
+			csv:      "date,base,quote,rate\n2024-01-03,eur,usd,1.0919\n2024-01-02,USD,JPY,141.80\n",
+			imported: 2, pairs: "EUR/USD,USD/JPY", from: "2024-01-02", to: "2024-01-03",
+			wantFirst: []driver.Value{"EUR", "USD", "2024-01-03", "1.0919", "import"},
+		},
+		{
+			name:     "ECB layout",
+			csv:      "Date,USD,JPY,\n2024-01-03,1.0919,155.52,\n2024-01-02,1.0956,N/A,\n",
+			imported: 3, pairs: "EUR/USD,EUR/JPY", from: "2024-01-02", to: "2024-01-03",
+			wantFirst: []driver.Value{"EUR", "USD", "2024-01-03", "1.0919", "import"},
+		},
+		{name: "empty", csv: "date,base,quote,rate\n", wantErr: "empty"},
+		{name: "unknown header", csv: "day,price\n2024-01-03,1.09\n", wantErr: "unrecognised header"},
+		{name: "bad date", csv: "date,base,quote,rate\n03/01/2024,EUR,USD,1.09\n", wantErr: "row 2: invalid date"},
+		{name: "bad pair", csv: "date,base,quote,rate\n2024-01-03,EURO,USD,1.09\n", wantErr: "row 2: invalid currency pair"},
+		{name: "negative rate", csv: "date,base,quote,rate\n2024-01-03,EUR,USD,1.09\n2024-01-04,EUR,USD,-1.09\n", wantErr: "row 3: invalid rate"},
+		{name: "short row", csv: "date,base,quote,rate\n2024-01-03,EUR,USD\n", wantErr: "row 2: need date, base, quote and rate"},
+	}
+	for _, tt := range tests {
+		db, fake := newFakeDB(t)
+		fake.on("INSERT INTO fx_rates", func([]driver.Value) (fakeRows, error) { return fakeRows{affected: 1}, nil })
+		s := &FXService{db: db}
+
+		result, err := s.ImportRatesCSV(strings.NewReader(tt.csv))
+		stored := fake.executed("INSERT INTO fx_rates")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.wantErr)
+			}
+			// A file with an invalid row imports nothing
+			if len(stored) > 0 {
+				t.Errorf("%s: stored %d rates from an invalid file", tt.name, len(stored))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+
+		if result.Imported != tt.imported || strings.Join(result.Pairs, ",") != tt.pairs || result.From != tt.from || result.To != tt.to {
+			t.Errorf("%s: got %+v, want %d rates of %s from %s to %s", tt.name, result, tt.imported, tt.pairs, tt.from, tt.to)
+		}
+		if len(stored) != tt.imported {
+			t.Errorf("%s: stored %d rates, want %d", tt.name, len(stored), tt.imported)
+			continue
+		}
+		for i, want := range tt.wantFirst {
+			if stored[0].args[i] != want {
+				t.Errorf("%s: first rate stored as %v, want %v", tt.name, stored[0].args[:5], tt.wantFirst)
+				break
+			}
+		}
+	}
+}
+
+// recordingFXProvider returns a rate of 1.1 for every day asked for and
+// records the ranges requested
+type recordingFXProvider struct {
+	requests []string
+}
+
+func (p *recordingFXProvider) Name() string { return "recording" }
+
+func (p *recordingFXProvider) GetRate(base, quote string) (money.Decimal, error) {
+	return money.MustParse("1.1"), nil
+}
+
+func (p *recordingFXProvider) GetHistory(base, quote string, from, to time.Time) ([]FXRate, error) {
+	p.requests = append(p.requests, base+"/"+quote+" "+from.Format("2006-01-02")+" "+to.Format("2006-01-02"))
+	var rates []FXRate
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		rates = append(rates, FXRate{Base: base, Quote: quote, Date: day, Rate: money.MustParse("1.1")})
+	}
+	return rates, nil
+}
+
+func TestBackfillAllRates(t *testing.T) {
+	yesterday := truncateToDate(time.Now()).AddDate(0, 0, -1)
+	db, fake := newFakeDB(t)
+	fake.returns("FROM assets", []string{"currency", "since"},
+		[]driver.Value{"CHF", yesterday.AddDate(0, 0, -20)},
+		[]driver.Value{"EUR", yesterday.AddDate(0, 0, -30)},
+		[]driver.Value{"GBP", yesterday.AddDate(0, 0, -10)},
+	)
+	stored := map[string][]driver.Value{
+		// Stored up to five days ago: only the days since are fetched
+		"EUR": {yesterday.AddDate(0, 0, -31), yesterday.AddDate(0, 0, -5)},
+		// Stored only from two weeks after the holding began: fetched again in full
+		"CHF": {yesterday.AddDate(0, 0, -5), yesterday.AddDate(0, 0, -1)},
+		// Nothing stored yet
+		"GBP": {nil, nil},
+	}
+	fake.on("SELECT MIN(date), MAX(date) FROM fx_rates", func(args []driver.Value) (fakeRows, error) {
+		return fakeRows{columns: []string{"min", "max"}, values: [][]driver.Value{stored[args[0].(string)]}}, nil
+	})
+	fake.on("INSERT INTO fx_rates", func([]driver.Value) (fakeRows, error) { return fakeRows{affected: 1}, nil })
+
+	provider := &recordingFXProvider{}
+	s := &FXService{db: db, provider: provider, baseCurrency: "USD"}
+	results, err := s.BackfillAllRates()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	day := func(daysBeforeYesterday int) string {
+		return yesterday.AddDate(0, 0, -daysBeforeYesterday).Format("2006-01-02")
+	}
+	want := []string{
+		"CHF/USD " + day(20) + " " + day(0),
+		"EUR/USD " + day(4) + " " + day(0),
+		"GBP/USD " + day(10) + " " + day(0),
+	}
+	if strings.Join(provider.requests, "; ") != strings.Join(want, "; ") {
+		t.Errorf("fetched %v, want %v", provider.requests, want)
+	}
+
+	wantRates := []int{21, 5, 11}
+	for i, result := range results {
+		if result.Error != "" || result.Rates != wantRates[i] {
+			t.Errorf("%s: stored %d rates (%s), want %d", result.Base, result.Rates, result.Error, wantRates[i])
+		}
+	}
+	if inserts := fake.executed("INSERT INTO fx_rates"); len(inserts) != 37 {
+		t.Errorf("stored %d rates, want 37", len(inserts))
+	}
+	if queries := fake.executed("FROM assets"); len(queries) != 1 || queries[0].args[0] != "USD" {
+		t.Errorf("currencies queried %v, want every currency but USD", queries)
+	}
+}
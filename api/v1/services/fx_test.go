@@ -0,0 +1,108 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+func TestFixtureFXProviderRates(t *testing.T) {
+	dir := t.TempDir()
+	fixture := "date,base,quote,rate\n" +
+		"2024-01-02,EUR,USD,1.0956\n" +
+		"2024-01-03,EUR,USD,1.0919\n" +
+		"2024-01-03,USD,JPY,143.2500000000\n"
+	if err := os.WriteFile(filepath.Join(dir, "rates.csv"), []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	provider := NewFixtureFXProvider(dir)
+
+	tests := []struct {
+		base, quote string
+		want        string
+		wantErr     bool
+	}{
+		{base: "EUR", quote: "USD", want: "1.0919"},
+		// The reverse pair is inverted to the precision of the rate columns
+		{base: "USD", quote: "EUR", want: "0.9158347834"},
+		{base: "JPY", quote: "USD", want: "0.0069808028"},
+		{base: "GBP", quote: "USD", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := provider.GetRate(tt.base, tt.quote)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("GetRate(%s, %s) = %s, want an error", tt.base, tt.quote, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("GetRate(%s, %s) returned error %v", tt.base, tt.quote, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("GetRate(%s, %s) = %s, want %s", tt.base, tt.quote, got, tt.want)
+		}
+	}
+
+	history, err := provider.GetHistory("EUR", "USD", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Rate.String() != "1.0919" {
+		t.Errorf("GetHistory from 2024-01-03 = %v, want the single 1.0919 rate", history)
+	}
+}
+
+func TestParseECBRecords(t *testing.T) {
+	header := []string{"Date", "USD", "JPY", "CYP"}
+	records := [][]string{
+		{"2024-01-03", "1.0919", "155.52", "N/A"},
+		{"2024-01-02", "1.0956", "", "N/A"},
+	}
+
+	rates, err := parseECBRecords(header, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"EUR/USD 2024-01-03 1.0919", "EUR/JPY 2024-01-03 155.52", "EUR/USD 2024-01-02 1.0956"}
+	if len(rates) != len(want) {
+		t.Fatalf("parsed %d rates, want %d", len(rates), len(want))
+	}
+	for i, rate := range rates {
+		got := rate.Base + "/" + rate.Quote + " " + rate.Date.Format("2006-01-02") + " " + rate.Rate.String()
+		if got != want[i] {
+			t.Errorf("rate %d = %s, want %s", i, got, want[i])
+		}
+	}
+
+	if _, err := parseECBRecords(header, [][]string{{"2024-01-03", "1,09"}}); err == nil {
+		t.Error("parseECBRecords accepted a rate with a decimal comma")
+	}
+}
+
+func TestConverterApply(t *testing.T) {
+	tests := []struct {
+		target string
+		amount string
+		rate   string
+		want   string
+	}{
+		{target: "USD", amount: "1000.00", rate: "1.0919", want: "1091.90"},
+		// Exactly half a cent rounds away from zero
+		{target: "USD", amount: "0.05", rate: "0.1", want: "0.01"},
+		{target: "USD", amount: "123456789.12", rate: "0.9158347834", want: "113066021.72"},
+		{target: "JPY", amount: "250.00", rate: "155.52", want: "38880"},
+	}
+	for _, tt := range tests {
+		c := &Converter{target: tt.target}
+		got := c.apply(money.MustParse(tt.amount), money.MustParse(tt.rate))
+		if got.String() != tt.want {
+			t.Errorf("apply(%s, %s) into %s = %s, want %s", tt.amount, tt.rate, tt.target, got, tt.want)
+		}
+	}
+}
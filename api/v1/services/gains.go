@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"personal-finance/api/v1/models"
+)
+
+// RealizedGains replays every asset's ledger and returns the disposals sold
+// in the given year, converted into the converter's currency: proceeds at
+// the rate of the sale date and cost basis at the rate of the acquisition
+// date, as tax authorities expect.
+func (s *LedgerService) RealizedGains(year int, converter *Converter) (*models.GainsReport, error) {
+	rows, err := s.db.Query(`SELECT id, name, COALESCE(currency, ''), COALESCE(cost_basis_method, '') FROM assets ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch assets: %w", err)
+	}
+
+	type reportAsset struct {
+		id, name string
+		ledger   ledgerAsset
+	}
+	assets := []reportAsset{}
+	for rows.Next() {
+		var asset reportAsset
+		if err := rows.Scan(&asset.id, &asset.name, &asset.ledger.currency, &asset.ledger.method); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse assets: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	rows.Close()
+
+	report := &models.GainsReport{Year: year, Currency: converter.Target(), Disposals: []models.Disposal{}}
+	for _, asset := range assets {
+		transactions, err := loadTransactions(s.db, asset.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch transactions for %s: %w", asset.name, err)
+		}
+		position, err := BuildPosition(transactions, asset.ledger.currency, asset.ledger.method)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay ledger for %s: %w", asset.name, err)
+		}
+
+		for _, disposal := range position.Disposals {
+			if disposal.DateSold.Year() != year {
+				continue
+			}
+			disposal.AssetID = asset.id
+			disposal.Description = disposal.Quantity.String() + " " + asset.name
+			converted, err := convertDisposal(disposal, converter)
+			if err != nil {
+				return nil, err
+			}
+			report.Disposals = append(report.Disposals, converted)
+		}
+	}
+
+	sort.SliceStable(report.Disposals, func(i, j int) bool {
+		return report.Disposals[i].DateSold.Before(report.Disposals[j].DateSold)
+	})
+	for _, disposal := range report.Disposals {
+		report.Proceeds = report.Proceeds.Add(disposal.Proceeds)
+		report.CostBasis = report.CostBasis.Add(disposal.CostBasis)
+		if disposal.Term == models.GainTermLong {
+			report.LongTerm = report.LongTerm.Add(disposal.Gain)
+		} else {
+			report.ShortTerm = report.ShortTerm.Add(disposal.Gain)
+		}
+	}
+	report.Total = report.ShortTerm.Add(report.LongTerm)
+	return report, nil
+}
+
+// convertDisposal converts a disposal's proceeds at the sale date rate and
+// its cost basis at the acquisition date rate, recomputing the gain
+func convertDisposal(disposal models.Disposal, converter *Converter) (models.Disposal, error) {
+	if disposal.Currency == converter.Target() {
+		return disposal, nil
+	}
+
+	proceeds, err := converter.ConvertOn(disposal.Proceeds, disposal.Currency, disposal.DateSold)
+	if err != nil {
+		return models.Disposal{}, err
+	}
+	cost, err := converter.ConvertOn(disposal.CostBasis, disposal.Currency, disposal.DateAcquired)
+	if err != nil {
+		return models.Disposal{}, err
+	}
+
+	disposal.OriginalCurrency = disposal.Currency
+	disposal.Currency = converter.Target()
+	disposal.Proceeds = proceeds
+	disposal.CostBasis = cost
+	disposal.Gain = proceeds.Sub(cost)
+	return disposal, nil
+}
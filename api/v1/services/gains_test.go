@@ -0,0 +1,140 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func TestHoldingTerm(t *testing.T) {
+	lot := models.Lot{Date: mustDate("2023-03-15")}
+	tests := []struct {
+		sold string
+		want models.GainTerm
+	}{
+		{sold: "2023-09-15", want: models.GainTermShort},
+		// Long-term means held more than one year: the anniversary is still short
+		{sold: "2024-03-15", want: models.GainTermShort},
+		{sold: "2024-03-16", want: models.GainTermLong},
+	}
+	for _, tt := range tests {
+		if got := holdingTerm(lot, models.Transaction{Date: mustDate(tt.sold)}); got != tt.want {
+			t.Errorf("lot bought 2023-03-15 sold %s: term %s, want %s", tt.sold, got, tt.want)
+		}
+	}
+}
+
+func TestConvertDisposal(t *testing.T) {
+	converter := &Converter{
+		target: "USD",
+		ratesOn: map[string]money.Decimal{
+			"EUR@2024-06-03": money.MustParse("1.08"),
+			"EUR@2022-01-10": money.MustParse("1.13"),
+		},
+	}
+	disposal := models.Disposal{
+		DateAcquired: mustDate("2022-01-10"),
+		DateSold:     mustDate("2024-06-03"),
+		Proceeds:     money.MustParse("1200.00"),
+		CostBasis:    money.MustParse("1000.00"),
+		Gain:         money.MustParse("200.00"),
+		Currency:     "EUR",
+	}
+
+	// Proceeds at the sale date's rate, cost at the acquisition date's rate
+	got, err := convertDisposal(disposal, converter)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got.Proceeds.String() != "1296.00" || got.CostBasis.String() != "1130.00" || got.Gain.String() != "166.00" {
+		t.Errorf("proceeds, cost and gain = %s, %s, %s; want 1296.00, 1130.00, 166.00", got.Proceeds, got.CostBasis, got.Gain)
+	}
+	if got.Currency != "USD" || got.OriginalCurrency != "EUR" {
+		t.Errorf("currency %s from %s, want USD from EUR", got.Currency, got.OriginalCurrency)
+	}
+
+	// A disposal already in the target currency is left alone
+	disposal.Currency = "USD"
+	if got, err := convertDisposal(disposal, converter); err != nil || got != disposal {
+		t.Errorf("USD disposal converted to %+v, %v; want it unchanged", got, err)
+	}
+}
+
+// ledgerRow returns a transactions row as loadTransactions reads it
+func ledgerRow(id string, kind models.TransactionType, on, quantity, price string) []driver.Value {
+	created := mustDate(on)
+	return []driver.Value{id, "", string(kind), mustDate(on), quantity, price, "0", "0", "0", "", "", created, created}
+}
+
+func TestRealizedGains(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.returns("FROM assets", []string{"id", "name", "currency", "cost_basis_method"},
+		[]driver.Value{"apple", "Apple", "USD", "fifo"},
+		[]driver.Value{"sap", "SAP", "EUR", "fifo"},
+	)
+	ledgers := map[string][][]driver.Value{
+		"apple": {
+			ledgerRow("b1", models.TransactionTypeBuy, "2023-03-15", "10", "100"),
+			// Sold the year before the report
+			ledgerRow("s0", models.TransactionTypeSell, "2023-06-01", "2", "110"),
+			ledgerRow("s1", models.TransactionTypeSell, "2024-03-15", "4", "150"),
+			ledgerRow("s2", models.TransactionTypeSell, "2024-03-16", "4", "150"),
+		},
+		"sap": {
+			ledgerRow("b2", models.TransactionTypeBuy, "2022-01-10", "10", "100"),
+			ledgerRow("s3", models.TransactionTypeSell, "2024-06-03", "10", "120"),
+		},
+	}
+	fake.on("FROM transactions WHERE asset_id", func(args []driver.Value) (fakeRows, error) {
+		return fakeRows{
+			columns: []string{"id", "asset_id", "type", "date", "quantity", "price", "amount", "fee", "ratio", "lot_id", "notes", "created_at", "updated_at"},
+			values:  ledgers[args[0].(string)],
+		}, nil
+	})
+
+	converter := &Converter{
+		target: "USD",
+		ratesOn: map[string]money.Decimal{
+			"EUR@2024-06-03": money.MustParse("1.08"),
+			"EUR@2022-01-10": money.MustParse("1.13"),
+		},
+	}
+	report, err := (&LedgerService{db: db}).RealizedGains(2024, converter)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	type disposal struct {
+		sale, description, proceeds, cost, gain string
+		term                                    models.GainTerm
+	}
+	want := []disposal{
+		{"s1", "4 Apple", "600.00", "400.00", "200.00", models.GainTermShort},
+		{"s2", "4 Apple", "600.00", "400.00", "200.00", models.GainTermLong},
+		{"s3", "10 SAP", "1296.00", "1130.00", "166.00", models.GainTermLong},
+	}
+	if len(report.Disposals) != len(want) {
+		t.Fatalf("got %d disposals, want %d", len(report.Disposals), len(want))
+	}
+	for i, w := range want {
+		d := report.Disposals[i]
+		got := disposal{d.SaleID, d.Description, d.Proceeds.String(), d.CostBasis.String(), d.Gain.String(), d.Term}
+		if got != w {
+			t.Errorf("disposal %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	got := []string{report.ShortTerm.String(), report.LongTerm.String(), report.Total.String(), report.Proceeds.String(), report.CostBasis.String()}
+	wantTotals := []string{"200.00", "366.00", "566.00", "2496.00", "1930.00"}
+	for i := range got {
+		if got[i] != wantTotals[i] {
+			t.Errorf("short, long, total, proceeds and cost = %v, want %v", got, wantTotals)
+			break
+		}
+	}
+	if report.Year != 2024 || report.Currency != "USD" {
+		t.Errorf("report for %d in %s, want 2024 in USD", report.Year, report.Currency)
+	}
+}
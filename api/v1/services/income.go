@@ -0,0 +1,342 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// DividendSyncJobName is the scheduler name of the provider dividend import job
+const DividendSyncJobName = "dividend_sync"
+
+// DividendSyncResult describes the dividends imported for one asset
+type DividendSyncResult struct {
+	AssetID   string `json:"asset_id"`
+	Symbol    string `json:"symbol"`
+	Provider  string `json:"provider,omitempty"`
+	Dividends int    `json:"dividends"`
+	Inserted  int    `json:"inserted"`
+	Error     string `json:"error,omitempty"`
+}
+
+// IncomeService imports dividends from market data providers and reports
+// dividend, interest and rent income recorded in the ledger
+type IncomeService struct {
+	db         *sql.DB
+	ledger     *LedgerService
+	marketData *MarketDataService
+}
+
+// NewIncomeService creates a new income service
+func NewIncomeService(db *sql.DB, ledger *LedgerService, marketData *MarketDataService) *IncomeService {
+	return &IncomeService{db: db, ledger: ledger, marketData: marketData}
+}
+
+// SyncDividends records the provider's dividend history for a market-priced
+// asset as dividend transactions. Each dividend pays its amount per share on
+// the units held the day before the ex-date; dates that already have a
+// dividend in the ledger are skipped, so manual entries win and reruns are
+// idempotent. Providers quote past dividends per share of today, so amounts
+// before a split are scaled back to the shares held at the time.
+func (s *IncomeService) SyncDividends(assetID string) (*DividendSyncResult, error) {
+	var assetType, symbol, source, provider, currency string
+	query := `SELECT type, COALESCE(symbol, ''), source, COALESCE(provider, ''), COALESCE(currency, '') FROM assets WHERE id = $1`
+	err := s.db.QueryRow(query, assetID).Scan(&assetType, &symbol, &source, &provider, &currency)
+	if err == sql.ErrNoRows {
+		return nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch asset: %w", err)
+	}
+	// Only stocks pay dividends; crypto is market-priced but has none to sync
+	if assetType != "stock" || !IsMarketPriced(assetType, source, symbol) {
+		return nil, ErrNotMarketPriced
+	}
+
+	transactions, err := s.ledger.ListTransactions(assetID)
+	if err != nil {
+		return nil, err
+	}
+	result := &DividendSyncResult{AssetID: assetID, Symbol: symbol}
+	if len(transactions) == 0 {
+		return result, nil
+	}
+
+	paid := make(map[string]bool)
+	for _, t := range transactions {
+		if t.Type == models.TransactionTypeDividend {
+			paid[t.Date.Format("2006-01-02")] = true
+		}
+	}
+
+	dividends, providerName, err := s.marketData.GetDividends(provider, symbol, transactions[0].Date, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result.Provider = providerName
+	result.Dividends = len(dividends)
+
+	for _, dividend := range dividends {
+		if paid[dividend.Date.Format("2006-01-02")] {
+			continue
+		}
+
+		held, err := quantityBefore(transactions, dividend.Date, currency)
+		if err != nil {
+			return nil, err
+		}
+		perShare := dividend.Amount.Mul(splitFactorAfter(transactions, dividend.Date))
+		amount := money.RoundTo(perShare.Mul(held), currency)
+		if !amount.IsPositive() {
+			continue
+		}
+
+		err = s.ledger.CreateTransaction(assetID, &models.Transaction{
+			Type:   models.TransactionTypeDividend,
+			Date:   dividend.Date,
+			Amount: amount,
+			Notes:  fmt.Sprintf("%s per share on %s units, from %s", perShare, held, providerName),
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Inserted++
+	}
+
+	log.Printf("[Income] %s (%s): %d dividends from %s, %d new", symbol, assetID, result.Dividends, providerName, result.Inserted)
+	return result, nil
+}
+
+// SyncAllDividends imports dividends for every market-priced asset. Failures
+// are reported per asset and do not stop the run.
+func (s *IncomeService) SyncAllDividends() ([]DividendSyncResult, error) {
+	rows, err := s.db.Query(`SELECT id FROM assets WHERE type = 'stock' AND source = 'market_api' ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch assets: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	results := []DividendSyncResult{}
+	failed := 0
+	for _, id := range ids {
+		result, err := s.SyncDividends(id)
+		if errors.Is(err, ErrNotMarketPriced) {
+			continue
+		}
+		if err != nil {
+			failed++
+			results = append(results, DividendSyncResult{AssetID: id, Error: err.Error()})
+			continue
+		}
+		results = append(results, *result)
+	}
+
+	if failed > 0 && failed == len(results) {
+		return results, fmt.Errorf("dividend sync failed for every asset")
+	}
+	return results, nil
+}
+
+// quantityBefore returns the units held at the end of the day before date
+func quantityBefore(transactions []models.Transaction, date time.Time, currency string) (money.Decimal, error) {
+	earlier := []models.Transaction{}
+	for _, t := range transactions {
+		if t.Date.Before(date) {
+			earlier = append(earlier, t)
+		}
+	}
+	// Quantity does not depend on the cost basis method
+	position, err := BuildPosition(earlier, currency, models.CostBasisFIFO)
+	if err != nil {
+		return money.Zero, err
+	}
+	return position.Quantity, nil
+}
+
+// splitFactorAfter returns how many of today's shares one share held on date
+// has become through the splits recorded after it
+func splitFactorAfter(transactions []models.Transaction, date time.Time) money.Decimal {
+	factor := money.NewFromInt(1)
+	for _, t := range transactions {
+		if t.Type == models.TransactionTypeSplit && t.Date.After(date) {
+			factor = factor.Mul(t.Ratio)
+		}
+	}
+	return factor
+}
+
+// incomeEvent is one income transaction joined with its asset
+type incomeEvent struct {
+	assetID, name, currency string
+	kind                    models.TransactionType
+	date                    time.Time
+	amount                  money.Decimal
+}
+
+// loadIncome returns the income transactions dated between from and to
+func (s *IncomeService) loadIncome(from, to time.Time) ([]incomeEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT t.asset_id, a.name, COALESCE(a.currency, ''), t.type, t.date, t.amount
+		FROM transactions t
+		JOIN assets a ON a.id = t.asset_id
+		WHERE t.type IN ($1, $2, $3) AND t.date BETWEEN $4 AND $5
+		ORDER BY t.date
+	`,
+		models.TransactionTypeDividend, models.TransactionTypeInterest, models.TransactionTypeRent,
+		from.Format("2006-01-02"), to.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch income: %w", err)
+	}
+	defer rows.Close()
+
+	events := []incomeEvent{}
+	for rows.Next() {
+		var e incomeEvent
+		if err := rows.Scan(&e.assetID, &e.name, &e.currency, &e.kind, &e.date, &e.amount); err != nil {
+			return nil, fmt.Errorf("failed to parse income: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// TotalIncome returns all income ever received, converted at the rate of
+// each payment date
+func (s *IncomeService) TotalIncome(converter *Converter) (money.Decimal, error) {
+	events, err := s.loadIncome(time.Time{}, time.Now())
+	if err != nil {
+		return money.Zero, err
+	}
+
+	total := money.Zero
+	for _, e := range events {
+		amount, err := converter.ConvertOn(e.amount, e.currency, e.date)
+		if err != nil {
+			return money.Zero, err
+		}
+		total = total.Add(amount)
+	}
+	return total, nil
+}
+
+// Report sums the income received between from and to by month or by asset.
+// Monthly reports include months without income so trends chart evenly;
+// asset reports add each asset's yield: the period's income annualized as a
+// percentage of the asset's current value.
+func (s *IncomeService) Report(from, to time.Time, groupBy models.IncomeGroupBy, converter *Converter) (*models.IncomeReport, error) {
+	events, err := s.loadIncome(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.IncomeReport{
+		From:     from,
+		To:       to,
+		Currency: converter.Target(),
+		GroupBy:  groupBy,
+		ByType:   make(map[string]money.Decimal),
+		Groups:   []models.IncomeGroup{},
+	}
+
+	index := make(map[string]int)
+	group := func(key, name string) *models.IncomeGroup {
+		if i, ok := index[key]; ok {
+			return &report.Groups[i]
+		}
+		index[key] = len(report.Groups)
+		report.Groups = append(report.Groups, models.IncomeGroup{Key: key, Name: name, ByType: make(map[string]money.Decimal)})
+		return &report.Groups[len(report.Groups)-1]
+	}
+
+	if groupBy == models.IncomeByMonth {
+		for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(to); month = month.AddDate(0, 1, 0) {
+			group(month.Format("2006-01"), "")
+		}
+	}
+
+	for _, e := range events {
+		amount, err := converter.ConvertOn(e.amount, e.currency, e.date)
+		if err != nil {
+			return nil, err
+		}
+
+		var g *models.IncomeGroup
+		if groupBy == models.IncomeByAsset {
+			g = group(e.assetID, e.name)
+		} else {
+			g = group(e.date.Format("2006-01"), "")
+		}
+		g.Amount = g.Amount.Add(amount)
+		g.ByType[string(e.kind)] = g.ByType[string(e.kind)].Add(amount)
+		report.ByType[string(e.kind)] = report.ByType[string(e.kind)].Add(amount)
+		report.Total = report.Total.Add(amount)
+	}
+
+	if groupBy == models.IncomeByAsset {
+		if err := s.addYields(report, converter); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// addYields values each asset in an asset-grouped report and sets its
+// annualized yield for the report period
+func (s *IncomeService) addYields(report *models.IncomeReport, converter *Converter) error {
+	rows, err := s.db.Query(`
+		SELECT id, type, name, COALESCE(symbol, ''), current_value, quantity, COALESCE(currency, ''), source, COALESCE(provider, '')
+		FROM assets
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch assets: %w", err)
+	}
+
+	assets := []models.Asset{}
+	for rows.Next() {
+		var asset models.Asset
+		err := rows.Scan(&asset.ID, &asset.Type, &asset.Name, &asset.Symbol, &asset.CurrentValue, &asset.Quantity, &asset.Currency, &asset.Source, &asset.Provider)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse assets: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	rows.Close()
+
+	s.marketData.ApplyMarketPrices(assets)
+
+	days := report.To.Sub(report.From).Hours()/24 + 1
+	for _, asset := range assets {
+		for i := range report.Groups {
+			g := &report.Groups[i]
+			if g.Key != asset.ID {
+				continue
+			}
+			value, err := converter.Convert(asset.TotalValue(), asset.Currency)
+			if err != nil {
+				return err
+			}
+			g.Value = &value
+			if value.IsPositive() && days > 0 {
+				yield := math.Round(g.Amount.Float64()/value.Float64()*365/days*100*100) / 100
+				g.Yield = &yield
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// averagePricePlaces is the precision kept for the average cost per unit in
+// assets.buy_price; cost basis stays exact, so this only affects display
+const averagePricePlaces = 10
+
+var (
+	// ErrTransactionNotFound is returned when the requested transaction does not exist
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrInvalidTransaction is returned for transactions with missing or
+	// inconsistent fields, or that would leave the ledger inconsistent
+	ErrInvalidTransaction = errors.New("invalid transaction")
+)
+
+// transactionColumns lists the transaction columns in the order scanTransaction expects
+const transactionColumns = `id, asset_id, type, date, quantity, price, amount, fee, ratio, COALESCE(lot_id::text, ''), COALESCE(notes, ''), created_at, updated_at`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTransaction scans a row selected with transactionColumns into a transaction
+func scanTransaction(row scanner, t *models.Transaction) error {
+	return row.Scan(
+		&t.ID, &t.AssetID, &t.Type, &t.Date, &t.Quantity, &t.Price, &t.Amount,
+		&t.Fee, &t.Ratio, &t.LotID, &t.Notes, &t.CreatedAt, &t.UpdatedAt,
+	)
+}
+
+// LedgerService records asset transactions and derives each asset's
+// quantity and cost basis from them
+type LedgerService struct {
+	db *sql.DB
+}
+
+// NewLedgerService creates a new ledger service
+func NewLedgerService(db *sql.DB) *LedgerService {
+	return &LedgerService{db: db}
+}
+
+// ListTransactions returns an asset's ledger in the order it is replayed
+func (s *LedgerService) ListTransactions(assetID string) ([]models.Transaction, error) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM assets WHERE id = $1)`, assetID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrAssetNotFound
+	}
+	return loadTransactions(s.db, assetID)
+}
+
+// GetTransaction returns one transaction of an asset
+func (s *LedgerService) GetTransaction(assetID, id string) (*models.Transaction, error) {
+	var t models.Transaction
+	err := scanTransaction(s.db.QueryRow(
+		`SELECT `+transactionColumns+` FROM transactions WHERE id = $1 AND asset_id = $2`,
+		id, assetID,
+	), &t)
+	if err == sql.ErrNoRows {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetPosition replays an asset's ledger and returns its open lots and totals
+func (s *LedgerService) GetPosition(assetID string) (*models.Position, error) {
+	asset, err := loadLedgerAsset(s.db, assetID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := loadTransactions(s.db, assetID)
+	if err != nil {
+		return nil, err
+	}
+	position, err := BuildPosition(transactions, asset.currency, asset.method)
+	if err != nil {
+		return nil, err
+	}
+	position.AssetID = assetID
+	for i := range position.Disposals {
+		position.Disposals[i].AssetID = assetID
+	}
+	return position, nil
+}
+
+// CreateTransaction records a transaction and updates the asset's position.
+// The transaction is rejected if the ledger would no longer replay, e.g. a
+// sale of more units than are held at that date.
+func (s *LedgerService) CreateTransaction(assetID string, t *models.Transaction) error {
+	return s.withLedger(assetID, func(tx *sql.Tx, asset *ledgerAsset) error {
+		if err := validateTransaction(t, asset.currency); err != nil {
+			return err
+		}
+
+		return insertTransaction(tx, assetID, t)
+	})
+}
+
+// insertTransaction stores a validated transaction as a new ledger entry of the asset
+func insertTransaction(db execer, assetID string, t *models.Transaction) error {
+	now := time.Now()
+	t.ID = uuid.New().String()
+	t.AssetID = assetID
+	t.CreatedAt = now
+	t.UpdatedAt = now
+
+	_, err := db.Exec(`
+		INSERT INTO transactions (id, asset_id, type, date, quantity, price, amount, fee, ratio, lot_id, notes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`,
+		t.ID, t.AssetID, t.Type, t.Date.Format("2006-01-02"), t.Quantity, t.Price, t.Amount,
+		t.Fee, t.Ratio, nullableID(t.LotID), t.Notes, t.CreatedAt, t.UpdatedAt,
+	)
+	return err
+}
+
+// UpdateTransaction saves changes to an existing transaction and updates the
+// asset's position
+func (s *LedgerService) UpdateTransaction(t *models.Transaction) error {
+	return s.withLedger(t.AssetID, func(tx *sql.Tx, asset *ledgerAsset) error {
+		if err := validateTransaction(t, asset.currency); err != nil {
+			return err
+		}
+
+		t.UpdatedAt = time.Now()
+		result, err := tx.Exec(`
+			UPDATE transactions
+			SET type = $1, date = $2, quantity = $3, price = $4, amount = $5, fee = $6, ratio = $7, lot_id = $8, notes = $9, updated_at = $10
+			WHERE id = $11 AND asset_id = $12
+		`,
+			t.Type, t.Date.Format("2006-01-02"), t.Quantity, t.Price, t.Amount, t.Fee, t.Ratio,
+			nullableID(t.LotID), t.Notes, t.UpdatedAt, t.ID, t.AssetID,
+		)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return ErrTransactionNotFound
+		}
+		return nil
+	})
+}
+
+// DeleteTransaction removes a transaction and updates the asset's position
+func (s *LedgerService) DeleteTransaction(assetID, id string) error {
+	return s.withLedger(assetID, func(tx *sql.Tx, asset *ledgerAsset) error {
+		result, err := tx.Exec(`DELETE FROM transactions WHERE id = $1 AND asset_id = $2`, id, assetID)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return ErrTransactionNotFound
+		}
+		return nil
+	})
+}
+
+// SetCostBasisMethod changes how an asset's sales consume lots and replays
+// its ledger under the new method
+func (s *LedgerService) SetCostBasisMethod(assetID string, method models.CostBasisMethod) error {
+	if !method.Valid() {
+		return fmt.Errorf("%w: unknown cost basis method %q (use fifo, lifo, average or specific)", ErrInvalidTransaction, method)
+	}
+
+	return s.withLedger(assetID, func(tx *sql.Tx, asset *ledgerAsset) error {
+		asset.method = method
+		_, err := tx.Exec(`UPDATE assets SET cost_basis_method = $1 WHERE id = $2`, method, assetID)
+		return err
+	})
+}
+
+// HasTransactions reports whether an asset's position is derived from a ledger
+func (s *LedgerService) HasTransactions(assetID string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM transactions WHERE asset_id = $1)`, assetID).Scan(&exists)
+	return exists, err
+}
+
+// withLedger runs fn inside a database transaction with the asset row
+// locked, then replays the ledger and stores the resulting position on the
+// asset. Any error, including a ledger that no longer replays, rolls back.
+func (s *LedgerService) withLedger(assetID string, fn func(tx *sql.Tx, asset *ledgerAsset) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	asset, err := loadLedgerAsset(tx, assetID, true)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx, asset); err != nil {
+		return err
+	}
+
+	transactions, err := loadTransactions(tx, assetID)
+	if err != nil {
+		return err
+	}
+	position, err := BuildPosition(transactions, asset.currency, asset.method)
+	if err != nil {
+		return err
+	}
+	if err := storePosition(tx, assetID, position); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ledgerAsset holds the asset settings a ledger replay depends on
+type ledgerAsset struct {
+	currency string
+	method   models.CostBasisMethod
+}
+
+// loadLedgerAsset reads an asset's currency and cost basis method,
+// optionally locking the row for the rest of the database transaction
+func loadLedgerAsset(db querier, assetID string, lock bool) (*ledgerAsset, error) {
+	query := `SELECT COALESCE(currency, ''), COALESCE(cost_basis_method, '') FROM assets WHERE id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	var asset ledgerAsset
+	err := db.QueryRow(query, assetID).Scan(&asset.currency, &asset.method)
+	if err == sql.ErrNoRows {
+		return nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// nullableID stores an empty optional ID as NULL
+func nullableID(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+// loadTransactions returns an asset's transactions by date, then by entry order
+func loadTransactions(db querier, assetID string) ([]models.Transaction, error) {
+	rows, err := db.Query(
+		`SELECT `+transactionColumns+` FROM transactions WHERE asset_id = $1 ORDER BY date, created_at`,
+		assetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		var t models.Transaction
+		if err := scanTransaction(rows, &t); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+// storePosition writes the ledger-derived quantity and cost basis to the
+// asset. The buy price becomes the average cost per unit and the purchase
+// date the acquisition date of the oldest open lot.
+func storePosition(db execer, assetID string, position *models.Position) error {
+	if len(position.Lots) == 0 {
+		_, err := db.Exec(
+			`UPDATE assets SET quantity = $1, cost_basis = $2, realized_gain = $3, income = $4, updated_at = $5 WHERE id = $6`,
+			position.Quantity, position.CostBasis, position.RealizedGain, position.Income, time.Now(), assetID,
+		)
+		return err
+	}
+
+	averagePrice := position.CostBasis.Div(position.Quantity, averagePricePlaces)
+	_, err := db.Exec(`
+		UPDATE assets
+		SET quantity = $1, cost_basis = $2, realized_gain = $3, income = $4, buy_price = $5, purchase_date = $6, updated_at = $7
+		WHERE id = $8
+	`,
+		position.Quantity, position.CostBasis, position.RealizedGain, position.Income, averagePrice,
+		position.Lots[0].Date.Format("2006-01-02"), time.Now(), assetID,
+	)
+	return err
+}
+
+// InsertOpeningTransaction records the buy that opened a newly created or
+// imported asset, so its position is derived from the ledger from the start.
+// Any cost basis above buy price × quantity is recorded as the buy's fee.
+func InsertOpeningTransaction(db execer, asset *models.Asset) error {
+	if !asset.Quantity.IsPositive() {
+		return nil
+	}
+
+	fee := money.Zero
+	if extra := asset.CostBasis.Sub(asset.PurchaseCost()); extra.IsPositive() {
+		fee = extra
+	}
+
+	now := time.Now()
+	_, err := db.Exec(`
+		INSERT INTO transactions (id, asset_id, type, date, quantity, price, fee, notes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`,
+		uuid.New().String(), asset.ID, models.TransactionTypeBuy, asset.PurchaseDate.Format("2006-01-02"),
+		asset.Quantity, asset.BuyPrice, fee, "Opening position", now, now,
+	)
+	return err
+}
+
+// validateTransaction checks the fields each type needs and rounds cash
+// amounts to the currency's minor unit
+func validateTransaction(t *models.Transaction, currency string) error {
+	if !t.Type.Valid() {
+		return fmt.Errorf("%w: unknown type %q (use buy, sell, dividend, interest, rent, split, fee or transfer)", ErrInvalidTransaction, t.Type)
+	}
+	if t.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidTransaction)
+	}
+	if t.Price.IsNegative() || t.Fee.IsNegative() {
+		return fmt.Errorf("%w: price and fee cannot be negative", ErrInvalidTransaction)
+	}
+	if t.LotID != "" && t.Type != models.TransactionTypeSell && t.Type != models.TransactionTypeTransfer {
+		return fmt.Errorf("%w: lot_id only applies to sells and transfers out", ErrInvalidTransaction)
+	}
+	if t.LotID != "" {
+		if _, err := uuid.Parse(t.LotID); err != nil {
+			return fmt.Errorf("%w: lot_id must be the id of a buy or transfer in", ErrInvalidTransaction)
+		}
+	}
+
+	switch t.Type {
+	case models.TransactionTypeBuy, models.TransactionTypeSell:
+		if !t.Quantity.IsPositive() {
+			return fmt.Errorf("%w: %s quantity must be positive", ErrInvalidTransaction, t.Type)
+		}
+	case models.TransactionTypeDividend, models.TransactionTypeInterest, models.TransactionTypeRent, models.TransactionTypeFee:
+		if !t.Amount.IsPositive() {
+			return fmt.Errorf("%w: %s amount must be positive", ErrInvalidTransaction, t.Type)
+		}
+	case models.TransactionTypeSplit:
+		if !t.Ratio.IsPositive() {
+			return fmt.Errorf("%w: split ratio must be positive", ErrInvalidTransaction)
+		}
+	case models.TransactionTypeTransfer:
+		if t.Quantity.IsZero() {
+			return fmt.Errorf("%w: transfer quantity must be non-zero (negative for transfers out)", ErrInvalidTransaction)
+		}
+	}
+
+	t.Amount = money.RoundTo(t.Amount, currency)
+	t.Fee = money.RoundTo(t.Fee, currency)
+	return nil
+}
@@ -2,14 +2,16 @@ package services
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"personal-finance/api/v1/money"
 )
 
 // MarketDataProvider defines the data source
@@ -21,266 +23,447 @@ const (
 	ProviderAlphaVantage      MarketDataProvider = "alphavantage"
 )
 
+const (
+	// PriceSourceLastKnown marks a price served from an expired DB cache
+	// entry because every provider in the failover chain failed
+	PriceSourceLastKnown = "last_known"
+	// PriceSourceStored marks the value stored on the asset itself
+	PriceSourceStored = "stored"
+
+	// priceCacheTTL is how long a price in stock_prices is considered fresh
+	priceCacheTTL = 60 * time.Minute
+)
+
 // MarketDataService provides stock market data
 type MarketDataService struct {
-	provider   MarketDataProvider
-	apiKey     string
-	httpClient *http.Client
-	cache      map[string]*CachedPrice
-	db         *sql.DB
+	provider MarketDataProvider
+	// cryptoProvider starts the chain for crypto assets without a provider of their own
+	cryptoProvider string
+	failover       []string
+	cooldown       time.Duration
+	// maxConcurrency bounds parallel provider requests in GetQuotes
+	maxConcurrency int
+	httpClient     *http.Client
+	cache          map[string]*CachedPrice
+	db             *sql.DB
+
+	mu        sync.Mutex
+	providers map[string]QuoteProvider
+	breakers  map[string]*CircuitBreaker
+	inflight  quoteGroup
 }
 
 // CachedPrice stores a price with timestamp
 type CachedPrice struct {
-	Price     float64
+	Price     money.Decimal
 	Timestamp time.Time
 }
 
 // NewMarketDataService creates a new market data service
 func NewMarketDataService(db *sql.DB) *MarketDataService {
 	// Check which provider to use
-	provider := os.Getenv("MARKET_DATA_PROVIDER")
+	provider := strings.ToLower(os.Getenv("MARKET_DATA_PROVIDER"))
 	if provider == "" {
 		provider = string(DefaultMarketDataProvider)
 	}
-
-	apiKey := os.Getenv("ALPHA_VANTAGE_API_KEY")
-	if apiKey == "" {
-		apiKey = "demo"
+	if !IsRegisteredProvider(provider) {
+		log.Printf("[MarketData] Unknown provider %q, falling back to %s", provider, DefaultMarketDataProvider)
+		provider = string(DefaultMarketDataProvider)
 	}
 
-	return &MarketDataService{
-		provider: MarketDataProvider(provider),
-		apiKey:   apiKey,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		cache: make(map[string]*CachedPrice),
-		db:    db,
+	cryptoProvider := strings.ToLower(os.Getenv("CRYPTO_DATA_PROVIDER"))
+	if cryptoProvider == "" {
+		cryptoProvider = string(ProviderCoinbase)
+	}
+	if !IsRegisteredProvider(cryptoProvider) {
+		log.Printf("[MarketData] Unknown crypto provider %q, falling back to %s", cryptoProvider, ProviderCoinbase)
+		cryptoProvider = string(ProviderCoinbase)
 	}
-}
-
-// GetStockPrice fetches the current price for a stock symbol
-// It first checks the database cache, and only fetches from API if cache is older than 1 hour
-func (s *MarketDataService) GetStockPrice(symbol string) (float64, error) {
-	symbol = strings.ToUpper(strings.TrimSpace(symbol))
 
-	// Check database cache first (valid for 1 hour)
-	var price float64
-	var lastUpdated time.Time
-	checkQuery := `SELECT price, last_updated FROM stock_prices WHERE symbol = $1`
-	err := s.db.QueryRow(checkQuery, symbol).Scan(&price, &lastUpdated)
-
-	if err == nil {
-		// Found in cache, check if still valid
-		if time.Since(lastUpdated) < 60*time.Minute {
-			fmt.Printf("[MarketData] Using DB cached price for %s: %.2f (age: %v)\n", symbol, price, time.Since(lastUpdated))
-			return price, nil
+	// Providers tried, in order, after the preferred one fails
+	var failover []string
+	for _, name := range strings.Split(os.Getenv("MARKET_DATA_FAILOVER"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
 		}
-		fmt.Printf("[MarketData] DB cache expired for %s (age: %v), fetching fresh data\n", symbol, time.Since(lastUpdated))
-	} else if err != sql.ErrNoRows {
-		// Database error (not just "no rows"), log it but continue
-		fmt.Printf("[MarketData] DB cache check error for %s: %v\n", symbol, err)
+		if !IsRegisteredProvider(name) {
+			log.Printf("[MarketData] Ignoring unknown failover provider %q", name)
+			continue
+		}
+		failover = append(failover, name)
 	}
 
-	// Fetch from API
-	var fetchErr error
-	switch s.provider {
-	case ProviderYahooFinance:
-		price, fetchErr = s.getYahooFinancePrice(symbol)
-	case ProviderAlphaVantage:
-		price, fetchErr = s.getAlphaVantagePrice(symbol)
-	default:
-		price, fetchErr = s.getYahooFinancePrice(symbol)
+	cooldown := defaultBreakerCooldown
+	if value := os.Getenv("MARKET_DATA_BREAKER_COOLDOWN"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			cooldown = d
+		} else {
+			log.Printf("[MarketData] Invalid MARKET_DATA_BREAKER_COOLDOWN %q, using %v", value, cooldown)
+		}
 	}
 
-	if fetchErr != nil {
-		return 0, fetchErr
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	// Capture real provider responses so they can be replayed offline
+	if dir := os.Getenv("MARKET_DATA_RECORD_DIR"); dir != "" {
+		log.Printf("[MarketData] Recording provider responses to %s", dir)
+		httpClient.Transport = NewRecordingTransport(dir, nil)
 	}
 
-	// Store in database cache
-	upsertQuery := `
-		INSERT INTO stock_prices (symbol, price, last_updated, created_at)
-		VALUES ($1, $2, $3, $3)
-		ON CONFLICT (symbol) 
-		DO UPDATE SET price = $2, last_updated = $3
-	`
-	now := time.Now()
-	_, err = s.db.Exec(upsertQuery, symbol, price, now)
-	if err != nil {
-		fmt.Printf("[MarketData] Failed to cache price in DB for %s: %v\n", symbol, err)
-		// Don't fail the request if caching fails
-	} else {
-		fmt.Printf("[MarketData] Cached %s price in DB: %.2f\n", symbol, price)
+	return &MarketDataService{
+		provider:       MarketDataProvider(provider),
+		cryptoProvider: cryptoProvider,
+		failover:       failover,
+		cooldown:       cooldown,
+		maxConcurrency: maxConcurrencyFromEnv(),
+		httpClient:     httpClient,
+		cache:          make(map[string]*CachedPrice),
+		db:             db,
+		providers:      make(map[string]QuoteProvider),
+		breakers:       make(map[string]*CircuitBreaker),
 	}
+}
 
-	return price, nil
+// DefaultProvider returns the name of the globally configured provider
+func (s *MarketDataService) DefaultProvider() string {
+	return string(s.provider)
 }
 
-// getYahooFinancePrice fetches price from Yahoo Finance (FREE, no API key)
-func (s *MarketDataService) getYahooFinancePrice(symbol string) (float64, error) {
-	// Using Yahoo Finance query API (free, no authentication)
-	url := fmt.Sprintf(
-		"https://query1.finance.yahoo.com/v8/finance/chart/%s?interval=1d&range=1d",
-		symbol,
-	)
+// CryptoProvider returns the name of the provider crypto assets are priced by default
+func (s *MarketDataService) CryptoProvider() string {
+	return s.cryptoProvider
+}
 
-	resp, err := s.httpClient.Get(url)
-	if err != nil {
-		return 0, fmt.Errorf("failed to fetch stock price: %w", err)
+// PreferredProvider returns the provider an asset's failover chain starts
+// at: its own provider if set, the crypto provider for crypto assets, and
+// otherwise "" for the default provider
+func (s *MarketDataService) PreferredProvider(assetType, provider string) string {
+	if provider == "" && assetType == "crypto" {
+		return s.cryptoProvider
 	}
-	defer resp.Body.Close()
+	return provider
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("API returned status code: %d", resp.StatusCode)
+// Provider returns the provider instance for name, creating it from the
+// registry on first use. An empty name selects the default provider.
+func (s *MarketDataService) Provider(name string) (QuoteProvider, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = string(s.provider)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read response: %w", err)
-	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Parse Yahoo Finance response
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, fmt.Errorf("failed to parse response: %w", err)
+	if p, ok := s.providers[name]; ok {
+		return p, nil
 	}
 
-	// Navigate the nested structure
-	chart, ok := result["chart"].(map[string]interface{})
-	if !ok {
-		return 0, fmt.Errorf("invalid response format: missing chart")
+	p, err := newProvider(name, ProviderConfig{HTTPClient: s.httpClient})
+	if err != nil {
+		return nil, err
 	}
+	s.providers[name] = p
+	return p, nil
+}
 
-	resultArray, ok := chart["result"].([]interface{})
-	if !ok || len(resultArray) == 0 {
-		return 0, fmt.Errorf("invalid response format: missing result array")
-	}
+// UseProvider installs a provider instance under its own name, replacing any
+// instance built from the registry. This is how test doubles are injected.
+func (s *MarketDataService) UseProvider(p QuoteProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.providers[strings.ToLower(p.Name())] = p
+}
 
-	firstResult, ok := resultArray[0].(map[string]interface{})
-	if !ok {
-		return 0, fmt.Errorf("invalid response format: invalid result")
+// Chain returns the ordered list of providers tried for a preferred provider:
+// the preferred (or default) provider first, then the configured failovers
+func (s *MarketDataService) Chain(preferred string) []string {
+	preferred = strings.ToLower(strings.TrimSpace(preferred))
+	if preferred == "" {
+		preferred = string(s.provider)
 	}
 
-	meta, ok := firstResult["meta"].(map[string]interface{})
-	if !ok {
-		return 0, fmt.Errorf("invalid response format: missing meta")
+	chain := []string{preferred}
+	for _, name := range s.failover {
+		if name != preferred {
+			chain = append(chain, name)
+		}
 	}
+	return chain
+}
+
+// breaker returns the circuit breaker for a provider, creating it on first use
+func (s *MarketDataService) breaker(name string) *CircuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Get current price from meta
-	priceInterface, ok := meta["regularMarketPrice"].(float64)
+	b, ok := s.breakers[name]
 	if !ok {
-		return 0, fmt.Errorf("invalid response format: missing price")
+		b = NewCircuitBreaker(name, s.cooldown)
+		s.breakers[name] = b
 	}
+	return b
+}
 
-	return priceInterface, nil
+// BreakerStatuses returns the circuit breaker state of every provider in
+// the default chain and of the crypto provider
+func (s *MarketDataService) BreakerStatuses() []BreakerStatus {
+	statuses := []BreakerStatus{}
+	seen := make(map[string]bool)
+	for _, name := range append(s.Chain(""), s.cryptoProvider) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		statuses = append(statuses, s.breaker(name).Status())
+	}
+	return statuses
 }
 
-// getAlphaVantagePrice fetches price from Alpha Vantage (requires API key)
-func (s *MarketDataService) getAlphaVantagePrice(symbol string) (float64, error) {
-	url := fmt.Sprintf(
-		"https://www.alphavantage.co/query?function=GLOBAL_QUOTE&symbol=%s&apikey=%s",
-		symbol, s.apiKey,
-	)
+// GetStockPrice fetches the current price for a stock symbol from the default provider
+func (s *MarketDataService) GetStockPrice(symbol string) (money.Decimal, error) {
+	return s.GetStockPriceFrom("", symbol)
+}
 
-	resp, err := s.httpClient.Get(url)
+// GetStockPriceFrom fetches the current price for a stock symbol starting
+// the failover chain at the named provider
+func (s *MarketDataService) GetStockPriceFrom(providerName, symbol string) (money.Decimal, error) {
+	quote, err := s.GetQuote(providerName, symbol)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch stock price: %w", err)
+		return money.Zero, err
 	}
-	defer resp.Body.Close()
+	return quote.Price, nil
+}
+
+// GetQuote returns the current quote for a symbol and which provider produced it.
+// It first checks the database cache, and only fetches from the failover chain if
+// no provider of the chain has a price younger than 1 hour. When every provider
+// fails the last known price from the database is returned, marked as stale.
+func (s *MarketDataService) GetQuote(providerName, symbol string) (*Quote, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("API returned status code: %d", resp.StatusCode)
+	fresh, last := s.pickCached(providerName, s.cachedQuotes([]string{symbol})[symbol])
+	if fresh != nil {
+		log.Printf("[MarketData] Using DB cached price for %s: %s (age: %v)", symbol, fresh.Price, time.Since(fresh.Timestamp))
+		return fresh, nil
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read response: %w", err)
+	return s.resolveQuote(providerName, symbol, last)
+}
+
+// pickCached chooses among the stored prices of a symbol, most recent first.
+// fresh is the price of the first provider in the chain starting at preferred
+// that is younger than priceCacheTTL, so a price from another provider is
+// never served as fresh; last is the most recent price of any provider, the
+// fallback when every provider fails.
+func (s *MarketDataService) pickCached(preferred string, stored []*Quote) (fresh, last *Quote) {
+	if len(stored) == 0 {
+		return nil, nil
 	}
 
-	// Parse response
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, fmt.Errorf("failed to parse response: %w", err)
+	for _, name := range s.Chain(preferred) {
+		for _, quote := range stored {
+			if quote.Provider == name && time.Since(quote.Timestamp) < priceCacheTTL {
+				return quote, stored[0]
+			}
+		}
 	}
+	return nil, stored[0]
+}
+
+// resolveQuote fetches a symbol through the failover chain, falling back to
+// the cached quote (which may be nil) when every provider fails. Concurrent
+// calls for the same provider and symbol share a single fetch.
+func (s *MarketDataService) resolveQuote(providerName, symbol string, cached *Quote) (*Quote, error) {
+	return s.inflight.do(quoteKey(providerName, symbol), func() (*Quote, error) {
+		quote, err := s.fetchFromChain(providerName, symbol)
+		if err != nil {
+			if cached != nil {
+				log.Printf("[MarketData] %v - using last known price for %s from %v", err, symbol, cached.Timestamp)
+				stale := *cached
+				stale.Provider = PriceSourceLastKnown
+				stale.Stale = true
+				return &stale, nil
+			}
+			return nil, err
+		}
+
+		s.storeQuote(quote)
+		return quote, nil
+	})
+}
+
+// quoteKey identifies the fetches of a symbol starting at a provider
+func quoteKey(providerName, symbol string) string {
+	return strings.ToLower(providerName) + "|" + symbol
+}
 
-	// Check for error messages
-	if note, ok := result["Note"].(string); ok {
-		return 0, fmt.Errorf("API limit reached: %s", note)
+// fetchFromChain fetches a quote through the failover chain
+func (s *MarketDataService) fetchFromChain(preferred, symbol string) (*Quote, error) {
+	var quote *Quote
+	name, err := s.withFailover(preferred, func(p QuoteProvider) error {
+		var err error
+		quote, err = p.GetQuote(symbol)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("all providers failed for %s (%v)", symbol, err)
 	}
 
-	if errMsg, ok := result["Error Message"].(string); ok {
-		return 0, fmt.Errorf("API error: %s", errMsg)
+	if quote.Provider == "" {
+		quote.Provider = name
 	}
+	return quote, nil
+}
 
-	// Extract price from Global Quote
-	globalQuote, ok := result["Global Quote"].(map[string]interface{})
-	if !ok {
-		return 0, fmt.Errorf("invalid response format: missing Global Quote")
+// fetchManyFromChain fetches quotes for several symbols through the failover
+// chain with one GetQuotes call per provider. Symbols a provider leaves out
+// are asked of the next one. The error explains the symbols left unpriced.
+func (s *MarketDataService) fetchManyFromChain(preferred string, symbols []string) (map[string]*Quote, error) {
+	quotes := make(map[string]*Quote, len(symbols))
+	remaining := symbols
+	_, err := s.withFailover(preferred, func(p QuoteProvider) error {
+		batch, err := p.GetQuotes(remaining)
+		if err != nil {
+			return err
+		}
+
+		var left []string
+		for _, symbol := range remaining {
+			quote, ok := batch[symbol]
+			if !ok || quote == nil {
+				left = append(left, symbol)
+				continue
+			}
+			if quote.Provider == "" {
+				quote.Provider = p.Name()
+			}
+			quotes[symbol] = quote
+		}
+		remaining = left
+		if len(remaining) > 0 {
+			return fmt.Errorf("%w: no price for %s", errIncomplete, strings.Join(remaining, ", "))
+		}
+		return nil
+	})
+	if err != nil {
+		return quotes, fmt.Errorf("all providers failed for %s (%v)", strings.Join(remaining, ", "), err)
 	}
+	return quotes, nil
+}
 
-	priceStr, ok := globalQuote["05. price"].(string)
-	if !ok {
-		return 0, fmt.Errorf("invalid response format: missing price field")
+// errNotSupported is returned from a withFailover callback for providers
+// that do not offer the requested data; it moves on without a breaker failure
+var errNotSupported = errors.New("not supported by this provider")
+
+// errIncomplete is returned from a withFailover callback when a provider
+// answered only part of a request; the provider counts as healthy and the
+// rest is asked of the next one
+var errIncomplete = errors.New("incomplete answer")
+
+// withFailover calls fn with each provider of the chain in turn until one
+// succeeds, skipping providers whose circuit breaker is open. It returns the
+// name of the provider that succeeded.
+func (s *MarketDataService) withFailover(preferred string, fn func(p QuoteProvider) error) (string, error) {
+	var failures []string
+	for _, name := range s.Chain(preferred) {
+		b := s.breaker(name)
+		if !b.Allow() {
+			failures = append(failures, name+": circuit open")
+			continue
+		}
+
+		provider, err := s.Provider(name)
+		if err != nil {
+			b.RecordFailure(err)
+			failures = append(failures, name+": "+err.Error())
+			continue
+		}
+
+		if err := fn(provider); err != nil {
+			if errors.Is(err, errNotSupported) {
+				// The provider is healthy, it just cannot answer this kind of
+				// request; a trial request goes back to the breaker unused
+				b.Release()
+				failures = append(failures, name+": "+err.Error())
+				continue
+			}
+			if errors.Is(err, errIncomplete) || errors.Is(err, ErrSymbolNotFound) {
+				// The provider answered; another one may know the symbol
+				b.RecordSuccess()
+				failures = append(failures, name+": "+err.Error())
+				continue
+			}
+			b.RecordFailure(err)
+			log.Printf("[MarketData] %s failed: %v", name, err)
+			failures = append(failures, name+": "+err.Error())
+			continue
+		}
+
+		b.RecordSuccess()
+		return name, nil
 	}
 
-	price, err := strconv.ParseFloat(priceStr, 64)
+	return "", errors.New(strings.Join(failures, "; "))
+}
+
+// storeQuote writes a fresh quote to the stock_prices cache, next to the
+// prices of the same symbol from other providers
+func (s *MarketDataService) storeQuote(quote *Quote) {
+	upsertQuery := `
+		INSERT INTO stock_prices (symbol, price, provider, last_updated, created_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (symbol, provider)
+		DO UPDATE SET price = $2, last_updated = $4
+	`
+	_, err := s.db.Exec(upsertQuery, quote.Symbol, quote.Price, quote.Provider, time.Now())
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse price: %w", err)
+		// Don't fail the request if caching fails
+		log.Printf("[MarketData] Failed to cache price in DB for %s: %v", quote.Symbol, err)
+		return
 	}
-
-	return price, nil
+	log.Printf("[MarketData] Cached %s price in DB: %s (provider: %s)", quote.Symbol, quote.Price, quote.Provider)
 }
 
-// IsStockSymbol checks if an asset name is likely a stock symbol
-func IsStockSymbol(name string) bool {
-	// Simple heuristic: stock symbols are typically 1-5 uppercase letters
-	name = strings.TrimSpace(name)
-	if len(name) < 1 || len(name) > 5 {
+// IsMarketPriced reports whether an asset's value comes from market data:
+// market_api stocks and funds with a ticker and market_api crypto with a
+// BASE-QUOTE pair. Only funds among investments can be market_api.
+func IsMarketPriced(assetType, source, symbol string) bool {
+	if source != "market_api" {
 		return false
 	}
-
-	for _, char := range name {
-		if char < 'A' || char > 'Z' {
-			return false
-		}
+	switch assetType {
+	case "stock", "investment":
+		return IsStockSymbol(symbol)
+	case "crypto":
+		_, _, ok := CryptoPair(symbol)
+		return ok
 	}
-
-	return true
+	return false
 }
 
-// GetCurrentValue returns the current value for an asset
-// If it's a stock and market_api source, fetch from API
-// Otherwise return the stored value
-func (s *MarketDataService) GetCurrentValue(assetType, name string, storedValue float64, source string) (float64, error) {
+// GetCurrentValue returns the current quote for an asset
+// If it's a stock, fund or crypto pair with market_api source, fetch through the failover chain
+// Otherwise, or if every source fails, return the stored value
+// provider selects a per-asset provider; an empty string uses the default (crypto provider for crypto)
+func (s *MarketDataService) GetCurrentValue(assetType, symbol string, storedValue money.Decimal, source, provider string) (*Quote, error) {
+	stored := &Quote{Symbol: symbol, Price: storedValue, Provider: PriceSourceStored, Timestamp: time.Now()}
+
 	// Only fetch for stocks with market_api source
-	if assetType == "stock" && source == "market_api" && IsStockSymbol(name) {
-		fmt.Printf("[MarketData] Fetching real-time price for %s (stored: %.2f)\n", name, storedValue)
-		price, err := s.GetStockPrice(name)
+	if IsMarketPriced(assetType, source, symbol) {
+		quote, err := s.GetQuote(s.PreferredProvider(assetType, provider), symbol)
 		if err != nil {
-			// If API fails, fall back to stored value
-			fmt.Printf("[MarketData] ERROR fetching %s: %v - using stored value\n", name, err)
-			return storedValue, nil
-		}
-		fmt.Printf("[MarketData] Successfully fetched %s: %.2f\n", name, price)
-
-		// Update all assets with this stock symbol in the database
-		updateQuery := `
-			UPDATE assets 
-			SET current_value = $1, updated_at = $2 
-			WHERE name = $3 AND type = 'stock' AND source = 'market_api'
-		`
-		result, err := s.db.Exec(updateQuery, price, time.Now(), name)
-		if err != nil {
-			fmt.Printf("[MarketData] Failed to update assets for %s: %v\n", name, err)
-		} else {
-			rowsAffected, _ := result.RowsAffected()
-			fmt.Printf("[MarketData] Updated %d asset(s) with %s price\n", rowsAffected, name)
+			// If every source fails, fall back to stored value
+			log.Printf("[MarketData] ERROR fetching %s: %v - using stored value", symbol, err)
+			stored.Stale = true
+			return stored, nil
 		}
 
-		return price, nil
+		return quote, nil
 	}
 
-	// For non-stocks or manual source, return stored value
-	return storedValue, nil
+	// For other assets or manual source, return stored value
+	return stored, nil
 }
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+// stubProvider serves quotes only, with no dividend or split history. It
+// has no price for the unknown symbols.
+type stubProvider struct {
+	name    string
+	err     error
+	unknown []string
+	batches [][]string
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) GetQuote(symbol string) (*Quote, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	for _, unknown := range p.unknown {
+		if symbol == unknown {
+			return nil, fmt.Errorf("%w: no data for %s", ErrSymbolNotFound, symbol)
+		}
+	}
+	return &Quote{Symbol: symbol, Price: money.MustParse("100.00"), Timestamp: time.Now()}, nil
+}
+
+// GetQuotes records each batch it is asked for
+func (p *stubProvider) GetQuotes(symbols []string) (map[string]*Quote, error) {
+	p.batches = append(p.batches, symbols)
+	return fetchQuotesSequentially(p, symbols)
+}
+
+func (p *stubProvider) GetHistory(symbol string, from, to time.Time) ([]Bar, error) {
+	return nil, p.err
+}
+
+func (p *stubProvider) SearchSymbols(query string) ([]SymbolInfo, error) {
+	return nil, p.err
+}
+
+// newStubMarketData returns a service whose chain holds the given providers
+// in order, with breakers that cool down after a millisecond
+func newStubMarketData(providers ...QuoteProvider) *MarketDataService {
+	s := &MarketDataService{
+		provider:  MarketDataProvider(providers[0].Name()),
+		cooldown:  time.Millisecond,
+		providers: make(map[string]QuoteProvider),
+		breakers:  make(map[string]*CircuitBreaker),
+	}
+	for i, p := range providers {
+		s.UseProvider(p)
+		if i > 0 {
+			s.failover = append(s.failover, p.Name())
+		}
+	}
+	return s
+}
+
+func TestUnsupportedRequestReleasesTrial(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func(s *MarketDataService) error
+	}{
+		{name: "dividends", request: func(s *MarketDataService) error {
+			_, _, err := s.GetDividends("", "AAPL", mustDate("2024-01-01"), mustDate("2024-12-31"))
+			return err
+		}},
+		{name: "splits", request: func(s *MarketDataService) error {
+			_, _, err := s.GetSplits("", "AAPL", mustDate("2024-01-01"), mustDate("2024-12-31"))
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		stub := &stubProvider{name: "stub", err: errors.New("status 500")}
+		s := newStubMarketData(stub)
+		for i := 0; i < defaultBreakerMaxConsecutive; i++ {
+			s.fetchFromChain("", "AAPL")
+		}
+		if state := s.breaker("stub").Status().State; state != BreakerOpen {
+			t.Fatalf("%s: breaker %s after %d failures, want open", tt.name, state, defaultBreakerMaxConsecutive)
+		}
+		time.Sleep(2 * time.Millisecond)
+
+		// The cooled down breaker's trial goes to a request the provider cannot serve
+		stub.err = nil
+		if err := tt.request(s); err == nil {
+			t.Errorf("%s: request succeeded on a provider without support for it", tt.name)
+		}
+
+		quote, err := s.fetchFromChain("", "AAPL")
+		if err != nil {
+			t.Errorf("%s: quote after the unsupported request failed: %v", tt.name, err)
+			continue
+		}
+		if quote.Provider != "stub" || s.breaker("stub").Status().State != BreakerClosed {
+			t.Errorf("%s: quote from %q with breaker %s, want stub with the breaker closed",
+				tt.name, quote.Provider, s.breaker("stub").Status().State)
+		}
+	}
+}
+
+func TestUnknownSymbolKeepsBreakerClosed(t *testing.T) {
+	primary := &stubProvider{name: "primary", unknown: []string{"NOPE", "ASML.AS"}}
+	secondary := &stubProvider{name: "secondary", unknown: []string{"NOPE"}}
+	s := newStubMarketData(primary, secondary)
+
+	for i := 0; i < 2*defaultBreakerMaxConsecutive; i++ {
+		if _, err := s.fetchFromChain("", "NOPE"); err == nil {
+			t.Fatalf("quote for an unknown symbol succeeded")
+		}
+	}
+	for _, name := range []string{"primary", "secondary"} {
+		if status := s.breaker(name).Status(); status.State != BreakerClosed {
+			t.Errorf("%s breaker %s after unknown symbols, want closed", name, status.State)
+		}
+	}
+
+	// A symbol only the next provider knows is priced there
+	quote, err := s.fetchFromChain("", "ASML.AS")
+	if err != nil || quote.Provider != "secondary" {
+		t.Errorf("ASML.AS = %+v, %v; want a quote from secondary", quote, err)
+	}
+	if quote, err := s.fetchFromChain("", "AAPL"); err != nil || quote.Provider != "primary" {
+		t.Errorf("AAPL = %+v, %v; want a quote from primary", quote, err)
+	}
+}
+
+func TestProvidersReportUnknownSymbols(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		provider func(url string) QuoteProvider
+		notFound bool
+	}{
+		{
+			name: "yahoo 404", status: http.StatusNotFound,
+			body:     `{"chart":{"result":null,"error":{"code":"Not Found","description":"No data found, symbol may be delisted"}}}`,
+			provider: func(url string) QuoteProvider { return &YahooProvider{httpClient: http.DefaultClient, baseURL: url} },
+			notFound: true,
+		},
+		{
+			name: "yahoo not found error", status: http.StatusOK,
+			body:     `{"chart":{"result":null,"error":{"code":"Not Found","description":"No data found, symbol may be delisted"}}}`,
+			provider: func(url string) QuoteProvider { return &YahooProvider{httpClient: http.DefaultClient, baseURL: url} },
+			notFound: true,
+		},
+		{
+			name: "yahoo empty result", status: http.StatusOK,
+			body:     `{"chart":{"result":[],"error":null}}`,
+			provider: func(url string) QuoteProvider { return &YahooProvider{httpClient: http.DefaultClient, baseURL: url} },
+			notFound: true,
+		},
+		{
+			name: "yahoo outage", status: http.StatusInternalServerError, body: `{}`,
+			provider: func(url string) QuoteProvider { return &YahooProvider{httpClient: http.DefaultClient, baseURL: url} },
+		},
+		{
+			name: "alpha vantage empty quote", status: http.StatusOK, body: `{"Global Quote": {}}`,
+			provider: func(url string) QuoteProvider {
+				return &AlphaVantageProvider{httpClient: http.DefaultClient, apiKey: "demo", baseURL: url}
+			},
+			notFound: true,
+		},
+		{
+			name: "alpha vantage malformed", status: http.StatusOK, body: `{"Global Quote": {"01. symbol": "NOPE"}}`,
+			provider: func(url string) QuoteProvider {
+				return &AlphaVantageProvider{httpClient: http.DefaultClient, apiKey: "demo", baseURL: url}
+			},
+		},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+
+		_, err := tt.provider(server.URL).GetQuote("NOPE")
+		server.Close()
+		if err == nil {
+			t.Errorf("%s: quote succeeded, want an error", tt.name)
+			continue
+		}
+		if got := errors.Is(err, ErrSymbolNotFound); got != tt.notFound {
+			t.Errorf("%s: error %v is ErrSymbolNotFound: %v, want %v", tt.name, err, got, tt.notFound)
+		}
+	}
+}
+
+func TestProviderRegistry(t *testing.T) {
+	RegisterProvider("Registry-Test", func(cfg ProviderConfig) (QuoteProvider, error) {
+		return &stubProvider{name: "registry-test"}, nil
+	})
+
+	if !IsRegisteredProvider("registry-test") || !IsRegisteredProvider("REGISTRY-TEST") {
+		t.Errorf("registry-test is not registered under its lowercase name")
+	}
+	found := false
+	for _, name := range RegisteredProviders() {
+		found = found || name == "registry-test"
+	}
+	if !found {
+		t.Errorf("RegisteredProviders() = %v, want registry-test listed", RegisteredProviders())
+	}
+	for _, name := range []string{"yahoo", "alphavantage", "coinbase", "fixture"} {
+		if !IsRegisteredProvider(name) {
+			t.Errorf("built-in provider %s is not registered", name)
+		}
+	}
+
+	// The service builds each provider once and keeps the instance
+	s := newStubMarketData(&stubProvider{name: "stub"})
+	first, err := s.Provider(" Registry-Test ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := s.Provider("registry-test"); second != first || first.Name() != "registry-test" {
+		t.Errorf("Provider returned %v then %v, want the same registry-test instance", first, second)
+	}
+	if p, _ := s.Provider(""); p.Name() != "stub" {
+		t.Errorf("Provider(\"\") = %s, want the default stub", p.Name())
+	}
+	if _, err := s.Provider("nope"); err == nil {
+		t.Errorf("Provider(\"nope\") succeeded, want an unknown provider error")
+	}
+}
+
+func TestChain(t *testing.T) {
+	s := newStubMarketData(&stubProvider{name: "yahoo"}, &stubProvider{name: "alphavantage"}, &stubProvider{name: "fixture"})
+
+	tests := []struct {
+		preferred string
+		want      string
+	}{
+		{preferred: "", want: "yahoo,alphavantage,fixture"},
+		{preferred: "yahoo", want: "yahoo,alphavantage,fixture"},
+		// A failover provider is not tried twice
+		{preferred: " AlphaVantage ", want: "alphavantage,fixture"},
+		{preferred: "coinbase", want: "coinbase,alphavantage,fixture"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(s.Chain(tt.preferred), ","); got != tt.want {
+			t.Errorf("Chain(%q) = %s, want %s", tt.preferred, got, tt.want)
+		}
+	}
+}
+
+func TestCachedPriceFollowsProvider(t *testing.T) {
+	fresh := time.Now().Add(-time.Minute)
+	expired := time.Now().Add(-2 * priceCacheTTL)
+
+	tests := []struct {
+		name      string
+		stored    [][]driver.Value
+		preferred string
+		down      bool
+		want      string
+		provider  string
+		fetched   bool
+	}{
+		{
+			name:   "fresh price of the default provider",
+			stored: [][]driver.Value{{"AAPL", "190.00", fresh, "primary"}},
+			want:   "190.00", provider: "primary",
+		},
+		{
+			// Another provider's price is not the asset's provider's price
+			name:      "asset with its own provider",
+			stored:    [][]driver.Value{{"AAPL", "190.00", fresh, "primary"}},
+			preferred: "own", want: "100.00", provider: "own", fetched: true,
+		},
+		{
+			name:      "fresh price of the asset's provider",
+			stored:    [][]driver.Value{{"AAPL", "190.00", fresh, "primary"}, {"AAPL", "185.00", fresh, "own"}},
+			preferred: "own", want: "185.00", provider: "own",
+		},
+		{
+			// The failover priced it while the default provider was down
+			name:   "fresh price of a failover provider",
+			stored: [][]driver.Value{{"AAPL", "191.00", fresh, "secondary"}, {"AAPL", "180.00", expired, "primary"}},
+			want:   "191.00", provider: "secondary",
+		},
+		{
+			name:   "expired prices",
+			stored: [][]driver.Value{{"AAPL", "180.00", expired, "primary"}},
+			want:   "100.00", provider: "primary", fetched: true,
+		},
+		{
+			// The last known price may come from any provider
+			name:      "every provider down",
+			stored:    [][]driver.Value{{"AAPL", "190.00", fresh, "primary"}, {"AAPL", "180.00", expired, "own"}},
+			preferred: "own", down: true, want: "190.00", provider: PriceSourceLastKnown,
+		},
+	}
+	for _, tt := range tests {
+		for _, batch := range []bool{false, true} {
+			db, fake := newFakeDB(t)
+			fake.returns("FROM stock_prices WHERE symbol = ANY($1)", []string{"symbol", "price", "last_updated", "provider"}, tt.stored...)
+			fake.on("INSERT INTO stock_prices", func([]driver.Value) (fakeRows, error) { return fakeRows{affected: 1}, nil })
+
+			own, secondary := &stubProvider{name: "own"}, &stubProvider{name: "secondary"}
+			if tt.down {
+				own.err = errors.New("service unavailable")
+				secondary.err = own.err
+			}
+			s := newStubMarketData(&stubProvider{name: "primary"}, secondary)
+			s.UseProvider(own)
+			s.maxConcurrency = 1
+			s.db = db
+
+			var quote *Quote
+			if batch {
+				quote = s.GetQuotes(tt.preferred, []string{"AAPL"})["AAPL"]
+			} else {
+				var err error
+				if quote, err = s.GetQuote(tt.preferred, "AAPL"); err != nil {
+					t.Errorf("%s: unexpected error %v", tt.name, err)
+					continue
+				}
+			}
+			if quote == nil || quote.Price.String() != tt.want || quote.Provider != tt.provider {
+				t.Errorf("%s (batch %v): quote %+v, want %s from %s", tt.name, batch, quote, tt.want, tt.provider)
+				continue
+			}
+
+			stores := fake.executed("INSERT INTO stock_prices")
+			if tt.fetched != (len(stores) == 1) {
+				t.Errorf("%s (batch %v): %d prices stored, want fetched %v", tt.name, batch, len(stores), tt.fetched)
+			} else if tt.fetched && stores[0].args[2] != tt.provider {
+				t.Errorf("%s (batch %v): price stored for %v, want %s", tt.name, batch, stores[0].args[2], tt.provider)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"log"
+	"time"
+	_ "time/tzdata" // the API image ships without system time zone data
+)
+
+// marketLocation is the time zone of the US equity exchanges
+var marketLocation = loadMarketLocation()
+
+func loadMarketLocation() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		log.Printf("[MarketData] Failed to load market time zone, using UTC: %v", err)
+		return time.UTC
+	}
+	return loc
+}
+
+// Regular trading session of the US equity exchanges, in exchange local time
+const (
+	marketOpenMinute  = 9*60 + 30
+	marketCloseMinute = 16 * 60
+)
+
+// IsMarketOpen reports whether t falls inside the regular US trading session.
+// Exchange holidays are not modelled; a refresh on a holiday is harmless.
+func IsMarketOpen(t time.Time) bool {
+	local := t.In(marketLocation)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	return minute >= marketOpenMinute && minute < marketCloseMinute
+}
+
+// LastMarketClose returns the most recent session close at or before t
+func LastMarketClose(t time.Time) time.Time {
+	local := t.In(marketLocation)
+	close := time.Date(local.Year(), local.Month(), local.Day(), marketCloseMinute/60, marketCloseMinute%60, 0, 0, marketLocation)
+	if close.After(local) {
+		close = close.AddDate(0, 0, -1)
+	}
+	for close.Weekday() == time.Saturday || close.Weekday() == time.Sunday {
+		close = close.AddDate(0, 0, -1)
+	}
+	return close
+}
+
+// navPublishDelay is how long after the close fund NAVs are usually published
+const navPublishDelay = 3 * time.Hour
+
+// NAVDue is a scheduler due function for fund prices, which are struck once
+// a day: run once after each session's NAVs are published
+func NAVDue(now, lastSuccess time.Time) bool {
+	published := LastMarketClose(now.Add(-navPublishDelay)).Add(navPublishDelay)
+	return lastSuccess.Before(published)
+}
+
+// MarketHoursDue is a scheduler due function for market data jobs: run on
+// every tick while the market is open, and once more after it closes so the
+// closing price is captured
+func MarketHoursDue(now, lastSuccess time.Time) bool {
+	if IsMarketOpen(now) {
+		return true
+	}
+	return lastSuccess.Before(LastMarketClose(now))
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+const (
+	// maxPayoffMonths ends a simulation whose payments never clear the debts
+	maxPayoffMonths = 1200
+
+	// A debt without a set minimum pays a card-style minimum: the month's
+	// interest plus cardMinimumPercent of the balance, at least
+	// cardMinimumFloorUSD converted into the plan's currency
+	cardMinimumPercent  = 1
+	cardMinimumFloorUSD = 25
+)
+
+// ErrInvalidPayoffPlan is returned when a payoff plan cannot be simulated as requested
+var ErrInvalidPayoffPlan = errors.New("invalid payoff plan")
+
+// PayoffDebt is a debt as the payoff planner sees it, in the plan's currency
+type PayoffDebt struct {
+	ID           string
+	Name         string
+	Balance      money.Decimal
+	InterestRate float64
+	// Minimum is the fixed monthly minimum payment; nil uses a card-style minimum
+	Minimum *money.Decimal
+	// RateChanges are the known changes to InterestRate, oldest first
+	RateChanges []models.RateChange
+}
+
+// rateOn returns the rate a debt is charged for the month starting on a date
+func (d *PayoffDebt) rateOn(date time.Time) float64 {
+	rate := d.InterestRate
+	for _, change := range d.RateChanges {
+		if change.Date.After(date) {
+			break
+		}
+		rate = change.Rate
+	}
+	return rate
+}
+
+// CardMinimumFloor returns the least a card-style minimum payment is, in the
+// converter's currency at today's rate
+func CardMinimumFloor(converter *Converter) (money.Decimal, error) {
+	return converter.Convert(money.NewFromInt(cardMinimumFloorUSD), "USD")
+}
+
+// payoffState tracks one debt through a simulation
+type payoffState struct {
+	debt     *PayoffDebt
+	balance  money.Decimal
+	interest money.Decimal
+	paid     money.Decimal
+	month    int
+}
+
+// PlanPayoff simulates repaying every debt month by month from start with a
+// fixed monthly budget. Each month interest is added at the debt's rate / 12
+// (the rate in effect when the month starts), every debt gets its minimum
+// payment, and whatever is left of the budget (including the minimums of
+// debts already paid off) goes to the debts in the strategy's order. The
+// plan is compared with paying only the minimums. The budget must cover the
+// minimums every month, including after a rate rises. cardFloor is the
+// CardMinimumFloor in the plan's currency.
+func PlanPayoff(debts []PayoffDebt, budget money.Decimal, strategy models.PayoffStrategy, order []string, currency string, cardFloor money.Decimal, start time.Time) (*models.PayoffPlan, error) {
+	if !strategy.Valid() {
+		return nil, fmt.Errorf("%w: unknown strategy %q (use snowball, avalanche or custom)", ErrInvalidPayoffPlan, strategy)
+	}
+	if !budget.IsPositive() {
+		return nil, fmt.Errorf("%w: monthly_budget must be positive", ErrInvalidPayoffPlan)
+	}
+
+	var open []PayoffDebt
+	for _, debt := range debts {
+		if debt.Balance.IsPositive() {
+			open = append(open, debt)
+		}
+	}
+	priority, err := payoffPriority(open, strategy, order)
+	if err != nil {
+		return nil, err
+	}
+
+	minimum := money.Zero
+	for i := range open {
+		interest := monthlyInterest(open[i].Balance, open[i].InterestRate, currency)
+		minimum = minimum.Add(minimumPayment(&open[i], open[i].Balance, interest, currency, cardFloor))
+	}
+	if budget.LessThan(minimum) {
+		return nil, fmt.Errorf("%w: monthly_budget %s is below the %s of minimum payments", ErrInvalidPayoffPlan, budget.String(), minimum.String())
+	}
+
+	start = truncateToDate(start)
+	planned, summary, err := simulatePayoff(open, priority, &budget, currency, cardFloor, start)
+	if err != nil {
+		return nil, err
+	}
+	baseline, minimumOnly, err := simulatePayoff(open, priority, nil, currency, cardFloor, start)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &models.PayoffPlan{
+		Strategy:       strategy,
+		Currency:       currency,
+		MonthlyBudget:  budget,
+		MinimumPayment: minimum,
+		PayoffSummary:  summary,
+		MinimumOnly:    minimumOnly,
+		InterestSaved:  minimumOnly.TotalInterest.Sub(summary.TotalInterest),
+		MonthsSaved:    minimumOnly.Months - summary.Months,
+		Debts:          make([]models.DebtPayoff, 0, len(open)),
+		CalculatedAt:   time.Now(),
+	}
+
+	for rank, i := range priority {
+		debt := &open[i]
+		interest := monthlyInterest(debt.Balance, debt.InterestRate, currency)
+		payoff := models.DebtPayoff{
+			DebtID:              debt.ID,
+			Name:                debt.Name,
+			Priority:            rank + 1,
+			Balance:             debt.Balance,
+			InterestRate:        debt.InterestRate,
+			MinimumPayment:      minimumPayment(debt, debt.Balance, interest, currency, cardFloor),
+			PayoffMonth:         planned[i].month,
+			TotalInterest:       planned[i].interest,
+			TotalPaid:           planned[i].paid,
+			MinimumOnlyInterest: baseline[i].interest,
+		}
+		if planned[i].month > 0 {
+			payoff.PayoffDate = addMonthsClamped(start, planned[i].month)
+		}
+		if baseline[i].month > 0 {
+			date := addMonthsClamped(start, baseline[i].month)
+			payoff.MinimumOnlyPayoffDate = &date
+		}
+		plan.Debts = append(plan.Debts, payoff)
+	}
+	return plan, nil
+}
+
+// payoffPriority returns the indexes of debts in the order extra payments go
+// to them: smallest balance first for snowball, highest rate first for
+// avalanche, and the given IDs first for custom, the rest in avalanche order
+func payoffPriority(debts []PayoffDebt, strategy models.PayoffStrategy, order []string) ([]int, error) {
+	priority := make([]int, len(debts))
+	for i := range priority {
+		priority[i] = i
+	}
+
+	avalanche := func(a, b int) bool {
+		if debts[a].InterestRate != debts[b].InterestRate {
+			return debts[a].InterestRate > debts[b].InterestRate
+		}
+		return debts[a].Balance.LessThan(debts[b].Balance)
+	}
+
+	switch strategy {
+	case models.PayoffSnowball:
+		sort.SliceStable(priority, func(i, j int) bool {
+			a, b := priority[i], priority[j]
+			if !debts[a].Balance.Equal(debts[b].Balance) {
+				return debts[a].Balance.LessThan(debts[b].Balance)
+			}
+			return debts[a].InterestRate > debts[b].InterestRate
+		})
+	case models.PayoffAvalanche:
+		sort.SliceStable(priority, func(i, j int) bool { return avalanche(priority[i], priority[j]) })
+	case models.PayoffCustom:
+		if len(order) == 0 {
+			return nil, fmt.Errorf("%w: the custom strategy needs an order of debt IDs", ErrInvalidPayoffPlan)
+		}
+		rank := make(map[string]int, len(order))
+		for i, id := range order {
+			rank[id] = i
+		}
+		known := make(map[string]bool, len(debts))
+		for _, debt := range debts {
+			known[debt.ID] = true
+		}
+		for _, id := range order {
+			if !known[id] {
+				return nil, fmt.Errorf("%w: order lists %s, which is not an open debt", ErrInvalidPayoffPlan, id)
+			}
+		}
+		sort.SliceStable(priority, func(i, j int) bool {
+			a, b := priority[i], priority[j]
+			rankA, listedA := rank[debts[a].ID]
+			rankB, listedB := rank[debts[b].ID]
+			switch {
+			case listedA && listedB:
+				return rankA < rankB
+			case listedA != listedB:
+				return listedA
+			}
+			return avalanche(a, b)
+		})
+	}
+	return priority, nil
+}
+
+// simulatePayoff repays the debts month by month. With a budget, what is
+// left after the minimums goes to the debts in priority order, and a month
+// whose minimums exceed the budget (a rate rise can raise them) is an
+// error; without one only the minimums are paid.
+func simulatePayoff(debts []PayoffDebt, priority []int, budget *money.Decimal, currency string, cardFloor money.Decimal, start time.Time) ([]payoffState, models.PayoffSummary, error) {
+	states := make([]payoffState, len(debts))
+	for i := range debts {
+		states[i] = payoffState{debt: &debts[i], balance: debts[i].Balance, interest: money.Zero, paid: money.Zero}
+	}
+
+	summary := models.PayoffSummary{TotalInterest: money.Zero, TotalPaid: money.Zero}
+	remaining := len(states)
+	month := 0
+	for month = 1; remaining > 0 && month <= maxPayoffMonths; month++ {
+		spent := money.Zero
+		monthStart := addMonthsClamped(start, month-1)
+		for i := range states {
+			state := &states[i]
+			if !state.balance.IsPositive() {
+				continue
+			}
+			interest := monthlyInterest(state.balance, state.debt.rateOn(monthStart), currency)
+			payment := minimumPayment(state.debt, state.balance, interest, currency, cardFloor)
+			state.balance = state.balance.Add(interest).Sub(payment)
+			state.interest = state.interest.Add(interest)
+			state.paid = state.paid.Add(payment)
+			spent = spent.Add(payment)
+		}
+
+		if budget != nil {
+			if budget.LessThan(spent) {
+				return nil, models.PayoffSummary{}, fmt.Errorf("%w: monthly_budget %s is below the %s of minimum payments due from %s",
+					ErrInvalidPayoffPlan, budget.String(), spent.String(), monthStart.Format("2006-01-02"))
+			}
+			extra := budget.Sub(spent)
+			for _, i := range priority {
+				state := &states[i]
+				if !extra.IsPositive() {
+					break
+				}
+				if !state.balance.IsPositive() {
+					continue
+				}
+				payment := extra
+				if state.balance.LessThan(payment) {
+					payment = state.balance
+				}
+				state.balance = state.balance.Sub(payment)
+				state.paid = state.paid.Add(payment)
+				extra = extra.Sub(payment)
+			}
+		}
+
+		for i := range states {
+			if states[i].month == 0 && !states[i].balance.IsPositive() {
+				states[i].month = month
+				remaining--
+			}
+		}
+	}
+
+	summary.Months = month - 1
+	summary.PaidOff = remaining == 0
+	if summary.PaidOff && summary.Months > 0 {
+		date := addMonthsClamped(start, summary.Months)
+		summary.PayoffDate = &date
+	}
+	for _, state := range states {
+		summary.TotalInterest = summary.TotalInterest.Add(state.interest)
+		summary.TotalPaid = summary.TotalPaid.Add(state.paid)
+	}
+	return states, summary, nil
+}
+
+// monthlyInterest returns a month of interest on a balance at an annual rate
+func monthlyInterest(balance money.Decimal, rate float64, currency string) money.Decimal {
+	return money.RoundTo(balance.Mul(money.NewFromFloat(rate)).Div(money.NewFromInt(1200), amortizationPlaces), currency)
+}
+
+// minimumPayment returns the minimum due on a debt for a month, at most
+// what is owed. A card-style minimum is at least cardFloor.
+func minimumPayment(debt *PayoffDebt, balance, interest money.Decimal, currency string, cardFloor money.Decimal) money.Decimal {
+	var payment money.Decimal
+	if debt.Minimum != nil {
+		payment = *debt.Minimum
+	} else {
+		payment = money.RoundTo(balance.Mul(money.NewFromInt(cardMinimumPercent)).Div(money.NewFromInt(100), amortizationPlaces), currency).Add(interest)
+		if payment.LessThan(cardFloor) {
+			payment = cardFloor
+		}
+	}
+	if owed := balance.Add(interest); owed.LessThan(payment) {
+		payment = owed
+	}
+	return payment
+}
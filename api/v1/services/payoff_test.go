@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func TestPayoffPriority(t *testing.T) {
+	debts := []PayoffDebt{
+		{ID: "card", Balance: money.MustParse("500"), InterestRate: 20},
+		{ID: "store", Balance: money.MustParse("3000"), InterestRate: 25},
+		{ID: "car", Balance: money.MustParse("1500"), InterestRate: 5},
+	}
+
+	tests := []struct {
+		strategy models.PayoffStrategy
+		order    []string
+		want     []string
+		wantErr  bool
+	}{
+		{strategy: models.PayoffSnowball, want: []string{"card", "car", "store"}},
+		{strategy: models.PayoffAvalanche, want: []string{"store", "card", "car"}},
+		// Debts missing from the order follow in avalanche order
+		{strategy: models.PayoffCustom, order: []string{"car"}, want: []string{"car", "store", "card"}},
+		{strategy: models.PayoffCustom, order: []string{"card", "car", "store"}, want: []string{"card", "car", "store"}},
+		{strategy: models.PayoffCustom, wantErr: true},
+		{strategy: models.PayoffCustom, order: []string{"boat"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		priority, err := payoffPriority(debts, tt.strategy, tt.order)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidPayoffPlan) {
+				t.Errorf("%s %v: got error %v, want ErrInvalidPayoffPlan", tt.strategy, tt.order, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %v: unexpected error %v", tt.strategy, tt.order, err)
+			continue
+		}
+		got := make([]string, len(priority))
+		for i, index := range priority {
+			got[i] = debts[index].ID
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s %v: order %v, want %v", tt.strategy, tt.order, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s %v: order %v, want %v", tt.strategy, tt.order, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPlanPayoff(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	loan := PayoffDebt{ID: "loan", Balance: money.MustParse("1000"), InterestRate: 12, Minimum: decimalPtr("100")}
+
+	plan, err := PlanPayoff([]PayoffDebt{loan}, money.MustParse("200"), models.PayoffAvalanche, nil, "USD", money.NewFromInt(25), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10.00, 8.10, 6.18, 4.24, 2.29 and 0.31 of interest, then the last 31.12 clears it
+	if plan.Months != 6 || plan.TotalInterest.String() != "31.12" || plan.TotalPaid.String() != "1031.12" {
+		t.Errorf("plan = %d months, %s interest, %s paid; want 6 months, 31.12 interest, 1031.12 paid",
+			plan.Months, plan.TotalInterest, plan.TotalPaid)
+	}
+	if plan.PayoffDate == nil || !plan.PayoffDate.Equal(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("payoff date = %v, want 2024-07-01", plan.PayoffDate)
+	}
+	if plan.MinimumOnly.Months != 11 || plan.MonthsSaved != 5 {
+		t.Errorf("minimum only = %d months (%d saved), want 11 months (5 saved)", plan.MinimumOnly.Months, plan.MonthsSaved)
+	}
+	if !plan.InterestSaved.IsPositive() {
+		t.Errorf("interest saved = %s, want a positive amount", plan.InterestSaved)
+	}
+}
+
+func TestPlanPayoffMinimumsAfterRateRise(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// A 0% promotion ends in March and the card-style minimum jumps from the
+	// 25 floor to 1% of the balance plus a month of 29.99% interest
+	card := PayoffDebt{
+		ID:          "card",
+		Balance:     money.MustParse("2000"),
+		RateChanges: []models.RateChange{{Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Rate: 29.99}},
+	}
+
+	tests := []struct {
+		budget  string
+		wantErr bool
+	}{
+		{budget: "40", wantErr: true},
+		{budget: "100"},
+	}
+	for _, tt := range tests {
+		_, err := PlanPayoff([]PayoffDebt{card}, money.MustParse(tt.budget), models.PayoffSnowball, nil, "USD", money.NewFromInt(25), start)
+		if tt.wantErr && !errors.Is(err, ErrInvalidPayoffPlan) {
+			t.Errorf("budget %s: got error %v, want ErrInvalidPayoffPlan", tt.budget, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("budget %s: unexpected error %v", tt.budget, err)
+		}
+	}
+}
+
+func TestCardMinimumFloor(t *testing.T) {
+	tests := []struct {
+		currency string
+		rate     string
+		want     string
+	}{
+		{currency: "USD", want: "25.00"},
+		{currency: "JPY", rate: "150.12", want: "3753"},
+		{currency: "EUR", rate: "0.92", want: "23.00"},
+		{currency: "KWD", rate: "0.3071", want: "7.678"},
+	}
+	for _, tt := range tests {
+		converter := &Converter{target: tt.currency, rates: map[string]money.Decimal{}}
+		if tt.rate != "" {
+			converter.rates["USD"] = money.MustParse(tt.rate)
+		}
+		floor, err := CardMinimumFloor(converter)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.currency, err)
+			continue
+		}
+		if floor.String() != tt.want {
+			t.Errorf("%s: card minimum floor %s, want %s", tt.currency, floor, tt.want)
+		}
+	}
+
+	// A yen card pays the converted floor, not 25 yen
+	card := PayoffDebt{ID: "card", Balance: money.MustParse("100000")}
+	plan, err := PlanPayoff([]PayoffDebt{card}, money.MustParse("5000"), models.PayoffSnowball, nil, "JPY", money.MustParse("3753"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.MinimumPayment.String() != "3753" || plan.MinimumOnly.Months != 27 {
+		t.Errorf("minimum %s paid off in %d months, want 3753 over 27 months", plan.MinimumPayment, plan.MinimumOnly.Months)
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"personal-finance/api/v1/money"
+)
+
+const (
+	// PriceRefreshJobName is the scheduler name of the stock price refresh job
+	PriceRefreshJobName = "price_refresh"
+	// CryptoPriceRefreshJobName is the scheduler name of the crypto price
+	// refresh job, which runs around the clock
+	CryptoPriceRefreshJobName = "crypto_price_refresh"
+	// NAVRefreshJobName is the scheduler name of the fund price refresh job,
+	// which runs once a day after NAVs are published
+	NAVRefreshJobName = "nav_refresh"
+)
+
+// PriceRefreshResult summarizes one run of the price refresh job
+type PriceRefreshResult struct {
+	Symbols        int               `json:"symbols"`
+	AssetsUpdated  int               `json:"assets_updated"`
+	HistoryWritten int               `json:"history_written"`
+	Providers      map[string]string `json:"providers"`
+	Failed         []string          `json:"failed"`
+}
+
+// refreshTarget is a market-priced asset considered by the refresh job
+type refreshTarget struct {
+	id       string
+	symbol   string
+	provider string
+}
+
+// RefreshAssetPrices fetches fresh quotes for every market_api stock asset,
+// bypassing the price cache, stores them as the assets' current value and
+// records today's value in asset_history
+func (s *MarketDataService) RefreshAssetPrices() (*PriceRefreshResult, error) {
+	return s.refreshPrices("stock")
+}
+
+// RefreshCryptoPrices does the same as RefreshAssetPrices for market_api crypto assets
+func (s *MarketDataService) RefreshCryptoPrices() (*PriceRefreshResult, error) {
+	return s.refreshPrices("crypto")
+}
+
+// RefreshFundPrices does the same as RefreshAssetPrices for market_api funds,
+// whose price is the NAV struck once a day (or the ETF's close)
+func (s *MarketDataService) RefreshFundPrices() (*PriceRefreshResult, error) {
+	return s.refreshPrices("investment")
+}
+
+// refreshPrices refreshes the market_api assets of one type
+func (s *MarketDataService) refreshPrices(assetType string) (*PriceRefreshResult, error) {
+	query := `
+		SELECT id, COALESCE(symbol, ''), COALESCE(provider, '')
+		FROM assets
+		WHERE type = $1 AND source = 'market_api'
+	`
+	rows, err := s.db.Query(query, assetType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch assets: %w", err)
+	}
+
+	var targets []refreshTarget
+	symbolsByProvider := make(map[string][]string)
+	for rows.Next() {
+		var t refreshTarget
+		if err := rows.Scan(&t.id, &t.symbol, &t.provider); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse assets: %w", err)
+		}
+		if !IsMarketPriced(assetType, "market_api", t.symbol) {
+			continue
+		}
+		t.provider = s.PreferredProvider(assetType, t.provider)
+		targets = append(targets, t)
+		symbolsByProvider[t.provider] = append(symbolsByProvider[t.provider], t.symbol)
+	}
+	rows.Close()
+
+	result := &PriceRefreshResult{
+		Providers: make(map[string]string),
+		Failed:    []string{},
+	}
+
+	quotesByProvider := make(map[string]map[string]*Quote)
+	for provider, symbols := range symbolsByProvider {
+		quotesByProvider[provider] = s.fetchQuotes(provider, symbols, false)
+	}
+
+	today := time.Now()
+	seenSymbols := make(map[string]bool)
+	for _, t := range targets {
+		quote, ok := quotesByProvider[t.provider][t.symbol]
+		if !seenSymbols[t.symbol] {
+			seenSymbols[t.symbol] = true
+			result.Symbols++
+			if !ok || quote.Stale {
+				result.Failed = append(result.Failed, t.symbol)
+			}
+		}
+		if !ok || quote.Stale {
+			continue
+		}
+		result.Providers[t.symbol] = quote.Provider
+
+		if err := s.storeAssetPrice(t.id, quote.Price, today); err != nil {
+			log.Printf("[PriceRefresh] Failed to store price for asset %s (%s): %v", t.id, t.symbol, err)
+			continue
+		}
+		result.AssetsUpdated++
+		result.HistoryWritten++
+	}
+
+	if result.Symbols > 0 && len(result.Failed) == result.Symbols {
+		return result, fmt.Errorf("no prices could be refreshed")
+	}
+	return result, nil
+}
+
+// storeAssetPrice updates an asset's current value and its history row for the day
+func (s *MarketDataService) storeAssetPrice(assetID string, price money.Decimal, date time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`UPDATE assets SET current_value = $1, updated_at = $2 WHERE id = $3`,
+		price, time.Now(), assetID,
+	); err != nil {
+		return err
+	}
+
+	if err := upsertAssetHistory(tx, assetID, price, date); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// upsertAssetHistory records an asset's value for a date, replacing any existing row for that date
+func upsertAssetHistory(db execer, assetID string, value money.Decimal, date time.Time) error {
+	query := `
+		INSERT INTO asset_history (id, asset_id, value, date, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (asset_id, date) DO UPDATE SET value = $3
+	`
+	_, err := db.Exec(query, uuid.New().String(), assetID, value, date.Format("2006-01-02"), time.Now())
+	return err
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"personal-finance/api/v1/models"
+)
+
+// ApplyMarketPrices replaces the stored value of every market-priced asset
+// with a live quote. Symbols are fetched in one batch per provider, so a
+// portfolio holding the same ticker in several accounts fetches it once.
+// Only the given slice is changed; stored values are updated by the
+// background price refresh job.
+func (s *MarketDataService) ApplyMarketPrices(assets []models.Asset) {
+	symbolsByProvider := make(map[string][]string)
+	for _, asset := range assets {
+		if IsMarketPriced(string(asset.Type), string(asset.Source), asset.Symbol) {
+			provider := s.PreferredProvider(string(asset.Type), asset.Provider)
+			symbolsByProvider[provider] = append(symbolsByProvider[provider], asset.Symbol)
+		}
+	}
+
+	for provider, symbols := range symbolsByProvider {
+		quotes := s.GetQuotes(provider, symbols)
+
+		for i := range assets {
+			asset := &assets[i]
+			if !IsMarketPriced(string(asset.Type), string(asset.Source), asset.Symbol) ||
+				s.PreferredProvider(string(asset.Type), asset.Provider) != provider {
+				continue
+			}
+
+			quote, ok := quotes[asset.Symbol]
+			if !ok {
+				// If every source failed, keep the stored value
+				asset.PriceProvider = PriceSourceStored
+				asset.PriceStale = true
+				continue
+			}
+			asset.CurrentValue = quote.Price
+			asset.PriceProvider = quote.Provider
+			asset.PriceStale = quote.Stale
+		}
+	}
+}
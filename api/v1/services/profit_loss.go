@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// PeriodStart returns the baseline date of a summary period: the change is
+// measured against the last recorded value on or before that date
+func PeriodStart(period string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch period {
+	case "1d":
+		return today.AddDate(0, 0, -1), nil
+	case "1w":
+		return today.AddDate(0, 0, -7), nil
+	case "1m":
+		// A month before the 31st is the end of a shorter month
+		return addMonthsClamped(today, -1), nil
+	case "ytd":
+		// Last close of the previous year
+		return time.Date(today.Year()-1, time.December, 31, 0, 0, 0, 0, time.UTC), nil
+	case "1y":
+		return addMonthsClamped(today, -12), nil
+	default:
+		return time.Time{}, fmt.Errorf("Invalid period (use 1d, 1w, 1m, ytd or 1y)")
+	}
+}
+
+// ProfitLossSince returns the change in net worth since the given date,
+// split into price and FX gains: the change in value of the assets less the
+// change in what is owed on debts.
+//
+// Each holding is compared with its last asset_history value on or before
+// that date, converted at that date's rate; holdings bought afterwards are
+// compared with their buy price at the purchase date's rate, and holdings
+// without earlier history keep their price but can still move with the
+// exchange rate. Debts are compared the same way with their debt_history
+// balance, or their principal when they started afterwards.
+func ProfitLossSince(db querier, assets []models.Asset, since time.Time, converter *Converter) (models.GainBreakdown, error) {
+	gains, err := assetChangeSince(db, assets, since, converter)
+	if err != nil {
+		return models.GainBreakdown{}, err
+	}
+
+	debts, err := debtChangeSince(db, since, converter)
+	if err != nil {
+		return models.GainBreakdown{}, err
+	}
+	gains.PriceGain = gains.PriceGain.Sub(debts.PriceGain)
+	gains.FXGain = gains.FXGain.Sub(debts.FXGain)
+	return gains, nil
+}
+
+// assetChangeSince returns the change in value of the assets since the given date
+func assetChangeSince(db querier, assets []models.Asset, since time.Time, converter *Converter) (models.GainBreakdown, error) {
+	query := `
+		SELECT DISTINCT ON (asset_id) asset_id, value
+		FROM asset_history
+		WHERE date <= $1
+		ORDER BY asset_id, date DESC
+	`
+	rows, err := db.Query(query, since.Format("2006-01-02"))
+	if err != nil {
+		return models.GainBreakdown{}, err
+	}
+	defer rows.Close()
+
+	baselines := make(map[string]money.Decimal)
+	for rows.Next() {
+		var assetID string
+		var value money.Decimal
+		if err := rows.Scan(&assetID, &value); err != nil {
+			return models.GainBreakdown{}, err
+		}
+		baselines[assetID] = value
+	}
+
+	var gains models.GainBreakdown
+	for _, asset := range assets {
+		baselineDate := since
+		baseline, ok := baselines[asset.ID]
+		if !ok {
+			if asset.PurchaseDate.After(since) {
+				baseline = asset.BuyPrice
+				baselineDate = asset.PurchaseDate
+			} else {
+				baseline = asset.CurrentValue
+			}
+		}
+
+		priceGain, fxGain, err := converter.Gain(asset.Currency, money.RoundTo(baseline.Mul(asset.Quantity), asset.Currency), baselineDate, asset.TotalValue())
+		if err != nil {
+			return models.GainBreakdown{}, err
+		}
+		gains.Add(models.GainBreakdown{PriceGain: priceGain, FXGain: fxGain})
+	}
+
+	return gains, nil
+}
+
+// debtChangeSince returns the change in what is owed on debts since the
+// given date, accrued interest included
+func debtChangeSince(db querier, since time.Time, converter *Converter) (models.GainBreakdown, error) {
+	query := `
+		SELECT COALESCE(d.currency, ''), d.principal, d.current_value + COALESCE(d.accrued_interest, 0), d.start_date, (
+			SELECT h.balance FROM debt_history h
+			WHERE h.debt_id = d.id AND h.date <= $1
+			ORDER BY h.date DESC LIMIT 1
+		)
+		FROM debts d
+	`
+	rows, err := db.Query(query, since.Format("2006-01-02"))
+	if err != nil {
+		return models.GainBreakdown{}, err
+	}
+	defer rows.Close()
+
+	var change models.GainBreakdown
+	for rows.Next() {
+		var currency string
+		var principal, balance money.Decimal
+		var startDate time.Time
+		var recorded *money.Decimal
+		if err := rows.Scan(&currency, &principal, &balance, &startDate, &recorded); err != nil {
+			return models.GainBreakdown{}, err
+		}
+
+		baseline, baselineDate := balance, since
+		switch {
+		case recorded != nil:
+			baseline = *recorded
+		case startDate.After(since):
+			baseline, baselineDate = principal, startDate
+		}
+
+		priceChange, fxChange, err := converter.Gain(currency, baseline, baselineDate, balance)
+		if err != nil {
+			return models.GainBreakdown{}, err
+		}
+		change.Add(models.GainBreakdown{PriceGain: priceChange, FXGain: fxChange})
+	}
+	return change, rows.Err()
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+func TestPeriodStart(t *testing.T) {
+	tests := []struct {
+		period string
+		now    time.Time
+		want   string
+	}{
+		{period: "1d", now: time.Date(2024, time.March, 1, 18, 30, 0, 0, time.UTC), want: "2024-02-29"},
+		// A week back crosses the month and the year
+		{period: "1w", now: time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC), want: "2024-02-26"},
+		{period: "1w", now: time.Date(2024, time.January, 3, 9, 0, 0, 0, time.UTC), want: "2023-12-27"},
+		{period: "1m", now: time.Date(2024, time.May, 15, 9, 0, 0, 0, time.UTC), want: "2024-04-15"},
+		// A month before the 31st is the end of the shorter month, not a day in this one
+		{period: "1m", now: time.Date(2024, time.March, 31, 9, 0, 0, 0, time.UTC), want: "2024-02-29"},
+		{period: "1m", now: time.Date(2023, time.March, 31, 9, 0, 0, 0, time.UTC), want: "2023-02-28"},
+		{period: "1m", now: time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC), want: "2023-12-31"},
+		// The year to date is measured from the last close of the previous year
+		{period: "ytd", now: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), want: "2023-12-31"},
+		{period: "ytd", now: time.Date(2024, time.December, 31, 23, 59, 0, 0, time.UTC), want: "2023-12-31"},
+		{period: "1y", now: time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC), want: "2023-02-28"},
+	}
+	for _, tt := range tests {
+		got, err := PeriodStart(tt.period, tt.now)
+		if err != nil {
+			t.Errorf("PeriodStart(%s, %s): unexpected error %v", tt.period, tt.now, err)
+			continue
+		}
+		if got.Format("2006-01-02") != tt.want {
+			t.Errorf("PeriodStart(%s, %s) = %s, want %s", tt.period, tt.now.Format("2006-01-02"), got.Format("2006-01-02"), tt.want)
+		}
+	}
+
+	if _, err := PeriodStart("2w", time.Now()); err == nil {
+		t.Errorf("PeriodStart(2w) succeeded, want an error")
+	}
+}
+
+func TestProfitLossSince(t *testing.T) {
+	since := mustDate("2024-06-03")
+	converter := &Converter{
+		target:  "USD",
+		rates:   map[string]money.Decimal{"EUR": money.MustParse("1.10")},
+		ratesOn: map[string]money.Decimal{"EUR@2024-06-03": money.MustParse("1.05")},
+	}
+
+	assets := []models.Asset{
+		// 10 units from 100.00 to 120.00
+		{ID: "held", Currency: "USD", PurchaseDate: mustDate("2023-01-10"), BuyPrice: money.MustParse("80.00"), CurrentValue: money.MustParse("120.00"), Quantity: money.MustParse("10")},
+		// Bought during the window: measured from the buy price
+		{ID: "bought", Currency: "USD", PurchaseDate: mustDate("2024-06-05"), BuyPrice: money.MustParse("50.00"), CurrentValue: money.MustParse("60.00"), Quantity: money.MustParse("2")},
+		// No history: the price stands still but the euro rose
+		{ID: "unpriced", Currency: "EUR", PurchaseDate: mustDate("2023-01-10"), BuyPrice: money.MustParse("90.00"), CurrentValue: money.MustParse("100.00"), Quantity: money.MustParse("1")},
+	}
+
+	db, fake := newFakeDB(t)
+	fake.returns("FROM asset_history", []string{"asset_id", "value"},
+		[]driver.Value{"held", "100.00"},
+	)
+	fake.returns("FROM debts d", []string{"currency", "principal", "balance", "start_date", "recorded"},
+		// Paid down from 1000.00 to 955.00 with interest
+		[]driver.Value{"USD", "5000.00", "955.00", mustDate("2020-01-01"), "1000.00"},
+		// Started during the window: only the interest since is new debt
+		[]driver.Value{"USD", "500.00", "502.50", mustDate("2024-06-10"), nil},
+		// No history: the balance stands still but costs more in dollars
+		[]driver.Value{"EUR", "300.00", "200.00", mustDate("2022-01-01"), nil},
+	)
+
+	gains, err := ProfitLossSince(db, assets, since, converter)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Assets: 200.00 + 20.00 in price, 5.00 in FX. Debts: 45.00 repaid,
+	// 2.50 accrued, 10.00 more owed through the euro.
+	if gains.PriceGain.String() != "262.50" || gains.FXGain.String() != "-5.00" {
+		t.Errorf("price and FX gains = %s and %s, want 262.50 and -5.00", gains.PriceGain, gains.FXGain)
+	}
+
+	for _, fragment := range []string{"FROM asset_history", "FROM debts d"} {
+		queries := fake.executed(fragment)
+		if len(queries) != 1 || queries[0].args[0] != "2024-06-03" {
+			t.Errorf("%s queried with %v, want once as of 2024-06-03", fragment, queries)
+		}
+	}
+}
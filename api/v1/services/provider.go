@@ -0,0 +1,204 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+// Quote is a single price observation returned by a provider
+type Quote struct {
+	Symbol    string        `json:"symbol"`
+	Price     money.Decimal `json:"price"`
+	Currency  string        `json:"currency,omitempty"`
+	Exchange  string        `json:"exchange,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	Provider  string        `json:"provider"`
+	Stale     bool          `json:"stale,omitempty"`
+}
+
+// Bar is a daily closing price for a symbol
+type Bar struct {
+	Date  time.Time     `json:"date"`
+	Close money.Decimal `json:"close"`
+}
+
+// Dividend is a cash distribution per share, keyed by its ex-dividend date
+type Dividend struct {
+	Date   time.Time     `json:"date"`
+	Amount money.Decimal `json:"amount"`
+}
+
+// Split replaces every Denominator shares with Numerator shares on its ex-date
+type Split struct {
+	Date        time.Time     `json:"date"`
+	Numerator   money.Decimal `json:"numerator"`
+	Denominator money.Decimal `json:"denominator"`
+}
+
+// SymbolInfo describes a tradable symbol returned by a lookup
+type SymbolInfo struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Exchange string `json:"exchange,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	Type     string `json:"type,omitempty"`
+}
+
+// QuoteProvider is the contract every market data source implements
+type QuoteProvider interface {
+	// Name returns the registry name of the provider (e.g. "yahoo")
+	Name() string
+	// GetQuote fetches the latest price for a single symbol
+	GetQuote(symbol string) (*Quote, error)
+	// GetQuotes fetches the latest prices for several symbols, keyed by the
+	// symbols asked for. Symbols that could not be priced are omitted.
+	// Providers without a multi-symbol endpoint use fetchQuotesSequentially.
+	GetQuotes(symbols []string) (map[string]*Quote, error)
+	// GetHistory returns daily closes between from and to (inclusive), oldest first
+	GetHistory(symbol string, from, to time.Time) ([]Bar, error)
+	// SearchSymbols looks up symbols matching a free-text query
+	SearchSymbols(query string) ([]SymbolInfo, error)
+}
+
+// DividendProvider is implemented by providers that also serve dividend history
+type DividendProvider interface {
+	// GetDividends returns dividends with ex-dates between from and to (inclusive), oldest first
+	GetDividends(symbol string, from, to time.Time) ([]Dividend, error)
+}
+
+// SplitProvider is implemented by providers that also serve stock split history
+type SplitProvider interface {
+	// GetSplits returns splits with ex-dates between from and to (inclusive), oldest first
+	GetSplits(symbol string, from, to time.Time) ([]Split, error)
+}
+
+// ProviderConfig carries the shared dependencies handed to provider factories
+type ProviderConfig struct {
+	HTTPClient *http.Client
+}
+
+// ProviderFactory builds a QuoteProvider from the shared configuration
+type ProviderFactory func(cfg ProviderConfig) (QuoteProvider, error)
+
+var (
+	registryMu       sync.RWMutex
+	providerRegistry = map[string]ProviderFactory{}
+)
+
+// RegisterProvider makes a provider available under the given name.
+// Registering the same name twice replaces the previous factory.
+func RegisterProvider(name string, factory ProviderFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	providerRegistry[strings.ToLower(name)] = factory
+}
+
+// RegisteredProviders returns the names of all registered providers
+func RegisteredProviders() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(providerRegistry))
+	for name := range providerRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsRegisteredProvider reports whether a provider is registered under name
+func IsRegisteredProvider(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := providerRegistry[strings.ToLower(name)]
+	return ok
+}
+
+// newProvider instantiates a registered provider by name
+func newProvider(name string, cfg ProviderConfig) (QuoteProvider, error) {
+	registryMu.RLock()
+	factory, ok := providerRegistry[strings.ToLower(name)]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown market data provider: %s", name)
+	}
+	return factory(cfg)
+}
+
+func init() {
+	RegisterProvider(string(ProviderYahooFinance), func(cfg ProviderConfig) (QuoteProvider, error) {
+		return NewYahooProvider(cfg.HTTPClient), nil
+	})
+	RegisterProvider(string(ProviderAlphaVantage), func(cfg ProviderConfig) (QuoteProvider, error) {
+		return NewAlphaVantageProvider(cfg.HTTPClient), nil
+	})
+}
+
+// fetchQuotesSequentially implements GetQuotes for providers without a
+// multi-symbol endpoint by calling GetQuote once per symbol
+func fetchQuotesSequentially(p QuoteProvider, symbols []string) (map[string]*Quote, error) {
+	quotes := make(map[string]*Quote, len(symbols))
+	var lastErr error
+	for _, symbol := range symbols {
+		quote, err := p.GetQuote(symbol)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		quotes[symbol] = quote
+	}
+
+	if len(quotes) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return quotes, nil
+}
+
+// fetchBody performs a GET request and returns the body of a 200 response
+func fetchBody(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch market data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &RateLimitError{Message: "too many requests", RetryAfter: time.Minute}
+	}
+
+	// Every request names a symbol, so a missing resource is an unknown one
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w (API returned status code: %d)", ErrSymbolNotFound, resp.StatusCode)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return body, nil
+}
+
+// fetchJSON performs a GET request and decodes the JSON body into out
+func fetchJSON(client *http.Client, url string, out interface{}) error {
+	body, err := fetchBody(client, url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// recordedResponse is the on-disk format of a recorded HTTP exchange
+type recordedResponse struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+}
+
+// unkeyedParams are the query parameters dropped from a request URL before
+// it is hashed: the API key, so secrets never end up on disk, and the
+// parameters computed from the clock (Yahoo's period1/period2 range and
+// Alpha Vantage's outputsize, chosen from how far back the range starts).
+// A history request is keyed by symbol and interval alone, and the
+// providers filter the recorded series to the dates asked for.
+var unkeyedParams = []string{"apikey", "period1", "period2", "outputsize"}
+
+// recordingKey normalizes a request URL so recordings do not depend on
+// secrets or on when they are replayed
+func recordingKey(u *url.URL) (string, string) {
+	normalized := *u
+	query := normalized.Query()
+	for _, param := range unkeyedParams {
+		query.Del(param)
+	}
+	normalized.RawQuery = query.Encode()
+
+	sum := sha1.Sum([]byte(normalized.String()))
+	return hex.EncodeToString(sum[:]) + ".json", normalized.String()
+}
+
+// ReplayTransport serves HTTP responses previously captured by a
+// RecordingTransport, so real provider parsers run without network access
+type ReplayTransport struct {
+	dir string
+}
+
+// NewReplayTransport creates a transport replaying recordings from dir
+func NewReplayTransport(dir string) *ReplayTransport {
+	return &ReplayTransport{dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name, normalized := recordingKey(req.URL)
+
+	data, err := os.ReadFile(filepath.Join(t.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s", normalized)
+	}
+
+	var recorded recordedResponse
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("invalid recording %s: %w", name, err)
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(recorded.Status),
+		StatusCode: recorded.Status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(recorded.Body)),
+		Request:    req,
+	}, nil
+}
+
+// RecordingTransport passes requests through to another transport and
+// saves every response to dir in the format ReplayTransport reads
+type RecordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+// NewRecordingTransport creates a transport recording responses into dir
+func NewRecordingTransport(dir string, next http.RoundTripper) *RecordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RecordingTransport{dir: dir, next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// A recording that cannot be saved must not fail the live request
+	name, normalized := recordingKey(req.URL)
+	if err := t.save(name, recordedResponse{URL: normalized, Status: resp.StatusCode, Body: string(body)}); err != nil {
+		log.Printf("[MarketData] Failed to record %s: %v", normalized, err)
+	}
+
+	return resp, nil
+}
+
+// save writes a recording to dir
+func (t *RecordingTransport) save(name string, recorded recordedResponse) error {
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(t.dir, name), data, 0o644)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRecordingKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		// History asked for on different days replays the same recording
+		{
+			a:    "https://query1.finance.yahoo.com/v8/finance/chart/AAPL?interval=1d&period1=1704067200&period2=1706745600",
+			b:    "https://query1.finance.yahoo.com/v8/finance/chart/AAPL?interval=1d&period1=1704153600&period2=1706832000",
+			same: true,
+		},
+		{
+			a:    "https://www.alphavantage.co/query?function=TIME_SERIES_DAILY&symbol=IBM&apikey=one",
+			b:    "https://www.alphavantage.co/query?apikey=two&function=TIME_SERIES_DAILY&outputsize=full&symbol=IBM",
+			same: true,
+		},
+		{
+			a: "https://query1.finance.yahoo.com/v8/finance/chart/AAPL?interval=1d&period1=1704067200",
+			b: "https://query1.finance.yahoo.com/v8/finance/chart/MSFT?interval=1d&period1=1704067200",
+		},
+		{
+			a: "https://query1.finance.yahoo.com/v8/finance/chart/AAPL?events=div&interval=1d",
+			b: "https://query1.finance.yahoo.com/v8/finance/chart/AAPL?events=split&interval=1d",
+		},
+	}
+	for _, tt := range tests {
+		a, _ := url.Parse(tt.a)
+		b, _ := url.Parse(tt.b)
+		nameA, normalized := recordingKey(a)
+		nameB, _ := recordingKey(b)
+		if (nameA == nameB) != tt.same {
+			t.Errorf("recordingKey(%s) == recordingKey(%s) is %v, want %v", tt.a, tt.b, nameA == nameB, tt.same)
+		}
+		if strings.Contains(normalized, "apikey") || strings.Contains(normalized, "period1") {
+			t.Errorf("recordingKey(%s) kept a secret or clock parameter: %s", tt.a, normalized)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"symbol":"`+r.URL.Query().Get("symbol")+`"}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: NewRecordingTransport(dir, nil)}
+	resp, err := recorder.Get(server.URL + "/query?symbol=IBM&apikey=secret&outputsize=compact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	replayer := &http.Client{Transport: NewReplayTransport(dir)}
+	resp, err = replayer.Get(server.URL + "/query?symbol=IBM&outputsize=full")
+	if err != nil {
+		t.Fatalf("replay of a recording with another range failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"symbol":"IBM"}` {
+		t.Errorf("replayed body = %s", body)
+	}
+
+	if _, err := replayer.Get(server.URL + "/query?symbol=MSFT"); err == nil {
+		t.Error("replay of an unrecorded request succeeded")
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrJobNotFound is returned when no job is registered under a name
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobRunning is returned when a job is triggered while it is running
+	ErrJobRunning = errors.New("job already running")
+)
+
+// JobFunc performs one run of a background job and returns a summary of
+// what it did, which is reported by the admin endpoint
+type JobFunc func() (interface{}, error)
+
+// JobRun records the outcome of a single job run
+type JobRun struct {
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+	Duration   string      `json:"duration"`
+	Success    bool        `json:"success"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+// JobStatus is a point-in-time view of a scheduled job
+type JobStatus struct {
+	Name     string     `json:"name"`
+	Interval string     `json:"interval"`
+	Running  bool       `json:"running"`
+	Runs     int64      `json:"runs"`
+	Failures int64      `json:"failures"`
+	LastRun  *JobRun    `json:"last_run,omitempty"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+}
+
+// scheduledJob is a job registered with the scheduler
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	// due decides, at each tick, whether the job should run given its last successful run
+	due func(now, lastSuccess time.Time) bool
+	fn  JobFunc
+
+	running     bool
+	runs        int64
+	failures    int64
+	lastRun     *JobRun
+	lastSuccess time.Time
+	nextRun     time.Time
+}
+
+// Scheduler runs background jobs on fixed intervals inside the server process
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*scheduledJob
+	quit    chan struct{}
+	wg      sync.WaitGroup
+	started bool
+}
+
+// NewScheduler creates an empty scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs: make(map[string]*scheduledJob),
+		quit: make(chan struct{}),
+	}
+}
+
+// Register adds a job that is considered every interval. due may be nil, in
+// which case the job runs on every tick.
+func (s *Scheduler) Register(name string, interval time.Duration, due func(now, lastSuccess time.Time) bool, fn JobFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[name] = &scheduledJob{
+		name:     name,
+		interval: interval,
+		due:      due,
+		fn:       fn,
+		nextRun:  time.Now(),
+	}
+}
+
+// Start launches one goroutine per registered job. Each job is considered
+// once immediately and then on every interval tick.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+}
+
+// Stop signals every job loop to exit and waits for running jobs to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	close(s.quit)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// RunNow triggers a job immediately in the background, regardless of its
+// schedule. The job is marked running before RunNow returns, so a second
+// trigger gets ErrJobRunning.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if job.running {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+	job.running = true
+	s.mu.Unlock()
+
+	go s.execute(job)
+	return nil
+}
+
+// Statuses returns the status of every registered job, sorted by name
+func (s *Scheduler) Statuses() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		status := JobStatus{
+			Name:     job.name,
+			Interval: job.interval.String(),
+			Running:  job.running,
+			Runs:     job.runs,
+			Failures: job.failures,
+			LastRun:  job.lastRun,
+		}
+		if s.started {
+			nextRun := job.nextRun
+			status.NextRun = &nextRun
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// loop runs a job on its interval until the scheduler stops
+func (s *Scheduler) loop(job *scheduledJob) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	s.tick(job)
+	for {
+		select {
+		case <-ticker.C:
+			s.tick(job)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// tick runs the job if it is due
+func (s *Scheduler) tick(job *scheduledJob) {
+	now := time.Now()
+
+	s.mu.Lock()
+	job.nextRun = now.Add(job.interval)
+	lastSuccess := job.lastSuccess
+	s.mu.Unlock()
+
+	if job.due != nil && !job.due(now, lastSuccess) {
+		return
+	}
+	s.run(job)
+}
+
+// run executes the job once unless it is already running
+func (s *Scheduler) run(job *scheduledJob) {
+	s.mu.Lock()
+	if job.running {
+		s.mu.Unlock()
+		return
+	}
+	job.running = true
+	s.mu.Unlock()
+
+	s.execute(job)
+}
+
+// execute runs a job marked running and records the outcome
+func (s *Scheduler) execute(job *scheduledJob) {
+	started := time.Now()
+	result, err := job.fn()
+	finished := time.Now()
+
+	run := &JobRun{
+		StartedAt:  started,
+		FinishedAt: finished,
+		Duration:   finished.Sub(started).String(),
+		Success:    err == nil,
+		Result:     result,
+	}
+	if err != nil {
+		run.Error = err.Error()
+		log.Printf("[Scheduler] Job %s failed after %v: %v", job.name, finished.Sub(started), err)
+	} else {
+		log.Printf("[Scheduler] Job %s finished in %v", job.name, finished.Sub(started))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.running = false
+	job.runs++
+	job.lastRun = run
+	if err != nil {
+		job.failures++
+	} else {
+		job.lastSuccess = started
+	}
+}
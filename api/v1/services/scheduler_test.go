@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunNow(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan struct{})
+	s := NewScheduler()
+	s.Register("blocking", time.Hour, nil, func() (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	s.Register("failing", time.Hour, nil, func() (interface{}, error) {
+		defer close(done)
+		return nil, errors.New("provider down")
+	})
+
+	tests := []struct {
+		name string
+		want error
+	}{
+		{name: "blocking"},
+		// Still running: the first trigger marked it before returning
+		{name: "blocking", want: ErrJobRunning},
+		{name: "missing", want: ErrJobNotFound},
+		{name: "failing"},
+	}
+	for _, tt := range tests {
+		err := s.RunNow(tt.name)
+		if tt.want == nil && err != nil {
+			t.Errorf("RunNow(%s) returned %v", tt.name, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("RunNow(%s) returned %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	close(release)
+	<-done
+	deadline := time.Now().Add(time.Second)
+	for {
+		statuses := s.Statuses()
+		if !statuses[0].Running && !statuses[1].Running && statuses[0].Runs == 1 && statuses[1].Runs == 1 {
+			if statuses[0].Failures != 0 || statuses[1].Failures != 1 || statuses[1].LastRun.Error != "provider down" {
+				t.Errorf("statuses = %+v, want one success and one failure", statuses)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not finish: %+v", statuses)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
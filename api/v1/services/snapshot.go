@@ -0,0 +1,237 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"personal-finance/api/v1/models"
+	"personal-finance/api/v1/money"
+)
+
+// NetWorthSnapshotJobName is the scheduler name of the net worth snapshot job
+const NetWorthSnapshotJobName = "networth_snapshot"
+
+// SnapshotService records and queries daily net worth snapshots
+type SnapshotService struct {
+	db         *sql.DB
+	marketData *MarketDataService
+	fx         *FXService
+}
+
+// NewSnapshotService creates a new snapshot service
+func NewSnapshotService(db *sql.DB, marketData *MarketDataService, fx *FXService) *SnapshotService {
+	return &SnapshotService{db: db, marketData: marketData, fx: fx}
+}
+
+// TakeSnapshot values every asset and debt now, converted into the base
+// currency, and stores the totals as today's snapshot. Later snapshots on
+// the same day replace earlier ones, so the last run of the day is kept.
+func (s *SnapshotService) TakeSnapshot() (*models.NetWorthSnapshot, error) {
+	converter := s.fx.NewConverter("")
+
+	assetsByType, err := s.assetTotalsByType(converter)
+	if err != nil {
+		return nil, err
+	}
+
+	debtsByType, err := s.debtTotalsByType(converter)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	snapshot := &models.NetWorthSnapshot{
+		ID:           uuid.New().String(),
+		Date:         time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		Currency:     converter.Target(),
+		AssetsByType: assetsByType,
+		DebtsByType:  debtsByType,
+		CreatedAt:    now,
+	}
+	for _, value := range assetsByType {
+		snapshot.TotalAssets = snapshot.TotalAssets.Add(value)
+	}
+	for _, value := range debtsByType {
+		snapshot.TotalDebts = snapshot.TotalDebts.Add(value)
+	}
+	snapshot.NetWorth = snapshot.TotalAssets.Sub(snapshot.TotalDebts)
+
+	assetsJSON, _ := json.Marshal(snapshot.AssetsByType)
+	debtsJSON, _ := json.Marshal(snapshot.DebtsByType)
+
+	query := `
+		INSERT INTO networth_snapshots (id, date, total_assets, total_debts, net_worth, currency, assets_by_type, debts_by_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (date) DO UPDATE SET
+			total_assets = $3, total_debts = $4, net_worth = $5, currency = $6,
+			assets_by_type = $7, debts_by_type = $8, created_at = $9
+		RETURNING id
+	`
+	err = s.db.QueryRow(query,
+		snapshot.ID, snapshot.Date.Format("2006-01-02"), snapshot.TotalAssets, snapshot.TotalDebts,
+		snapshot.NetWorth, snapshot.Currency, assetsJSON, debtsJSON, snapshot.CreatedAt,
+	).Scan(&snapshot.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// ListSnapshots returns snapshots between from and to, oldest first. For the
+// week and month intervals only the last snapshot of each period is returned.
+func (s *SnapshotService) ListSnapshots(from, to time.Time, interval string) ([]models.NetWorthSnapshot, error) {
+	switch interval {
+	case "", "day", "week", "month":
+	default:
+		return nil, fmt.Errorf("invalid interval: %s", interval)
+	}
+
+	query := `
+		SELECT id, date, total_assets, total_debts, net_worth, currency, assets_by_type, debts_by_type, created_at
+		FROM networth_snapshots
+		WHERE date BETWEEN $1 AND $2
+		ORDER BY date
+	`
+	rows, err := s.db.Query(query, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []models.NetWorthSnapshot{}
+	for rows.Next() {
+		var snapshot models.NetWorthSnapshot
+		var assetsJSON, debtsJSON []byte
+		err := rows.Scan(
+			&snapshot.ID, &snapshot.Date, &snapshot.TotalAssets, &snapshot.TotalDebts,
+			&snapshot.NetWorth, &snapshot.Currency, &assetsJSON, &debtsJSON, &snapshot.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal(assetsJSON, &snapshot.AssetsByType)
+		json.Unmarshal(debtsJSON, &snapshot.DebtsByType)
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if interval == "week" || interval == "month" {
+		snapshots = lastPerPeriod(snapshots, interval)
+	}
+	return snapshots, nil
+}
+
+// lastPerPeriod keeps the last of snapshots sorted by date in each ISO week
+// (Monday to Sunday) or calendar month
+func lastPerPeriod(snapshots []models.NetWorthSnapshot, interval string) []models.NetWorthSnapshot {
+	period := func(date time.Time) [2]int {
+		if interval == "week" {
+			year, week := date.ISOWeek()
+			return [2]int{year, week}
+		}
+		return [2]int{date.Year(), int(date.Month())}
+	}
+
+	last := []models.NetWorthSnapshot{}
+	for i, snapshot := range snapshots {
+		if i+1 < len(snapshots) && period(snapshots[i+1].Date) == period(snapshot.Date) {
+			continue
+		}
+		last = append(last, snapshot)
+	}
+	return last
+}
+
+// ConvertSnapshot converts a snapshot's totals from the currency it was
+// recorded in into the converter's currency at the rate of the snapshot date
+func ConvertSnapshot(snapshot *models.NetWorthSnapshot, converter *Converter) error {
+	rate, err := converter.RateOn(snapshot.Currency, snapshot.Date)
+	if err != nil {
+		return err
+	}
+
+	snapshot.TotalAssets = converter.apply(snapshot.TotalAssets, rate)
+	snapshot.TotalDebts = converter.apply(snapshot.TotalDebts, rate)
+	for key, value := range snapshot.AssetsByType {
+		snapshot.AssetsByType[key] = converter.apply(value, rate)
+	}
+	for key, value := range snapshot.DebtsByType {
+		snapshot.DebtsByType[key] = converter.apply(value, rate)
+	}
+	snapshot.NetWorth = snapshot.TotalAssets.Sub(snapshot.TotalDebts)
+	snapshot.Currency = converter.Target()
+	return nil
+}
+
+// assetTotalsByType values every asset as the net worth does, with market
+// prices and bonds and CDs valued from their terms, and sums by type
+func (s *SnapshotService) assetTotalsByType(converter *Converter) (map[string]money.Decimal, error) {
+	query := `
+		SELECT id, type, name, COALESCE(symbol, ''), buy_price, current_value, quantity, COALESCE(currency, ''), purchase_date,
+			source, COALESCE(provider, ''), COALESCE(kind, ''), face_value, clean_price, COALESCE(interest_rate, 0),
+			COALESCE(coupon_frequency, 0), maturity_date
+		FROM assets
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch assets: %w", err)
+	}
+
+	assets := []models.Asset{}
+	for rows.Next() {
+		var asset models.Asset
+		err := rows.Scan(&asset.ID, &asset.Type, &asset.Name, &asset.Symbol, &asset.BuyPrice, &asset.CurrentValue, &asset.Quantity,
+			&asset.Currency, &asset.PurchaseDate, &asset.Source, &asset.Provider, &asset.Kind, &asset.FaceValue, &asset.CleanPrice,
+			&asset.InterestRate, &asset.CouponFrequency, &asset.MaturityDate)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse assets: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	rows.Close()
+
+	s.marketData.ApplyMarketPrices(assets)
+	ApplyFixedIncomeValues(assets, time.Now())
+
+	totals := make(map[string]money.Decimal)
+	for i := range assets {
+		value, err := converter.Convert(assets[i].TotalValue(), assets[i].Currency)
+		if err != nil {
+			return nil, err
+		}
+		totals[string(assets[i].Type)] = totals[string(assets[i].Type)].Add(value)
+	}
+	return totals, nil
+}
+
+// debtTotalsByType sums debt balances, accrued interest included, by type
+func (s *SnapshotService) debtTotalsByType(converter *Converter) (map[string]money.Decimal, error) {
+	rows, err := s.db.Query(`
+		SELECT type, COALESCE(currency, ''), COALESCE(SUM(current_value + COALESCE(accrued_interest, 0)), 0)
+		FROM debts GROUP BY type, currency
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch debts: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]money.Decimal)
+	for rows.Next() {
+		var debtType, currency string
+		var total money.Decimal
+		if err := rows.Scan(&debtType, &currency, &total); err != nil {
+			return nil, fmt.Errorf("failed to parse debts: %w", err)
+		}
+		converted, err := converter.Convert(total, currency)
+		if err != nil {
+			return nil, err
+		}
+		totals[debtType] = totals[debtType].Add(converted)
+	}
+	return totals, nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"personal-finance/api/v1/models"
+)
+
+func TestTakeSnapshotReplacesTodays(t *testing.T) {
+	db, fake := newFakeDB(t)
+	cash := "1000.00"
+	fake.on("FROM assets", func([]driver.Value) (fakeRows, error) {
+		return fakeRows{
+			columns: []string{
+				"id", "type", "name", "symbol", "buy_price", "current_value", "quantity", "currency", "purchase_date",
+				"source", "provider", "kind", "face_value", "clean_price", "interest_rate", "coupon_frequency", "maturity_date",
+			},
+			values: [][]driver.Value{{"cash", "cash", "Checking", "", cash, cash, "1", "USD", mustDate("2024-01-01"),
+				"manual", "", "", nil, nil, "0", int64(0), nil}},
+		}, nil
+	})
+	fake.returns("FROM debts GROUP BY", []string{"type", "currency", "total"},
+		[]driver.Value{"credit_card", "USD", "250.00"},
+	)
+
+	// The table keeps one row per date: a second insert the same day keeps the first id
+	stored := map[string]string{}
+	fake.on("INSERT INTO networth_snapshots", func(args []driver.Value) (fakeRows, error) {
+		date := args[1].(string)
+		if _, ok := stored[date]; !ok {
+			stored[date] = args[0].(string)
+		}
+		return fakeRows{columns: []string{"id"}, values: [][]driver.Value{{stored[date]}}}, nil
+	})
+
+	s := &SnapshotService{db: db, marketData: newStubMarketData(&stubProvider{name: "stub"}), fx: &FXService{baseCurrency: "USD"}}
+	first, err := s.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cash = "1500.00"
+	second, err := s.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if second.ID != first.ID {
+		t.Errorf("second snapshot of the day has id %s, want the first one's %s", second.ID, first.ID)
+	}
+	if second.NetWorth.String() != "1250.00" || second.AssetsByType["cash"].String() != "1500.00" ||
+		second.DebtsByType["credit_card"].String() != "250.00" {
+		t.Errorf("second snapshot %+v, want 1500.00 cash less 250.00 owed", second)
+	}
+
+	inserts := fake.executed("ON CONFLICT (date) DO UPDATE")
+	today := time.Now().Format("2006-01-02")
+	if len(inserts) != 2 || inserts[0].args[1] != today || inserts[1].args[1] != today {
+		t.Fatalf("snapshots stored %v, want two upserts for %s", inserts, today)
+	}
+	if inserts[1].args[4] != "1250.00" {
+		t.Errorf("second upsert stored net worth %v, want 1250.00", inserts[1].args[4])
+	}
+}
+
+func TestListSnapshotsIntervals(t *testing.T) {
+	// 2024-01-28 is a Sunday, 2024-01-29 the next Monday
+	dates := []string{"2024-01-22", "2024-01-26", "2024-01-28", "2024-01-29", "2024-01-31", "2024-02-01", "2024-02-05"}
+	var rows [][]driver.Value
+	for _, date := range dates {
+		created := mustDate(date)
+		rows = append(rows, []driver.Value{date, created, "1000.00", "0", "1000.00", "USD",
+			[]byte(`{"cash":"1000.00"}`), []byte(`{}`), created})
+	}
+
+	tests := []struct {
+		interval string
+		want     []string
+	}{
+		{interval: "day", want: dates},
+		{interval: "week", want: []string{"2024-01-28", "2024-02-01", "2024-02-05"}},
+		{interval: "month", want: []string{"2024-01-31", "2024-02-05"}},
+	}
+	for _, tt := range tests {
+		db, fake := newFakeDB(t)
+		fake.returns("FROM networth_snapshots", []string{
+			"id", "date", "total_assets", "total_debts", "net_worth", "currency", "assets_by_type", "debts_by_type", "created_at",
+		}, rows...)
+
+		s := &SnapshotService{db: db}
+		snapshots, err := s.ListSnapshots(mustDate("2024-01-01"), mustDate("2024-02-29"), tt.interval)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.interval, err)
+			continue
+		}
+
+		var got []string
+		for _, snapshot := range snapshots {
+			got = append(got, snapshot.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got snapshots %v, want %v", tt.interval, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got snapshots %v, want %v", tt.interval, got, tt.want)
+				break
+			}
+		}
+		if queries := fake.executed("FROM networth_snapshots"); len(queries) != 1 ||
+			queries[0].args[0] != "2024-01-01" || queries[0].args[1] != "2024-02-29" {
+			t.Errorf("%s: queried %v, want the range 2024-01-01 to 2024-02-29", tt.interval, queries)
+		}
+	}
+
+	if _, err := (&SnapshotService{}).ListSnapshots(mustDate("2024-01-01"), mustDate("2024-02-29"), "year"); err == nil {
+		t.Errorf("year interval accepted, want an error")
+	}
+}
+
+func TestLastPerPeriodAcrossYears(t *testing.T) {
+	// 2024-12-30 and 2025-01-02 are in ISO week 1 of 2025
+	var snapshots []models.NetWorthSnapshot
+	for _, date := range []string{"2024-12-27", "2024-12-30", "2025-01-02", "2025-01-06"} {
+		snapshots = append(snapshots, models.NetWorthSnapshot{ID: date, Date: mustDate(date)})
+	}
+
+	tests := []struct {
+		interval string
+		want     []string
+	}{
+		{interval: "week", want: []string{"2024-12-27", "2025-01-02", "2025-01-06"}},
+		{interval: "month", want: []string{"2024-12-30", "2025-01-06"}},
+	}
+	for _, tt := range tests {
+		got := lastPerPeriod(snapshots, tt.interval)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d snapshots, want %v", tt.interval, len(got), tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].ID != tt.want[i] {
+				t.Errorf("%s: snapshot %d is %s, want %s", tt.interval, i, got[i].ID, tt.want[i])
+			}
+		}
+	}
+}
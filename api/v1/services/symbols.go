@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSymbolNotFound is returned when no provider knows a symbol, and by a
+// provider that has no data for one. A provider that answers with it is
+// healthy, so it does not count against its breaker.
+var ErrSymbolNotFound = errors.New("symbol not found")
+
+// SearchSymbols looks up symbols matching a free-text query, walking the
+// failover chain starting at the named provider. It also returns the
+// provider that answered.
+func (s *MarketDataService) SearchSymbols(providerName, query string) ([]SymbolInfo, string, error) {
+	query = strings.TrimSpace(query)
+
+	var matches []SymbolInfo
+	name, err := s.withFailover(providerName, func(p QuoteProvider) error {
+		var err error
+		matches, err = p.SearchSymbols(query)
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("all providers failed to search %q (%v)", query, err)
+	}
+	return matches, name, nil
+}
+
+// LookupSymbol confirms a symbol is known to the providers and returns its
+// display name, exchange and trading currency. The symbol exists when the
+// search lists it or a quote can be fetched for it; the quote also fills in
+// the currency, which search results often omit. ErrSymbolNotFound means the
+// providers answered but do not know the symbol.
+func (s *MarketDataService) LookupSymbol(providerName, symbol string) (*SymbolInfo, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	info := &SymbolInfo{Symbol: symbol}
+	listed := false
+	matches, _, searchErr := s.SearchSymbols(providerName, symbol)
+	for _, m := range matches {
+		if strings.EqualFold(m.Symbol, symbol) {
+			match := m
+			info = &match
+			info.Symbol = symbol
+			listed = true
+			break
+		}
+	}
+
+	quote, quoteErr := s.fetchFromChain(providerName, symbol)
+	if quoteErr != nil && !listed {
+		if searchErr != nil {
+			return nil, fmt.Errorf("could not verify %s: %v", symbol, quoteErr)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrSymbolNotFound, symbol)
+	}
+	if quote != nil {
+		if info.Currency == "" {
+			info.Currency = quote.Currency
+		}
+		if info.Exchange == "" {
+			info.Exchange = quote.Exchange
+		}
+		s.storeQuote(quote)
+	}
+	return info, nil
+}
+
+// isSymbolSeparator reports whether c may join the parts of a symbol
+func isSymbolSeparator(c rune) bool {
+	return c == '.' || c == '-' || c == '='
+}
+
+// IsStockSymbol checks if an asset name looks like a ticker symbol: up to 20
+// uppercase letters and digits, with at least one letter, optionally joined
+// by the separators used for share classes, listings and pairs (BRK.B,
+// RDS-A, VWRL.L, 7203.T, BTC-USD, EURUSD=X) and with a leading ^ for indexes
+func IsStockSymbol(name string) bool {
+	// The limit is the width of the symbol column, which stores the ^
+	name = strings.TrimSpace(name)
+	if len(name) > 20 {
+		return false
+	}
+	name = strings.TrimPrefix(name, "^")
+	if len(name) < 1 {
+		return false
+	}
+
+	letters := 0
+	for i, char := range name {
+		switch {
+		case char >= 'A' && char <= 'Z':
+			letters++
+		case char >= '0' && char <= '9':
+		case isSymbolSeparator(char):
+			// Separators only join letters and digits
+			if i == 0 || i == len(name)-1 || isSymbolSeparator(rune(name[i-1])) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return letters > 0
+}
+
+// CryptoPair splits a crypto symbol into the asset and the currency it is
+// quoted in (BTC-USD is bitcoin priced in US dollars, ETH-BTC is ether priced
+// in bitcoin). ok is false unless the symbol is exactly BASE-QUOTE.
+func CryptoPair(symbol string) (base, quote string, ok bool) {
+	symbol = strings.TrimSpace(symbol)
+	if !IsStockSymbol(symbol) || strings.ContainsAny(symbol, "^.=") {
+		return "", "", false
+	}
+
+	parts := strings.Split(symbol, "-")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,77 @@
+package services
+
+import "testing"
+
+func TestCryptoPair(t *testing.T) {
+	tests := []struct {
+		symbol      string
+		base, quote string
+		ok          bool
+	}{
+		{symbol: "BTC-USD", base: "BTC", quote: "USD", ok: true},
+		{symbol: " ETH-BTC ", base: "ETH", quote: "BTC", ok: true},
+		{symbol: "BTC"},
+		{symbol: "BTC-USD-X"},
+		{symbol: "btc-usd"},
+		{symbol: "EURUSD=X"},
+		{symbol: "^BTC-USD"},
+	}
+	for _, tt := range tests {
+		base, quote, ok := CryptoPair(tt.symbol)
+		if base != tt.base || quote != tt.quote || ok != tt.ok {
+			t.Errorf("CryptoPair(%q) = %q, %q, %v, want %q, %q, %v", tt.symbol, base, quote, ok, tt.base, tt.quote, tt.ok)
+		}
+	}
+}
+
+func TestIsMarketPriced(t *testing.T) {
+	tests := []struct {
+		assetType, source, symbol string
+		want                      bool
+	}{
+		{"stock", "market_api", "AAPL", true},
+		{"stock", "manual", "AAPL", false},
+		{"stock", "market_api", "", false},
+		{"crypto", "market_api", "BTC-USD", true},
+		{"crypto", "market_api", "BTC", false},
+		{"crypto", "manual", "BTC-USD", false},
+		{"real_estate", "market_api", "AAPL", false},
+	}
+	for _, tt := range tests {
+		if got := IsMarketPriced(tt.assetType, tt.source, tt.symbol); got != tt.want {
+			t.Errorf("IsMarketPriced(%s, %s, %q) = %v, want %v", tt.assetType, tt.source, tt.symbol, got, tt.want)
+		}
+	}
+}
+
+func TestIsStockSymbol(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"AAPL", true},
+		{" BRK.B ", true},
+		{"RDS-A", true},
+		{"7203.T", true},
+		{"EURUSD=X", true},
+		{"^GSPC", true},
+		{"ABCDEFGHIJKLMNOPQRST", true},
+		{"^ABCDEFGHIJKLMNOPQRS", true},
+		// 21 characters do not fit the symbol column, with or without the ^
+		{"^ABCDEFGHIJKLMNOPQRST", false},
+		{"ABCDEFGHIJKLMNOPQRSTU", false},
+		{"", false},
+		{"^", false},
+		{"1234", false},
+		{"aapl", false},
+		{"Apple Inc", false},
+		{"BRK..B", false},
+		{".BRK", false},
+		{"BRK-", false},
+	}
+	for _, tt := range tests {
+		if got := IsStockSymbol(tt.name); got != tt.want {
+			t.Errorf("IsStockSymbol(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
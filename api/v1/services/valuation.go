@@ -0,0 +1,56 @@
+package services
+
+import (
+	"personal-finance/api/v1/models"
+)
+
+// ValueAsset converts an asset into the target currency. The cost basis is
+// converted at the rate of the purchase date and the current value at
+// today's rate, so the gain splits into a price part and an FX part.
+func (c *Converter) ValueAsset(asset *models.Asset) (*models.AssetValuation, error) {
+	rate, err := c.Rate(asset.Currency)
+	if err != nil {
+		return nil, err
+	}
+	purchaseRate, err := c.RateOn(asset.Currency, asset.PurchaseDate)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := asset.CostBasis
+	value := asset.TotalValue()
+	valuation := &models.AssetValuation{
+		AssetID:      asset.ID,
+		Currency:     converterCurrency(asset.Currency),
+		CostBasis:    c.apply(cost, purchaseRate),
+		PurchaseRate: purchaseRate,
+		Value:        c.apply(value, rate),
+		Rate:         rate,
+	}
+	// The FX gain is the remainder so the parts add up to value - cost exactly
+	valuation.PriceGain = c.apply(value.Sub(cost), rate)
+	valuation.FXGain = valuation.Value.Sub(valuation.CostBasis).Sub(valuation.PriceGain)
+	return valuation, nil
+}
+
+// ValueDebt converts a debt into the target currency: the principal at the
+// rate of the start date and the balance at today's rate
+func (c *Converter) ValueDebt(debt *models.Debt) (*models.DebtValuation, error) {
+	rate, err := c.Rate(debt.Currency)
+	if err != nil {
+		return nil, err
+	}
+	startRate, err := c.RateOn(debt.Currency, debt.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DebtValuation{
+		DebtID:    debt.ID,
+		Currency:  converterCurrency(debt.Currency),
+		Principal: c.apply(debt.Principal, startRate),
+		StartRate: startRate,
+		Balance:   c.apply(debt.CurrentValue, rate),
+		Rate:      rate,
+	}, nil
+}
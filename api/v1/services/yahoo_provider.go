@@ -0,0 +1,253 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"personal-finance/api/v1/money"
+)
+
+const yahooBaseURL = "https://query1.finance.yahoo.com"
+
+// YahooProvider fetches market data from Yahoo Finance (FREE, no API key)
+type YahooProvider struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewYahooProvider creates a new Yahoo Finance provider
+func NewYahooProvider(httpClient *http.Client) *YahooProvider {
+	return &YahooProvider{
+		httpClient: httpClient,
+		baseURL:    yahooBaseURL,
+	}
+}
+
+// Name returns the provider name
+func (p *YahooProvider) Name() string {
+	return string(ProviderYahooFinance)
+}
+
+// yahooChartResponse mirrors the parts of the v8 chart API we use
+type yahooChartResponse struct {
+	Chart struct {
+		Result []struct {
+			Meta struct {
+				Symbol             string         `json:"symbol"`
+				Currency           string         `json:"currency"`
+				ExchangeName       string         `json:"exchangeName"`
+				RegularMarketPrice *money.Decimal `json:"regularMarketPrice"`
+				RegularMarketTime  int64          `json:"regularMarketTime"`
+			} `json:"meta"`
+			Timestamp []int64 `json:"timestamp"`
+			Events    struct {
+				Dividends map[string]struct {
+					Amount money.Decimal `json:"amount"`
+					Date   int64         `json:"date"`
+				} `json:"dividends"`
+				Splits map[string]struct {
+					Date        int64         `json:"date"`
+					Numerator   money.Decimal `json:"numerator"`
+					Denominator money.Decimal `json:"denominator"`
+				} `json:"splits"`
+			} `json:"events"`
+			Indicators struct {
+				Quote []struct {
+					Close []*money.Decimal `json:"close"`
+				} `json:"quote"`
+			} `json:"indicators"`
+		} `json:"result"`
+		Error *struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	} `json:"chart"`
+}
+
+// chart calls the chart endpoint with the given query parameters
+func (p *YahooProvider) chart(symbol string, params url.Values) (*yahooChartResponse, error) {
+	endpoint := fmt.Sprintf("%s/v8/finance/chart/%s?%s", p.baseURL, url.PathEscape(symbol), params.Encode())
+
+	var result yahooChartResponse
+	if err := fetchJSON(p.httpClient, endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	if result.Chart.Error != nil {
+		if result.Chart.Error.Code == "Not Found" {
+			return nil, fmt.Errorf("%w: %s", ErrSymbolNotFound, result.Chart.Error.Description)
+		}
+		return nil, fmt.Errorf("API error: %s", result.Chart.Error.Description)
+	}
+	if len(result.Chart.Result) == 0 {
+		return nil, fmt.Errorf("%w: no chart for %s", ErrSymbolNotFound, symbol)
+	}
+	return &result, nil
+}
+
+// GetQuote fetches the latest price for a symbol
+func (p *YahooProvider) GetQuote(symbol string) (*Quote, error) {
+	result, err := p.chart(symbol, url.Values{"interval": {"1d"}, "range": {"1d"}})
+	if err != nil {
+		return nil, err
+	}
+
+	meta := result.Chart.Result[0].Meta
+	if meta.RegularMarketPrice == nil {
+		return nil, fmt.Errorf("invalid response format: missing price")
+	}
+
+	timestamp := time.Now()
+	if meta.RegularMarketTime > 0 {
+		timestamp = time.Unix(meta.RegularMarketTime, 0)
+	}
+
+	return &Quote{
+		Symbol:    symbol,
+		Price:     *meta.RegularMarketPrice,
+		Currency:  meta.Currency,
+		Exchange:  meta.ExchangeName,
+		Timestamp: timestamp,
+		Provider:  p.Name(),
+	}, nil
+}
+
+// GetQuotes fetches the latest prices for several symbols
+func (p *YahooProvider) GetQuotes(symbols []string) (map[string]*Quote, error) {
+	return fetchQuotesSequentially(p, symbols)
+}
+
+// GetHistory returns daily closes between from and to
+func (p *YahooProvider) GetHistory(symbol string, from, to time.Time) ([]Bar, error) {
+	params := url.Values{
+		"interval": {"1d"},
+		"period1":  {fmt.Sprintf("%d", from.Unix())},
+		"period2":  {fmt.Sprintf("%d", to.AddDate(0, 0, 1).Unix())},
+	}
+
+	result, err := p.chart(symbol, params)
+	if err != nil {
+		return nil, err
+	}
+
+	series := result.Chart.Result[0]
+	if len(series.Indicators.Quote) == 0 {
+		return []Bar{}, nil
+	}
+	closes := series.Indicators.Quote[0].Close
+
+	bars := make([]Bar, 0, len(series.Timestamp))
+	for i, ts := range series.Timestamp {
+		// Yahoo returns null closes for non-trading days inside the range
+		if i >= len(closes) || closes[i] == nil {
+			continue
+		}
+		date := truncateToDate(time.Unix(ts, 0).UTC())
+		if date.Before(truncateToDate(from)) || date.After(truncateToDate(to)) {
+			continue
+		}
+		bars = append(bars, Bar{Date: date, Close: *closes[i]})
+	}
+
+	return bars, nil
+}
+
+// GetDividends returns the dividends paid between from and to
+func (p *YahooProvider) GetDividends(symbol string, from, to time.Time) ([]Dividend, error) {
+	params := url.Values{
+		"interval": {"1d"},
+		"events":   {"div"},
+		"period1":  {fmt.Sprintf("%d", from.Unix())},
+		"period2":  {fmt.Sprintf("%d", to.AddDate(0, 0, 1).Unix())},
+	}
+
+	result, err := p.chart(symbol, params)
+	if err != nil {
+		return nil, err
+	}
+
+	dividends := []Dividend{}
+	for _, event := range result.Chart.Result[0].Events.Dividends {
+		date := truncateToDate(time.Unix(event.Date, 0).UTC())
+		if date.Before(truncateToDate(from)) || date.After(truncateToDate(to)) {
+			continue
+		}
+		dividends = append(dividends, Dividend{Date: date, Amount: event.Amount})
+	}
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].Date.Before(dividends[j].Date) })
+
+	return dividends, nil
+}
+
+// GetSplits returns the stock splits between from and to
+func (p *YahooProvider) GetSplits(symbol string, from, to time.Time) ([]Split, error) {
+	params := url.Values{
+		"interval": {"1d"},
+		"events":   {"split"},
+		"period1":  {fmt.Sprintf("%d", from.Unix())},
+		"period2":  {fmt.Sprintf("%d", to.AddDate(0, 0, 1).Unix())},
+	}
+
+	result, err := p.chart(symbol, params)
+	if err != nil {
+		return nil, err
+	}
+
+	splits := []Split{}
+	for _, event := range result.Chart.Result[0].Events.Splits {
+		date := truncateToDate(time.Unix(event.Date, 0).UTC())
+		if date.Before(truncateToDate(from)) || date.After(truncateToDate(to)) {
+			continue
+		}
+		if !event.Numerator.IsPositive() || !event.Denominator.IsPositive() {
+			continue
+		}
+		splits = append(splits, Split{Date: date, Numerator: event.Numerator, Denominator: event.Denominator})
+	}
+	sort.Slice(splits, func(i, j int) bool { return splits[i].Date.Before(splits[j].Date) })
+
+	return splits, nil
+}
+
+// SearchSymbols looks up symbols matching the query
+func (p *YahooProvider) SearchSymbols(query string) ([]SymbolInfo, error) {
+	endpoint := fmt.Sprintf("%s/v1/finance/search?q=%s&quotesCount=10&newsCount=0", p.baseURL, url.QueryEscape(query))
+
+	var result struct {
+		Quotes []struct {
+			Symbol    string `json:"symbol"`
+			ShortName string `json:"shortname"`
+			LongName  string `json:"longname"`
+			Exchange  string `json:"exchange"`
+			QuoteType string `json:"quoteType"`
+		} `json:"quotes"`
+	}
+	if err := fetchJSON(p.httpClient, endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	matches := make([]SymbolInfo, 0, len(result.Quotes))
+	for _, q := range result.Quotes {
+		name := q.LongName
+		if name == "" {
+			name = q.ShortName
+		}
+		matches = append(matches, SymbolInfo{
+			Symbol:   q.Symbol,
+			Name:     name,
+			Exchange: q.Exchange,
+			Type:     strings.ToLower(q.QuoteType),
+		})
+	}
+
+	return matches, nil
+}
+
+// truncateToDate drops the time of day, keeping the calendar date in UTC
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -39,17 +39,116 @@ func main() {
 
 	// Initialize market data service with database connection
 	marketDataService := services.NewMarketDataService(database.DB)
-	provider := os.Getenv("MARKET_DATA_PROVIDER")
-	if provider == "" {
-		provider = string(services.DefaultMarketDataProvider)
+	log.Printf("Market data service initialized (chain: %v, available: %v)",
+		marketDataService.Chain(""), services.RegisteredProviders())
+
+	fxService := services.NewFXService(database.DB)
+	log.Printf("Base currency: %s", fxService.BaseCurrency())
+
+	snapshotService := services.NewSnapshotService(database.DB, marketDataService, fxService)
+	ledgerService := services.NewLedgerService(database.DB)
+	incomeService := services.NewIncomeService(database.DB, ledgerService, marketDataService)
+	corporateActionService := services.NewCorporateActionService(database.DB, ledgerService, marketDataService)
+	fixedIncomeService := services.NewFixedIncomeService(database.DB)
+	debtLedgerService := services.NewDebtLedgerService(database.DB)
+	debtAccrualService := services.NewDebtAccrualService(database.DB)
+	debtRateService := services.NewDebtRateService(database.DB)
+
+	// Initialize background jobs
+	scheduler := services.NewScheduler()
+	if os.Getenv("PRICE_REFRESH_ENABLED") != "false" {
+		interval := 15 * time.Minute
+		if value := os.Getenv("PRICE_REFRESH_INTERVAL"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d > 0 {
+				interval = d
+			} else {
+				log.Printf("Invalid PRICE_REFRESH_INTERVAL %q, using %v", value, interval)
+			}
+		}
+
+		// By default only refresh while the market is open, plus once after the close
+		due := services.MarketHoursDue
+		if os.Getenv("PRICE_REFRESH_MARKET_HOURS_ONLY") == "false" {
+			due = nil
+		}
+
+		scheduler.Register(services.PriceRefreshJobName, interval, due, func() (interface{}, error) {
+			return marketDataService.RefreshAssetPrices()
+		})
+		// Crypto trades around the clock, so its refresh ignores market hours
+		scheduler.Register(services.CryptoPriceRefreshJobName, interval, nil, func() (interface{}, error) {
+			return marketDataService.RefreshCryptoPrices()
+		})
+		// Funds are priced once a day at NAV, so they are fetched once it is published
+		scheduler.Register(services.NAVRefreshJobName, interval, services.NAVDue, func() (interface{}, error) {
+			return marketDataService.RefreshFundPrices()
+		})
+		log.Printf("Price refresh scheduled every %v", interval)
+	}
+
+	// Fill gaps in stock price history daily (idempotent, existing rows are kept)
+	scheduler.Register(services.HistoryBackfillJobName, 24*time.Hour, nil, func() (interface{}, error) {
+		return marketDataService.BackfillAllAssetHistory()
+	})
+
+	// Keep historical exchange rates for every held currency up to date
+	scheduler.Register(services.FXBackfillJobName, 24*time.Hour, nil, func() (interface{}, error) {
+		return fxService.BackfillAllRates()
+	})
+
+	// Record the day's accrued value of bonds and CDs
+	scheduler.Register(services.FixedIncomeJobName, 24*time.Hour, nil, func() (interface{}, error) {
+		return fixedIncomeService.RevalueAll()
+	})
+
+	// Record the day's accrued interest on debts
+	scheduler.Register(services.DebtAccrualJobName, 24*time.Hour, nil, func() (interface{}, error) {
+		return debtAccrualService.AccrueAll()
+	})
+
+	// Apply new stock splits to market-priced holdings (splits already recorded are skipped)
+	scheduler.Register(services.CorporateActionSyncJobName, 24*time.Hour, nil, func() (interface{}, error) {
+		return corporateActionService.SyncAllSplits()
+	})
+
+	// Import new dividends for market-priced holdings (dates already paid are skipped)
+	scheduler.Register(services.DividendSyncJobName, 24*time.Hour, nil, func() (interface{}, error) {
+		// Splits first, so dividends before a split are paid on the shares held at the time
+		if _, err := corporateActionService.SyncAllSplits(); err != nil {
+			log.Printf("Split sync before dividend sync failed: %v", err)
+		}
+		return incomeService.SyncAllDividends()
+	})
+
+	// Record today's net worth; later runs on the same day replace the snapshot
+	snapshotInterval := time.Hour
+	if value := os.Getenv("NETWORTH_SNAPSHOT_INTERVAL"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			snapshotInterval = d
+		} else {
+			log.Printf("Invalid NETWORTH_SNAPSHOT_INTERVAL %q, using %v", value, snapshotInterval)
+		}
 	}
-	log.Printf("Market data service initialized (provider: %s)", provider)
+	scheduler.Register(services.NetWorthSnapshotJobName, snapshotInterval, nil, func() (interface{}, error) {
+		return snapshotService.TakeSnapshot()
+	})
+	scheduler.Start()
+	defer scheduler.Stop()
 
 	// Initialize handlers
-	assetHandler := handlers.NewAssetHandler(database, marketDataService)
-	debtHandler := handlers.NewDebtHandler(database)
-	summaryHandler := handlers.NewSummaryHandler(database, marketDataService)
-	exportHandler := handlers.NewExportHandler(database)
+	assetHandler := handlers.NewAssetHandler(database, marketDataService, fxService, ledgerService)
+	transactionHandler := handlers.NewTransactionHandler(ledgerService)
+	gainsHandler := handlers.NewGainsHandler(ledgerService, fxService)
+	incomeHandler := handlers.NewIncomeHandler(incomeService, fxService)
+	corporateActionHandler := handlers.NewCorporateActionHandler(corporateActionService)
+	debtHandler := handlers.NewDebtHandler(database, fxService)
+	debtPaymentHandler := handlers.NewDebtPaymentHandler(debtLedgerService)
+	debtRateHandler := handlers.NewDebtRateHandler(debtRateService)
+	summaryHandler := handlers.NewSummaryHandler(database, marketDataService, snapshotService, fxService, incomeService)
+	exportHandler := handlers.NewExportHandler(database, fxService, ledgerService)
+	marketDataHandler := handlers.NewMarketDataHandler(marketDataService)
+	fxHandler := handlers.NewFXHandler(fxService)
+	adminHandler := handlers.NewAdminHandler(scheduler)
 
 	// Setup router
 	r := chi.NewRouter()
@@ -88,27 +187,87 @@ func main() {
 			r.Put("/{id}", assetHandler.UpdateAsset)
 			r.Delete("/{id}", assetHandler.DeleteAsset)
 			r.Get("/{id}/history", assetHandler.GetAssetHistory)
+			r.Post("/{id}/history/backfill", assetHandler.BackfillAssetHistory)
+			r.Get("/{id}/position", transactionHandler.GetPosition)
+			r.Get("/{id}/transactions", transactionHandler.ListTransactions)
+			r.Post("/{id}/transactions", transactionHandler.CreateTransaction)
+			r.Get("/{id}/transactions/{txID}", transactionHandler.GetTransaction)
+			r.Put("/{id}/transactions/{txID}", transactionHandler.UpdateTransaction)
+			r.Delete("/{id}/transactions/{txID}", transactionHandler.DeleteTransaction)
+			r.Post("/{id}/income/sync", incomeHandler.SyncDividends)
+			r.Get("/{id}/corporate-actions", corporateActionHandler.ListCorporateActions)
+			r.Post("/{id}/corporate-actions", corporateActionHandler.CreateCorporateAction)
+			r.Post("/{id}/corporate-actions/sync", corporateActionHandler.SyncSplits)
+			r.Delete("/{id}/corporate-actions/{actionID}", corporateActionHandler.DeleteCorporateAction)
 		})
 
+		// Realized gains and income
+		r.Get("/gains", gainsHandler.GetGains)
+		r.Get("/income", incomeHandler.GetIncome)
+
 		// Debts
 		r.Route("/debts", func(r chi.Router) {
 			r.Post("/", debtHandler.CreateDebt)
 			r.Get("/", debtHandler.ListDebts)
+			r.Post("/payoff-plan", debtHandler.PlanPayoff)
 			r.Get("/{id}", debtHandler.GetDebt)
 			r.Put("/{id}", debtHandler.UpdateDebt)
 			r.Delete("/{id}", debtHandler.DeleteDebt)
+			r.Get("/{id}/schedule", debtHandler.GetSchedule)
+			r.Get("/{id}/history", debtHandler.GetDebtHistory)
+			r.Get("/{id}/payments", debtPaymentHandler.ListPayments)
+			r.Post("/{id}/payments", debtPaymentHandler.CreatePayment)
+			r.Get("/{id}/payments/{paymentID}", debtPaymentHandler.GetPayment)
+			r.Put("/{id}/payments/{paymentID}", debtPaymentHandler.UpdatePayment)
+			r.Delete("/{id}/payments/{paymentID}", debtPaymentHandler.DeletePayment)
+			r.Get("/{id}/rates", debtRateHandler.ListRates)
+			r.Post("/{id}/rates", debtRateHandler.CreateRate)
+			r.Get("/{id}/rates/{rateID}", debtRateHandler.GetRate)
+			r.Put("/{id}/rates/{rateID}", debtRateHandler.UpdateRate)
+			r.Delete("/{id}/rates/{rateID}", debtRateHandler.DeleteRate)
+		})
+
+		// Rate indexes variable-rate debts follow
+		r.Route("/rate-indexes", func(r chi.Router) {
+			r.Get("/", debtRateHandler.ListRateIndexes)
+			r.Get("/{name}", debtRateHandler.GetRateIndex)
+			r.Post("/{name}", debtRateHandler.SetRateIndexValue)
+			r.Delete("/{name}/{date}", debtRateHandler.DeleteRateIndexValue)
 		})
 
 		// Summary
 		r.Get("/networth", summaryHandler.GetNetWorth)
+		r.Get("/networth/history", summaryHandler.GetNetWorthHistory)
+		r.Post("/networth/snapshots", summaryHandler.CreateNetWorthSnapshot)
 		r.Get("/summary", summaryHandler.GetSummary)
 
+		// Market data
+		r.Route("/market-data", func(r chi.Router) {
+			r.Get("/providers", marketDataHandler.GetProviders)
+		})
+		r.Get("/symbols/search", marketDataHandler.SearchSymbols)
+
+		// Exchange rates
+		r.Route("/fx", func(r chi.Router) {
+			r.Get("/rate", fxHandler.GetRate)
+			r.Get("/rates", fxHandler.ListRates)
+			r.Post("/rates/backfill", fxHandler.BackfillRates)
+			r.Post("/rates/import", fxHandler.ImportRates)
+		})
+
+		// Admin
+		r.Route("/admin", func(r chi.Router) {
+			r.Get("/jobs", adminHandler.ListJobs)
+			r.Post("/jobs/{name}/run", adminHandler.RunJob)
+		})
+
 		// Export endpoints
 		r.Route("/export", func(r chi.Router) {
 			r.Get("/assets/json", exportHandler.ExportAssetsJSON)
 			r.Get("/assets/csv", exportHandler.ExportAssetsCSV)
 			r.Get("/debts/json", exportHandler.ExportDebtsJSON)
 			r.Get("/debts/csv", exportHandler.ExportDebtsCSV)
+			r.Get("/gains/csv", exportHandler.ExportGainsCSV)
 			r.Get("/all", exportHandler.ExportAll)
 		})
 
@@ -118,6 +277,7 @@ func main() {
 			r.Post("/assets/csv", exportHandler.ImportAssetsCSV)
 			r.Post("/debts/json", exportHandler.ImportDebtsJSON)
 			r.Post("/debts/csv", exportHandler.ImportDebtsCSV)
+			r.Post("/all", exportHandler.ImportAll)
 		})
 	})
 